@@ -1,23 +1,33 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"indicator-stickynotes/stickynotes"
 
 	"github.com/dawidd6/go-appindicator"
 	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
 
-// embeddedResourceGetter implements stickynotes.ResourceGetter interface
-type embeddedResourceGetter struct{}
+// embeddedResourceGetter implements stickynotes.ResourceGetter and stickynotes.ResourceCache
+type embeddedResourceGetter struct {
+	cacheDir string
+	onChange func() // called after the cache was cleared or re-extracted
+}
 
 func (g *embeddedResourceGetter) GetEmbeddedUI(filename string) (string, error) {
 	return GetEmbeddedUI(filename)
@@ -31,40 +41,195 @@ func (g *embeddedResourceGetter) GetEmbeddedIcon(iconPath string) ([]byte, error
 	return GetEmbeddedIcon(iconPath)
 }
 
+func (g *embeddedResourceGetter) CachePath() string {
+	return g.cacheDir
+}
+
+func (g *embeddedResourceGetter) ClearCache() error {
+	if err := os.RemoveAll(g.cacheDir); err != nil {
+		return err
+	}
+	g.changed()
+	return nil
+}
+
+func (g *embeddedResourceGetter) ExtractResources() error {
+	hash, err := embeddedResourcesHash()
+	if err != nil {
+		return err
+	}
+	if err := extractEmbeddedResources(g.cacheDir, hash); err != nil {
+		return err
+	}
+	g.changed()
+	return nil
+}
+
+func (g *embeddedResourceGetter) changed() {
+	if g.onChange != nil {
+		g.onChange()
+	}
+}
+
+// resourceCacheDir returns where the embedded resources are extracted.
+// It follows XDG_CACHE_HOME, so portable mode keeps it next to the data file.
+func resourceCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "postnote", "resources")
+}
+
 // IndicatorStickyNotes manages the system tray indicator
 type IndicatorStickyNotes struct {
-	Args      *Args
-	DataFile  string
-	NoteSet   *stickynotes.NoteSet
-	Indicator *appindicator.Indicator
-	Menu      *gtk.Menu
+	Args        *Args
+	DataFile    string
+	Resources   *embeddedResourceGetter
+	NoteSet     *stickynotes.NoteSet
+	Indicator   *appindicator.Indicator
+	Menu        *gtk.Menu
+	TagsItem    *gtk.MenuItem
+	PeekItem    *gtk.MenuItem
+	OverdueItem *gtk.MenuItem
+	CatsItem    *gtk.MenuItem
+
+	hiddenByLock     bool                // Notes were hidden because the session locked
+	restoreAfterLock bool                // Notes were visible before the session locked
+	importBackup     *stickynotes.Backup // Taken before the last import, for "Undo Import"
 }
 
 type Args struct {
-	Dev bool
+	Dev         bool
+	Profile     string
+	Portable    bool
+	PortableDir string
+	Check       bool
+	Repair      bool
+	Append      string
+	Note        string
+	ForceX11    bool
+	SafeMode    bool
+	NoCrash     bool
+	Glance      bool
+	ReadOnly    bool
+	InUse       bool // Another instance holds the data file, see ReadOnly
 }
 
 func main() {
-	// Initialize GTK
-	gtk.Init(nil)
-
-	// Set up embedded resource getter for stickynotes package
-	// This allows stickynotes to access embedded resources without importing main
-	stickynotes.SetResourceGetter(&embeddedResourceGetter{})
-
 	// Parse arguments
 	args := &Args{}
-	flag.BoolVar(&args.Dev, "d", false, "use the development data file")
+	flag.BoolVar(&args.Dev, "d", false, "use the development data file (same as -profile dev)")
+	flag.StringVar(&args.Profile, "profile", stickynotes.DefaultProfile, "use the notes of the profile `name`, e.g. work")
+	flag.BoolVar(&args.Portable, "portable", false, "keep data, settings and cache next to the executable")
+	flag.StringVar(&args.PortableDir, "portable-dir", "", "keep data, settings and cache in `dir` (implies -portable)")
+	flag.BoolVar(&args.Check, "check", false, "check the data file for problems, asking before repairing each one")
+	flag.BoolVar(&args.Repair, "repair", false, "with -check, repair all problems without asking")
+	flag.StringVar(&args.Append, "append", "", "append `text` to a note and exit (- reads standard input)")
+	flag.StringVar(&args.Note, "note", stickynotes.DefaultInboxNote, "with -append, the `uuid or title` of the note, created if missing")
+	flag.BoolVar(&args.ForceX11, "force-x11", false, "use the X11 backend (XWayland on Wayland) for native window positioning")
+	flag.BoolVar(&args.Glance, "glance", false, "show or hide the glance of pinned notes and reminders of the running instance, e.g. from a keyboard shortcut")
+	flag.BoolVar(&args.NoCrash, "no-crash-handler", false, "run without the crash handler, e.g. in a debugger")
+	flag.BoolVar(&args.ReadOnly, "read-only", false, "open the notes without saving changes to them")
+	flag.BoolVar(&args.SafeMode, "safe-mode", false, "start with all notes hidden, without the window-calls extension, custom colors and fonts, or syncing the data file")
 	flag.Parse()
 
-	// Determine data file
-	dataFile := stickynotes.SettingsFile
-	if args.Dev {
-		dataFile = stickynotes.DebugSettingsFile
+	if args.Dev && args.Profile == stickynotes.DefaultProfile {
+		args.Profile = stickynotes.DevProfile
+	}
+	if args.Profile != stickynotes.DefaultProfile && !stickynotes.ValidProfileName(args.Profile) {
+		fmt.Fprintf(os.Stderr, "Invalid profile name %q: use letters, digits, - and _\n", args.Profile)
+		os.Exit(2)
+	}
+
+	// Portable mode must be set up before GTK starts, so GTK's own files follow it too
+	if args.Portable || args.PortableDir != "" {
+		dir, err := setupPortable(args.PortableDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting up portable mode: %v\n", err)
+			os.Exit(1)
+		}
+		args.PortableDir = dir
+	}
+
+	// Determine data file, moving it from where older versions kept it
+	dataFile, err := stickynotes.MigrateDataFile(args.Profile, args.PortableDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error moving the data file to %s, still using %s: %v\n",
+			stickynotes.ResolvePath(stickynotes.ProfileDataFile(args.Profile)), dataFile, err)
+	}
+
+	// Check the data file and exit, without starting the GUI
+	if args.Check {
+		os.Exit(checkDataFile(dataFile, args.Repair))
 	}
 
+	// Append to a note and exit, through the running instance if there is one
+	if args.Append != "" {
+		os.Exit(appendToNote(dataFile, args.Note, args.Append))
+	}
+
+	// Toggle the glance of the running instance and exit
+	if args.Glance {
+		if err := stickynotes.CallActivateAction("glance", ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Error showing the glance: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Run the application in a child process that is restarted after a crash
+	if !args.NoCrash && !stickynotes.Supervised() {
+		if code := stickynotes.Supervise(os.Args[1:], dataFile); code >= 0 {
+			os.Exit(code)
+		}
+	}
+
+	// One instance writes the data file: starting another one brings up the running
+	// one's notes, or opens them read-only when it can't be reached
+	if !args.ReadOnly {
+		if err := stickynotes.LockDataFile(dataFile); errors.Is(err, stickynotes.ErrDataFileLocked) {
+			if err := stickynotes.CallActivateAction("show-all", ""); err == nil {
+				os.Exit(0)
+			}
+			fmt.Printf("[Lock] %s is in use by another instance, opening it read-only\n", dataFile)
+			args.ReadOnly, args.InUse = true, true
+		} else if err != nil {
+			fmt.Printf("[Lock] Failed to lock %s: %v\n", dataFile, err)
+		}
+	}
+
+	// The X11 backend must be chosen before GTK starts. Without the flag, the choice
+	// saved in settings applies.
+	if args.ForceX11 || savedForceX11(dataFile) {
+		os.Setenv("GDK_BACKEND", "x11")
+	}
+
+	// Initialize GTK. The program class becomes the Wayland app id and the X11 WM_CLASS
+	// of every window; GTK would otherwise derive it from the binary name.
+	glib.SetPrgname(stickynotes.AppID)
+	glib.SetApplicationName("PostNote")
+	gtkArgs := []string{os.Args[0], "--class=" + stickynotes.AppID}
+	gtk.Init(&gtkArgs)
+
+	// Safe mode must be on before the notes are loaded
+	if args.SafeMode {
+		stickynotes.EnableSafeMode()
+	}
+
+	// Set up embedded resource getter for stickynotes package
+	// This allows stickynotes to access embedded resources without importing main
+	resources := &embeddedResourceGetter{cacheDir: resourceCacheDir()}
+	// Extract the resources again after upgrades, when the cache no longer matches this build
+	if extracted, err := initEmbeddedResources(resources.cacheDir); err != nil {
+		fmt.Printf("[Resources] Failed to prepare resource cache: %v\n", err)
+	} else if extracted {
+		fmt.Printf("[Resources] Extracted resources to %s\n", resources.cacheDir)
+	}
+	stickynotes.SetResourceGetter(resources)
+
 	// Create indicator
-	indicator := NewIndicatorStickyNotes(args, dataFile)
+	indicator := NewIndicatorStickyNotes(args, dataFile, resources)
 
 	// Load global CSS
 	stickynotes.LoadGlobalCSS()
@@ -81,21 +246,177 @@ func main() {
 	// Run GTK main loop
 	gtk.Main()
 
-	// Final save
+	// Final save, including a deletion that could still have been undone
+	stickynotes.FinishPendingDelete()
 	indicator.Save()
+	if err := indicator.NoteSet.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving the notes: %v\n", err)
+	}
 }
 
-func NewIndicatorStickyNotes(args *Args, dataFile string) *IndicatorStickyNotes {
+// setupPortable prepares portable mode in dir, or next to the executable when dir is empty.
+// When running from an AppImage the executable lives on a read-only mount, so the
+// directory of the .AppImage file is used instead. The XDG directories are pointed
+// inside the portable directory so GTK doesn't write to the home directory either.
+func setupPortable(dir string) (string, error) {
+	if dir == "" {
+		exe := os.Getenv("APPIMAGE")
+		if exe == "" {
+			var err error
+			if exe, err = os.Executable(); err != nil {
+				return "", err
+			}
+		}
+		dir = filepath.Dir(exe)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	xdgDirs := map[string]string{
+		"XDG_CONFIG_HOME": "config",
+		"XDG_DATA_HOME":   "data",
+		"XDG_CACHE_HOME":  "cache",
+		"XDG_STATE_HOME":  "state",
+	}
+	for env, sub := range xdgDirs {
+		path := filepath.Join(dir, sub)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return "", err
+		}
+		os.Setenv(env, path)
+	}
+	return dir, nil
+}
+
+// savedForceX11 reads the "force_x11" setting from the data file, before GTK is initialized
+func savedForceX11(dataFile string) bool {
+	noteset := stickynotes.NewNoteSet(dataFile, nil)
+	if err := noteset.Open(); err != nil {
+		return false
+	}
+	forceX11, _ := noteset.Properties["force_x11"].(bool)
+	return forceX11
+}
+
+// checkDataFile reports the problems in the data file and repairs them, asking for each
+// one unless repair is set. The original file is kept as <file>.bak before saving repairs.
+// Returns the process exit code: 0 when the file is (now) consistent, 1 otherwise.
+func checkDataFile(dataFile string, repair bool) int {
+	noteset := stickynotes.NewNoteSet(dataFile, nil)
+	if err := noteset.Open(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading data file %s: %v\n", noteset.DataPath(), err)
+		return 1
+	}
+	if noteset.Recovered != nil {
+		fmt.Printf("%v\n", noteset.Recovered)
+	}
+
+	problems := noteset.Check()
+	if len(problems) == 0 {
+		fmt.Printf("%s: no problems found\n", noteset.DataPath())
+		return 0
+	}
+	if err := stickynotes.LockDataFile(dataFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't repair data file %s: %v. Quit PostNote first.\n", noteset.DataPath(), err)
+		return 1
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	repaired := 0
+	for _, p := range problems {
+		fmt.Printf("- %s\n", p.Message)
+		if !repair {
+			fmt.Print("  Repair? [y/N] ")
+			answer, _ := stdin.ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+				continue
+			}
+		}
+		noteset.Repair(p)
+		repaired++
+	}
+
+	if repaired > 0 {
+		// Saving keeps the previous version as <file>.bak
+		if err := noteset.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving data file %s: %v\n", noteset.DataPath(), err)
+			return 1
+		}
+		fmt.Printf("Repaired %d of %d problems (backup saved to %s.bak)\n", repaired, len(problems), noteset.DataPath())
+	}
+	if repaired < len(problems) {
+		return 1
+	}
+	return 0
+}
+
+// appendToNote appends text to the note named by target. The running instance does it
+// over D-Bus, otherwise the data file is updated directly. Returns the process exit code.
+func appendToNote(dataFile, target, text string) int {
+	if text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading standard input: %v\n", err)
+			return 1
+		}
+		text = string(data)
+	}
+
+	if stickynotes.ServiceRunning() {
+		if _, err := stickynotes.CallAppendToNote(target, text); err != nil {
+			fmt.Fprintf(os.Stderr, "Error appending to note: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if err := stickynotes.LockDataFile(dataFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error appending to note: %v\n", err)
+		return 1
+	}
+	noteset := stickynotes.NewNoteSet(dataFile, nil)
+	if err := noteset.Open(); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error reading data file %s: %v\n", noteset.DataPath(), err)
+		return 1
+	}
+	noteset.AppendToNote(target, text)
+	if err := noteset.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving data file %s: %v\n", noteset.DataPath(), err)
+		return 1
+	}
+	return 0
+}
+
+func NewIndicatorStickyNotes(args *Args, dataFile string, resources *embeddedResourceGetter) *IndicatorStickyNotes {
 	ind := &IndicatorStickyNotes{
-		Args:     args,
-		DataFile: dataFile,
+		Args:      args,
+		DataFile:  dataFile,
+		Resources: resources,
 	}
 
 	// Initialize NoteSet
 	ind.NoteSet = stickynotes.NewNoteSet(dataFile, ind)
-
-	// Try to open existing data
-	if err := ind.NoteSet.Open(); err != nil {
+	ind.NoteSet.SetReadOnly(args.ReadOnly)
+	ind.NoteSet.NotesChanged = ind.RefreshNotesMenu
+
+	// Try to open existing data, asking for the passphrase of an encrypted data file
+	err := ind.NoteSet.Open()
+	for errors.Is(err, stickynotes.ErrPassphraseRequired) || errors.Is(err, stickynotes.ErrWrongPassphrase) {
+		message := "Enter the passphrase of your notes."
+		if errors.Is(err, stickynotes.ErrWrongPassphrase) {
+			message = "Wrong passphrase. Enter the passphrase of your notes."
+		}
+		passphrase, _, ok := stickynotes.AskPassphrase(nil, message, false)
+		if !ok {
+			// Starting without the notes would overwrite them
+			os.Exit(0)
+		}
+		ind.NoteSet.SetPassphrase(passphrase)
+		err = ind.NoteSet.Open()
+	}
+	if err != nil {
 		if os.IsNotExist(err) {
 			ind.NoteSet.LoadFresh()
 		} else {
@@ -111,10 +432,74 @@ func NewIndicatorStickyNotes(args *Args, dataFile string) *IndicatorStickyNotes
 			}
 			ind.NoteSet.LoadFresh()
 		}
+	} else if ind.NoteSet.Recovered != nil {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_WARNING, gtk.BUTTONS_OK, "The data file was damaged. Your notes were restored from its backup.")
+		dialog.FormatSecondaryText("%s", ind.NoteSet.Recovered.Error())
+		dialog.Run()
+		dialog.Destroy()
+		ind.NoteSet.Save()
+	}
+	if args.InUse {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_WARNING, gtk.BUTTONS_OK, "Your notes are open read-only.")
+		dialog.FormatSecondaryText("Another PostNote is running with them and couldn't be reached. Changes made here are not saved; quit the other one to edit them here.")
+		dialog.Run()
+		dialog.Destroy()
 	}
 
-	// Show all notes if they were visible previously
-	if allVisible, ok := ind.NoteSet.Properties["all_visible"].(bool); ok && allVisible {
+	// Write the data file without holding up the windows from now on
+	ind.NoteSet.StartBackgroundSave()
+
+	// Move expired notes to the trash, before they are shown
+	if expired := ind.NoteSet.ExpireNotes(); expired > 0 {
+		fmt.Printf("[Expiry] Moved %d expired notes to the trash\n", expired)
+	}
+
+	// Drop notes that have been in the trash for too long
+	if purged := ind.NoteSet.PurgeTrash(); purged > 0 {
+		fmt.Printf("[Trash] Purged %d notes from the trash\n", purged)
+		ind.NoteSet.Save()
+	}
+
+	// Let scripts append to notes through the running instance
+	if err := stickynotes.ExportService(ind.NoteSet); err != nil {
+		fmt.Printf("[Service] Failed to export D-Bus service: %v\n", err)
+	}
+
+	// Notify when note reminders are due
+	if err := stickynotes.StartReminders(ind.NoteSet); err != nil {
+		fmt.Printf("[Reminders] Not scheduling reminders: %v\n", err)
+	}
+
+	// Keep the due date borders and countdowns current
+	stickynotes.WatchDueDates(ind.NoteSet)
+
+	// Move notes to the trash as they expire
+	stickynotes.WatchExpiry(ind.NoteSet)
+
+	// Snapshot the notes daily
+	stickynotes.WatchBackups(ind.NoteSet)
+
+	// Summarize the week's notes when a digest is set up
+	if !args.ReadOnly {
+		stickynotes.WatchDigest(ind.NoteSet)
+	}
+
+	// Fade or badge notes as they go untouched
+	stickynotes.WatchAging(ind.NoteSet)
+
+	// Hide notes while a fullscreen application has the focus (if enabled in settings)
+	stickynotes.WatchFullscreen(ind.NoteSet)
+
+	// Pick up edits made on other devices sharing the data file
+	if !args.SafeMode && !args.ReadOnly {
+		stickynotes.WatchDataFile(ind.NoteSet)
+		stickynotes.WatchSyncConflicts(ind.NoteSet)
+		stickynotes.StartRemoteSync(ind.NoteSet)
+		stickynotes.StartWebDAVSync(ind.NoteSet)
+	}
+
+	// Show all notes if they were visible previously (safe mode starts with them hidden)
+	if allVisible, ok := ind.NoteSet.Properties["all_visible"].(bool); ok && allVisible && !args.SafeMode {
 		ind.NoteSet.ShowAll()
 		// Note: Window IDs are automatically assigned by the 300ms timeout in buildNote()
 		// No need for a separate AssignWindowIDs() call here
@@ -131,6 +516,11 @@ func NewIndicatorStickyNotes(args *Args, dataFile string) *IndicatorStickyNotes
 	// Create AppIndicator
 	ind.createIndicator()
 
+	// Hide notes while the session is locked (if enabled in settings)
+	if err := stickynotes.WatchSessionLock(ind.onSessionLocked, ind.onSessionUnlocked); err != nil {
+		fmt.Printf("[SessionLock] Not watching session lock: %v\n", err)
+	}
+
 	return ind
 }
 
@@ -149,20 +539,9 @@ func (ind *IndicatorStickyNotes) createIndicator() {
 	// Create AppIndicator
 	ind.Indicator = appindicator.New("indicator-stickynotes", "indicator-stickynotes-mono", appindicator.CategoryApplicationStatus)
 
-	// AppIndicator requires a file system path for icons, so we need to extract the indicator icon
-	// to a temporary location. Try embedded first, then fallback to file system.
-	iconPath := ind.getIndicatorIconPath()
-	if iconPath != "" {
-		// Extract base name without extension for SetIcon
-		baseName := strings.TrimSuffix(filepath.Base(iconPath), filepath.Ext(iconPath))
-		ind.Indicator.SetIconThemePath(filepath.Dir(iconPath))
-		ind.Indicator.SetIcon(baseName)
-	} else {
-		// Fallback to file system path
-		fsIconPath := filepath.Join(stickynotes.GetBasePath(), "Icons")
-		ind.Indicator.SetIconThemePath(fsIconPath)
-		ind.Indicator.SetIcon("indicator-stickynotes-mono")
-	}
+	ind.loadIndicatorIcon()
+	// The icon file lives in the resource cache, write it again when the cache changes
+	ind.Resources.onChange = ind.loadIndicatorIcon
 
 	ind.Indicator.SetStatus(appindicator.StatusActive)
 	ind.Indicator.SetTitle("Sticky Notes")
@@ -177,7 +556,25 @@ func (ind *IndicatorStickyNotes) createIndicator() {
 	ind.connectSecondaryActivate()
 }
 
-// getIndicatorIconPath extracts the indicator icon to a temporary directory and returns the path.
+// loadIndicatorIcon sets the indicator icon.
+// AppIndicator requires a file system path for icons, so we need to extract the indicator icon
+// to the resource cache. Try embedded first, then fallback to file system.
+func (ind *IndicatorStickyNotes) loadIndicatorIcon() {
+	iconPath := ind.getIndicatorIconPath()
+	if iconPath != "" {
+		// Extract base name without extension for SetIcon
+		baseName := strings.TrimSuffix(filepath.Base(iconPath), filepath.Ext(iconPath))
+		ind.Indicator.SetIconThemePath(filepath.Dir(iconPath))
+		ind.Indicator.SetIcon(baseName)
+	} else {
+		// Fallback to file system path
+		fsIconPath := filepath.Join(stickynotes.GetBasePath(), "Icons")
+		ind.Indicator.SetIconThemePath(fsIconPath)
+		ind.Indicator.SetIcon("indicator-stickynotes-mono")
+	}
+}
+
+// getIndicatorIconPath extracts the indicator icon to the resource cache and returns the path.
 // Returns empty string if extraction fails (will fallback to file system).
 func (ind *IndicatorStickyNotes) getIndicatorIconPath() string {
 	// Try different icon name variations (AppIndicator expects "indicator-stickynotes-mono")
@@ -214,9 +611,10 @@ func (ind *IndicatorStickyNotes) getIndicatorIconPath() string {
 		return ""
 	}
 
-	// Create temp directory for indicator icon
-	tmpDir, err := os.MkdirTemp("", "postnote-icon-*")
-	if err != nil {
+	// The indicator icon has its own directory, which becomes the icon theme path
+	// (the resource cache itself follows XDG_CACHE_HOME, so portable mode is covered)
+	iconDir := filepath.Join(ind.Resources.CachePath(), "indicator")
+	if err := os.MkdirAll(iconDir, 0755); err != nil {
 		return ""
 	}
 
@@ -227,9 +625,8 @@ func (ind *IndicatorStickyNotes) getIndicatorIconPath() string {
 		ext = ".png"
 	}
 
-	iconPath := filepath.Join(tmpDir, "indicator-stickynotes-mono"+ext)
+	iconPath := filepath.Join(iconDir, "indicator-stickynotes-mono"+ext)
 	if err := os.WriteFile(iconPath, iconData, 0644); err != nil {
-		os.RemoveAll(tmpDir)
 		return ""
 	}
 
@@ -266,96 +663,167 @@ func (ind *IndicatorStickyNotes) connectSecondaryActivate() {
 	}
 }
 
-func (ind *IndicatorStickyNotes) createMenu() {
-	ind.Menu, _ = gtk.MenuNew()
+// registerActions registers the application-wide actions, used by the menu and the
+// command palette
+func (ind *IndicatorStickyNotes) registerActions() {
+	for _, action := range []*stickynotes.Action{
+		{ID: "new-note", Label: "New Note", Keywords: "create add", Run: ind.NewNote},
+		{ID: "show-all", Label: "Show All", Keywords: "notes", Run: ind.ShowAll},
+		{ID: "hide-all", Label: "Hide All", Keywords: "notes", Run: ind.HideAll},
+		{ID: "desktop-widgets", Label: "Desktop Widgets", Keywords: "keep below background taskbar",
+			Run:     func() { ind.NoteSet.SetDesktopWidgets(!ind.NoteSet.DesktopWidgets()) },
+			Checked: func(*stickynotes.StickyNote) bool { return ind.NoteSet.DesktopWidgets() }},
+		{ID: "arrange-cascade", Label: "Arrange: Cascade", MenuLabel: "Cascade", Run: func() {
+			ind.NoteSet.Arrange(stickynotes.ArrangeCascade)
+		}},
+		{ID: "arrange-tile", Label: "Arrange: Tile", MenuLabel: "Tile", Run: func() {
+			ind.NoteSet.Arrange(stickynotes.ArrangeTile)
+		}},
+		{ID: "arrange-stack", Label: "Arrange: Stack", MenuLabel: "Stack", Run: func() {
+			ind.NoteSet.Arrange(stickynotes.ArrangeStack)
+		}},
+		{ID: "bring-on-screen", Label: "Bring All Notes on Screen", Keywords: "off-screen lost missing monitor resolution recover",
+			Run: ind.BringOnScreen},
+		{ID: "all-notes", Label: "All Notes…", Keywords: "list", Run: ind.ShowNoteList},
+		{ID: "search", Label: "Search Notes...", Keywords: "find", Run: ind.ShowSearch},
+		{ID: "glance", Label: "Glance", Keywords: "pinned reminders overview summary", Accels: []string{"<Control><Shift>g"},
+			Run: func() { stickynotes.ToggleGlance(ind.NoteSet) }},
+		{ID: "lock-all", Label: "Lock All", Run: ind.LockAll},
+		{ID: "unlock-all", Label: "Unlock All", Run: ind.UnlockAll},
+		{ID: "mute-sounds", Label: "Mute Sounds", Keywords: "feedback quiet",
+			Run:     func() { ind.NoteSet.SetFeedbackMuted(!ind.feedbackMuted()) },
+			Checked: func(*stickynotes.StickyNote) bool { return ind.feedbackMuted() }},
+		{ID: "trash", Label: "Trash...", Keywords: "deleted restore", Run: ind.ShowTrash},
+		{ID: "deletion-history", Label: "Deletion History…", Keywords: "audit log deleted merged lost missing", Run: ind.ShowDeletionHistory},
+		{ID: "paste-note", Label: "Paste Note", Keywords: "clipboard json", Run: ind.PasteNote},
+		{ID: "export", Label: "Export Data", Keywords: "save file", Run: ind.ExportDataFile},
+		{ID: "export-markdown", Label: "Export as Markdown…", Keywords: "save folder obsidian files", Run: ind.ExportMarkdown},
+		{ID: "export-python", Label: "Export for Python indicator-stickynotes…", Keywords: "save file original legacy", Run: ind.ExportPython},
+		{ID: "digest", Label: "Weekly Digest Now…", Keywords: "summary week email export", Run: ind.SendDigest},
+		{ID: "import", Label: "Import Data", Keywords: "open file joplin markdown", Run: ind.ImportDataFile},
+		{ID: "undo-import", Label: "Undo Import", Keywords: "revert", Run: ind.UndoImport},
+		{ID: "check", Label: "Check Data", Keywords: "repair", Run: ind.CheckData},
+		{ID: "backups", Label: "Restore from Backup…", Run: ind.ShowBackups},
+		{ID: "sync-now", Label: "Sync Now", Keywords: "nextcloud webdav server upload download", Run: ind.SyncNow},
+		{ID: "new-profile", Label: "New Profile…", Keywords: "work personal data file", Run: ind.NewProfile},
+		{ID: "about", Label: "About", Run: ind.ShowAbout},
+		{ID: "statistics", Label: "Statistics", Run: ind.ShowStatistics},
+		{ID: "settings", Label: "Settings", Keywords: "preferences options", Run: ind.ShowSettings},
+		{ID: "quit", Label: "Quit", Keywords: "exit", Run: func() {
+			ind.Save()
+			gtk.MainQuit()
+		}},
+	} {
+		ind.NoteSet.RegisterAction(action)
+	}
+	// Until something is imported
+	ind.NoteSet.SetActionEnabled("undo-import", false)
+	// Until a server is set up in the settings
+	ind.NoteSet.SetActionEnabled("sync-now", ind.NoteSet.SyncEnabled())
+}
 
-	// New Note
-	mNewNote, _ := gtk.MenuItemNewWithLabel("New Note")
-	mNewNote.Connect("activate", ind.NewNote)
-	ind.Menu.Append(mNewNote)
-	mNewNote.Show()
+// appendAction appends the item of a registered action to menu
+func (ind *IndicatorStickyNotes) appendAction(menu *gtk.Menu, id string) {
+	item := ind.NoteSet.ActionMenuItem(id, nil)
+	menu.Append(item)
+	item.Show()
+}
 
-	// Separator
+// appendSeparator appends a separator to menu
+func appendSeparator(menu *gtk.Menu) {
 	sep, _ := gtk.SeparatorMenuItemNew()
-	ind.Menu.Append(sep)
-	sep.Show()
-
-	// Show All
-	mShowAll, _ := gtk.MenuItemNewWithLabel("Show All")
-	mShowAll.Connect("activate", ind.ShowAll)
-	ind.Menu.Append(mShowAll)
-	mShowAll.Show()
-
-	// Hide All
-	mHideAll, _ := gtk.MenuItemNewWithLabel("Hide All")
-	mHideAll.Connect("activate", ind.HideAll)
-	ind.Menu.Append(mHideAll)
-	mHideAll.Show()
-
-	// Separator
-	sep, _ = gtk.SeparatorMenuItemNew()
-	ind.Menu.Append(sep)
-	sep.Show()
-
-	// Lock All
-	mLockAll, _ := gtk.MenuItemNewWithLabel("Lock All")
-	mLockAll.Connect("activate", ind.LockAll)
-	ind.Menu.Append(mLockAll)
-	mLockAll.Show()
-
-	// Unlock All
-	mUnlockAll, _ := gtk.MenuItemNewWithLabel("Unlock All")
-	mUnlockAll.Connect("activate", ind.UnlockAll)
-	ind.Menu.Append(mUnlockAll)
-	mUnlockAll.Show()
-
-	// Separator
-	sep, _ = gtk.SeparatorMenuItemNew()
-	ind.Menu.Append(sep)
+	menu.Append(sep)
 	sep.Show()
+}
 
-	// Export Data
-	mExport, _ := gtk.MenuItemNewWithLabel("Export Data")
-	mExport.Connect("activate", ind.ExportDataFile)
-	ind.Menu.Append(mExport)
-	mExport.Show()
-
-	// Import Data
-	mImport, _ := gtk.MenuItemNewWithLabel("Import Data")
-	mImport.Connect("activate", ind.ImportDataFile)
-	ind.Menu.Append(mImport)
-	mImport.Show()
-
-	// Separator
-	sep, _ = gtk.SeparatorMenuItemNew()
-	ind.Menu.Append(sep)
-	sep.Show()
+func (ind *IndicatorStickyNotes) createMenu() {
+	ind.registerActions()
+	ind.Menu, _ = gtk.MenuNew()
 
-	// About
-	mAbout, _ := gtk.MenuItemNewWithLabel("About")
-	mAbout.Connect("activate", ind.ShowAbout)
-	ind.Menu.Append(mAbout)
-	mAbout.Show()
-
-	// Settings
-	mSettings, _ := gtk.MenuItemNewWithLabel("Settings")
-	mSettings.Connect("activate", ind.ShowSettings)
-	ind.Menu.Append(mSettings)
-	mSettings.Show()
-
-	// Separator
-	sep, _ = gtk.SeparatorMenuItemNew()
-	ind.Menu.Append(sep)
-	sep.Show()
+	ind.appendAction(ind.Menu, "new-note")
+	appendSeparator(ind.Menu)
+	ind.appendAction(ind.Menu, "show-all")
+	ind.appendAction(ind.Menu, "hide-all")
+	ind.appendAction(ind.Menu, "desktop-widgets")
+
+	// Arrange the visible notes
+	mArrange, _ := gtk.MenuItemNewWithLabel("Arrange")
+	arrangeMenu, _ := gtk.MenuNew()
+	ind.appendAction(arrangeMenu, "arrange-cascade")
+	ind.appendAction(arrangeMenu, "arrange-tile")
+	ind.appendAction(arrangeMenu, "arrange-stack")
+	mArrange.SetSubmenu(arrangeMenu)
+	ind.Menu.Append(mArrange)
+	mArrange.Show()
+	ind.appendAction(ind.Menu, "bring-on-screen")
+
+	// Tag filter
+	ind.TagsItem, _ = gtk.MenuItemNewWithLabel("Show Tag")
+	ind.Menu.Append(ind.TagsItem)
+	ind.TagsItem.Show()
+	ind.RefreshTagsMenu()
+
+	// Per-category show/hide/new note, filled by RefreshNotesMenu
+	ind.CatsItem, _ = gtk.MenuItemNewWithLabel("Categories")
+	ind.Menu.Append(ind.CatsItem)
+	ind.CatsItem.Show()
+
+	// Note previews
+	ind.PeekItem, _ = gtk.MenuItemNewWithLabel("Peek")
+	ind.Menu.Append(ind.PeekItem)
+	ind.PeekItem.Show()
+	// Overdue notes
+	ind.OverdueItem, _ = gtk.MenuItemNewWithLabel("Overdue")
+	ind.Menu.Append(ind.OverdueItem)
+	ind.RefreshNotesMenu()
+
+	ind.appendAction(ind.Menu, "all-notes")
+	ind.appendAction(ind.Menu, "search")
+	ind.appendAction(ind.Menu, "glance")
+	ind.appendAction(ind.Menu, "pick-category")
+
+	ind.appendAction(ind.Menu, "command-palette")
+
+	appendSeparator(ind.Menu)
+	ind.appendAction(ind.Menu, "lock-all")
+	ind.appendAction(ind.Menu, "unlock-all")
+
+	// Quick toggle for the feedback sounds enabled in settings
+	ind.appendAction(ind.Menu, "mute-sounds")
+
+	appendSeparator(ind.Menu)
+	ind.appendAction(ind.Menu, "trash")
+	ind.appendAction(ind.Menu, "deletion-history")
+	ind.appendAction(ind.Menu, "paste-note")
+	ind.appendAction(ind.Menu, "export")
+	ind.appendAction(ind.Menu, "export-markdown")
+	ind.appendAction(ind.Menu, "export-python")
+	ind.appendAction(ind.Menu, "digest")
+	ind.appendAction(ind.Menu, "import")
+	ind.appendAction(ind.Menu, "undo-import")
+	ind.appendAction(ind.Menu, "check")
+	ind.appendAction(ind.Menu, "backups")
+	ind.appendAction(ind.Menu, "sync-now")
+
+	// Profile switcher
+	mProfile, _ := gtk.MenuItemNewWithLabel("Profile: " + stickynotes.ProfileLabel(ind.Args.Profile))
+	mProfile.SetSubmenu(ind.profileMenu())
+	ind.Menu.Append(mProfile)
+	mProfile.Show()
+
+	appendSeparator(ind.Menu)
+	ind.appendAction(ind.Menu, "about")
+	ind.appendAction(ind.Menu, "statistics")
+	ind.appendAction(ind.Menu, "settings")
+
+	appendSeparator(ind.Menu)
+	ind.appendAction(ind.Menu, "quit")
+}
 
-	// Quit
-	mQuit, _ := gtk.MenuItemNewWithLabel("Quit")
-	mQuit.Connect("activate", func() {
-		ind.Save()
-		gtk.MainQuit()
-	})
-	ind.Menu.Append(mQuit)
-	mQuit.Show()
+// feedbackMuted tells whether the feedback sounds are muted from the menu
+func (ind *IndicatorStickyNotes) feedbackMuted() bool {
+	muted, _ := ind.NoteSet.Properties["sound_muted"].(bool)
+	return muted
 }
 
 func (ind *IndicatorStickyNotes) NewNote() {
@@ -367,11 +835,182 @@ func (ind *IndicatorStickyNotes) ShowAll() {
 	ind.connectSecondaryActivate()
 }
 
+// BringOnScreen moves the notes stranded off the monitors back onto them
+func (ind *IndicatorStickyNotes) BringOnScreen() {
+	moved := ind.NoteSet.BringOnScreen()
+	fmt.Printf("[Arrange] Brought %d notes on screen\n", moved)
+}
+
 func (ind *IndicatorStickyNotes) HideAll() {
 	ind.NoteSet.HideAll()
 	ind.connectSecondaryActivate()
 }
 
+// onSessionLocked hides all notes when the screen locks, remembering whether they were visible
+func (ind *IndicatorStickyNotes) onSessionLocked() {
+	if enabled, ok := ind.NoteSet.Properties["hide_on_lock"].(bool); !ok || !enabled {
+		return
+	}
+	if ind.hiddenByLock {
+		return
+	}
+	allVisible, _ := ind.NoteSet.Properties["all_visible"].(bool)
+	ind.restoreAfterLock = allVisible
+	ind.hiddenByLock = true
+	if allVisible {
+		ind.HideAll()
+	}
+}
+
+// onSessionUnlocked restores the visibility the notes had before the session locked
+func (ind *IndicatorStickyNotes) onSessionUnlocked() {
+	if !ind.hiddenByLock {
+		return
+	}
+	ind.hiddenByLock = false
+	if ind.restoreAfterLock {
+		ind.ShowAll()
+	}
+}
+
+// RefreshTagsMenu rebuilds the "Show Tag" submenu from the tags currently used by notes
+func (ind *IndicatorStickyNotes) RefreshTagsMenu() {
+	if ind.TagsItem == nil {
+		return
+	}
+	tags := ind.NoteSet.AllTags()
+	submenu, _ := gtk.MenuNew()
+
+	mAll, _ := gtk.MenuItemNewWithLabel("All Notes")
+	mAll.Connect("activate", ind.ShowAll)
+	submenu.Append(mAll)
+	mAll.Show()
+
+	sep, _ := gtk.SeparatorMenuItemNew()
+	submenu.Append(sep)
+	sep.Show()
+
+	for _, tag := range tags {
+		tagName := tag // Capture for closure
+		mTag, _ := gtk.MenuItemNewWithLabel("#" + tagName)
+		mTag.Connect("activate", func() {
+			ind.NoteSet.ShowTagged(tagName)
+			ind.connectSecondaryActivate()
+		})
+		submenu.Append(mTag)
+		mTag.Show()
+	}
+
+	ind.TagsItem.SetSubmenu(submenu)
+	ind.TagsItem.SetSensitive(len(tags) > 0)
+}
+
+// RefreshNotesMenu rebuilds the "Peek" submenu. Hovering an entry previews the note
+// without showing it; activating it (menus exported over D-Bus don't report hover)
+// keeps the preview up until the pointer leaves it.
+func (ind *IndicatorStickyNotes) RefreshNotesMenu() {
+	if ind.PeekItem == nil {
+		return
+	}
+	submenu, _ := gtk.MenuNew()
+	submenu.Connect("hide", stickynotes.HidePeek)
+
+	for _, note := range ind.NoteSet.Notes {
+		n := note // Capture for closure
+		title := n.FirstLine()
+		if title == "" {
+			title = "(empty note)"
+		}
+		if len([]rune(title)) > 40 {
+			title = string([]rune(title)[:40]) + "…"
+		}
+		mNote, _ := gtk.MenuItemNewWithLabel(title)
+		mNote.Connect("select", func() {
+			stickynotes.ShowPeek(n)
+		})
+		mNote.Connect("deselect", stickynotes.HidePeek)
+		mNote.Connect("activate", func() {
+			stickynotes.ShowPeek(n)
+		})
+		submenu.Append(mNote)
+		mNote.Show()
+	}
+
+	ind.PeekItem.SetSubmenu(submenu)
+	ind.PeekItem.SetSensitive(len(ind.NoteSet.Notes) > 0)
+
+	ind.refreshOverdueMenu()
+	ind.refreshCategoriesMenu()
+}
+
+// refreshOverdueMenu rebuilds the "Overdue" submenu, shown only while notes are past due.
+// Activating an entry brings the note up.
+func (ind *IndicatorStickyNotes) refreshOverdueMenu() {
+	if ind.OverdueItem == nil {
+		return
+	}
+	overdue := ind.NoteSet.OverdueNotes()
+	submenu, _ := gtk.MenuNew()
+	for _, note := range overdue {
+		n := note // Capture for closure
+		title := n.FirstLine()
+		if title == "" {
+			title = "(empty note)"
+		}
+		if len([]rune(title)) > 40 {
+			title = string([]rune(title)[:40]) + "…"
+		}
+		mNote, _ := gtk.MenuItemNewWithLabel(title + " — " + n.DueCountdown())
+		mNote.Connect("activate", func() {
+			n.Show()
+			if n.GUI != nil {
+				n.GUI.Raise()
+			}
+		})
+		submenu.Append(mNote)
+		mNote.Show()
+	}
+
+	ind.OverdueItem.SetSubmenu(submenu)
+	ind.OverdueItem.SetLabel(fmt.Sprintf("Overdue (%d)", len(overdue)))
+	ind.OverdueItem.SetVisible(len(overdue) > 0)
+}
+
+// refreshCategoriesMenu rebuilds the "Categories" submenu: each category, with a swatch
+// of its color and its number of notes, can be shown, hidden or get a new note.
+func (ind *IndicatorStickyNotes) refreshCategoriesMenu() {
+	if ind.CatsItem == nil {
+		return
+	}
+	cats := ind.NoteSet.MatchCategories("")
+	submenu, _ := gtk.MenuNew()
+	for _, cat := range cats {
+		cat := cat // Capture for closure
+		label := fmt.Sprintf("%s (%d)", ind.NoteSet.CategoryName(cat), ind.NoteSet.CategoryNoteCount(cat))
+		mCat := stickynotes.ImageMenuItem(label, ind.NoteSet.CategoryIcon(cat))
+		catMenu, _ := gtk.MenuNew()
+		for _, entry := range []struct {
+			label string
+			run   func()
+		}{
+			{"Show", func() { ind.NoteSet.SetCategoryShown(cat, true) }},
+			{"Hide", func() { ind.NoteSet.SetCategoryShown(cat, false) }},
+			{"New Note", func() { ind.NoteSet.NewInCategory(cat) }},
+		} {
+			item, _ := gtk.MenuItemNewWithLabel(entry.label)
+			item.Connect("activate", entry.run)
+			catMenu.Append(item)
+			item.Show()
+		}
+		mCat.SetSubmenu(catMenu)
+		submenu.Append(mCat)
+		mCat.Show()
+	}
+
+	ind.CatsItem.SetSubmenu(submenu)
+	ind.CatsItem.SetSensitive(len(cats) > 0)
+}
+
 func (ind *IndicatorStickyNotes) LockAll() {
 	for _, note := range ind.NoteSet.Notes {
 		note.SetLockedState(true)
@@ -394,22 +1033,128 @@ func (ind *IndicatorStickyNotes) BackupDataFile() {
 	dialog.Destroy()
 
 	if response == gtk.RESPONSE_ACCEPT && backupFile != "" {
-		srcPath := ind.DataFile
-		if srcPath[0] == '~' {
-			home, _ := os.UserHomeDir()
-			srcPath = filepath.Join(home, srcPath[2:])
-		}
-		data, err := os.ReadFile(srcPath)
+		data, err := os.ReadFile(ind.NoteSet.DataPath())
 		if err == nil {
 			os.WriteFile(backupFile, data, 0644)
 		}
 	}
 }
 
+// ExportDataFile saves all notes, categories and attachments to a file of the user's choice,
+// or only the categories chosen when the notes are in several
 func (ind *IndicatorStickyNotes) ExportDataFile() {
-	ind.BackupDataFile()
+	all := ind.NoteSet.Export()
+	export := all
+	if cats, _ := stickynotes.FileCategories(all); len(cats) > 1 {
+		selected, ok := stickynotes.ChooseCategories("Export Categories", "Export", cats)
+		if !ok || len(selected) == 0 {
+			return
+		}
+		if len(selected) < len(cats) {
+			export = ind.NoteSet.ExportCategories(selected)
+		}
+	}
+
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Export Data", nil, gtk.FILE_CHOOSER_ACTION_SAVE, "Cancel", gtk.RESPONSE_CANCEL, "Save", gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+	response := dialog.Run()
+	exportFile := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response == gtk.RESPONSE_ACCEPT && exportFile != "" {
+		if err := os.WriteFile(exportFile, []byte(export), 0644); err == nil {
+			ind.NoteSet.RecordUsage(stickynotes.UsageExport)
+		}
+	}
 }
 
+// ExportMarkdown writes every note as a Markdown file to a folder of the user's choice
+func (ind *IndicatorStickyNotes) ExportMarkdown() {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Export as Markdown", nil, gtk.FILE_CHOOSER_ACTION_SELECT_FOLDER, "Cancel", gtk.RESPONSE_CANCEL, "Export", gtk.RESPONSE_ACCEPT)
+	dialog.SetCreateFolders(true)
+	response := dialog.Run()
+	dir := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT || dir == "" {
+		return
+	}
+	count, err := ind.NoteSet.ExportMarkdownDir(dir)
+	if err != nil {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error exporting notes.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+	ind.NoteSet.RecordUsage(stickynotes.UsageExport)
+	done := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_INFO, gtk.BUTTONS_CLOSE, "Notes exported.")
+	done.FormatSecondaryText("%d notes were written to %s.", count, dir)
+	done.Run()
+	done.Destroy()
+}
+
+// ExportPython writes the notes in the format of the original Python indicator-stickynotes,
+// suggesting the data file it reads
+func (ind *IndicatorStickyNotes) ExportPython() {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Export for Python indicator-stickynotes", nil, gtk.FILE_CHOOSER_ACTION_SAVE, "Cancel", gtk.RESPONSE_CANCEL, "Save", gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+	legacy := stickynotes.ResolvePath(stickynotes.LegacySettingsFile)
+	dialog.SetCurrentFolder(filepath.Dir(legacy))
+	dialog.SetCurrentName(filepath.Base(legacy))
+	response := dialog.Run()
+	exportFile := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT || exportFile == "" {
+		return
+	}
+	if err := os.WriteFile(exportFile, []byte(ind.NoteSet.ExportPython()), 0644); err != nil {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error exporting notes.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+	ind.NoteSet.RecordUsage(stickynotes.UsageExport)
+}
+
+// SendDigest makes the weekly digest now the way set up in the settings, or saves it
+// where the user picks when there is none
+func (ind *IndicatorStickyNotes) SendDigest() {
+	now := time.Now()
+	if ind.NoteSet.DigestMode() != stickynotes.DigestOff {
+		if _, err := ind.NoteSet.SendDigest(now); err != nil {
+			dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error making the weekly digest.")
+			dialog.FormatSecondaryText("%s", err.Error())
+			dialog.Run()
+			dialog.Destroy()
+		}
+		return
+	}
+
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Save Weekly Digest", nil, gtk.FILE_CHOOSER_ACTION_SAVE, "Cancel", gtk.RESPONSE_CANCEL, "Save", gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+	dialog.SetCurrentFolder(ind.NoteSet.DigestDir())
+	dialog.SetCurrentName("postnote-digest-" + now.Format("2006-01-02") + ".md")
+	response := dialog.Run()
+	digestFile := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT || digestFile == "" {
+		return
+	}
+	digest, _ := ind.NoteSet.Digest(now.AddDate(0, 0, -7), now)
+	if err := os.WriteFile(digestFile, []byte(digest), 0644); err != nil {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error saving the weekly digest.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+	}
+}
+
+// ImportDataFile imports the notes of a file of the user's choice. Of a notes file with
+// several categories, only the categories chosen are imported.
 func (ind *IndicatorStickyNotes) ImportDataFile() {
 	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Import Data", nil, gtk.FILE_CHOOSER_ACTION_OPEN, "Cancel", gtk.RESPONSE_CANCEL, "Open", gtk.RESPONSE_ACCEPT)
 	response := dialog.Run()
@@ -418,16 +1163,243 @@ func (ind *IndicatorStickyNotes) ImportDataFile() {
 
 	if response == gtk.RESPONSE_ACCEPT && importFile != "" {
 		data, err := os.ReadFile(importFile)
+		ext := strings.ToLower(filepath.Ext(importFile))
+		var selected []string // All categories when nil
+		if err == nil && ext != ".jex" && ext != ".md" && ext != ".markdown" {
+			if cats, _ := stickynotes.FileCategories(string(data)); len(cats) > 1 {
+				chosen, ok := stickynotes.ChooseCategories("Import Categories", "Import", cats)
+				if !ok || len(chosen) == 0 {
+					return
+				}
+				if len(chosen) < len(cats) {
+					selected = chosen
+				}
+			}
+		}
+		if err == nil {
+			backup, backupErr := ind.NoteSet.Backup(stickynotes.BackupImport)
+			if backupErr != nil {
+				fmt.Printf("[Backup] Failed to back up before import: %v\n", backupErr)
+			} else {
+				ind.importBackup = &backup
+			}
+			// Markdown files carry a single note with its metadata in front-matter, unless
+			// they are an item of a Joplin RAW export, whose whole folder is imported
+			switch ext {
+			case ".jex":
+				err = ind.NoteSet.ImportJoplin(importFile)
+			case ".md", ".markdown":
+				if stickynotes.IsJoplinItem(string(data)) {
+					err = ind.NoteSet.ImportJoplin(filepath.Dir(importFile))
+				} else {
+					err = ind.NoteSet.ImportMarkdown(string(data))
+				}
+			default:
+				if selected != nil {
+					err = ind.NoteSet.MergeCategories(string(data), selected)
+				} else {
+					err = ind.NoteSet.Merge(string(data))
+				}
+			}
+		}
 		if err == nil {
-			ind.NoteSet.Merge(string(data))
+			ind.NoteSet.RecordUsage(stickynotes.UsageImport)
+			ind.RefreshTagsMenu()
+			ind.NoteSet.SetActionEnabled("undo-import", ind.importBackup != nil)
+			if len(ind.NoteSet.MergeConflicts) > 0 {
+				stickynotes.NewMergeConflictWindow(ind.NoteSet, ind.NoteSet.MergeConflicts)
+			}
 		} else {
 			dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error importing data.")
+			dialog.FormatSecondaryText("%s", err.Error())
 			dialog.Run()
 			dialog.Destroy()
 		}
 	}
 }
 
+// profileMenu builds the profile switcher: the profiles with a data file, the current
+// one checked, and "New Profile…"
+func (ind *IndicatorStickyNotes) profileMenu() *gtk.Menu {
+	menu, _ := gtk.MenuNew()
+	profiles := stickynotes.Profiles(ind.Args.PortableDir)
+	if !slices.Contains(profiles, ind.Args.Profile) {
+		profiles = append(profiles, ind.Args.Profile)
+	}
+	var group *glib.SList
+	for _, profile := range profiles {
+		item, _ := gtk.RadioMenuItemNewWithLabel(group, stickynotes.ProfileLabel(profile))
+		group, _ = item.GetGroup()
+		item.SetActive(profile == ind.Args.Profile)
+		name := profile // Capture for closure
+		item.Connect("activate", func() {
+			if item.GetActive() && name != ind.Args.Profile {
+				ind.SwitchProfile(name)
+			}
+		})
+		menu.Append(item)
+		item.Show()
+	}
+	appendSeparator(menu)
+	ind.appendAction(menu, "new-profile")
+	return menu
+}
+
+// NewProfile asks for the name of a new profile and switches to it
+func (ind *IndicatorStickyNotes) NewProfile() {
+	dialog, _ := gtk.DialogNewWithButtons("New Profile", nil, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+		[]interface{}{"Create", gtk.RESPONSE_ACCEPT})
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetBorderWidth(8)
+	label, _ := gtk.LabelNew("Name of the profile (letters, digits, - and _), e.g. work:")
+	label.SetHAlign(gtk.ALIGN_START)
+	content.PackStart(label, false, false, 0)
+	entry, _ := gtk.EntryNew()
+	entry.SetActivatesDefault(true)
+	content.PackStart(entry, false, false, 0)
+	dialog.ShowAll()
+	response := dialog.Run()
+	name, _ := entry.GetText()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	name = strings.TrimSpace(name)
+	if !stickynotes.ValidProfileName(name) {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Invalid profile name.")
+		dialog.FormatSecondaryText("Use letters, digits, - and _ only.")
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+	if name != ind.Args.Profile {
+		ind.SwitchProfile(name)
+	}
+}
+
+// SwitchProfile saves the notes and restarts the application with the profile, keeping
+// the other command line options
+func (ind *IndicatorStickyNotes) SwitchProfile(profile string) {
+	exe, err := os.Executable()
+	if err == nil {
+		stickynotes.FinishPendingDelete()
+		ind.Save()
+		ind.NoteSet.Flush()
+		err = syscall.Exec(exe, profileArgs(os.Args, profile), os.Environ())
+	}
+	// Exec only returns on failure
+	dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error switching profile.")
+	dialog.FormatSecondaryText("%s", err.Error())
+	dialog.Run()
+	dialog.Destroy()
+}
+
+// profileArgs returns the command line args with the profile replaced
+func profileArgs(args []string, profile string) []string {
+	result := []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		arg := strings.TrimLeft(args[i], "-")
+		switch {
+		case arg == "d":
+		case arg == "profile":
+			i++ // Skips the value too
+		case strings.HasPrefix(arg, "profile="):
+		default:
+			result = append(result, args[i])
+		}
+	}
+	if profile != stickynotes.DefaultProfile {
+		result = append(result, "-profile", profile)
+	}
+	return result
+}
+
+// UndoImport restores the notes as they were before the last import, from the backup
+// taken then
+func (ind *IndicatorStickyNotes) UndoImport() {
+	backup := ind.importBackup
+	if backup == nil {
+		return
+	}
+	dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Undo the import?")
+	dialog.FormatSecondaryText("The notes are restored as they were before the import, at %s. Changes made since are lost, but kept in a backup.",
+		stickynotes.FormatClock(backup.Time))
+	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Undo Import", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	if err := ind.NoteSet.RestoreBackup(*backup); err != nil {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error undoing the import.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+	ind.importBackup = nil
+	ind.NoteSet.SetActionEnabled("undo-import", false)
+	ind.RefreshTagsMenu()
+	ind.connectSecondaryActivate()
+}
+
+// PasteNote adds the note copied to the clipboard with "Copy as JSON", or a note with
+// the copied text
+func (ind *IndicatorStickyNotes) PasteNote() {
+	if _, err := ind.NoteSet.PasteNote(); err != nil {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error pasting note.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+	ind.RefreshTagsMenu()
+}
+
+// CheckData validates the notes and categories and offers to repair the problems found
+func (ind *IndicatorStickyNotes) CheckData() {
+	ind.Save()
+	problems := ind.NoteSet.Check()
+	if len(problems) == 0 {
+		dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_INFO, gtk.BUTTONS_CLOSE, "No problems found in the data file.")
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = "• " + p.Message
+	}
+	dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_WARNING, gtk.BUTTONS_NONE, "%d problems found in the data file.", len(problems))
+	dialog.FormatSecondaryText("%s", strings.Join(lines, "\n"))
+	dialog.AddButton("Close", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Repair All", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	for _, p := range problems {
+		ind.NoteSet.Repair(p)
+	}
+	// Categories may have changed, refresh the note colors
+	for _, note := range ind.NoteSet.Notes {
+		if note.GUI != nil {
+			note.GUI.LoadCSS()
+			note.GUI.PopulateMenu()
+		}
+	}
+	ind.NoteSet.Save()
+}
+
 func (ind *IndicatorStickyNotes) ShowAbout() {
 	// Load about dialog from embedded UI file
 	uiContent, err := GetEmbeddedUI("GlobalDialogs.ui")
@@ -508,6 +1480,11 @@ Keyboard shortcuts:
 Ctrl + W:  Delete note
 Ctrl + L:  Lock note
 Ctrl + N:  New note
+Ctrl + F:  Find in note
+Ctrl + .:  Insert emoji
+Ctrl + D:  Strike through line
+Ctrl + K:  Pick category
+Ctrl + =/-:  Zoom text (Ctrl + 0 resets)
 
 Due to Wayland restrictions, window 
 positions cannot be saved. 
@@ -589,6 +1566,20 @@ from the original project. indicator-stickynotes is © 2012–2018 Umang Varma u
 		}
 	}
 
+	// Diagnostics tab, for bug reports
+	diagnostics := ind.diagnostics()
+	if tvObj, err := builder.GetObject("tvDiagnostics"); err == nil && tvObj != nil {
+		buffer, _ := tvObj.(*gtk.TextView).GetBuffer()
+		buffer.SetText(diagnostics)
+	}
+	if btnObj, err := builder.GetObject("bCopyDiagnostics"); err == nil && btnObj != nil {
+		btnObj.(*gtk.Button).Connect("clicked", func() {
+			if clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD); err == nil {
+				clipboard.SetText(diagnostics)
+			}
+		})
+	}
+
 	// Connect close button
 	if btnObj, err := builder.GetObject("bAboutClose"); err == nil && btnObj != nil {
 		btn := btnObj.(*gtk.Button)
@@ -601,11 +1592,132 @@ from the original project. indicator-stickynotes is © 2012–2018 Umang Varma u
 	aboutDialog.Destroy()
 }
 
+// diagnostics describes the environment PostNote runs in, for bug reports
+func (ind *IndicatorStickyNotes) diagnostics() string {
+	var b strings.Builder
+	env := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return "(unset)"
+	}
+
+	fmt.Fprintf(&b, "PostNote 0.1a (%s, %s/%s)\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	fmt.Fprintf(&b, "Session type:      %s\n", env("XDG_SESSION_TYPE"))
+	fmt.Fprintf(&b, "Desktop:           %s\n", env("XDG_CURRENT_DESKTOP"))
+	fmt.Fprintf(&b, "WAYLAND_DISPLAY:   %s\n", env("WAYLAND_DISPLAY"))
+	fmt.Fprintf(&b, "GDK_BACKEND:       %s\n", env("GDK_BACKEND"))
+	fmt.Fprintf(&b, "Wayland mode:      %v\n\n", stickynotes.IsWayland())
+
+	fmt.Fprintf(&b, "Window Calls:      %v\n", stickynotes.IsWindowCallsAvailable())
+	fmt.Fprintf(&b, "KWin scripting:    %v\n", stickynotes.KWinScripting())
+	fmt.Fprintf(&b, "Layer Shell:       %v\n", stickynotes.IsLayerShellAvailable())
+	fmt.Fprintf(&b, "Window backend:    %s\n", stickynotes.Backend().Name())
+	if version, err := stickynotes.WindowCallsVersion(); err == nil {
+		fmt.Fprintf(&b, "Window Calls ver.: %s\n\n", version)
+	} else {
+		fmt.Fprintf(&b, "Window Calls ver.: unknown (%v)\n\n", err)
+	}
+	if calls := stickynotes.WindowCallsStats(); calls.Calls > 0 || calls.Skipped > 0 {
+		fmt.Fprintf(&b, "Window Calls use:  %d calls, %d failed (%d timed out), %d throttled, %d skipped\n",
+			calls.Calls, calls.Failures, calls.Timeouts, calls.Throttled, calls.Skipped)
+		if calls.Failures > 0 {
+			fmt.Fprintf(&b, "Last failure:      %s: %s\n", calls.LastErrorAt.Format("15:04:05"), calls.LastError)
+		}
+		if time.Now().Before(calls.PausedUntil) {
+			fmt.Fprintf(&b, "Paused until:      %s (GNOME Shell not responding)\n", calls.PausedUntil.Format("15:04:05"))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "Data file:         %s\n", ind.NoteSet.DataPath())
+	if info, err := os.Stat(ind.NoteSet.DataPath()); err == nil {
+		fmt.Fprintf(&b, "Data file size:    %d bytes\n", info.Size())
+	} else {
+		fmt.Fprintf(&b, "Data file size:    %v\n", err)
+	}
+	fmt.Fprintf(&b, "Notes:             %d (%d in trash), %d categories\n\n",
+		len(ind.NoteSet.Notes), len(ind.NoteSet.Trash), len(ind.NoteSet.Categories))
+
+	fmt.Fprintf(&b, "Resource base:     %s\n", stickynotes.GetBasePath())
+	if ind.Resources != nil {
+		fmt.Fprintf(&b, "Resource cache:    %s\n", ind.Resources.CachePath())
+	}
+	return b.String()
+}
+
+func (ind *IndicatorStickyNotes) ShowStatistics() {
+	// Make sure the report reflects unsaved edits
+	for _, note := range ind.NoteSet.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateNote()
+		}
+	}
+	stickynotes.NewStatisticsDialog(ind.NoteSet)
+}
+
+func (ind *IndicatorStickyNotes) ShowSearch() {
+	stickynotes.NewSearchWindow(ind.NoteSet)
+}
+
+func (ind *IndicatorStickyNotes) ShowNoteList() {
+	stickynotes.NewNoteListWindow(ind.NoteSet)
+}
+
+func (ind *IndicatorStickyNotes) ShowTrash() {
+	stickynotes.NewTrashWindow(ind.NoteSet)
+}
+
+// ShowDeletionHistory lists the notes deleted, merged or replaced, from the audit log
+func (ind *IndicatorStickyNotes) ShowDeletionHistory() {
+	stickynotes.NewAuditWindow(ind.NoteSet)
+}
+
+// ShowBackups opens the list of backups, to restore one
+func (ind *IndicatorStickyNotes) ShowBackups() {
+	bw := stickynotes.NewBackupWindow(ind.NoteSet)
+	bw.OnRestore = func() {
+		ind.RefreshTagsMenu()
+		ind.connectSecondaryActivate()
+	}
+}
+
 func (ind *IndicatorStickyNotes) ShowSettings() {
 	stickynotes.NewSettingsDialog(ind.NoteSet)
 	ind.NoteSet.Save()
 }
 
+// SyncNow syncs the notes with the servers set up in the settings, reporting a failure
+func (ind *IndicatorStickyNotes) SyncNow() {
+	report := func(message string, err error) {
+		if err != nil {
+			dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "%s", message)
+			dialog.FormatSecondaryText("%s", err.Error())
+			dialog.Run()
+			dialog.Destroy()
+		}
+	}
+	if ind.NoteSet.RemoteSyncEnabled() {
+		ind.NoteSet.SyncRemote(func(result stickynotes.SyncResult, err error) {
+			report("Error syncing notes with Nextcloud.", err)
+		})
+	}
+	if ind.NoteSet.WebDAVEnabled() {
+		ind.NoteSet.SyncWebDAV(func(err error) {
+			report("Error syncing the data file through WebDAV.", err)
+		})
+	}
+}
+
+// ReportSaveError tells the user the notes couldn't be saved
+func (ind *IndicatorStickyNotes) ReportSaveError(err error) {
+	dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Your notes couldn't be saved.")
+	dialog.FormatSecondaryText("%s\n\nChanges are kept while PostNote is running and saved again with the next edit.", err.Error())
+	dialog.Run()
+	dialog.Destroy()
+}
+
 func (ind *IndicatorStickyNotes) Save() {
 	// Update all note positions before saving
 	for _, note := range ind.NoteSet.Notes {
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 //go:embed assets/StickyNotes.ui assets/GlobalDialogs.ui assets/SettingsCategory.ui assets/style.css assets/style_global.css
@@ -45,3 +51,71 @@ func GetEmbeddedIcon(iconPath string) ([]byte, error) {
 	}
 	return data, nil
 }
+
+// resourceStampFile records the hash of the embedded resources a cache was extracted from
+const resourceStampFile = ".embedded-hash"
+
+// embeddedResourcesHash hashes the paths and contents of all embedded resources,
+// so a cache extracted by another build (e.g. before an upgrade) is detected as stale
+func embeddedResourcesHash() (string, error) {
+	h := sha256.New()
+	for _, fsys := range []embed.FS{uiFiles, iconFiles} {
+		err := fs.WalkDir(fsys, "assets", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fsys.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+			h.Write(data)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// initEmbeddedResources makes sure dir holds an up-to-date copy of the embedded resources,
+// extracting them again when the cache is missing or stale. Returns whether it extracted.
+func initEmbeddedResources(dir string) (bool, error) {
+	hash, err := embeddedResourcesHash()
+	if err != nil {
+		return false, err
+	}
+	if stamp, err := os.ReadFile(filepath.Join(dir, resourceStampFile)); err == nil && strings.TrimSpace(string(stamp)) == hash {
+		return false, nil
+	}
+	return true, extractEmbeddedResources(dir, hash)
+}
+
+// extractEmbeddedResources replaces the contents of dir with the embedded resources
+// (UI files, CSS and icons, without the "assets/" prefix) and stamps it with hash
+func extractEmbeddedResources(dir, hash string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear resource cache %s: %w", dir, err)
+	}
+	for _, fsys := range []embed.FS{uiFiles, iconFiles} {
+		err := fs.WalkDir(fsys, "assets", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(path, "assets")))
+			if d.IsDir() {
+				return os.MkdirAll(target, 0755)
+			}
+			data, err := fsys.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, 0644)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to extract embedded resources to %s: %w", dir, err)
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, resourceStampFile), []byte(hash+"\n"), 0644)
+}
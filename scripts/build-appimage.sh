@@ -82,6 +82,7 @@ Exec=postnote
 Icon=postnote
 Type=Application
 Categories=Utility;
+StartupWMClass=app.runable.postnote
 EOF
     cp "$APPDIR/postnote.desktop" "$APPDIR/usr/share/applications/"
 fi
@@ -0,0 +1,317 @@
+package stickynotes
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Every user action is registered once under a name: the note actions below, and the
+// application-wide ones by the indicator. The indicator menu, the note menus, the note
+// window shortcuts, the command palette and the D-Bus API all run actions through
+// ActivateAction, so they share one implementation and one enabled state.
+
+// Action is something the user can do, on a note or application-wide
+type Action struct {
+	ID        string
+	Label     string   // Shown in menus and the command palette
+	MenuLabel string   // Shown in menus when it differs from Label, e.g. within a submenu
+	Keywords  string   // More words the command palette matches
+	Accels    []string // Shortcuts in note windows, as parsed by gtk.AcceleratorParse
+
+	// Run performs an application-wide action, or a note action invoked without a note
+	Run func()
+	// RunNote performs the action on the note it was invoked from
+	RunNote func(sn *StickyNote)
+	// Enabled tells whether the action can be used now, sn is nil outside a note (optional)
+	Enabled func(sn *StickyNote) bool
+	// Checked makes the action a toggle, shown with a check box in menus (optional)
+	Checked func(sn *StickyNote) bool
+	// Detail is added to the label in menus, e.g. when a reminder is set (optional)
+	Detail func(sn *StickyNote) string
+
+	disabled bool                 // Disabled with SetActionEnabled
+	items    []*gtk.MenuItem      // Menu items of an application-wide action, kept in step
+	checks   []*gtk.CheckMenuItem // The same, for toggles
+}
+
+// modifierMask is the modifiers shortcuts are matched on
+const modifierMask = gdk.CONTROL_MASK | gdk.SHIFT_MASK | gdk.MOD1_MASK
+
+// RegisterAction adds an action, replacing the one with the same id
+func (ns *NoteSet) RegisterAction(action *Action) {
+	for i, a := range ns.actions {
+		if a.ID == action.ID {
+			ns.actions[i] = action
+			return
+		}
+	}
+	ns.actions = append(ns.actions, action)
+}
+
+// Action returns the action with the given id, nil if there is none
+func (ns *NoteSet) Action(id string) *Action {
+	for _, a := range ns.actions {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// Actions returns the registered actions, in registration order
+func (ns *NoteSet) Actions() []*Action {
+	return ns.actions
+}
+
+// ActionEnabled tells whether the action can be run, on sn (nil outside a note)
+func (ns *NoteSet) ActionEnabled(action *Action, sn *StickyNote) bool {
+	if action.disabled {
+		return false
+	}
+	if action.Run == nil && (action.RunNote == nil || sn == nil) {
+		return false
+	}
+	return action.Enabled == nil || action.Enabled(sn)
+}
+
+// SetActionEnabled enables or disables an action everywhere it is offered
+func (ns *NoteSet) SetActionEnabled(id string, enabled bool) {
+	action := ns.Action(id)
+	if action == nil || action.disabled == !enabled {
+		return
+	}
+	action.disabled = !enabled
+	for _, item := range action.items {
+		item.SetSensitive(enabled)
+	}
+	if action.RunNote != nil {
+		for _, note := range ns.Notes {
+			if note.GUI != nil && note.GUI.Menu != nil {
+				note.GUI.PopulateMenu()
+			}
+		}
+	}
+}
+
+// ActivateAction runs the action with the given id, on sn when it is a note action
+// invoked from a note (sn may be nil)
+func (ns *NoteSet) ActivateAction(id string, sn *StickyNote) error {
+	action := ns.Action(id)
+	if action == nil {
+		return fmt.Errorf("no action %q", id)
+	}
+	if !ns.ActionEnabled(action, sn) {
+		return fmt.Errorf("action %q is not available", id)
+	}
+	if action.RunNote != nil && sn != nil {
+		action.RunNote(sn)
+	} else {
+		action.Run()
+	}
+	for _, check := range action.checks {
+		check.SetActive(action.Checked(nil))
+	}
+	return nil
+}
+
+// menuLabel returns the action's label for menus, with its detail
+func (action *Action) menuLabel(sn *StickyNote) string {
+	label := action.MenuLabel
+	if label == "" {
+		label = action.Label
+	}
+	if action.Detail != nil {
+		if detail := action.Detail(sn); detail != "" {
+			label += " (" + detail + ")"
+		}
+	}
+	return label
+}
+
+// ActionMenuItem returns a menu item running the action with the given id on sn (nil
+// in the indicator menu), a check item for toggles
+func (ns *NoteSet) ActionMenuItem(id string, sn *StickyNote) *gtk.MenuItem {
+	action := ns.Action(id)
+	if action == nil {
+		item, _ := gtk.MenuItemNewWithLabel(id)
+		item.SetSensitive(false)
+		return item
+	}
+
+	var item *gtk.MenuItem
+	if action.Checked != nil {
+		check, _ := gtk.CheckMenuItemNewWithLabel(action.menuLabel(sn))
+		check.SetActive(action.Checked(sn))
+		check.Connect("toggled", func() {
+			// Toggled back by the menu being rebuilt, not by the user
+			if check.GetActive() == action.Checked(sn) {
+				return
+			}
+			if err := ns.ActivateAction(id, sn); err != nil {
+				fmt.Printf("[Action] %v\n", err)
+			}
+		})
+		item = &check.MenuItem
+		if sn == nil {
+			action.checks = append(action.checks, check)
+		}
+	} else {
+		item, _ = gtk.MenuItemNewWithLabel(action.menuLabel(sn))
+		item.Connect("activate", func() {
+			if err := ns.ActivateAction(id, sn); err != nil {
+				fmt.Printf("[Action] %v\n", err)
+			}
+		})
+	}
+	item.SetSensitive(ns.ActionEnabled(action, sn))
+	if sn == nil {
+		action.items = append(action.items, item)
+	}
+	return item
+}
+
+// actionForKey returns the id of the action bound to the key pressed in a note window,
+// "" if none is
+func (ns *NoteSet) actionForKey(keyval uint, state gdk.ModifierType) string {
+	keyval = gdk.KeyvalToLower(keyval)
+	state &= modifierMask
+	for _, action := range ns.actions {
+		for _, accel := range action.Accels {
+			key, mods := gtk.AcceleratorParse(accel)
+			if key == 0 || key != keyval {
+				continue
+			}
+			// Shift is part of symbols like "+", unless the shortcut names it
+			symbol := gdk.KeyvalToUpper(key) == key
+			if state == mods || symbol && mods&gdk.SHIFT_MASK == 0 && state == mods|gdk.SHIFT_MASK {
+				return action.ID
+			}
+		}
+	}
+	return ""
+}
+
+// MatchActions returns the enabled actions whose label or keywords fuzzy-match query,
+// best match first (all of them in registration order for an empty query). Note actions
+// are only listed when sn is set.
+func (ns *NoteSet) MatchActions(query string, sn *StickyNote) []*Action {
+	type match struct {
+		action *Action
+		score  int
+	}
+	var matches []match
+	for _, action := range ns.actions {
+		if !ns.ActionEnabled(action, sn) {
+			continue
+		}
+		score := fuzzyScore(query, action.Label)
+		if action.Keywords != "" {
+			score = max(score, fuzzyScore(query, action.Label+" "+action.Keywords)-1)
+		}
+		if score >= 0 {
+			matches = append(matches, match{action, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	actions := make([]*Action, len(matches))
+	for i, m := range matches {
+		actions[i] = m.action
+	}
+	return actions
+}
+
+// registerNoteActions registers the actions of the note windows and their menus
+func (ns *NoteSet) registerNoteActions() {
+	for _, action := range []*Action{
+		{ID: "always-on-top", Label: "Always on top", Keywords: "pin above all workspaces",
+			RunNote: func(sn *StickyNote) { sn.SetPinned(!sn.Pinned()) },
+			Checked: func(sn *StickyNote) bool { return sn != nil && sn.Pinned() },
+			Enabled: func(*StickyNote) bool { return Backend().CanPlace() }},
+		{ID: "roll-up", Label: "Roll up", Keywords: "shade collapse title bar",
+			RunNote: func(sn *StickyNote) { sn.SetRolledUp(!sn.Note.RolledUp()) },
+			Checked: func(sn *StickyNote) bool { return sn != nil && sn.Note.RolledUp() }},
+		{ID: "opacity", Label: "Opacity…", Keywords: "transparent see-through dim",
+			RunNote: (*StickyNote).onOpacity},
+		{ID: "new-note-here", Label: "New note in this category", Accels: []string{"<Control>n"},
+			RunNote: (*StickyNote).onAdd},
+		{ID: "delete-note", Label: "Delete note", Accels: []string{"<Control>w"},
+			RunNote: (*StickyNote).onDelete},
+		{ID: "lock-note", Label: "Lock/unlock note", Accels: []string{"<Control>l"},
+			RunNote: (*StickyNote).onLockClicked},
+		{ID: "open-editor", Label: "Open in editor", RunNote: (*StickyNote).OpenEditor},
+		{ID: "find", Label: "Find in note", Keywords: "search", Accels: []string{"<Control>f"},
+			RunNote: func(sn *StickyNote) {
+				if sn.FindBar != nil {
+					sn.FindBar.Open()
+				}
+			}},
+		{ID: "strikethrough", Label: "Strike through line", Accels: []string{"<Control>d"},
+			RunNote: (*StickyNote).ToggleStrikethrough},
+		{ID: "calculate", Label: "Calculate selection", Keywords: "math calculator evaluate convert units",
+			RunNote: (*StickyNote).CalculateSelection,
+			Enabled: func(sn *StickyNote) bool { return sn == nil || !sn.InViewMode() }},
+		{ID: "insert-emoji", Label: "Insert emoji", Accels: []string{"<Control>period", "<Control>semicolon"},
+			RunNote: (*StickyNote).InsertEmoji,
+			Enabled: func(sn *StickyNote) bool { return sn == nil || !sn.InViewMode() }},
+		{ID: "zoom-in", Label: "Larger text", Keywords: "zoom font", Accels: []string{"<Control>plus", "<Control>equal", "<Control>KP_Add"},
+			RunNote: func(sn *StickyNote) { sn.SetFontDelta(sn.FontDelta() + fontDeltaStep) }},
+		{ID: "zoom-out", Label: "Smaller text", Keywords: "zoom font", Accels: []string{"<Control>minus", "<Control>KP_Subtract"},
+			RunNote: func(sn *StickyNote) { sn.SetFontDelta(sn.FontDelta() - fontDeltaStep) }},
+		{ID: "zoom-reset", Label: "Reset text size", Keywords: "zoom font", Accels: []string{"<Control>0", "<Control>KP_0"},
+			RunNote: func(sn *StickyNote) { sn.SetFontDelta(0) }},
+		{ID: "attach-file", Label: "Attach file...", RunNote: (*StickyNote).onAttachFile},
+		{ID: "view-mode", Label: "View mode", Keywords: "read only markdown",
+			RunNote: func(sn *StickyNote) { sn.SetViewMode(!sn.ViewMode()) },
+			Checked: func(sn *StickyNote) bool { return sn != nil && sn.ViewMode() }},
+		{ID: "remind-me", Label: "Remind me…", Keywords: "reminder notification",
+			RunNote: (*StickyNote).onRemindMe,
+			Detail: func(sn *StickyNote) string {
+				if at, ok := sn.Note.ReminderAt(); ok {
+					return FormatDayTime(at)
+				}
+				return ""
+			}},
+		{ID: "due-date", Label: "Due date…", Keywords: "deadline",
+			RunNote: (*StickyNote).onDueDate,
+			Detail: func(sn *StickyNote) string {
+				if due, ok := sn.Note.DueDate(); ok {
+					return FormatDay(due)
+				}
+				return ""
+			}},
+		{ID: "expire", Label: "Expire…", Keywords: "trash later",
+			RunNote: (*StickyNote).onExpiry,
+			Detail:  func(sn *StickyNote) string { return sn.Note.expirySummary() }},
+		{ID: "history", Label: "History…", Keywords: "versions", RunNote: (*StickyNote).onHistory},
+		{ID: "export-note", Label: "Export this note…", Keywords: "markdown save", RunNote: (*StickyNote).onExportNote},
+		{ID: "qr-code", Label: "Show as QR code…", Keywords: "phone", RunNote: (*StickyNote).onShowQRCode},
+		{ID: "copy-json", Label: "Copy as JSON", Keywords: "clipboard", RunNote: (*StickyNote).onCopyJSON},
+		{ID: "merge-into", Label: "Merge into...", RunNote: (*StickyNote).onMergeInto},
+		{ID: "tags", Label: "Tags",
+			RunNote: func(sn *StickyNote) {
+				if sn.ETags == nil {
+					return
+				}
+				visible := !sn.ETags.GetVisible()
+				sn.ETags.SetVisible(visible)
+				if visible {
+					sn.ETags.GrabFocus()
+				}
+			},
+			Checked: func(sn *StickyNote) bool { return sn != nil && sn.ETags != nil && sn.ETags.GetVisible() }},
+		{ID: "note-color", Label: "Note color…", Keywords: "background", RunNote: (*StickyNote).onNoteColor},
+		{ID: "pick-category", Label: "Pick Category...", Accels: []string{"<Control>k"},
+			Run: func() { NewCategoryPicker(ns) }},
+		{ID: "command-palette", Label: "Command Palette…", Accels: []string{"<Control><Shift>p"},
+			Run:     func() { NewCommandPalette(ns, nil) },
+			RunNote: func(sn *StickyNote) { NewCommandPalette(ns, sn) }},
+	} {
+		ns.RegisterAction(action)
+	}
+}
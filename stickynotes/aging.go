@@ -0,0 +1,200 @@
+package stickynotes
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Notes left untouched for weeks can age, to nudge cleaning them up: they fade towards
+// grey, or get a badge in the corner telling how long they have been untouched. Aging
+// starts after "aging_start_weeks" without edits and is complete after
+// "aging_full_weeks"; "aging" picks the effect (off by default).
+
+// Aging effects
+const (
+	AgingOff   = ""
+	AgingFade  = "fade"
+	AgingBadge = "badge"
+)
+
+const (
+	DefaultAgingStartWeeks = 2
+	DefaultAgingFullWeeks  = 8
+
+	// agingFadeMax is the part of the saturation taken away from a fully aged note
+	agingFadeMax = 0.75
+	// agingSteps is how many steps a note fades in, each one restyling the note
+	agingSteps = 10
+	// agingRefreshInterval is how often (ms) the notes are aged
+	agingRefreshInterval = 60 * 60 * 1000
+)
+
+// AgingMode returns the aging effect, AgingOff when notes don't age
+func (ns *NoteSet) AgingMode() string {
+	mode, _ := ns.Properties["aging"].(string)
+	return mode
+}
+
+// AgingWeeks returns after how many weeks without edits notes start to age and are fully aged
+func (ns *NoteSet) AgingWeeks() (start, full int) {
+	start, full = DefaultAgingStartWeeks, DefaultAgingFullWeeks
+	if weeks, ok := ns.Properties["aging_start_weeks"].(float64); ok && weeks >= 1 {
+		start = int(weeks)
+	}
+	if weeks, ok := ns.Properties["aging_full_weeks"].(float64); ok && weeks >= 1 {
+		full = int(weeks)
+	}
+	if full <= start {
+		full = start + 1
+	}
+	return start, full
+}
+
+// SetAging sets the aging effect and thresholds, and restyles the notes
+func (ns *NoteSet) SetAging(mode string, startWeeks, fullWeeks int) {
+	if mode == AgingOff {
+		delete(ns.Properties, "aging")
+	} else {
+		ns.Properties["aging"] = mode
+	}
+	ns.Properties["aging_start_weeks"] = float64(startWeeks)
+	ns.Properties["aging_full_weeks"] = float64(fullWeeks)
+	for _, note := range ns.Notes {
+		if note.GUI != nil {
+			note.GUI.LoadCSS()
+		}
+	}
+	ns.Save()
+}
+
+// weeksUntouched returns how many full weeks ago the note was last edited
+func (n *Note) weeksUntouched(now time.Time) int {
+	if n.LastModified.IsZero() {
+		return 0
+	}
+	return int(now.Sub(n.LastModified) / (7 * 24 * time.Hour))
+}
+
+// Age returns how far the note has aged, from 0 (recently edited) to 1 (fully aged)
+func (n *Note) Age(now time.Time) float64 {
+	if n.LastModified.IsZero() {
+		return 0
+	}
+	start, full := n.NoteSet.AgingWeeks()
+	weeks := now.Sub(n.LastModified).Hours() / (7 * 24)
+	return math.Max(0, math.Min(1, (weeks-float64(start))/float64(full-start)))
+}
+
+// currentAgeStep returns the note's fading step, 0 when it doesn't fade
+func (sn *StickyNote) currentAgeStep() int {
+	if sn.NoteSet.AgingMode() != AgingFade {
+		return 0
+	}
+	return int(math.Round(sn.Note.Age(time.Now()) * agingSteps))
+}
+
+// agedHSV returns the background color faded for the note's age, and remembers the step
+// it was styled for
+func (sn *StickyNote) agedHSV(hsv []float64) []float64 {
+	sn.ageStep = sn.currentAgeStep()
+	if sn.ageStep == 0 {
+		return hsv
+	}
+	fade := agingFadeMax * float64(sn.ageStep) / agingSteps
+	return []float64{hsv[0], hsv[1] * (1 - fade), hsv[2]}
+}
+
+// updateAgeBadge shows how long the note has been untouched, once it started to age
+func (sn *StickyNote) updateAgeBadge() {
+	if sn.LAge == nil {
+		return
+	}
+	start, _ := sn.NoteSet.AgingWeeks()
+	weeks := sn.Note.weeksUntouched(time.Now())
+	if sn.NoteSet.AgingMode() != AgingBadge || weeks < start {
+		sn.LAge.Hide()
+		return
+	}
+	sn.LAge.SetText(fmt.Sprintf("%d wk", weeks))
+	sn.LAge.SetTooltipText(fmt.Sprintf("Untouched for %d weeks, since %s", weeks, FormatDate(sn.Note.LastModified)))
+	sn.LAge.Show()
+}
+
+// refreshAge restyles the note when it faded another step, and updates its badge
+func (sn *StickyNote) refreshAge() {
+	if sn.WinMain == nil {
+		return
+	}
+	if sn.currentAgeStep() != sn.ageStep {
+		sn.LoadCSS()
+		return
+	}
+	sn.updateAgeBadge()
+}
+
+// WatchAging ages the shown notes as time goes by
+func WatchAging(ns *NoteSet) {
+	glib.TimeoutAdd(agingRefreshInterval, func() bool {
+		for _, note := range ns.Notes {
+			if note.GUI != nil {
+				note.GUI.refreshAge()
+			}
+		}
+		return true // Repeat
+	})
+}
+
+// agingSettings builds the aging row of the General settings
+func (sd *SettingsDialog) agingSettings() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	label, _ := gtk.LabelNew("Untouched notes")
+	label.SetHAlign(gtk.ALIGN_START)
+	box.PackStart(label, true, true, 0)
+
+	cbMode, _ := gtk.ComboBoxTextNew()
+	cbMode.Append(AgingOff, "Don't age")
+	cbMode.Append(AgingFade, "Fade")
+	cbMode.Append(AgingBadge, "Show a badge")
+	cbMode.SetActiveID(sd.NoteSet.AgingMode())
+	box.PackStart(cbMode, false, false, 0)
+
+	start, full := sd.NoteSet.AgingWeeks()
+	afterLabel, _ := gtk.LabelNew("after")
+	box.PackStart(afterLabel, false, false, 0)
+	spinStart, _ := gtk.SpinButtonNewWithRange(1, 520, 1)
+	spinStart.SetValue(float64(start))
+	spinStart.SetTooltipText("Weeks without edits before a note starts to age")
+	box.PackStart(spinStart, false, false, 0)
+	untilLabel, _ := gtk.LabelNew("to")
+	box.PackStart(untilLabel, false, false, 0)
+	spinFull, _ := gtk.SpinButtonNewWithRange(2, 521, 1)
+	spinFull.SetValue(float64(full))
+	spinFull.SetTooltipText("Weeks without edits until a note has faded completely")
+	box.PackStart(spinFull, false, false, 0)
+	weeksLabel, _ := gtk.LabelNew("weeks")
+	box.PackStart(weeksLabel, false, false, 0)
+
+	update := func() {
+		mode := cbMode.GetActiveID()
+		spinStart.SetSensitive(mode != AgingOff)
+		spinFull.SetSensitive(mode == AgingFade)
+		startWeeks, fullWeeks := spinStart.GetValueAsInt(), spinFull.GetValueAsInt()
+		if fullWeeks <= startWeeks {
+			fullWeeks = startWeeks + 1
+			spinFull.SetValue(float64(fullWeeks))
+		}
+		sd.NoteSet.SetAging(mode, startWeeks, fullWeeks)
+	}
+	spinStart.SetSensitive(cbMode.GetActiveID() != AgingOff)
+	spinFull.SetSensitive(cbMode.GetActiveID() == AgingFade)
+	cbMode.Connect("changed", update)
+	spinStart.Connect("value-changed", update)
+	spinFull.Connect("value-changed", update)
+
+	box.ShowAll()
+	return box
+}
@@ -0,0 +1,167 @@
+package stickynotes
+
+import (
+	"math"
+
+	"github.com/gotk3/gotk3/gdk"
+)
+
+// Arrangements of the visible notes on the primary monitor
+const (
+	ArrangeCascade = "cascade" // Overlapping diagonally from the top left
+	ArrangeTile    = "tile"    // Side by side in a grid
+	ArrangeStack   = "stack"   // Overlapping along the right edge, only the tops showing
+)
+
+const (
+	// arrangeMargin is the space (px) kept around and between arranged notes
+	arrangeMargin = 16
+	// arrangeCascadeStep is the offset (px) between cascaded notes
+	arrangeCascadeStep = 32
+	// arrangeStackStep is the offset (px) between stacked notes, enough to show the top bar
+	arrangeStackStep = 48
+	// noteMinSize is the smallest width and height (px) a restored note gets
+	noteMinSize = 60
+)
+
+// Arrange moves all visible notes into the given arrangement
+func (ns *NoteSet) Arrange(arrangement string) {
+	var notes []*StickyNote
+	for _, note := range ns.Notes {
+		if isNoteShown(note) {
+			notes = append(notes, note.GUI)
+		}
+	}
+	if len(notes) == 0 {
+		return
+	}
+	areaX, areaY, areaW, areaH := workarea()
+
+	switch arrangement {
+	case ArrangeCascade:
+		// Start over from the top when running off the screen
+		perRun := max(1, (areaH-arrangeMargin*2)/2/arrangeCascadeStep)
+		for i, sn := range notes {
+			offset := (i%perRun)*arrangeCascadeStep + (i/perRun)*arrangeMargin
+			sn.moveTo(areaX+arrangeMargin+offset, areaY+arrangeMargin+(i%perRun)*arrangeCascadeStep)
+		}
+	case ArrangeTile:
+		cols := int(math.Ceil(math.Sqrt(float64(len(notes)))))
+		rows := (len(notes) + cols - 1) / cols
+		cellW := (areaW - arrangeMargin*(cols+1)) / cols
+		cellH := (areaH - arrangeMargin*(rows+1)) / rows
+		for i, sn := range notes {
+			col, row := i%cols, i/cols
+			sn.moveTo(areaX+arrangeMargin+col*(cellW+arrangeMargin), areaY+arrangeMargin+row*(cellH+arrangeMargin))
+		}
+	case ArrangeStack:
+		for i, sn := range notes {
+			width, _ := sn.WinMain.GetSize()
+			sn.moveTo(areaX+areaW-arrangeMargin-width, areaY+arrangeMargin+i*arrangeStackStep)
+			// Later notes cover the earlier ones, only their tops stay visible
+			sn.Raise()
+		}
+	default:
+		return
+	}
+	ns.Save()
+}
+
+// workarea returns the usable area of the primary monitor (the first one when none is
+// marked primary, as on Wayland)
+func workarea() (x, y, width, height int) {
+	display, err := gdk.DisplayGetDefault()
+	if err == nil {
+		monitor, err := display.GetPrimaryMonitor()
+		if err != nil {
+			monitor, err = display.GetMonitor(0)
+		}
+		if err == nil {
+			return monitor.GetWorkarea().GetRectangleInt()
+		}
+	}
+	return 0, 0, 1920, 1080
+}
+
+// workareaAt returns the usable area (x, y, width, height) of the monitor at the point,
+// or of the primary monitor when none is there
+func workareaAt(x, y int) [4]int {
+	if display, err := gdk.DisplayGetDefault(); err == nil {
+		if monitor, err := display.GetMonitorAtPoint(x, y); err == nil {
+			areaX, areaY, areaW, areaH := monitor.GetWorkarea().GetRectangleInt()
+			return [4]int{areaX, areaY, areaW, areaH}
+		}
+	}
+	areaX, areaY, areaW, areaH := workarea()
+	return [4]int{areaX, areaY, areaW, areaH}
+}
+
+// clampToArea fits a note of size at pos into area (x, y, width, height): no larger than
+// the area, no smaller than noteMinSize, and fully inside it
+func clampToArea(pos, size [2]int, area [4]int) ([2]int, [2]int) {
+	for i := range 2 {
+		size[i] = max(min(size[i], area[2+i]), noteMinSize)
+		pos[i] = max(min(pos[i], area[i]+area[2+i]-size[i]), area[i])
+	}
+	return pos, size
+}
+
+// moveTo moves the note window through the window backend
+func (sn *StickyNote) moveTo(x, y int) {
+	Backend().Move(sn, x, y)
+	sn.LastKnownPos = [2]int{x, y}
+}
+
+// BringOnScreen moves the notes stranded off the monitors (after a resolution or monitor
+// change) onto the nearest one, shrunk to fit when larger; hidden notes are put there when
+// shown again. Notes pinned to a corner are laid out again instead. Returns how many notes
+// were moved.
+func (ns *NoteSet) BringOnScreen() int {
+	moved := 0
+	for _, note := range ns.Notes {
+		if note.Corner() != CornerNone {
+			continue
+		}
+		if isNoteShown(note) {
+			sn := note.GUI
+			pos, size := sn.LastKnownPos, sn.LastKnownSize
+			if size[0] <= 1 || size[1] <= 1 {
+				size[0], size[1] = sn.WinMain.GetSize()
+			}
+			newPos, newSize := clampToArea(pos, size, workareaAt(pos[0]+size[0]/2, pos[1]+size[1]/2))
+			if newPos == pos && newSize == size {
+				continue
+			}
+			if newSize != size {
+				sn.WinMain.Resize(newSize[0], newSize[1])
+				sn.LastKnownSize = newSize
+			}
+			sn.moveTo(newPos[0], newPos[1])
+			moved++
+			continue
+		}
+		saved, ok := floatList(note.Properties["position"])
+		if !ok || len(saved) < 2 {
+			continue
+		}
+		pos, size := [2]int{int(saved[0]), int(saved[1])}, [2]int{200, 150}
+		if savedSize, ok := floatList(note.Properties["size"]); ok && len(savedSize) >= 2 {
+			size = [2]int{int(savedSize[0]), int(savedSize[1])}
+		}
+		newPos, newSize := clampToArea(pos, size, workareaAt(pos[0]+size[0]/2, pos[1]+size[1]/2))
+		if newPos == pos && newSize == size {
+			continue
+		}
+		note.Properties["position"] = []interface{}{float64(newPos[0]), float64(newPos[1])}
+		note.Properties["size"] = []interface{}{float64(newSize[0]), float64(newSize[1])}
+		if note.GUI != nil {
+			note.GUI.LastKnownPos, note.GUI.LastKnownSize = newPos, newSize
+		}
+		moved++
+	}
+	ns.scheduleCornerLayout()
+	if moved > 0 {
+		ns.Save()
+	}
+	return moved
+}
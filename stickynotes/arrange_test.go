@@ -0,0 +1,23 @@
+package stickynotes
+
+import "testing"
+
+func TestClampToArea(t *testing.T) {
+	area := [4]int{1920, 0, 1920, 1040}
+	tests := []struct {
+		pos, size         [2]int
+		wantPos, wantSize [2]int
+	}{
+		{[2]int{2000, 100}, [2]int{200, 150}, [2]int{2000, 100}, [2]int{200, 150}},   // Inside
+		{[2]int{3800, 1000}, [2]int{200, 150}, [2]int{3640, 890}, [2]int{200, 150}},  // Past the bottom right
+		{[2]int{100, -50}, [2]int{200, 150}, [2]int{1920, 0}, [2]int{200, 150}},      // On the monitor to the left
+		{[2]int{2000, 100}, [2]int{5000, 3000}, [2]int{1920, 0}, [2]int{1920, 1040}}, // Larger than the monitor
+		{[2]int{2000, 100}, [2]int{1, 1}, [2]int{2000, 100}, [2]int{noteMinSize, noteMinSize}},
+	}
+	for _, tt := range tests {
+		pos, size := clampToArea(tt.pos, tt.size, area)
+		if pos != tt.wantPos || size != tt.wantSize {
+			t.Errorf("clampToArea(%v, %v) = %v, %v, want %v, %v", tt.pos, tt.size, pos, size, tt.wantPos, tt.wantSize)
+		}
+	}
+}
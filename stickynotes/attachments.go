@@ -0,0 +1,241 @@
+package stickynotes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// Attachments are stored as plain files next to the data file, in
+// "<data file>-attachments/<note uuid>/", so they can be opened directly.
+const attachmentsSuffix = "-attachments"
+
+// AttachmentsDir returns the directory holding the attachments of all notes
+func (ns *NoteSet) AttachmentsDir() string {
+	return ns.DataPath() + attachmentsSuffix
+}
+
+// AttachmentDir returns the directory holding the note's attachments
+func (n *Note) AttachmentDir() string {
+	return filepath.Join(n.NoteSet.AttachmentsDir(), n.UUID)
+}
+
+// Attachments returns the file names attached to the note, sorted
+func (n *Note) Attachments() []string {
+	entries, err := os.ReadDir(n.AttachmentDir())
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Attach copies a file into the note's attachments, renaming it if the name is taken.
+// Returns the attachment's name.
+func (n *Note) Attach(src string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(n.AttachmentDir(), 0755); err != nil {
+		return "", err
+	}
+	name := n.freeAttachmentName(filepath.Base(src))
+	out, err := os.Create(filepath.Join(n.AttachmentDir(), name))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return "", err
+	}
+	return name, out.Close()
+}
+
+// freeAttachmentName returns name, or "name (2).ext" etc. if an attachment already uses it
+func (n *Note) freeAttachmentName(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(n.AttachmentDir(), candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+// RemoveAttachment deletes an attachment, and the note's attachment directory once empty
+func (n *Note) RemoveAttachment(name string) error {
+	if err := os.Remove(filepath.Join(n.AttachmentDir(), filepath.Base(name))); err != nil {
+		return err
+	}
+	os.Remove(n.AttachmentDir()) // Only succeeds when empty
+	return nil
+}
+
+// RemoveAttachments deletes all of the note's attachments
+func (n *Note) RemoveAttachments() {
+	if !validNoteUUID(n.UUID) {
+		return // Never remove anything outside AttachmentsDir
+	}
+	os.RemoveAll(n.AttachmentDir())
+}
+
+// exportAttachments encodes the attachments of the notes for an export file, keyed by note
+// UUID and file name
+func (ns *NoteSet) exportAttachments(notes []*Note) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, note := range notes {
+		files := make(map[string]interface{})
+		for _, name := range note.Attachments() {
+			data, err := os.ReadFile(filepath.Join(note.AttachmentDir(), name))
+			if err != nil {
+				continue
+			}
+			files[name] = base64.StdEncoding.EncodeToString(data)
+		}
+		if len(files) > 0 {
+			result[note.UUID] = files
+		}
+	}
+	return result
+}
+
+// importAttachments writes the attachments of an export file, keeping existing files
+// with the same name
+func (ns *NoteSet) importAttachments(attachments map[string]interface{}) {
+	for noteUUID, filesData := range attachments {
+		files, ok := filesData.(map[string]interface{})
+		noteUUID = filepath.Base(noteUUID)
+		if !ok || noteUUID == "." || noteUUID == ".." {
+			continue
+		}
+		dir := filepath.Join(ns.AttachmentsDir(), noteUUID)
+		for name, encoded := range files {
+			str, _ := encoded.(string)
+			data, err := base64.StdEncoding.DecodeString(str)
+			name = filepath.Base(name)
+			if err != nil || name == "." || name == ".." {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				continue
+			}
+			os.WriteFile(path, data, 0644)
+		}
+	}
+}
+
+// Export serializes the noteset like Dumps, with the attachments embedded
+func (ns *NoteSet) Export() string {
+	data := ns.dumpData()
+	if attachments := ns.exportAttachments(ns.Notes); len(attachments) > 0 {
+		data["attachments"] = attachments
+	}
+	return marshalNoteSet(data)
+}
+
+// RefreshAttachments rebuilds the attachment chips under the text view
+func (sn *StickyNote) RefreshAttachments() {
+	if sn.BoxAttachments == nil {
+		return
+	}
+	sn.BoxAttachments.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			sn.BoxAttachments.Remove(widget)
+		}
+	})
+
+	names := sn.Note.Attachments()
+	for _, name := range names {
+		sn.BoxAttachments.Add(sn.attachmentChip(name))
+	}
+	sn.BoxAttachments.ShowAll()
+	sn.BoxAttachments.SetVisible(len(names) > 0)
+}
+
+// attachmentChip creates the chip for an attachment: its name opens it, the cross removes it
+func (sn *StickyNote) attachmentChip(name string) gtk.IWidget {
+	chip, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 0)
+
+	bOpen, _ := gtk.ButtonNewWithLabel(name)
+	bOpen.SetRelief(gtk.RELIEF_NONE)
+	bOpen.SetFocusOnClick(false)
+	bOpen.SetTooltipText("Open " + name)
+	if label, err := bOpen.GetChild(); err == nil {
+		if l, ok := label.(*gtk.Label); ok {
+			l.SetMaxWidthChars(20)
+			l.SetEllipsize(pango.ELLIPSIZE_END)
+		}
+	}
+	bOpen.Connect("clicked", func() {
+		path := filepath.Join(sn.Note.AttachmentDir(), name)
+		if err := exec.Command("xdg-open", path).Start(); err != nil {
+			fmt.Printf("Error opening attachment %s: %v\n", path, err)
+		}
+	})
+	chip.PackStart(bOpen, false, false, 0)
+
+	bRemove, _ := gtk.ButtonNewFromIconName("window-close-symbolic", gtk.ICON_SIZE_MENU)
+	bRemove.SetRelief(gtk.RELIEF_NONE)
+	bRemove.SetFocusOnClick(false)
+	bRemove.SetTooltipText("Remove attachment")
+	bRemove.SetSensitive(!sn.Locked)
+	bRemove.Connect("clicked", func() {
+		dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Remove the attachment \"%s\"?", name)
+		dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+		dialog.AddButton("Remove", gtk.RESPONSE_ACCEPT)
+		response := dialog.Run()
+		dialog.Destroy()
+		if response != gtk.RESPONSE_ACCEPT {
+			return
+		}
+		if err := sn.Note.RemoveAttachment(name); err != nil {
+			fmt.Printf("Error removing attachment %s: %v\n", name, err)
+		}
+		sn.RefreshAttachments()
+	})
+	chip.PackStart(bRemove, false, false, 0)
+
+	return chip
+}
+
+// onAttachFile lets the user pick a file to attach to the note
+func (sn *StickyNote) onAttachFile() {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Attach File", sn.WinMain, gtk.FILE_CHOOSER_ACTION_OPEN, "Cancel", gtk.RESPONSE_CANCEL, "Attach", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	file := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT || file == "" {
+		return
+	}
+	if _, err := sn.Note.Attach(file); err != nil {
+		dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error attaching file.")
+		dialog.FormatSecondaryText("%v", err)
+		dialog.Run()
+		dialog.Destroy()
+	}
+	sn.RefreshAttachments()
+}
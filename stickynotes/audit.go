@@ -0,0 +1,276 @@
+package stickynotes
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Everything that deletes or overwrites notes is recorded in "<data file>-audit.log",
+// one JSON entry per line, only ever appended to: deletions, notes purged from the
+// trash, notes merged or replaced by an import, deleted categories and restored
+// backups. An entry has the note's UUID and a hash of the text it had, and its title
+// unless the data file is encrypted, so "where did my note go?" has an answer. The
+// Deletion History window lists them.
+
+const auditSuffix = "-audit.log"
+
+// Audited operations
+const (
+	AuditDelete         = "delete"          // Moved to the trash
+	AuditPurge          = "purge"           // Deleted from the trash for good
+	AuditMerge          = "merge"           // Appended to another note, then moved to the trash
+	AuditImportReplace  = "import-replace"  // Replaced by the note with the same UUID in an import
+	AuditCategoryDelete = "category-delete" // Category deleted, its notes went to the default one
+	AuditRestoreBackup  = "restore-backup"  // All notes replaced by a backup's
+)
+
+// auditLabels describe the operations in the Deletion History window
+var auditLabels = map[string]string{
+	AuditDelete:         "Moved to the trash",
+	AuditPurge:          "Deleted for good",
+	AuditMerge:          "Merged",
+	AuditImportReplace:  "Replaced by an import",
+	AuditCategoryDelete: "Category deleted",
+	AuditRestoreBackup:  "Backup restored",
+}
+
+// AuditEntry is an operation recorded in the audit log
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	UUID     string    `json:"uuid,omitempty"`      // Of the note, or the category
+	Title    string    `json:"title,omitempty"`     // Not recorded for an encrypted data file
+	BodyHash string    `json:"body_hash,omitempty"` // Of the text the note had
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// AuditLogPath returns the audit log of the data file
+func (ns *NoteSet) AuditLogPath() string {
+	return ns.DataPath() + auditSuffix
+}
+
+// bodyHash identifies a note text in the audit log
+func bodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:8])
+}
+
+// audit appends an operation on note (nil for other operations) to the audit log. A
+// failure is only logged, it doesn't stop the operation.
+func (ns *NoteSet) audit(action string, note *Note, detail string) {
+	entry := AuditEntry{Time: time.Now(), Action: action, Detail: detail}
+	if note != nil {
+		entry.UUID = note.UUID
+		entry.BodyHash = bodyHash(note.Body)
+		if !ns.Encrypted() {
+			entry.Title = noteLabel(note)
+		}
+	}
+	ns.appendAudit(entry)
+}
+
+// auditCategory records the deletion of a category
+func (ns *NoteSet) auditCategory(cat string, notes int) {
+	entry := AuditEntry{Time: time.Now(), Action: AuditCategoryDelete, UUID: cat, Detail: fmt.Sprintf("%d notes moved to the default category", notes)}
+	if !ns.Encrypted() {
+		entry.Title = ns.CategoryName(cat)
+	}
+	ns.appendAudit(entry)
+}
+
+func (ns *NoteSet) appendAudit(entry AuditEntry) {
+	if ns.DataFile == "" {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(ns.AuditLogPath(), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600); err == nil {
+			// Start a new line after one cut short by a crash
+			last := make([]byte, 1)
+			if info, statErr := f.Stat(); statErr == nil && info.Size() > 0 {
+				if _, readErr := f.ReadAt(last, info.Size()-1); readErr == nil && last[0] != '\n' {
+					line = append([]byte{'\n'}, line...)
+				}
+			}
+			_, err = f.Write(append(line, '\n'))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("[Audit] Failed to record %s of %s: %v\n", entry.Action, entry.UUID, err)
+	}
+}
+
+// AuditLog returns the recorded operations, oldest first. Lines that can't be read
+// (e.g. cut short by a crash) are skipped.
+func (ns *NoteSet) AuditLog() ([]AuditEntry, error) {
+	f, err := os.Open(ns.AuditLogPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Action != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// AuditWindow lists the audit log, newest first
+type AuditWindow struct {
+	NoteSet *NoteSet
+	Window  *gtk.Window
+	List    *gtk.ListBox
+	Search  *gtk.SearchEntry
+	rows    map[int]AuditEntry // ListBox row index to entry
+}
+
+// NewAuditWindow opens the Deletion History window
+func NewAuditWindow(noteset *NoteSet) *AuditWindow {
+	aw := &AuditWindow{
+		NoteSet: noteset,
+		rows:    make(map[int]AuditEntry),
+	}
+
+	aw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	aw.Window.SetTitle("Deletion History")
+	aw.Window.SetDefaultSize(520, 420)
+	aw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	aw.Search, _ = gtk.SearchEntryNew()
+	aw.Search.SetPlaceholderText("Search by title or UUID")
+	aw.Search.Connect("search-changed", aw.refresh)
+	box.PackStart(aw.Search, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	aw.List, _ = gtk.ListBoxNew()
+	aw.List.SetSelectionMode(gtk.SELECTION_SINGLE)
+	aw.List.Connect("row-activated", func(list *gtk.ListBox, row *gtk.ListBoxRow) {
+		if entry, ok := aw.rows[row.GetIndex()]; ok {
+			aw.open(entry)
+		}
+	})
+	scrolled.Add(aw.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	hint, _ := gtk.LabelNew("Double-click a note to bring it up, or back from the trash.")
+	hint.SetHAlign(gtk.ALIGN_START)
+	hint.SetLineWrap(true)
+	box.PackStart(hint, false, false, 0)
+
+	aw.Window.Add(box)
+	aw.refresh()
+	aw.Window.ShowAll()
+
+	return aw
+}
+
+// refresh rebuilds the list from the audit log, keeping the entries matching the search
+func (aw *AuditWindow) refresh() {
+	aw.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			aw.List.Remove(widget)
+		}
+	})
+	aw.rows = make(map[int]AuditEntry)
+
+	entries, err := aw.NoteSet.AuditLog()
+	query, _ := aw.Search.GetText()
+	query = strings.ToLower(strings.TrimSpace(query))
+	shown := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if query != "" && !strings.Contains(strings.ToLower(entry.Title), query) && !strings.HasPrefix(entry.UUID, query) {
+			continue
+		}
+		action := auditLabels[entry.Action]
+		if action == "" {
+			action = entry.Action
+		}
+		title := entry.Title
+		if title == "" {
+			title = entry.UUID
+		}
+		if title == "" {
+			title = action
+		}
+		details := []string{action, FormatDateTime(entry.Time.Local())}
+		if entry.Detail != "" {
+			details = append(details, entry.Detail)
+		}
+		label, _ := gtk.LabelNew("")
+		label.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s</small>",
+			glib.MarkupEscapeText(title), glib.MarkupEscapeText(strings.Join(details, " · "))))
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		if entry.UUID != "" {
+			row.SetTooltipText(fmt.Sprintf("UUID %s\nText hash %s", entry.UUID, entry.BodyHash))
+		}
+		aw.List.Add(row)
+		aw.rows[row.GetIndex()] = entry
+		shown++
+	}
+
+	if shown == 0 {
+		text := "Nothing was deleted yet"
+		if err != nil {
+			text = "The deletion history can't be read: " + err.Error()
+		} else if query != "" {
+			text = "No matches"
+		}
+		label, _ := gtk.LabelNew(text)
+		label.SetMarginTop(12)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		row.SetSelectable(false)
+		row.SetActivatable(false)
+		aw.List.Add(row)
+	}
+	aw.List.ShowAll()
+}
+
+// open brings up the entry's note, restoring it when it is in the trash
+func (aw *AuditWindow) open(entry AuditEntry) {
+	if note := aw.NoteSet.noteByUUID(entry.UUID); note != nil {
+		note.Show()
+		return
+	}
+	for _, note := range aw.NoteSet.Trash {
+		if note.UUID == entry.UUID {
+			note.Restore()
+			note.Show()
+			return
+		}
+	}
+	dialog := gtk.MessageDialogNew(aw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_INFO, gtk.BUTTONS_CLOSE, "This note is gone.")
+	dialog.FormatSecondaryText("It isn't in the notes or the trash anymore. Restore from Backup… may still have it.")
+	dialog.Run()
+	dialog.Destroy()
+}
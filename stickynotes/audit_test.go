@@ -0,0 +1,68 @@
+package stickynotes
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if entries, err := ns.AuditLog(); err != nil || len(entries) != 0 {
+		t.Fatalf("AuditLog before anything was deleted = %v, %v", entries, err)
+	}
+
+	findNote(t, ns, "note-0001").Delete()
+	ns.EmptyTrash()
+
+	entries, err := ns.AuditLog()
+	if err != nil {
+		t.Fatalf("AuditLog: %v", err)
+	}
+	want := []struct{ action, uuid string }{
+		{AuditDelete, "note-0001"},
+		{AuditPurge, "note-0003"},
+		{AuditPurge, "note-0004"},
+		{AuditPurge, "note-0001"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("audit log has %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		if entries[i].Action != w.action || entries[i].UUID != w.uuid {
+			t.Errorf("entry %d = %s %s, want %s %s", i, entries[i].Action, entries[i].UUID, w.action, w.uuid)
+		}
+	}
+	if entries[0].Title != "Shopping" || entries[0].BodyHash != bodyHash("Shopping\nmilk") {
+		t.Errorf("delete entry = %+v, want the title and hash of the deleted text", entries[0])
+	}
+
+	// A line cut short by a crash is skipped, the log is still appended to
+	f, err := os.OpenFile(ns.AuditLogPath(), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time": "2024-`)
+	f.Close()
+	ns.audit(AuditRestoreBackup, nil, "")
+	if entries, err = ns.AuditLog(); err != nil || len(entries) != len(want)+1 || entries[len(want)].Action != AuditRestoreBackup {
+		t.Errorf("AuditLog after a damaged line = %+v, %v; want the entries before and after it", entries, err)
+	}
+}
+
+func TestAuditImportReplace(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if err := ns.Merge(`{"notes": [{"uuid": "note-0001", "body": "Shopping\nbread"}, {"uuid": "note-0002", "body": ""}]}`); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	entries, _ := ns.AuditLog()
+	if len(entries) != 1 || entries[0].Action != AuditImportReplace || entries[0].UUID != "note-0001" ||
+		entries[0].BodyHash != bodyHash("Shopping\nmilk") {
+		t.Errorf("audit log after an import = %+v, want note-0001 replaced", entries)
+	}
+}
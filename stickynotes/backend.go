@@ -1,23 +1,46 @@
 package stickynotes
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gotk3/gotk3/glib"
 )
 
 // Note represents a single sticky note
 type Note struct {
-	UUID         string
-	Body         string
-	Properties   map[string]interface{}
-	Category     string
-	LastModified time.Time
-	GUI          *StickyNote
-	NoteSet      *NoteSet
+	UUID          string
+	Body          string
+	Properties    map[string]interface{}
+	Category      string
+	Tags          []string
+	Formatting    []FormatRange // Strikethrough etc., kept in sync with the text view
+	LastModified  time.Time
+	DeletedAt     time.Time              // When the note was moved to the trash, zero otherwise
+	Revision      int                    // Bumped on every edit, to tell which copy of a shared data file is newer
+	EditedOn      string                 // Device the note was last edited on
+	RemoteEdit    *RemoteEdit            // Last change taken in from another device, nil once edited here
+	versionAt     time.Time              // When a version was last added to the history
+	savedRevision int                    // Revision in the data file when it was last read or written
+	onDisk        bool                   // The note was in the data file when it was last read or written
+	deletePending bool                   // Deleted, but the deletion can still be undone
+	extra         map[string]interface{} // Keys this version doesn't know (e.g. the Python app's), written back unchanged
+	GUI           *StickyNote
+	NoteSet       *NoteSet
+}
+
+// validNoteUUID reports whether u can be a note's UUID: 8 characters or more, and safe
+// to use as a file name
+func validNoteUUID(u string) bool {
+	return len(u) >= 8 && filepath.Base(u) == u && !strings.ContainsAny(u, `/\`)
 }
 
 // NewNote creates a new note
@@ -40,11 +63,37 @@ func NewNote(content map[string]interface{}, guiClass func(*Note) *StickyNote, n
 		if cat, ok := content["cat"].(string); ok && cat != "" {
 			note.Category = cat
 		}
+		if tags, ok := content["tags"].([]interface{}); ok {
+			note.Tags = tagList(tags)
+		}
+		if formatting, ok := content["formatting"].([]interface{}); ok {
+			note.Formatting = formatRangeList(formatting)
+		}
+		// Timestamps are saved as local wall-clock time (see Extract)
 		if lastMod, ok := content["last_modified"].(string); ok {
-			if t, err := time.ParseInLocation("2006-01-02T15:04:05", lastMod, time.UTC); err == nil {
+			if t, err := time.ParseInLocation("2006-01-02T15:04:05", lastMod, time.Local); err == nil {
 				note.LastModified = t
 			}
 		}
+		if deletedAt, ok := content["deleted_at"].(string); ok {
+			if t, err := time.ParseInLocation("2006-01-02T15:04:05", deletedAt, time.Local); err == nil {
+				note.DeletedAt = t
+			}
+		}
+		if rev, ok := content["rev"].(float64); ok {
+			note.Revision = int(rev)
+		}
+		if editedOn, ok := content["edited_on"].(string); ok {
+			note.EditedOn = editedOn
+		}
+		for key, val := range content {
+			if !noteKeys[key] {
+				if note.extra == nil {
+					note.extra = make(map[string]interface{})
+				}
+				note.extra[key] = val
+			}
+		}
 	}
 
 	// Only set category from parameter if it wasn't loaded from JSON
@@ -55,16 +104,29 @@ func NewNote(content map[string]interface{}, guiClass func(*Note) *StickyNote, n
 	// Don't clear category if it doesn't exist - GetCategoryProperty will handle it gracefully
 	// Keep the category string so each note can have its own category
 
-	if note.UUID == "" {
+	// Windows and logs show the first 8 characters, shorter ones only come from
+	// hand-edited or damaged files. The UUID also names the note's attachment directory
+	// and history file, so one that isn't a plain file name is replaced too.
+	if !validNoteUUID(note.UUID) {
 		note.UUID = uuid.New().String()
 	}
 	if note.LastModified.IsZero() {
 		note.LastModified = time.Now()
 	}
+	// A new note, rather than one read or imported
+	if content == nil {
+		note.Properties["created"] = note.LastModified.Format(time.RFC3339)
+	}
 
 	return note
 }
 
+// noteKeys are the keys of a saved note that NewNote reads
+var noteKeys = map[string]bool{
+	"uuid": true, "body": true, "properties": true, "cat": true, "tags": true, "formatting": true,
+	"last_modified": true, "deleted_at": true, "rev": true, "edited_on": true,
+}
+
 // Extract converts the note to a map for JSON serialization
 func (n *Note) Extract() map[string]interface{} {
 	if n.GUI != nil {
@@ -72,34 +134,125 @@ func (n *Note) Extract() map[string]interface{} {
 		n.Properties = n.GUI.Properties()
 	}
 
-	return map[string]interface{}{
-		"uuid":          n.UUID,
-		"body":          n.Body,
-		"last_modified": n.LastModified.Format("2006-01-02T15:04:05"),
-		"properties":    n.Properties,
-		"cat":           n.Category,
+	content := make(map[string]interface{}, len(n.extra)+6)
+	for key, val := range n.extra {
+		content[key] = val
+	}
+	content["uuid"] = n.UUID
+	content["body"] = n.Body
+	content["last_modified"] = n.LastModified.Format("2006-01-02T15:04:05")
+	content["properties"] = n.Properties
+	content["cat"] = n.Category
+	content["tags"] = n.Tags
+	if len(n.Formatting) > 0 {
+		content["formatting"] = n.Formatting
 	}
+	if n.Revision > 0 {
+		content["rev"] = n.Revision
+		content["edited_on"] = n.EditedOn
+	}
+	if !n.DeletedAt.IsZero() {
+		content["deleted_at"] = n.DeletedAt.Format("2006-01-02T15:04:05")
+	}
+	return content
 }
 
 // Update updates the note's body
+// LastModified is only bumped when the body actually changed
 func (n *Note) Update(body string) {
+	if body == n.Body {
+		return
+	}
+	n.snapshotBeforeEdit(body)
 	n.Body = body
 	n.LastModified = time.Now()
+	n.Revision++
+	n.EditedOn = deviceName()
 }
 
-// Delete removes the note from its noteset
+// FirstLine returns the first non-empty line of the note body, used as its title
+func (n *Note) FirstLine() string {
+	for _, line := range strings.Split(n.Body, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// SetTags replaces the note's tags
+func (n *Note) SetTags(tags []string) {
+	n.Tags = tags
+	n.LastModified = time.Now()
+}
+
+// HasTag reports whether the note carries the given tag (case-insensitive)
+func (n *Note) HasTag(tag string) bool {
+	for _, t := range n.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTags splits a comma separated tag string into a clean tag list.
+// Leading '#' characters are stripped and duplicates are dropped.
+func ParseTags(text string) []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(text, ",") {
+		tag := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(part), "#"))
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// tagList converts a JSON-decoded tag array to a clean tag list
+func tagList(list []interface{}) []string {
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		if tag, ok := item.(string); ok {
+			parts = append(parts, tag)
+		}
+	}
+	return ParseTags(strings.Join(parts, ","))
+}
+
+// Delete moves the note from its noteset to the trash, from where it can be restored
+// (attachments are kept until the trash is emptied)
 func (n *Note) Delete() {
+	n.deleteAs(AuditDelete, "")
+}
+
+// deleteAs moves the note to the trash, recording it in the audit log as action
+func (n *Note) deleteAs(action, detail string) {
 	for i, note := range n.NoteSet.Notes {
 		if note == n {
 			n.NoteSet.Notes = append(n.NoteSet.Notes[:i], n.NoteSet.Notes[i+1:]...)
 			break
 		}
 	}
+	n.DeletedAt = time.Now()
+	n.NoteSet.Trash = append(n.NoteSet.Trash, n)
+	n.NoteSet.audit(action, n, detail)
 	n.NoteSet.Save()
+	n.NoteSet.Feedback(FeedbackNoteDeleted)
 }
 
 // Show displays the note's GUI
 func (n *Note) Show() {
+	if n.deletePending {
+		return
+	}
+	// A note hidden in a collapsed group is listed on the group's header
+	if header := n.collapsedUnder(); header != nil {
+		n = header
+	}
 	if n.GUI == nil {
 		n.GUI = NewStickyNote(n)
 	} else {
@@ -114,6 +267,7 @@ func (n *Note) Show() {
 			n.GUI.Show()
 		}
 	}
+	n.NoteSet.scheduleCornerLayout()
 }
 
 // Hide hides the note's GUI
@@ -140,30 +294,124 @@ func (n *Note) CatProp(prop string) interface{} {
 // NoteSet manages a collection of notes
 type NoteSet struct {
 	Notes      []*Note
+	Trash      []*Note // Deleted notes, kept until restored or purged
 	Properties map[string]interface{}
 	Categories map[string]map[string]interface{}
 	DataFile   string
 	Indicator  interface{} // Use interface{} to avoid circular dependency
+
+	windows        *WindowSnapshot   // Shared by the notes while their windows get IDs assigned
+	windowIDs      WindowIDRegistry  // Window IDs the notes are matched with
+	cornerLayoutID glib.SourceHandle // Pending LayoutCorners, 0 when none
+
+	fullscreenHidden map[*Note]bool // Hidden while a fullscreen application has the focus, see checkFullscreen
+
+	reminderNotifications map[uint32]string         // Notification id to note UUID, for clicks
+	dataModTime           time.Time                 // Modification time of the data file when last read or written
+	savedSum              [sha256.Size]byte         // Of the notes as last read from or written to the data file
+	savedKey              *encryptionKey            // Encryption of the data file as last read or written
+	dataDamaged           bool                      // The data file on disk couldn't be read, don't back it up
+	saveFailed            bool                      // The last save failed, and it was reported
+	encryption            *encryptionKey            // Key the data file is encrypted with, nil when it isn't
+	passphrase            string                    // Passphrase the data file is decrypted with
+	actions               []*Action                 // Registered by the indicator, see RegisterAction
+	loaded                bool                      // Open or LoadFresh completed, saving can't lose notes
+	readOnly              bool                      // Another instance writes the data file, Save doesn't
+	extra                 map[string]interface{}    // Top-level keys this version doesn't know, written back unchanged
+	remoteSyncing         bool                      // A sync with the notes server is running
+	remoteSyncError       string                    // Why the last sync with the notes server failed, "" when it didn't
+	remoteSyncDone        []func(SyncResult, error) // Called when the running sync finishes
+	webdav                webdavSync                // WebDAV sync of the data file
+	saver                 *saveWriter               // Writes the data file in the background, nil to write it in Save
+	menuKey               string                    // What the indicator's menus showed at the last NotesChanged, see notesMenuKey
+	usage                 map[string]interface{}    // Usage insights, read from their file on first use (see usage.go)
+
+	// NotesChanged is called after a save that added, removed or retitled notes, or
+	// changed the categories, for the indicator to rebuild its menus
+	NotesChanged func()
+	// Recovered is set when the data file was damaged and the notes were loaded from its backup
+	Recovered error
+	// MergeConflicts are the notes the last Merge found changed both here and in the import
+	MergeConflicts []MergeConflict
 }
 
 // NewNoteSet creates a new noteset
 func NewNoteSet(dataFile string, indicator interface{}) *NoteSet {
-	return &NoteSet{
+	ns := &NoteSet{
 		Notes:      make([]*Note, 0),
 		Properties: make(map[string]interface{}),
 		Categories: make(map[string]map[string]interface{}),
 		DataFile:   dataFile,
 		Indicator:  indicator,
 	}
+	ns.registerNoteActions()
+	return ns
+}
+
+// noteSetParts are the top-level parts of a data file or an export, with their JSON type
+var noteSetParts = []struct{ key, kind string }{
+	{"notes", "list"},
+	{"trash", "list"},
+	{"categories", "object"},
+	{"properties", "object"},
+	{"attachments", "object"},
+}
+
+// isNoteSetPart reports whether key is one of noteSetParts
+func isNoteSetPart(key string) bool {
+	for _, part := range noteSetParts {
+		if part.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNoteSet decodes the JSON of a data file or an export, checking the types of its
+// parts so that a damaged or foreign file is rejected as a whole rather than half loaded
+func parseNoteSet(data string) (map[string]interface{}, error) {
+	var jdata map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &jdata); err != nil {
+		return nil, err
+	}
+	if jdata == nil {
+		return nil, errors.New("not a notes file: expected a JSON object")
+	}
+	for _, part := range noteSetParts {
+		switch jdata[part.key].(type) {
+		case nil:
+			// Absent, or null for none
+			continue
+		case []interface{}:
+			if part.kind == "list" {
+				continue
+			}
+		case map[string]interface{}:
+			if part.kind == "object" {
+				continue
+			}
+		}
+		return nil, fmt.Errorf("not a notes file: %q is not a JSON %s", part.key, part.kind)
+	}
+	return jdata, nil
 }
 
 // Loads parses JSON and loads notes
 func (ns *NoteSet) Loads(snoteset string) error {
-	var notes map[string]interface{}
-	if err := json.Unmarshal([]byte(snoteset), &notes); err != nil {
+	notes, err := parseNoteSet(snoteset)
+	if err != nil {
 		return err
 	}
 
+	ns.extra = nil
+	for key, val := range notes {
+		if !isNoteSetPart(key) {
+			if ns.extra == nil {
+				ns.extra = make(map[string]interface{})
+			}
+			ns.extra[key] = val
+		}
+	}
 	if props, ok := notes["properties"].(map[string]interface{}); ok {
 		ns.Properties = props
 	}
@@ -184,64 +432,199 @@ func (ns *NoteSet) Loads(snoteset string) error {
 			}
 		}
 	}
+	ns.Trash = nil
+	if trashList, ok := notes["trash"].([]interface{}); ok {
+		for _, noteData := range trashList {
+			if noteMap, ok := noteData.(map[string]interface{}); ok {
+				note := NewNote(noteMap, NewStickyNote, ns, "")
+				if note.DeletedAt.IsZero() {
+					note.DeletedAt = time.Now()
+				}
+				ns.Trash = append(ns.Trash, note)
+			}
+		}
+	}
 
 	return nil
 }
 
 // Dumps converts the noteset to JSON
 func (ns *NoteSet) Dumps() string {
+	return marshalNoteSet(ns.dumpData())
+}
+
+// dumpData collects the serializable state of the noteset
+func (ns *NoteSet) dumpData() map[string]interface{} {
 	notes := make([]map[string]interface{}, len(ns.Notes))
 	for i, note := range ns.Notes {
 		notes[i] = note.Extract()
 	}
 
-	data := map[string]interface{}{
-		"notes":      notes,
-		"properties": ns.Properties,
-		"categories": ns.Categories,
+	data := make(map[string]interface{}, len(ns.extra)+4)
+	for key, val := range ns.extra {
+		data[key] = val
 	}
+	data["notes"] = notes
+	data["properties"] = ns.Properties
+	data["categories"] = ns.Categories
+	if len(ns.Trash) > 0 {
+		trash := make([]map[string]interface{}, len(ns.Trash))
+		for i, note := range ns.Trash {
+			trash[i] = note.Extract()
+		}
+		data["trash"] = trash
+	}
+	return data
+}
 
+func marshalNoteSet(data map[string]interface{}) string {
 	jsonData, _ := json.Marshal(data)
 	return string(jsonData)
 }
 
-// Save writes the noteset to disk
-func (ns *NoteSet) Save() {
-	output := ns.Dumps()
-	path := ns.DataFile
-	if path[0] == '~' {
-		home, _ := os.UserHomeDir()
-		path = filepath.Join(home, path[2:])
+// Save writes the noteset to disk, or hands it to the writer goroutine once
+// StartBackgroundSave was called. A failure is reported once through the indicator,
+// until a save succeeds again.
+func (ns *NoteSet) Save() error {
+	// A noteset that isn't loaded yet would replace the notes on disk
+	if !ns.loaded {
+		fmt.Printf("[Save] Not saving %s, the notes aren't loaded yet\n", ns.DataPath())
+		return errNotLoaded
+	}
+	if ns.readOnly {
+		// Another instance writes the data file; the notes menu still follows the edits
+		ns.notifyNotesChanged()
+		return errReadOnly
+	}
+	// Take in edits from other devices sharing the file instead of overwriting them
+	fileUnchanged := ns.dataFileUnchanged()
+	ns.checkRemoteChanges()
+	plain := []byte(ns.Dumps())
+	if fileUnchanged && ns.upToDate(plain) {
+		return nil
+	}
+	snapshot := &saveSnapshot{plain: plain, key: ns.encryption, backup: !ns.dataDamaged}
+	ns.rememberSaved(plain)
+	var err error
+	if ns.saver != nil {
+		ns.saver.queue(snapshot)
+	} else {
+		err = ns.finishSave(ns.writeSnapshot(snapshot))
 	}
-	os.WriteFile(path, []byte(output), 0644)
+
+	// Keep the indicator's note list in step with the saved notes
+	ns.notifyNotesChanged()
+	return err
 }
 
-// Open reads the noteset from disk
-func (ns *NoteSet) Open() error {
-	path := ns.DataFile
-	if path[0] == '~' {
-		home, _ := os.UserHomeDir()
-		path = filepath.Join(home, path[2:])
+// notesMenuKey sums up what the indicator's menus show of the notes: their titles,
+// categories and due states, and the categories' names and colors
+func (ns *NoteSet) notesMenuKey() string {
+	var b strings.Builder
+	for _, note := range ns.Notes {
+		fmt.Fprintf(&b, "%s\x00%s\x00%s\x00%d\n", note.UUID, note.FirstLine(), ns.effectiveCategory(note), note.DueState())
+	}
+	for _, cat := range ns.MatchCategories("") {
+		fmt.Fprintf(&b, "%s\x00%s\x00%v\n", cat, ns.CategoryName(cat), ns.GetCategoryProperty(cat, "bgcolor_hsv"))
+	}
+	return b.String()
+}
+
+// notifyNotesChanged calls NotesChanged when what the indicator's menus show changed
+// since it was last called
+func (ns *NoteSet) notifyNotesChanged() {
+	key := ns.notesMenuKey()
+	if key == ns.menuKey {
+		return
+	}
+	ns.menuKey = key
+	if ns.NotesChanged != nil {
+		ns.NotesChanged()
+	}
+}
+
+// finishSave takes note of the data file written by Save, at modTime, or reports the
+// error
+func (ns *NoteSet) finishSave(modTime time.Time, err error) error {
+	if err == nil {
+		ns.dataModTime = modTime
+		ns.dataDamaged = false
+		ns.saveFailed = false
+		ns.scheduleWebDAVSync()
+		return nil
+	}
+	// Not on disk after all, the next save writes it again
+	ns.savedSum = [sha256.Size]byte{}
+	fmt.Printf("[Save] Failed to save %s: %v\n", ns.DataPath(), err)
+	if !ns.saveFailed {
+		ns.saveFailed = true
+		if indicator, ok := ns.Indicator.(interface{ ReportSaveError(error) }); ok {
+			indicator.ReportSaveError(err)
+		}
 	}
-	data, err := os.ReadFile(path)
+	return err
+}
+
+// DataPath returns the data file path resolved by DefaultResolver: ~ and environment
+// variables expanded, symlinks followed
+func (ns *NoteSet) DataPath() string {
+	return ResolvePath(ns.DataFile)
+}
+
+// Open reads the noteset from disk, falling back to the backup when the data file is
+// truncated or isn't valid JSON (see Recovered). An encrypted data file needs the
+// passphrase (see SetPassphrase), unless it is remembered in the keyring.
+func (ns *NoteSet) Open() error {
+	data, err := os.ReadFile(ns.DataPath())
 	if err != nil {
+		// Nothing on disk to lose by saving
+		ns.loaded = os.IsNotExist(err)
+		return err
+	}
+	plain, err := ns.decode(data)
+	if errors.Is(err, ErrPassphraseRequired) || errors.Is(err, ErrWrongPassphrase) {
 		return err
 	}
-	return ns.Loads(string(data))
+	if err == nil {
+		err = ns.Loads(string(plain))
+	}
+	if err != nil {
+		// Not backed up by the next save, even after LoadFresh: the backup is all that
+		// is left of the notes
+		ns.dataDamaged = true
+		if err := ns.openBackup(data, err); err != nil {
+			return err
+		}
+	}
+	ns.markSaved([]byte(ns.Dumps()))
+	ns.loaded = true
+	return nil
 }
 
 // LoadFresh initializes an empty noteset
 func (ns *NoteSet) LoadFresh() {
+	ns.loaded = true
 	ns.Loads("{}")
 	ns.New()
 }
 
-// Merge merges data from another noteset
+// Merge merges data from another noteset. Notes already here are merged with their
+// imported copy (see mergeNote), the ones changed on both sides are left in
+// MergeConflicts.
 func (ns *NoteSet) Merge(data string) error {
-	var jdata map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &jdata); err != nil {
+	jdata, err := parseNoteSet(data)
+	if err != nil {
 		return err
 	}
+	return ns.mergeData(jdata)
+}
+
+// mergeData merges a parsed notes file into the noteset
+func (ns *NoteSet) mergeData(jdata map[string]interface{}) error {
+	if _, ok := jdata["notes"].([]interface{}); !ok {
+		return errors.New("no notes in the file")
+	}
+	ns.MergeConflicts = nil
 
 	ns.HideAll()
 
@@ -268,14 +651,8 @@ func (ns *NoteSet) Merge(data string) error {
 			if newNote, ok := noteData.(map[string]interface{}); ok {
 				if uuidStr, ok := newNote["uuid"].(string); ok && uuidStr != "" {
 					if orignote, exists := dnotes[uuidStr]; exists {
-						if body, ok := newNote["body"].(string); ok {
-							orignote.Body = body
-						}
-						if props, ok := newNote["properties"].(map[string]interface{}); ok {
-							orignote.Properties = props
-						}
-						if cat, ok := newNote["cat"].(string); ok {
-							orignote.Category = cat
+						if conflict := ns.mergeNote(orignote, newNote); conflict != nil {
+							ns.MergeConflicts = append(ns.MergeConflicts, *conflict)
 						}
 						continue
 					}
@@ -284,6 +661,7 @@ func (ns *NoteSet) Merge(data string) error {
 				if note.UUID == "" {
 					note.UUID = uuid.New().String()
 				}
+				note.Properties[mergeRevProperty] = float64(note.Revision)
 				dnotes[note.UUID] = note
 			}
 		}
@@ -293,6 +671,18 @@ func (ns *NoteSet) Merge(data string) error {
 	for _, note := range dnotes {
 		ns.Notes = append(ns.Notes, note)
 	}
+	// An imported note deleted here comes back, rather than its UUID being used twice
+	trash := ns.Trash[:0]
+	for _, note := range ns.Trash {
+		if _, imported := dnotes[note.UUID]; !imported {
+			trash = append(trash, note)
+		}
+	}
+	ns.Trash = trash
+
+	if attachments, ok := jdata["attachments"].(map[string]interface{}); ok {
+		ns.importAttachments(attachments)
+	}
 
 	ns.ShowAll()
 	return nil
@@ -304,8 +694,15 @@ func (ns *NoteSet) New() *Note {
 	if def, ok := ns.Properties["default_cat"].(string); ok {
 		defaultCat = def
 	}
-	note := NewNote(nil, NewStickyNote, ns, defaultCat)
+	return ns.NewInCategory(defaultCat)
+}
+
+// NewInCategory creates a new note in the given category and adds it to the noteset
+func (ns *NoteSet) NewInCategory(cat string) *Note {
+	note := NewNote(nil, NewStickyNote, ns, cat)
 	ns.Notes = append(ns.Notes, note)
+	ns.RecordUsage(UsageNoteCreated)
+	ns.RecordCategoryUsage(note.Category)
 	note.Show()
 	return note
 }
@@ -350,24 +747,7 @@ func (ns *NoteSet) AssignWindowIDs() {
 
 // HideAll hides all notes
 func (ns *NoteSet) HideAll() {
-	// Before hiding, get current positions using D-Bus and print them
-	if IsWindowCallsAvailable() {
-		for _, note := range ns.Notes {
-			if note.GUI != nil && note.GUI.WinMain != nil {
-				// Try to get position from D-Bus if window ID is available
-				if note.GUI.WindowID != 0 {
-					details, err := GetWindowDetails(note.GUI.WindowID)
-					if err == nil && details != nil {
-						// Update LastKnownPos from D-Bus
-						note.GUI.LastKnownPos = [2]int{details.X, details.Y}
-						note.GUI.LastKnownSize = [2]int{details.Width, details.Height}
-					}
-				}
-			}
-		}
-	}
-
-	// Update note properties with current positions before saving
+	// Update note positions from their windows before saving
 	for _, note := range ns.Notes {
 		if note.GUI != nil {
 			note.GUI.UpdateNote() // This updates position in note.Properties
@@ -379,9 +759,66 @@ func (ns *NoteSet) HideAll() {
 	for _, note := range ns.Notes {
 		note.Hide()
 	}
+	// Hidden for good, not to be shown once the fullscreen application loses the focus
+	ns.fullscreenHidden = nil
 	ns.Properties["all_visible"] = false
 }
 
+// AllTags returns every tag used by a note in the set, sorted alphabetically
+func (ns *NoteSet) AllTags() []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for _, note := range ns.Notes {
+		for _, tag := range note.Tags {
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i]) < strings.ToLower(tags[j])
+	})
+	return tags
+}
+
+// ShowTagged shows only the notes carrying the given tag and hides all others
+func (ns *NoteSet) ShowTagged(tag string) {
+	for _, note := range ns.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateNote()
+		}
+	}
+	for _, note := range ns.Notes {
+		if note.HasTag(tag) {
+			note.Show()
+		} else {
+			note.Hide()
+		}
+	}
+	ns.Properties["all_visible"] = true
+	ns.Save()
+}
+
+// ShowCategory shows only the notes in the given category and hides all others.
+// Notes without a (known) category count as being in the default category.
+func (ns *NoteSet) ShowCategory(cat string) {
+	for _, note := range ns.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateNote()
+		}
+	}
+	for _, note := range ns.Notes {
+		if ns.effectiveCategory(note) == cat {
+			note.Show()
+		} else {
+			note.Hide()
+		}
+	}
+	ns.Properties["all_visible"] = true
+	ns.Save()
+}
+
 // GetCategoryProperty gets a property of a category or the default
 func (ns *NoteSet) GetCategoryProperty(cat, prop string) interface{} {
 	// If category is empty, try default_cat
@@ -415,6 +852,36 @@ func (ns *NoteSet) GetCategoryProperty(cat, prop string) interface{} {
 	return nil
 }
 
+// CategoryName returns the display name of a category, resolving "" to the default category
+func (ns *NoteSet) CategoryName(cat string) string {
+	if cat == "" {
+		cat, _ = ns.Properties["default_cat"].(string)
+	}
+	if catData, ok := ns.Categories[cat]; ok {
+		if name, ok := catData["name"].(string); ok && name != "" {
+			return name
+		}
+		return "New Category"
+	}
+	return "Uncategorized"
+}
+
+// categoryByName returns the category with the name, ignoring case. The first one by id
+// when several have it.
+func (ns *NoteSet) categoryByName(name string) (string, bool) {
+	ids := make([]string, 0, len(ns.Categories))
+	for cid := range ns.Categories {
+		ids = append(ids, cid)
+	}
+	sort.Strings(ids)
+	for _, cid := range ids {
+		if catName, ok := ns.Categories[cid]["name"].(string); ok && strings.EqualFold(catName, name) {
+			return cid, true
+		}
+	}
+	return "", false
+}
+
 // HasCategory checks if a category exists
 func (ns *NoteSet) HasCategory(cat string) bool {
 	_, ok := ns.Categories[cat]
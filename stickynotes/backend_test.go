@@ -0,0 +1,670 @@
+package stickynotes
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestNoteSet returns an empty noteset with its data file in a temporary directory.
+// It isn't loaded, so the saves Merge does leave the disk alone.
+func newTestNoteSet(t testing.TB) *NoteSet {
+	t.Helper()
+	return NewNoteSet(filepath.Join(t.TempDir(), "notes.json"), nil)
+}
+
+// findNote returns the note with the uuid, failing the test when there is none
+func findNote(t testing.TB, ns *NoteSet, uuid string) *Note {
+	t.Helper()
+	note := ns.noteByUUID(uuid)
+	if note == nil {
+		t.Fatalf("note %s not found", uuid)
+	}
+	return note
+}
+
+const testNoteSetJSON = `{
+	"properties": {"default_cat": "cat-a", "all_visible": true},
+	"categories": {
+		"cat-a": {"name": "Work", "bgcolor_hsv": [0.5, 1, 1]},
+		"cat-b": {"name": "Home"}
+	},
+	"notes": [
+		{
+			"uuid": "note-0001",
+			"body": "Shopping\nmilk",
+			"cat": "cat-b",
+			"tags": ["errands", "home"],
+			"last_modified": "2024-03-01T10:20:30",
+			"properties": {"locked": true, "position": [10, 20]},
+			"formatting": [{"start": 0, "end": 8, "style": "strikethrough"}],
+			"rev": 3,
+			"edited_on": "laptop"
+		},
+		{"uuid": "note-0002", "body": "", "cat": "", "last_modified": "2024-03-02T09:00:00"}
+	],
+	"trash": [
+		{"uuid": "note-0003", "body": "gone", "deleted_at": "2024-02-01T08:00:00"},
+		{"uuid": "note-0004", "body": "gone too"}
+	]
+}`
+
+func TestLoadsDumpsRoundTrip(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+
+	reloaded := newTestNoteSet(t)
+	if err := reloaded.Loads(ns.Dumps()); err != nil {
+		t.Fatalf("Loads of Dumps: %v", err)
+	}
+
+	if len(reloaded.Notes) != 2 || len(reloaded.Trash) != 2 {
+		t.Fatalf("got %d notes and %d in trash, want 2 and 2", len(reloaded.Notes), len(reloaded.Trash))
+	}
+	if !reflect.DeepEqual(reloaded.Properties, ns.Properties) {
+		t.Errorf("properties = %v, want %v", reloaded.Properties, ns.Properties)
+	}
+	if !reflect.DeepEqual(reloaded.Categories, ns.Categories) {
+		t.Errorf("categories = %v, want %v", reloaded.Categories, ns.Categories)
+	}
+
+	for i, want := range ns.Notes {
+		got := reloaded.Notes[i]
+		if got.UUID != want.UUID || got.Body != want.Body || got.Category != want.Category {
+			t.Errorf("note %d = %q %q %q, want %q %q %q", i, got.UUID, got.Body, got.Category, want.UUID, want.Body, want.Category)
+		}
+		if !reflect.DeepEqual(got.Tags, want.Tags) {
+			t.Errorf("note %s tags = %v, want %v", want.UUID, got.Tags, want.Tags)
+		}
+		if !reflect.DeepEqual(got.Properties, want.Properties) {
+			t.Errorf("note %s properties = %v, want %v", want.UUID, got.Properties, want.Properties)
+		}
+		if !reflect.DeepEqual(got.Formatting, want.Formatting) {
+			t.Errorf("note %s formatting = %v, want %v", want.UUID, got.Formatting, want.Formatting)
+		}
+		if !got.LastModified.Equal(want.LastModified) {
+			t.Errorf("note %s last modified = %v, want %v", want.UUID, got.LastModified, want.LastModified)
+		}
+		if got.Revision != want.Revision || got.EditedOn != want.EditedOn {
+			t.Errorf("note %s revision = %d on %q, want %d on %q", want.UUID, got.Revision, got.EditedOn, want.Revision, want.EditedOn)
+		}
+	}
+
+	note := findNote(t, reloaded, "note-0001")
+	wantModified := time.Date(2024, 3, 1, 10, 20, 30, 0, time.Local)
+	if !note.LastModified.Equal(wantModified) {
+		t.Errorf("last modified = %v, want %v", note.LastModified, wantModified)
+	}
+	if note.Revision != 3 || note.EditedOn != "laptop" {
+		t.Errorf("revision = %d on %q, want 3 on laptop", note.Revision, note.EditedOn)
+	}
+	if locked, _ := note.Properties["locked"].(bool); !locked {
+		t.Error("locked property lost")
+	}
+
+	wantDeleted := time.Date(2024, 2, 1, 8, 0, 0, 0, time.Local)
+	if deleted := reloaded.Trash[0].DeletedAt; !deleted.Equal(wantDeleted) {
+		t.Errorf("deleted at = %v, want %v", deleted, wantDeleted)
+	}
+	if reloaded.Trash[1].DeletedAt.IsZero() {
+		t.Error("trashed note without deleted_at has no deletion time")
+	}
+}
+
+func TestTimestampsLocal(t *testing.T) {
+	// Away from UTC, where parsing as UTC would shift the times
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	defer func() { time.Local = local }()
+
+	ns := newTestNoteSet(t)
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	deleted := modified.Add(time.Minute)
+	note := &Note{UUID: "note-0001", NoteSet: ns, Properties: map[string]interface{}{}, LastModified: modified, DeletedAt: deleted}
+
+	loaded := NewNote(note.Extract(), nil, ns, "")
+	if !loaded.LastModified.Equal(modified) || !loaded.DeletedAt.Equal(deleted) {
+		t.Errorf("times read back as %v and %v, want %v and %v", loaded.LastModified, loaded.DeletedAt, modified, deleted)
+	}
+	if time.Since(loaded.LastModified) < 0 {
+		t.Errorf("last modified %v is in the future", loaded.LastModified)
+	}
+}
+
+func TestLoadsInvalid(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	for _, data := range []string{"", "{", `{"notes": [`, "[]", "null", `{"notes": {}}`, `{"notes": "text"}`, `{"categories": []}`, `{"properties": 1}`} {
+		if err := ns.Loads(data); err == nil {
+			t.Errorf("Loads(%q) succeeded, want an error", data)
+		}
+	}
+	if len(ns.Notes) != 2 {
+		t.Errorf("failed Loads changed the notes: got %d, want 2", len(ns.Notes))
+	}
+}
+
+func TestTraversalUUID(t *testing.T) {
+	ns := newTestNoteSet(t)
+	// A directory next to the attachments one, that "../victim" would name
+	victim := filepath.Join(filepath.Dir(ns.AttachmentsDir()), "victim")
+	if err := os.MkdirAll(victim, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Loads(`{"notes": [{"uuid": "../victim", "body": "x"}, {"uuid": "a/../../victim", "body": "y"}]}`); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	for _, note := range ns.Notes {
+		if !validNoteUUID(note.UUID) || strings.Contains(note.UUID, "victim") {
+			t.Errorf("Loads kept UUID %q", note.UUID)
+		}
+	}
+
+	// Even a note that got its UUID some other way can't remove anything outside
+	ns.Trash = append(ns.Trash, ns.Notes...)
+	ns.Trash = append(ns.Trash, &Note{UUID: "../victim", NoteSet: ns, Properties: map[string]interface{}{}})
+	ns.Notes = nil
+	ns.EmptyTrash()
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("EmptyTrash removed a directory outside the attachments: %v", err)
+	}
+}
+
+func TestSaveOnlyChanges(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := os.WriteFile(ns.DataPath(), []byte(testNoteSetJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// The data file is replaced when written, so a rewrite shows as another file
+	saved := func() os.FileInfo {
+		t.Helper()
+		if err := ns.Save(); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		info, err := os.Stat(ns.DataPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	opened, _ := os.Stat(ns.DataPath())
+	if !os.SameFile(opened, saved()) {
+		t.Error("saving the notes as opened rewrote the data file")
+	}
+	findNote(t, ns, "note-0002").Body = "changed"
+	changed := saved()
+	if os.SameFile(opened, changed) {
+		t.Error("saving a changed note didn't write the data file")
+	}
+	if !os.SameFile(changed, saved()) {
+		t.Error("saving again without changes rewrote the data file")
+	}
+}
+
+func TestBackgroundSave(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := os.WriteFile(ns.DataPath(), []byte(testNoteSetJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ns.StartBackgroundSave()
+	note := findNote(t, ns, "note-0002")
+	for _, body := range []string{"first", "second", "last"} {
+		note.Body = body
+		if err := ns.Save(); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if err := ns.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded := newTestNoteSet(t)
+	reloaded.DataFile = ns.DataFile
+	if err := reloaded.Open(); err != nil {
+		t.Fatalf("Open after the background save: %v", err)
+	}
+	if body := findNote(t, reloaded, "note-0002").Body; body != "last" {
+		t.Errorf("saved body = %q, want the last one", body)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	for _, plain := range []string{`{}`, `{"notes": []}`, "{\"notes\": [{\"body\": \"a\"}]}\n"} {
+		data := addChecksum([]byte(plain))
+		got, err := verifyChecksum(data)
+		if err != nil || string(got) != strings.TrimSpace(plain) {
+			t.Errorf("verifyChecksum(addChecksum(%q)) = %q, %v", plain, got, err)
+		}
+		ns := newTestNoteSet(t)
+		if err := ns.Loads(string(data)); err != nil {
+			t.Errorf("Loads of %s: %v", data, err)
+		}
+	}
+
+	data := addChecksum([]byte(`{"notes": [{"body": "milk"}]}`))
+	if _, err := verifyChecksum(bytes.Replace(data, []byte("milk"), []byte("oats"), 1)); err != errChecksum {
+		t.Errorf("verifyChecksum of a changed file = %v, want errChecksum", err)
+	}
+	if got, err := verifyChecksum([]byte(testNoteSetJSON)); err != nil || string(got) != testNoteSetJSON {
+		t.Errorf("verifyChecksum without a checksum = %v, want the data as it is", err)
+	}
+}
+
+func TestOpenDamaged(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ns := newTestNoteSet(t)
+	if err := os.WriteFile(ns.DataPath(), []byte(testNoteSetJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Open(); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	snapshot, err := ns.Backup(BackupScheduled)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	findNote(t, ns, "note-0002").Body = "saved"
+	if err := ns.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Still valid JSON, but not what was saved
+	tamper := func() {
+		t.Helper()
+		data, err := os.ReadFile(ns.DataPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(ns.DataPath(), bytes.Replace(data, []byte("saved"), []byte("bogus"), 1), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reopen := func(from string) {
+		t.Helper()
+		reopened := newTestNoteSet(t)
+		reopened.DataFile = ns.DataFile
+		if err := reopened.Open(); err != nil {
+			t.Fatalf("Open of a damaged data file: %v", err)
+		}
+		if reopened.Recovered == nil || !strings.Contains(reopened.Recovered.Error(), from) {
+			t.Errorf("Recovered = %v, want the notes loaded from %s", reopened.Recovered, from)
+		}
+		if body := findNote(t, reopened, "note-0002").Body; body != "" {
+			t.Errorf("recovered body = %q, want the backup's", body)
+		}
+	}
+
+	tamper()
+	reopen(ns.backupPath())
+
+	// Without a readable .bak, the newest snapshot
+	if err := os.WriteFile(ns.backupPath(), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reopen(snapshot.Path)
+
+	// Nothing readable: starting afresh keeps the damaged file and leaves the backup alone
+	if err := os.WriteFile(snapshot.Path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	damaged, err := os.ReadFile(ns.DataPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh := newTestNoteSet(t)
+	fresh.DataFile = ns.DataFile
+	if err := fresh.Open(); err == nil {
+		t.Fatal("Open without a readable backup succeeded")
+	}
+	if kept, err := os.ReadFile(ns.damagedPath()); err != nil || !bytes.Equal(kept, damaged) {
+		t.Errorf("damaged file kept as %q, %v", kept, err)
+	}
+	// As LoadFresh does, without the window of its first note
+	fresh.loaded = true
+	fresh.Loads("{}")
+	if err := fresh.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if bak, err := os.ReadFile(ns.backupPath()); err != nil || string(bak) != "{" {
+		t.Errorf("backup after saving fresh notes = %q, %v, want it left alone", bak, err)
+	}
+}
+
+func TestLoadsDefaults(t *testing.T) {
+	ns := newTestNoteSet(t)
+	before := time.Now()
+	if err := ns.Loads(`{"notes": [{"body": "no uuid"}, "not a note"]}`); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if len(ns.Notes) != 1 {
+		t.Fatalf("got %d notes, want 1", len(ns.Notes))
+	}
+	note := ns.Notes[0]
+	if note.UUID == "" {
+		t.Error("note without uuid didn't get one")
+	}
+	if note.LastModified.Before(before.Truncate(time.Second)) {
+		t.Errorf("note without last_modified = %v, want now", note.LastModified)
+	}
+	if note.Properties == nil {
+		t.Error("note without properties has nil properties")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+
+	err := ns.Merge(`{
+		"categories": {
+			"cat-b": {"name": "Family"},
+			"cat-c": {"name": "Ideas"}
+		},
+		"notes": [
+			{"uuid": "note-0001", "body": "Shopping\nbread", "cat": "cat-c", "tags": ["food"], "properties": {"position": [5, 5]}},
+			{"uuid": "note-0005", "body": "new"},
+			{"uuid": "short", "body": "with a short uuid"},
+			{"body": "without uuid"},
+			{"uuid": "", "body": "with empty uuid"}
+		]
+	}`)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	if len(ns.Notes) != 6 {
+		t.Fatalf("got %d notes, want 6", len(ns.Notes))
+	}
+	uuids := make(map[string]bool)
+	for _, note := range ns.Notes {
+		if len(note.UUID) < 8 {
+			t.Errorf("note %q has uuid %q, want a full one", note.Body, note.UUID)
+		}
+		if uuids[note.UUID] {
+			t.Errorf("uuid %s used twice", note.UUID)
+		}
+		uuids[note.UUID] = true
+	}
+
+	// A note with the same UUID is updated in place
+	updated := findNote(t, ns, "note-0001")
+	if updated.Body != "Shopping\nbread" || updated.Category != "cat-c" {
+		t.Errorf("merged note = %q in %q, want the imported body and category", updated.Body, updated.Category)
+	}
+	if !reflect.DeepEqual(updated.Tags, []string{"food"}) {
+		t.Errorf("merged note tags = %v, want [food]", updated.Tags)
+	}
+	if _, ok := updated.Properties["locked"]; ok {
+		t.Error("merged note kept the properties it had, want the imported ones")
+	}
+	// Kept as they were when the import doesn't have them
+	if updated.Revision != 3 || len(updated.Formatting) != 1 {
+		t.Errorf("merged note revision %d, %d formatting ranges; want 3 and 1", updated.Revision, len(updated.Formatting))
+	}
+	findNote(t, ns, "note-0002")
+	findNote(t, ns, "note-0005")
+
+	// The imported category replaces the one with the same id, the others are kept
+	if name := ns.CategoryName("cat-b"); name != "Family" {
+		t.Errorf("colliding category name = %q, want Family", name)
+	}
+	if name := ns.CategoryName("cat-a"); name != "Work" {
+		t.Errorf("kept category name = %q, want Work", name)
+	}
+	if !ns.HasCategory("cat-c") {
+		t.Error("new category not added")
+	}
+	// The trash isn't touched
+	if len(ns.Trash) != 2 {
+		t.Errorf("got %d notes in trash, want 2", len(ns.Trash))
+	}
+}
+
+func TestMergeFromTrash(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if err := ns.Merge(`{"notes": [{"uuid": "note-0003", "body": "back from the trash"}]}`); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	// An imported note deleted here is taken out of the trash, the others stay
+	if len(ns.Trash) != 1 || ns.Trash[0].UUID != "note-0004" {
+		t.Errorf("got %d notes in trash, want only note-0004", len(ns.Trash))
+	}
+	if restored := findNote(t, ns, "note-0003"); restored.Body != "back from the trash" {
+		t.Errorf("note back from the trash = %q, want the imported body", restored.Body)
+	}
+	if len(ns.Notes) != 3 {
+		t.Errorf("got %d notes, want 3", len(ns.Notes))
+	}
+}
+
+func TestMergeInvalid(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	for _, data := range []string{"not json", "null", "{}", `{"notes": 1}`, `{"notes": [], "attachments": []}`} {
+		if err := ns.Merge(data); err == nil {
+			t.Errorf("Merge(%q) succeeded, want an error", data)
+		}
+	}
+	if len(ns.Notes) != 2 || findNote(t, ns, "note-0001").Body != "Shopping\nmilk" {
+		t.Error("failed Merge changed the notes")
+	}
+}
+
+func TestMergeIntoEmpty(t *testing.T) {
+	ns := newTestNoteSet(t)
+	ns.Categories = nil
+	if err := ns.Merge(`{"categories": {"cat-a": {"name": "Work"}}, "notes": [{"uuid": "note-0001", "body": "a"}]}`); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if len(ns.Notes) != 1 || !ns.HasCategory("cat-a") {
+		t.Errorf("got %d notes, category added %v; want 1 and true", len(ns.Notes), ns.HasCategory("cat-a"))
+	}
+}
+
+func TestGetCategoryProperty(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	workColor := []interface{}{0.5, float64(1), float64(1)}
+
+	tests := []struct {
+		name string
+		cat  string
+		prop string
+		want interface{}
+	}{
+		{"category property", "cat-a", "bgcolor_hsv", workColor},
+		{"empty category uses the default category", "", "bgcolor_hsv", workColor},
+		{"missing property falls back, not to the default category", "cat-b", "bgcolor_hsv", FallbackProperties["bgcolor_hsv"]},
+		{"unknown category falls back", "cat-x", "bgcolor_hsv", FallbackProperties["bgcolor_hsv"]},
+		{"fallback of another property", "cat-a", "shadow", FallbackProperties["shadow"]},
+		{"unknown property", "cat-a", "no_such_property", nil},
+	}
+	for _, tt := range tests {
+		if got := ns.GetCategoryProperty(tt.cat, tt.prop); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: GetCategoryProperty(%q, %q) = %v, want %v", tt.name, tt.cat, tt.prop, got, tt.want)
+		}
+	}
+
+	// Without a default category, an empty category falls back too
+	delete(ns.Properties, "default_cat")
+	if got := ns.GetCategoryProperty("", "bgcolor_hsv"); !reflect.DeepEqual(got, FallbackProperties["bgcolor_hsv"]) {
+		t.Errorf("GetCategoryProperty without default category = %v, want the fallback", got)
+	}
+
+	note := findNote(t, ns, "note-0001")
+	note.Category = "cat-a"
+	if got := note.CatProp("bgcolor_hsv"); !reflect.DeepEqual(got, workColor) {
+		t.Errorf("CatProp = %v, want %v", got, workColor)
+	}
+}
+
+func TestCategoryName(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	ns.Categories["cat-unnamed"] = map[string]interface{}{}
+
+	tests := map[string]string{
+		"cat-b":       "Home",
+		"":            "Work",
+		"cat-unnamed": "New Category",
+		"cat-x":       "Uncategorized",
+	}
+	for cat, want := range tests {
+		if got := ns.CategoryName(cat); got != want {
+			t.Errorf("CategoryName(%q) = %q, want %q", cat, got, want)
+		}
+	}
+}
+
+func TestCategoryNoteCount(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	// A note in a category that doesn't exist counts as in the default one
+	ns.Notes = append(ns.Notes, &Note{UUID: "note-0005", Category: "cat-x", Properties: map[string]interface{}{}})
+
+	tests := map[string]int{
+		"cat-a": 2,
+		"cat-b": 1,
+		"cat-x": 0,
+	}
+	for cat, want := range tests {
+		if got := ns.CategoryNoteCount(cat); got != want {
+			t.Errorf("CategoryNoteCount(%q) = %d, want %d", cat, got, want)
+		}
+	}
+}
+
+func TestRestoreExpired(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	dated := findNote(t, ns, "note-0001")
+	dated.Properties["expire_on"] = time.Now().AddDate(0, 0, -1).Format(dueDateLayout)
+	idle := findNote(t, ns, "note-0002")
+	idle.Properties["expire_idle_days"] = float64(3)
+	idle.LastModified = time.Now().AddDate(0, 0, -5)
+
+	if expired := ns.ExpireNotes(); expired != 2 {
+		t.Fatalf("ExpireNotes() = %d, want 2", expired)
+	}
+	dated.Restore()
+	idle.Restore()
+	if expired := ns.ExpireNotes(); expired != 0 {
+		t.Errorf("restored notes expired again: ExpireNotes() = %d, want 0", expired)
+	}
+	if len(ns.Notes) != 2 {
+		t.Errorf("got %d notes, want 2", len(ns.Notes))
+	}
+}
+
+func TestNotesChanged(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	calls := 0
+	ns.NotesChanged = func() { calls++ }
+
+	steps := []struct {
+		name   string
+		change func()
+		want   int
+	}{
+		{"first save", func() {}, 1},
+		{"nothing changed", func() {}, 1},
+		{"body below the title", func() { findNote(t, ns, "note-0001").Body += "\neggs" }, 1},
+		{"position", func() { findNote(t, ns, "note-0001").Properties["position"] = []interface{}{50.0, 60.0} }, 1},
+		{"retitled", func() { findNote(t, ns, "note-0001").Body = "Groceries\nmilk" }, 2},
+		{"recategorized", func() { findNote(t, ns, "note-0001").Category = "cat-a" }, 3},
+		{"added", func() {
+			ns.Notes = append(ns.Notes, &Note{UUID: "note-0005", NoteSet: ns, Properties: map[string]interface{}{}})
+		}, 4},
+		{"category renamed", func() { ns.Categories["cat-b"]["name"] = "House" }, 5},
+		{"removed", func() { ns.Notes = ns.Notes[:1] }, 6},
+	}
+	for _, step := range steps {
+		step.change()
+		ns.notifyNotesChanged()
+		if calls != step.want {
+			t.Errorf("%s: NotesChanged called %d times, want %d", step.name, calls, step.want)
+		}
+	}
+}
+
+func TestUsageOutsideDataFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	// Counters older versions kept in the data file are moved out of it
+	ns.Properties["usage"] = map[string]interface{}{"since": "2024-01-01", UsageSearch: float64(2)}
+	ns.Properties["usage_enabled"] = true
+	ns.RecordUsage(UsageSearch)
+	ns.RecordCategoryUsage("cat-a")
+	if strings.Contains(ns.Dumps(), UsageSearch) {
+		t.Error("usage counters are in the data file")
+	}
+
+	// Another noteset of the same data file reads them back
+	other := NewNoteSet(ns.DataFile, nil)
+	other.Properties["usage_enabled"] = true
+	if got, _ := other.loadUsage()[UsageSearch].(float64); got != 3 {
+		t.Errorf("%s = %v, want 3", UsageSearch, got)
+	}
+	other.ClearUsage()
+	if _, err := os.Stat(other.usagePath()); !os.IsNotExist(err) {
+		t.Errorf("usage file left after ClearUsage: %v", err)
+	}
+}
+
+func TestExportMarkdownDirKeepsFiles(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "Shopping.md")
+	if err := os.WriteFile(existing, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if written, err := ns.ExportMarkdownDir(dir); err != nil || written != 2 {
+		t.Fatalf("ExportMarkdownDir = %d, %v; want 2 written", written, err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "mine" {
+		t.Errorf("existing file overwritten: %q", data)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Shopping (2).md")); err != nil || !strings.Contains(string(data), "milk") {
+		t.Errorf("note not exported next to the existing file: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,405 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Snapshots of all notes are kept in ~/.local/share/indicator-stickynotes/backups (under
+// XDG_DATA_HOME), as "<data file>-<time>-<reason>.json": daily, and before operations
+// that change many notes at once (import, merge, deleting a category, restoring a
+// backup). Only the newest ones are kept, see BackupKeep.
+
+// Reasons a backup is taken
+const (
+	BackupScheduled      = "scheduled"
+	BackupImport         = "import"
+	BackupMerge          = "merge"
+	BackupDeleteCategory = "category-delete"
+	BackupRestore        = "before-restore"
+	BackupSyncConflict   = "sync-conflict"
+)
+
+const (
+	// DefaultBackupKeep is how many backups are kept unless set in the "backup_keep" property
+	DefaultBackupKeep = 20
+	// backupInterval is the time between scheduled backups
+	backupInterval = 24 * time.Hour
+	// backupCheckInterval is how often (ms) a scheduled backup is looked for
+	backupCheckInterval = 60 * 60 * 1000
+	// backupTimeLayout is the time in backup file names
+	backupTimeLayout = "20060102-150405"
+)
+
+// Backup is a snapshot of the notes
+type Backup struct {
+	Path   string
+	Time   time.Time
+	Reason string
+}
+
+// BackupDir returns the directory holding the backups
+func BackupDir() string {
+	return ResolvePath(filepath.Join("$XDG_DATA_HOME", LocaleDomain, "backups"))
+}
+
+// backupPrefix starts the names of this data file's backups, so the development data
+// file's backups are kept apart
+func (ns *NoteSet) backupPrefix() string {
+	return backupPrefixOf(ns.DataPath())
+}
+
+// backupPrefixOf starts the names of the backups of the data file at path
+func backupPrefixOf(path string) string {
+	return strings.TrimPrefix(filepath.Base(path), ".") + "-"
+}
+
+// BackupKeep returns how many backups are kept
+func (ns *NoteSet) BackupKeep() int {
+	if keep, ok := ns.Properties["backup_keep"].(float64); ok && keep >= 1 {
+		return int(keep)
+	}
+	return DefaultBackupKeep
+}
+
+// SetBackupKeep sets how many backups are kept, and drops the extra ones
+func (ns *NoteSet) SetBackupKeep(keep int) {
+	ns.Properties["backup_keep"] = float64(keep)
+	ns.pruneBackups()
+	ns.Save()
+}
+
+// Backups returns the data file's backups, newest first
+func (ns *NoteSet) Backups() []Backup {
+	entries, err := os.ReadDir(BackupDir())
+	if err != nil {
+		return nil
+	}
+	prefix := ns.backupPrefix()
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json")
+		if len(stamp) < len(backupTimeLayout) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeLayout, stamp[:len(backupTimeLayout)], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Path:   filepath.Join(BackupDir(), name),
+			Time:   t,
+			Reason: strings.TrimPrefix(stamp[len(backupTimeLayout):], "-"),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups
+}
+
+// Backup takes a snapshot of the notes, then drops the backups beyond BackupKeep
+func (ns *NoteSet) Backup(reason string) (Backup, error) {
+	if err := os.MkdirAll(BackupDir(), 0755); err != nil {
+		return Backup{}, err
+	}
+	now := time.Now()
+	name := ns.backupPrefix() + now.Format(backupTimeLayout) + "-" + reason + ".json"
+	data, err := ns.encode([]byte(ns.Dumps()))
+	if err != nil {
+		return Backup{}, err
+	}
+	backup := Backup{Path: filepath.Join(BackupDir(), name), Time: now, Reason: reason}
+	if err := os.WriteFile(backup.Path, data, 0600); err != nil {
+		return Backup{}, err
+	}
+	ns.pruneBackups()
+	return backup, nil
+}
+
+// readBackups returns the notes JSON of every backup, by path
+func (ns *NoteSet) readBackups() map[string][]byte {
+	plains := make(map[string][]byte)
+	for _, backup := range ns.Backups() {
+		data, err := os.ReadFile(backup.Path)
+		if err == nil {
+			data, err = ns.decode(data)
+		}
+		if err != nil {
+			fmt.Printf("[Backup] Failed to read %s: %v\n", backup.Path, err)
+			continue
+		}
+		plains[backup.Path] = data
+	}
+	return plains
+}
+
+// writeBackups writes the backups read with readBackups again, encrypted or not as
+// the data file now is
+func (ns *NoteSet) writeBackups(plains map[string][]byte) {
+	for path, plain := range plains {
+		data, err := ns.encode(plain)
+		if err == nil {
+			err = os.WriteFile(path, data, 0600)
+		}
+		if err != nil {
+			fmt.Printf("[Backup] Failed to rewrite %s: %v\n", path, err)
+		}
+	}
+}
+
+// backupBefore takes a snapshot before a risky operation. A failure is only logged, it
+// doesn't stop the operation.
+func (ns *NoteSet) backupBefore(reason string) {
+	if _, err := ns.Backup(reason); err != nil {
+		fmt.Printf("[Backup] Failed to back up before %s: %v\n", reason, err)
+	}
+}
+
+// pruneBackups drops the oldest backups beyond BackupKeep
+func (ns *NoteSet) pruneBackups() {
+	backups := ns.Backups()
+	for i := ns.BackupKeep(); i < len(backups); i++ {
+		os.Remove(backups[i].Path)
+	}
+}
+
+// backupDue reports whether a scheduled backup is due
+func (ns *NoteSet) backupDue() bool {
+	for _, backup := range ns.Backups() {
+		if backup.Reason == BackupScheduled {
+			return time.Since(backup.Time) >= backupInterval
+		}
+	}
+	return true
+}
+
+// WatchBackups takes the scheduled backups, now if one is due and then daily
+func WatchBackups(ns *NoteSet) {
+	check := func() {
+		if !ns.backupDue() {
+			return
+		}
+		if _, err := ns.Backup(BackupScheduled); err != nil {
+			fmt.Printf("[Backup] Failed to back up: %v\n", err)
+		}
+	}
+	check()
+	glib.TimeoutAdd(backupCheckInterval, func() bool {
+		check()
+		return true // Repeat
+	})
+}
+
+// RestoreBackup replaces all notes, categories and settings with the backup's. The current
+// state is backed up first, so the restore can be undone from the same list.
+func (ns *NoteSet) RestoreBackup(backup Backup) error {
+	data, err := os.ReadFile(backup.Path)
+	if err == nil {
+		data, err = ns.decode(data)
+	}
+	if err != nil {
+		return err
+	}
+	var check map[string]interface{}
+	if err := json.Unmarshal(data, &check); err != nil {
+		return fmt.Errorf("the backup is damaged: %w", err)
+	}
+
+	FinishPendingDelete()
+	for _, note := range ns.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateNote()
+		}
+	}
+	if _, err := ns.Backup(BackupRestore); err != nil {
+		return err
+	}
+
+	ns.audit(AuditRestoreBackup, nil, fmt.Sprintf("%d notes replaced by the backup of %s", len(ns.Notes), FormatDateTime(backup.Time)))
+	for _, note := range ns.Notes {
+		note.destroyGUI()
+	}
+	if err := ns.Loads(string(data)); err != nil {
+		return err
+	}
+	ns.Save()
+	if allVisible, ok := ns.Properties["all_visible"].(bool); ok && allVisible {
+		ns.ShowAll()
+	}
+	return nil
+}
+
+// BackupWindow lists the backups, to restore one
+type BackupWindow struct {
+	NoteSet *NoteSet
+	Window  *gtk.Window
+	List    *gtk.ListBox
+	rows    map[int]Backup // ListBox row index to backup
+
+	// OnRestore is called after a backup was restored
+	OnRestore func()
+}
+
+// NewBackupWindow opens the list of backups
+func NewBackupWindow(noteset *NoteSet) *BackupWindow {
+	bw := &BackupWindow{
+		NoteSet: noteset,
+		rows:    make(map[int]Backup),
+	}
+
+	bw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	bw.Window.SetTitle("Restore from Backup")
+	bw.Window.SetDefaultSize(420, 380)
+	bw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	bw.List, _ = gtk.ListBoxNew()
+	bw.List.SetSelectionMode(gtk.SELECTION_SINGLE)
+	bw.List.Connect("row-activated", func(list *gtk.ListBox, row *gtk.ListBoxRow) {
+		if backup, ok := bw.rows[row.GetIndex()]; ok {
+			bw.restore(backup)
+		}
+	})
+	scrolled.Add(bw.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	// Retention setting
+	keepBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	keepLabel, _ := gtk.LabelNew("Keep the newest")
+	keepBox.PackStart(keepLabel, false, false, 0)
+	spinKeep, _ := gtk.SpinButtonNewWithRange(1, 500, 1)
+	spinKeep.SetValue(float64(noteset.BackupKeep()))
+	spinKeep.Connect("value-changed", func() {
+		noteset.SetBackupKeep(spinKeep.GetValueAsInt())
+		bw.refresh()
+	})
+	keepBox.PackStart(spinKeep, false, false, 0)
+	backupsLabel, _ := gtk.LabelNew("backups")
+	keepBox.PackStart(backupsLabel, false, false, 0)
+	box.PackStart(keepBox, false, false, 0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bNow, _ := gtk.ButtonNewWithLabel("Back Up Now")
+	bNow.Connect("clicked", func() {
+		if _, err := noteset.Backup(BackupScheduled); err != nil {
+			bw.showError("Error backing up notes.", err)
+		}
+		bw.refresh()
+	})
+	buttons.PackStart(bNow, false, false, 0)
+	bRestore, _ := gtk.ButtonNewWithLabel("Restore")
+	bRestore.Connect("clicked", func() {
+		if row := bw.List.GetSelectedRow(); row != nil {
+			if backup, ok := bw.rows[row.GetIndex()]; ok {
+				bw.restore(backup)
+			}
+		}
+	})
+	buttons.PackEnd(bRestore, false, false, 0)
+	box.PackStart(buttons, false, false, 0)
+
+	bw.Window.Add(box)
+	bw.refresh()
+	bw.Window.ShowAll()
+
+	return bw
+}
+
+// backupReasonLabels describe why a backup was taken
+var backupReasonLabels = map[string]string{
+	BackupScheduled:      "Automatic",
+	BackupImport:         "Before import",
+	BackupMerge:          "Before merging notes",
+	BackupDeleteCategory: "Before deleting a category",
+	BackupRestore:        "Before restoring a backup",
+	BackupSyncConflict:   "Before merging a sync conflict",
+}
+
+// refresh rebuilds the list of backups, newest first
+func (bw *BackupWindow) refresh() {
+	bw.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			bw.List.Remove(widget)
+		}
+	})
+	bw.rows = make(map[int]Backup)
+
+	backups := bw.NoteSet.Backups()
+	if len(backups) == 0 {
+		label, _ := gtk.LabelNew("No backups yet")
+		label.SetMarginTop(12)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		row.SetSelectable(false)
+		row.SetActivatable(false)
+		bw.List.Add(row)
+		bw.List.ShowAll()
+		return
+	}
+
+	for _, backup := range backups {
+		reason, ok := backupReasonLabels[backup.Reason]
+		if !ok {
+			reason = backup.Reason
+		}
+		label, _ := gtk.LabelNew("")
+		label.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s · %s</small>",
+			glib.MarkupEscapeText(FormatDateTime(backup.Time)),
+			glib.MarkupEscapeText(reason),
+			FormatAgo(backup.Time)))
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		bw.List.Add(row)
+		bw.rows[row.GetIndex()] = backup
+	}
+	bw.List.ShowAll()
+}
+
+// restore asks before replacing the notes with the backup's
+func (bw *BackupWindow) restore(backup Backup) {
+	dialog := gtk.MessageDialogNew(bw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Restore the backup from %s?", FormatDateTime(backup.Time))
+	dialog.FormatSecondaryText("All notes, categories and settings are replaced with the backup's. The current notes are backed up first.")
+	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Restore", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	if err := bw.NoteSet.RestoreBackup(backup); err != nil {
+		bw.showError("Error restoring the backup.", err)
+		return
+	}
+	bw.refresh()
+	if bw.OnRestore != nil {
+		bw.OnRestore()
+	}
+}
+
+func (bw *BackupWindow) showError(message string, err error) {
+	dialog := gtk.MessageDialogNew(bw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, message)
+	dialog.FormatSecondaryText("%s", err.Error())
+	dialog.Run()
+	dialog.Destroy()
+}
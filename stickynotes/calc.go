@@ -0,0 +1,356 @@
+package stickynotes
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Notes double as a calculator: typing "=" after an expression such as "12*4.5" or a
+// conversion such as "5 km in mi" completes the result, and "Calculate selection" does
+// the same for the selected text. Expressions take + - * / (or × ÷), ^ for powers,
+// parentheses and a % suffix for percentages ("200*15%"). Completing on "=" can be
+// turned off in Settings ("calc_on_equals"), and leaves dates and phone numbers alone.
+
+// calcUnit is a unit of measure: its dimension and its size in the base unit of the
+// dimension (temperatures also have an offset, see convertUnit)
+type calcUnit struct {
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+// calcUnits are the units conversions understand, by lowercase name
+var calcUnits = map[string]calcUnit{
+	// Length, in meters
+	"mm": {"length", 0.001, 0}, "cm": {"length", 0.01, 0}, "m": {"length", 1, 0}, "km": {"length", 1000, 0},
+	"in": {"length", 0.0254, 0}, "ft": {"length", 0.3048, 0}, "yd": {"length", 0.9144, 0}, "mi": {"length", 1609.344, 0},
+	// Mass, in grams
+	"mg": {"mass", 0.001, 0}, "g": {"mass", 1, 0}, "kg": {"mass", 1000, 0}, "t": {"mass", 1e6, 0},
+	"oz": {"mass", 28.349523125, 0}, "lb": {"mass", 453.59237, 0},
+	// Volume, in liters
+	"ml": {"volume", 0.001, 0}, "l": {"volume", 1, 0}, "cup": {"volume", 0.2365882365, 0},
+	"pt": {"volume", 0.473176473, 0}, "gal": {"volume", 3.785411784, 0},
+	// Time, in seconds
+	"ms": {"time", 0.001, 0}, "s": {"time", 1, 0}, "min": {"time", 60, 0}, "h": {"time", 3600, 0},
+	"day": {"time", 86400, 0}, "days": {"time", 86400, 0}, "week": {"time", 604800, 0}, "weeks": {"time", 604800, 0},
+	// Data, in bytes
+	"b": {"data", 1, 0}, "kb": {"data", 1e3, 0}, "mb": {"data", 1e6, 0}, "gb": {"data", 1e9, 0}, "tb": {"data", 1e12, 0},
+	"kib": {"data", 1 << 10, 0}, "mib": {"data", 1 << 20, 0}, "gib": {"data", 1 << 30, 0}, "tib": {"data", 1 << 40, 0},
+	// Temperature, in kelvin
+	"k": {"temperature", 1, 0}, "c": {"temperature", 1, 273.15}, "°c": {"temperature", 1, 273.15},
+	"f": {"temperature", 5.0 / 9, 459.67 * 5 / 9}, "°f": {"temperature", 5.0 / 9, 459.67 * 5 / 9},
+}
+
+var (
+	// calcConversionPattern matches "<expression> <unit> in|to <unit>"
+	calcConversionPattern = regexp.MustCompile(`^(.*?)\s*(°?[A-Za-z]+)\s+(?:in|to)\s+(°?[A-Za-z]+)$`)
+	// calcTailPattern matches the characters an expression can end a line with
+	calcTailPattern = regexp.MustCompile(`[0-9.\s+\-*/×÷^%()]+$`)
+	// calcConversionTailPattern matches a conversion ending a line
+	calcConversionTailPattern = regexp.MustCompile(`[0-9.\s+\-*/×÷^%()]*[0-9.)%]\s*°?[A-Za-z]+\s+(?:in|to)\s+°?[A-Za-z]+$`)
+	// calcDatePhonePattern matches what reads as a subtraction or division but is a date
+	// or a phone number: digit groups joined by single "-" ("2024-01-15", "555-1234"),
+	// or by two "/" ("01/15/2024")
+	calcDatePhonePattern = regexp.MustCompile(`^\d+(?:-\d+)+$|^\d+/\d+/\d+$`)
+)
+
+var errCalcSyntax = errors.New("not an expression")
+
+// calcParser evaluates an arithmetic expression by recursive descent
+type calcParser struct {
+	input []rune
+	pos   int
+	ops   int // Operators applied, an expression needs one to be worth completing
+}
+
+// evaluate computes the expression, with the number of operators it contains
+func evaluate(expr string) (float64, int, error) {
+	p := &calcParser{input: []rune(expr)}
+	value, err := p.sum()
+	if err != nil {
+		return 0, 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return 0, 0, errCalcSyntax
+	}
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, 0, errors.New("no finite result")
+	}
+	return value, p.ops, nil
+}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// next returns the next character after spaces without consuming it, 0 at the end
+func (p *calcParser) next() rune {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// sum := product (("+" | "-") product)*
+func (p *calcParser) sum() (float64, error) {
+	value, err := p.product()
+	for err == nil {
+		op := p.next()
+		if op != '+' && op != '-' {
+			break
+		}
+		p.pos++
+		var rhs float64
+		if rhs, err = p.product(); err == nil {
+			if op == '+' {
+				value += rhs
+			} else {
+				value -= rhs
+			}
+			p.ops++
+		}
+	}
+	return value, err
+}
+
+// product := power (("*" | "/" | "×" | "÷") power)*
+func (p *calcParser) product() (float64, error) {
+	value, err := p.power()
+	for err == nil {
+		op := p.next()
+		if op != '*' && op != '/' && op != '×' && op != '÷' {
+			break
+		}
+		p.pos++
+		var rhs float64
+		if rhs, err = p.power(); err == nil {
+			if op == '*' || op == '×' {
+				value *= rhs
+			} else if rhs == 0 {
+				return 0, errors.New("division by zero")
+			} else {
+				value /= rhs
+			}
+			p.ops++
+		}
+	}
+	return value, err
+}
+
+// power := unary ("^" power)?, right associative
+func (p *calcParser) power() (float64, error) {
+	value, err := p.unary()
+	if err != nil || p.next() != '^' {
+		return value, err
+	}
+	p.pos++
+	exponent, err := p.power()
+	p.ops++
+	return math.Pow(value, exponent), err
+}
+
+// unary := ("+" | "-") unary | primary "%"?
+func (p *calcParser) unary() (float64, error) {
+	switch p.next() {
+	case '-':
+		p.pos++
+		value, err := p.unary()
+		return -value, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	value, err := p.primary()
+	if err == nil && p.next() == '%' {
+		p.pos++
+		p.ops++
+		value /= 100
+	}
+	return value, err
+}
+
+// primary := number | "(" sum ")"
+func (p *calcParser) primary() (float64, error) {
+	if p.next() == '(' {
+		p.pos++
+		value, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ')' {
+			return 0, errCalcSyntax
+		}
+		p.pos++
+		return value, nil
+	}
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	return parseCalcNumber(string(p.input[start:p.pos]))
+}
+
+// parseCalcNumber parses a plain decimal number
+func parseCalcNumber(s string) (float64, error) {
+	if s == "" || s == "." {
+		return 0, errCalcSyntax
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errCalcSyntax
+	}
+	return value, nil
+}
+
+// convertUnit converts value from one unit to another of the same dimension
+func convertUnit(value float64, from, to string) (float64, error) {
+	src, ok1 := calcUnits[strings.ToLower(from)]
+	dst, ok2 := calcUnits[strings.ToLower(to)]
+	if !ok1 || !ok2 {
+		return 0, errors.New("unknown unit")
+	}
+	if src.dimension != dst.dimension {
+		return 0, errors.New("units of different kinds")
+	}
+	return (value*src.factor + src.offset - dst.offset) / dst.factor, nil
+}
+
+// calculate evaluates an expression or a unit conversion, ok=false when text is neither
+// or is a bare number, which is not worth a result
+func calculate(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if m := calcConversionPattern.FindStringSubmatch(text); m != nil {
+		value, _, err := evaluate(m[1])
+		if err != nil {
+			return "", false
+		}
+		converted, err := convertUnit(value, m[2], m[3])
+		if err != nil {
+			return "", false
+		}
+		return formatCalcResult(converted) + " " + m[3], true
+	}
+	value, ops, err := evaluate(text)
+	if err != nil || ops == 0 {
+		return "", false
+	}
+	return formatCalcResult(value), true
+}
+
+// formatCalcResult writes a result with up to 10 significant digits, so 0.1+0.2 gives 0.3
+func formatCalcResult(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatFloat(value, 'g', 10, 64)
+}
+
+// CalcOnEquals reports whether typing "=" after an expression completes its result, on
+// unless turned off in settings
+func (ns *NoteSet) CalcOnEquals() bool {
+	enabled, ok := ns.Properties["calc_on_equals"].(bool)
+	return enabled || !ok
+}
+
+// calculateLineEnd evaluates the expression or conversion the line ends with: the
+// longest one that works, e.g. "4*5" in "Item 3 4*5". Dates and phone numbers aren't
+// taken for expressions.
+func calculateLineEnd(line string) (string, bool) {
+	tail := calcConversionTailPattern.FindString(line)
+	if tail == "" {
+		tail = calcTailPattern.FindString(line)
+	}
+	for start := 0; start < len(tail); start++ {
+		// Only start at the beginning of a word
+		if start > 0 && !unicode.IsSpace(rune(tail[start-1])) || unicode.IsSpace(rune(tail[start])) {
+			continue
+		}
+		if calcDatePhonePattern.MatchString(strings.TrimSpace(tail[start:])) {
+			continue
+		}
+		if result, ok := calculate(tail[start:]); ok {
+			return result, true
+		}
+	}
+	return "", false
+}
+
+// onCalcKeyPress completes the result when "=" is typed after an expression
+func (sn *StickyNote) onCalcKeyPress(tv *gtk.TextView, event *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+	if keyEvent.KeyVal() != gdk.KEY_equal && keyEvent.KeyVal() != gdk.KEY_KP_Equal {
+		return false
+	}
+	if gdk.ModifierType(keyEvent.State())&(gdk.CONTROL_MASK|gdk.MOD1_MASK) != 0 {
+		return false
+	}
+	if sn.InViewMode() || sn.BBody == nil || sn.BBody.GetHasSelection() || !sn.NoteSet.CalcOnEquals() {
+		return false
+	}
+
+	cursor := sn.BBody.GetIterAtMark(sn.BBody.GetInsert())
+	// Code blocks are left alone
+	start, end := sn.BBody.GetBounds()
+	text, _ := sn.BBody.GetText(start, end, true)
+	for _, block := range codeBlockLines(text) {
+		if line := cursor.GetLine(); line >= block[0] && line <= block[1] {
+			return false
+		}
+	}
+	lineStart := sn.BBody.GetIterAtLine(cursor.GetLine())
+	result, ok := calculateLineEnd(lineStart.GetText(cursor))
+	if !ok {
+		return false
+	}
+
+	sn.BBody.BeginUserAction()
+	defer sn.BBody.EndUserAction()
+	sn.BBody.InsertAtCursor("=" + result)
+	tv.ScrollMarkOnscreen(sn.BBody.GetInsert())
+	return true
+}
+
+// CalculateSelection adds the result of the selected expression (or of the one the
+// cursor's line ends with) after it
+func (sn *StickyNote) CalculateSelection() {
+	if sn.InViewMode() || sn.BBody == nil {
+		return
+	}
+	var result string
+	var ok bool
+	var at *gtk.TextIter
+	if start, end, selected := sn.BBody.GetSelectionBounds(); selected {
+		text, _ := sn.BBody.GetText(start, end, false)
+		result, ok = calculate(strings.TrimSuffix(strings.TrimSpace(text), "="))
+		at = end
+	} else {
+		cursor := sn.BBody.GetIterAtMark(sn.BBody.GetInsert())
+		at = sn.BBody.GetIterAtLine(cursor.GetLine())
+		if !at.EndsLine() {
+			at.ForwardToLineEnd()
+		}
+		text, _ := sn.BBody.GetText(sn.BBody.GetIterAtLine(cursor.GetLine()), at, false)
+		result, ok = calculateLineEnd(strings.TrimSuffix(strings.TrimRight(text, " "), "="))
+	}
+	if !ok {
+		if display, err := gdk.DisplayGetDefault(); err == nil {
+			display.Beep()
+		}
+		return
+	}
+
+	sn.BBody.BeginUserAction()
+	defer sn.BBody.EndUserAction()
+	sn.BBody.PlaceCursor(at)
+	sn.BBody.InsertAtCursor(" = " + result)
+}
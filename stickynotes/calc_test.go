@@ -0,0 +1,73 @@
+package stickynotes
+
+import "testing"
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		text   string
+		result string
+		ok     bool
+	}{
+		{"12*4.5", "54", true},
+		{"1 + 2 * 3", "7", true},
+		{"(1 + 2) * 3", "9", true},
+		{"2^3^2", "512", true},
+		{"-2^2", "4", true},
+		{"10 / 4", "2.5", true},
+		{"0.1+0.2", "0.3", true},
+		{"1/3", "0.3333333333", true},
+		{"200*15%", "30", true},
+		{"12 × 3 ÷ 4", "9", true},
+		{"5 km in mi", "3.106855961 mi", true},
+		{"12 in to cm", "30.48 cm", true},
+		{"2*1.5 kg in lb", "6.613867866 lb", true},
+		{"100 C in F", "212 F", true},
+		{"1 GiB in MB", "1073.741824 MB", true},
+		// Not worth a result, or not an expression
+		{"42", "", false},
+		{"(42)", "", false},
+		{"1/0", "", false},
+		{"10^400", "", false},
+		{"1 +", "", false},
+		{"(1 + 2", "", false},
+		{"1..2 + 3", "", false},
+		{"5 km in kg", "", false},
+		{"5 parsecs in m", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		result, ok := calculate(tt.text)
+		if result != tt.result || ok != tt.ok {
+			t.Errorf("calculate(%q) = %q, %v; want %q, %v", tt.text, result, ok, tt.result, tt.ok)
+		}
+	}
+}
+
+func TestCalculateLineEnd(t *testing.T) {
+	tests := []struct {
+		line   string
+		result string
+		ok     bool
+	}{
+		{"12*4.5", "54", true},
+		{"Rent share: 1200/3", "400", true},
+		{"Item 3 4*5", "20", true},
+		{"- milk 2*1.25", "2.5", true},
+		{"Run 5 km in mi", "3.106855961 mi", true},
+		{"x", "", false},
+		{"a = b", "", false},
+		{"Call at 5", "", false},
+		{"Deadline 2024-01-15", "", false},
+		{"Call 555-1234", "", false},
+		{"Due 01/15/2024", "", false},
+		{"Left: 10 - 3", "7", true},
+		{"(10-3)*2", "14", true},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		result, ok := calculateLineEnd(tt.line)
+		if result != tt.result || ok != tt.ok {
+			t.Errorf("calculateLineEnd(%q) = %q, %v; want %q, %v", tt.line, result, ok, tt.result, tt.ok)
+		}
+	}
+}
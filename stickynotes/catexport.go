@@ -0,0 +1,206 @@
+package stickynotes
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Exports and imports can be limited to some categories, e.g. to share only the "Work"
+// notes with a colleague. A note without a category the file has counts as being in its
+// default category; exported notes name their category, so they land in the same one
+// on import. A partial export has only the notes, their categories and attachments: no
+// trash and no settings.
+
+// FileCategory is a category of a notes file
+type FileCategory struct {
+	ID    string // "" for notes without a category
+	Name  string
+	Notes int // How many notes are in it
+}
+
+// fileCategoryOf returns the category a note of a notes file is in, resolving a missing
+// or unknown one to the default category
+func fileCategoryOf(note, categories map[string]interface{}, defaultCat string) string {
+	cat, _ := note["cat"].(string)
+	if _, ok := categories[cat]; ok {
+		return cat
+	}
+	if _, ok := categories[defaultCat]; ok {
+		return defaultCat
+	}
+	return ""
+}
+
+// fileDefaultCategory returns the default category of a parsed notes file
+func fileDefaultCategory(jdata map[string]interface{}) string {
+	props, _ := jdata["properties"].(map[string]interface{})
+	defaultCat, _ := props["default_cat"].(string)
+	return defaultCat
+}
+
+// FileCategories lists the categories of a notes file that have notes, by name
+func FileCategories(data string) ([]FileCategory, error) {
+	jdata, err := parseNoteSet(data)
+	if err != nil {
+		return nil, err
+	}
+	categories, _ := jdata["categories"].(map[string]interface{})
+	defaultCat := fileDefaultCategory(jdata)
+	notes, _ := jdata["notes"].([]interface{})
+
+	counts := make(map[string]int)
+	for _, item := range notes {
+		if note, ok := item.(map[string]interface{}); ok {
+			counts[fileCategoryOf(note, categories, defaultCat)]++
+		}
+	}
+	result := make([]FileCategory, 0, len(counts))
+	for cat, count := range counts {
+		name := "Uncategorized"
+		if cat != "" {
+			catData, _ := categories[cat].(map[string]interface{})
+			if name, _ = catData["name"].(string); name == "" {
+				name = "New Category"
+			}
+		}
+		result = append(result, FileCategory{ID: cat, Name: name, Notes: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if a, b := strings.ToLower(result[i].Name), strings.ToLower(result[j].Name); a != b {
+			return a < b
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// ExportCategories serializes the notes of the categories like Export, with those
+// categories and the notes' attachments
+func (ns *NoteSet) ExportCategories(cats []string) string {
+	defaultCat, _ := ns.Properties["default_cat"].(string)
+	notes := []interface{}{}
+	var kept []*Note
+	for _, note := range ns.Notes {
+		cat := note.Category
+		if !ns.HasCategory(cat) {
+			cat = defaultCat
+		}
+		if !ns.HasCategory(cat) {
+			cat = ""
+		}
+		if !slices.Contains(cats, cat) {
+			continue
+		}
+		content := note.Extract()
+		content["cat"] = cat
+		notes = append(notes, content)
+		kept = append(kept, note)
+	}
+	categories := make(map[string]interface{})
+	for _, cat := range cats {
+		if catData, ok := ns.Categories[cat]; ok {
+			categories[cat] = catData
+		}
+	}
+
+	data := map[string]interface{}{"notes": notes, "categories": categories}
+	if attachments := ns.exportAttachments(kept); len(attachments) > 0 {
+		data["attachments"] = attachments
+	}
+	return marshalNoteSet(data)
+}
+
+// MergeCategories merges the notes of the categories of a notes file like Merge, with
+// those categories and the notes' attachments
+func (ns *NoteSet) MergeCategories(data string, cats []string) error {
+	jdata, err := parseNoteSet(data)
+	if err != nil {
+		return err
+	}
+	if _, ok := jdata["notes"].([]interface{}); !ok {
+		return errors.New("no notes in the file")
+	}
+	selectCategories(jdata, cats)
+	return ns.mergeData(jdata)
+}
+
+// selectCategories keeps the notes of the categories in a parsed notes file, with those
+// categories and the notes' attachments. The trash and settings are dropped.
+func selectCategories(jdata map[string]interface{}, cats []string) {
+	categories, _ := jdata["categories"].(map[string]interface{})
+	defaultCat := fileDefaultCategory(jdata)
+	notes, _ := jdata["notes"].([]interface{})
+	attachments, _ := jdata["attachments"].(map[string]interface{})
+
+	keptNotes := []interface{}{}
+	keptAttachments := make(map[string]interface{})
+	for _, item := range notes {
+		note, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cat := fileCategoryOf(note, categories, defaultCat)
+		if !slices.Contains(cats, cat) {
+			continue
+		}
+		if cat != "" {
+			note["cat"] = cat
+		}
+		keptNotes = append(keptNotes, note)
+		if id, ok := note["uuid"].(string); ok && attachments[id] != nil {
+			keptAttachments[id] = attachments[id]
+		}
+	}
+	keptCategories := make(map[string]interface{})
+	for _, cat := range cats {
+		if catData, ok := categories[cat]; ok {
+			keptCategories[cat] = catData
+		}
+	}
+
+	for key := range jdata {
+		delete(jdata, key)
+	}
+	jdata["notes"] = keptNotes
+	jdata["categories"] = keptCategories
+	if len(keptAttachments) > 0 {
+		jdata["attachments"] = keptAttachments
+	}
+}
+
+// ChooseCategories asks which of the categories to export or import, all checked at
+// first. Returns the ids of the checked ones, or false when cancelled.
+func ChooseCategories(title, action string, cats []FileCategory) ([]string, bool) {
+	dialog, _ := gtk.DialogNewWithButtons(title, nil, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+		[]interface{}{action, gtk.RESPONSE_ACCEPT})
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetBorderWidth(8)
+	label, _ := gtk.LabelNew("Only the notes of the checked categories are included:")
+	label.SetHAlign(gtk.ALIGN_START)
+	content.PackStart(label, false, false, 0)
+
+	checks := make([]*gtk.CheckButton, len(cats))
+	for i, cat := range cats {
+		checks[i], _ = gtk.CheckButtonNewWithLabel(fmt.Sprintf("%s (%d)", cat.Name, cat.Notes))
+		checks[i].SetActive(true)
+		content.PackStart(checks[i], false, false, 0)
+	}
+	dialog.ShowAll()
+	response := dialog.Run()
+	var selected []string
+	for i, check := range checks {
+		if check.GetActive() {
+			selected = append(selected, cats[i].ID)
+		}
+	}
+	dialog.Destroy()
+	return selected, response == gtk.RESPONSE_ACCEPT
+}
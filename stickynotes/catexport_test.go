@@ -0,0 +1,67 @@
+package stickynotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileCategories(t *testing.T) {
+	cats, err := FileCategories(testNoteSetJSON)
+	if err != nil {
+		t.Fatalf("FileCategories: %v", err)
+	}
+	// note-0002 has no category, so it is in the default one
+	want := []FileCategory{{ID: "cat-b", Name: "Home", Notes: 1}, {ID: "cat-a", Name: "Work", Notes: 1}}
+	if !reflect.DeepEqual(cats, want) {
+		t.Errorf("FileCategories = %+v, want %+v", cats, want)
+	}
+
+	cats, _ = FileCategories(`{"notes": [{"body": "a", "cat": "gone"}, {"body": "b"}]}`)
+	if want := []FileCategory{{ID: "", Name: "Uncategorized", Notes: 2}}; !reflect.DeepEqual(cats, want) {
+		t.Errorf("FileCategories without categories = %+v, want %+v", cats, want)
+	}
+}
+
+func TestExportCategories(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	export := ns.ExportCategories([]string{"cat-a"})
+
+	other := newTestNoteSet(t)
+	if err := other.Loads(`{"categories": {"cat-c": {"name": "Mine"}}, "notes": []}`); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if err := other.Merge(export); err != nil {
+		t.Fatalf("Merge of the export: %v", err)
+	}
+	if len(other.Notes) != 1 || other.Notes[0].UUID != "note-0002" || other.Notes[0].Category != "cat-a" {
+		t.Fatalf("imported notes = %+v, want only note-0002 in its category", other.Notes)
+	}
+	if !other.HasCategory("cat-a") || other.HasCategory("cat-b") || !other.HasCategory("cat-c") {
+		t.Errorf("categories after the import = %v, want the exported one added", other.Categories)
+	}
+	if len(other.Trash) != 0 {
+		t.Errorf("the trash was exported: %+v", other.Trash)
+	}
+}
+
+func TestMergeCategories(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(`{"notes": []}`); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if err := ns.MergeCategories(testNoteSetJSON, []string{"cat-b"}); err != nil {
+		t.Fatalf("MergeCategories: %v", err)
+	}
+	if len(ns.Notes) != 1 || ns.Notes[0].UUID != "note-0001" {
+		t.Fatalf("imported notes = %+v, want only note-0001", ns.Notes)
+	}
+	if !ns.HasCategory("cat-b") || ns.HasCategory("cat-a") {
+		t.Errorf("categories after the import = %v, want only the chosen one", ns.Categories)
+	}
+	if err := ns.MergeCategories(`{"categories": {}}`, []string{"cat-b"}); err == nil {
+		t.Error("MergeCategories of a file without notes succeeded, want an error")
+	}
+}
@@ -0,0 +1,104 @@
+package stickynotes
+
+/*
+#cgo pkg-config: gtk+-3.0
+#include <stdlib.h>
+#include <gtk/gtk.h>
+
+// GtkImageMenuItem is deprecated, but its image is the only one the indicator menu,
+// exported over D-Bus, shows. gotk3 doesn't bind it.
+static GtkWidget *image_menu_item(const char *label, GdkPixbuf *pixbuf) {
+	G_GNUC_BEGIN_IGNORE_DEPRECATIONS
+	GtkWidget *item = gtk_image_menu_item_new_with_label(label);
+	gtk_image_menu_item_set_image(GTK_IMAGE_MENU_ITEM(item), gtk_image_new_from_pixbuf(pixbuf));
+	gtk_image_menu_item_set_always_show_image(GTK_IMAGE_MENU_ITEM(item), TRUE);
+	G_GNUC_END_IGNORE_DEPRECATIONS
+	return item;
+}
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// The indicator's "Categories" submenu lists each category with a swatch of its color,
+// to show, hide or add a note to it (built in main.go from these).
+
+// categoryIconSize is the size (px) of a category's swatch in menus
+const categoryIconSize = 16
+
+// CategoryIcon returns a swatch of the category's background color, nil when it has none
+func (ns *NoteSet) CategoryIcon(cat string) *gdk.Pixbuf {
+	hsv, ok := floatList(ns.GetCategoryProperty(cat, "bgcolor_hsv"))
+	if !ok || len(hsv) < 3 {
+		return nil
+	}
+	pixbuf, err := gdk.PixbufNew(gdk.COLORSPACE_RGB, true, 8, categoryIconSize, categoryIconSize)
+	if err != nil {
+		return nil
+	}
+	rgb := hsvToRGB(hsv[0], hsv[1], hsv[2])
+	pixbuf.Fill(uint32(rgb[0]*255)<<24 | uint32(rgb[1]*255)<<16 | uint32(rgb[2]*255)<<8 | 0xff)
+	return pixbuf
+}
+
+// ImageMenuItem returns a menu item with an icon, shown in the indicator menu too. Without
+// an icon it is a plain menu item.
+func ImageMenuItem(label string, icon *gdk.Pixbuf) *gtk.MenuItem {
+	if icon == nil {
+		item, _ := gtk.MenuItemNewWithLabel(label)
+		return item
+	}
+	cLabel := C.CString(label)
+	defer C.free(unsafe.Pointer(cLabel))
+	widget := C.image_menu_item(cLabel, (*C.GdkPixbuf)(unsafe.Pointer(icon.Native())))
+	obj := glib.Take(unsafe.Pointer(widget))
+	return &gtk.MenuItem{Bin: gtk.Bin{Container: gtk.Container{Widget: gtk.Widget{InitiallyUnowned: glib.InitiallyUnowned{Object: obj}}}}}
+}
+
+// effectiveCategory returns the category a note counts as being in: the default category
+// when it has none, or one that doesn't exist
+func (ns *NoteSet) effectiveCategory(note *Note) string {
+	if note.Category == "" || !ns.HasCategory(note.Category) {
+		defaultCat, _ := ns.Properties["default_cat"].(string)
+		return defaultCat
+	}
+	return note.Category
+}
+
+// SetCategoryShown shows or hides the notes in the category, leaving the others as they are
+func (ns *NoteSet) SetCategoryShown(cat string, shown bool) {
+	for _, note := range ns.Notes {
+		if ns.effectiveCategory(note) != cat {
+			continue
+		}
+		if shown {
+			note.Show()
+		} else {
+			if note.GUI != nil {
+				note.GUI.UpdateNote()
+			}
+			note.Hide()
+		}
+	}
+	if shown {
+		ns.Properties["all_visible"] = true
+	}
+	ns.Save()
+}
+
+// CategoryNoteCount returns how many notes are in the category
+func (ns *NoteSet) CategoryNoteCount(cat string) int {
+	count := 0
+	for _, note := range ns.Notes {
+		if ns.effectiveCategory(note) == cat {
+			count++
+		}
+	}
+	return count
+}
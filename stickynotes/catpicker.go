@@ -0,0 +1,224 @@
+package stickynotes
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// fuzzyScore scores how well query matches name as an in-order subsequence of its
+// characters (case-insensitive). Returns -1 when it doesn't match. Consecutive
+// characters and matches at the start of words score higher.
+func fuzzyScore(query, name string) int {
+	q := []rune(strings.ToLower(strings.TrimSpace(query)))
+	n := []rune(strings.ToLower(name))
+	if len(q) == 0 {
+		return 0
+	}
+
+	score, qi, prev := 0, 0, -2
+	for i, r := range n {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(n[i-1]) && !unicode.IsDigit(n[i-1]) {
+			score += 3
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return -1
+	}
+	return score
+}
+
+// MatchCategories returns the ids of the categories whose name fuzzy-matches query,
+// best match first (all categories by name for an empty query)
+func (ns *NoteSet) MatchCategories(query string) []string {
+	type match struct {
+		cat   string
+		name  string
+		score int
+	}
+	var matches []match
+	for cat := range ns.Categories {
+		name := ns.CategoryName(cat)
+		if score := fuzzyScore(query, name); score >= 0 {
+			matches = append(matches, match{cat, name, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return strings.ToLower(matches[i].name) < strings.ToLower(matches[j].name)
+	})
+
+	cats := make([]string, len(matches))
+	for i, m := range matches {
+		cats[i] = m.cat
+	}
+	return cats
+}
+
+// CategoryPicker is a small keyboard-driven overlay to jump to a category:
+// Enter shows only the notes of the chosen category, Ctrl+Enter creates a note in it
+type CategoryPicker struct {
+	NoteSet *NoteSet
+	Window  *gtk.Window
+	Entry   *gtk.SearchEntry
+	List    *gtk.ListBox
+	cats    []string // ListBox row index to category id
+	newNote bool     // The next activation creates a note instead of filtering
+}
+
+// NewCategoryPicker opens the category picker
+func NewCategoryPicker(noteset *NoteSet) *CategoryPicker {
+	cp := &CategoryPicker{NoteSet: noteset}
+
+	cp.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	cp.Window.SetTitle("Pick Category")
+	cp.Window.SetDecorated(false)
+	cp.Window.SetKeepAbove(true)
+	cp.Window.SetSkipTaskbarHint(true)
+	cp.Window.SetTypeHint(gdk.WINDOW_TYPE_HINT_DIALOG)
+	cp.Window.SetDefaultSize(320, 280)
+	cp.Window.SetPosition(gtk.WIN_POS_CENTER)
+	cp.Window.Connect("key-press-event", cp.onKeyPress)
+	// Dismiss like a popup when focus moves elsewhere
+	cp.Window.Connect("focus-out-event", func() bool {
+		cp.Window.Destroy()
+		return false
+	})
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	cp.Entry, _ = gtk.SearchEntryNew()
+	cp.Entry.SetPlaceholderText("Category")
+	cp.Entry.Connect("search-changed", cp.refresh)
+	cp.Entry.Connect("activate", func() {
+		if row := cp.List.GetSelectedRow(); row != nil {
+			cp.onRowActivated(cp.List, row)
+		}
+	})
+	box.PackStart(cp.Entry, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	cp.List, _ = gtk.ListBoxNew()
+	cp.List.SetActivateOnSingleClick(true)
+	cp.List.Connect("row-activated", cp.onRowActivated)
+	scrolled.Add(cp.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	hint, _ := gtk.LabelNew("")
+	hint.SetMarkup("<small>Enter: show only this category · Ctrl+Enter: new note</small>")
+	hint.SetHAlign(gtk.ALIGN_START)
+	box.PackStart(hint, false, false, 0)
+
+	cp.Window.Add(box)
+	cp.refresh()
+	cp.Window.ShowAll()
+	cp.Window.Present()
+	cp.Entry.GrabFocus()
+
+	return cp
+}
+
+// refresh rebuilds the list for the current query and selects the best match
+func (cp *CategoryPicker) refresh() {
+	cp.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			cp.List.Remove(widget)
+		}
+	})
+
+	query, _ := cp.Entry.GetText()
+	cp.cats = cp.NoteSet.MatchCategories(query)
+	for _, cat := range cp.cats {
+		label, _ := gtk.LabelNew(cp.NoteSet.CategoryName(cat))
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		label.SetMarginTop(3)
+		label.SetMarginBottom(3)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		cp.List.Add(row)
+	}
+	cp.List.ShowAll()
+	if row := cp.List.GetRowAtIndex(0); row != nil {
+		cp.List.SelectRow(row)
+	}
+}
+
+// onKeyPress moves the selection with the arrow keys while typing, closes on Escape
+// and remembers whether Ctrl was held for Enter
+func (cp *CategoryPicker) onKeyPress(win *gtk.Window, event *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+	ctrl := gdk.ModifierType(keyEvent.State())&gdk.CONTROL_MASK != 0
+
+	switch keyEvent.KeyVal() {
+	case gdk.KEY_Escape:
+		cp.Window.Destroy()
+		return true
+	case gdk.KEY_Return, gdk.KEY_KP_Enter:
+		cp.newNote = ctrl
+		if row := cp.List.GetSelectedRow(); row != nil {
+			cp.onRowActivated(cp.List, row)
+		}
+		return true
+	case gdk.KEY_Down, gdk.KEY_Up:
+		row := cp.List.GetSelectedRow()
+		index := 0
+		if row != nil {
+			index = row.GetIndex()
+			if keyEvent.KeyVal() == gdk.KEY_Down {
+				index++
+			} else {
+				index--
+			}
+		}
+		if next := cp.List.GetRowAtIndex(index); next != nil {
+			cp.List.SelectRow(next)
+		}
+		return true
+	}
+	return false
+}
+
+func (cp *CategoryPicker) onRowActivated(list *gtk.ListBox, row *gtk.ListBoxRow) {
+	index := row.GetIndex()
+	if index < 0 || index >= len(cp.cats) {
+		return
+	}
+	cat := cp.cats[index]
+	newNote := cp.newNote
+	cp.Window.Destroy()
+
+	if newNote {
+		note := cp.NoteSet.NewInCategory(cat)
+		// Give the new window time to get its window-calls ID before raising it
+		glib.TimeoutAdd(400, func() bool {
+			if note.GUI != nil {
+				note.GUI.Raise()
+			}
+			return false // Don't repeat
+		})
+		return
+	}
+	cp.NoteSet.ShowCategory(cat)
+}
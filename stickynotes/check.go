@@ -0,0 +1,152 @@
+package stickynotes
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of data file problems found by Check
+const (
+	ProblemDuplicateUUID    = "duplicate-uuid"
+	ProblemMissingCategory  = "missing-category"
+	ProblemOrphanedCategory = "orphaned-category"
+	ProblemInvalidDefault   = "invalid-default-category"
+	ProblemInvalidPosition  = "invalid-position"
+	ProblemInvalidSize      = "invalid-size"
+)
+
+// maxCoordinate bounds sane window coordinates, anything beyond is treated as corrupt
+const maxCoordinate = 100000
+
+// DataProblem is an inconsistency in the noteset, with enough context to repair it
+type DataProblem struct {
+	Kind     string
+	Message  string
+	Note     *Note  // Affected note, if any
+	Category string // Affected category, if any
+}
+
+// Check validates the noteset: notes referencing missing categories, unnamed categories
+// no note uses, a missing default category, invalid positions and sizes, and duplicate UUIDs
+func (ns *NoteSet) Check() []DataProblem {
+	var problems []DataProblem
+
+	seen := make(map[string]bool)
+	used := make(map[string]bool)
+	for _, note := range ns.Notes {
+		title := noteLabel(note)
+
+		if seen[note.UUID] {
+			problems = append(problems, DataProblem{
+				Kind:    ProblemDuplicateUUID,
+				Message: fmt.Sprintf("Note %q has the same UUID as another note (%s)", title, note.UUID),
+				Note:    note,
+			})
+		}
+		seen[note.UUID] = true
+
+		if note.Category != "" && !ns.HasCategory(note.Category) {
+			problems = append(problems, DataProblem{
+				Kind:     ProblemMissingCategory,
+				Message:  fmt.Sprintf("Note %q references missing category %q", title, note.Category),
+				Note:     note,
+				Category: note.Category,
+			})
+		}
+		used[note.Category] = true
+
+		if pos, ok := note.Properties["position"]; ok && !validCoordinates(pos, false) {
+			problems = append(problems, DataProblem{
+				Kind:    ProblemInvalidPosition,
+				Message: fmt.Sprintf("Note %q has an invalid position %v", title, pos),
+				Note:    note,
+			})
+		}
+		if size, ok := note.Properties["size"]; ok && !validCoordinates(size, true) {
+			problems = append(problems, DataProblem{
+				Kind:    ProblemInvalidSize,
+				Message: fmt.Sprintf("Note %q has an invalid size %v", title, size),
+				Note:    note,
+			})
+		}
+	}
+
+	defaultCat, _ := ns.Properties["default_cat"].(string)
+	if defaultCat != "" && !ns.HasCategory(defaultCat) {
+		problems = append(problems, DataProblem{
+			Kind:     ProblemInvalidDefault,
+			Message:  fmt.Sprintf("Default category %q does not exist", defaultCat),
+			Category: defaultCat,
+		})
+	}
+
+	cats := make([]string, 0, len(ns.Categories))
+	for cat := range ns.Categories {
+		cats = append(cats, cat)
+	}
+	sort.Strings(cats)
+	for _, cat := range cats {
+		name, _ := ns.Categories[cat]["name"].(string)
+		if !used[cat] && cat != defaultCat && name == "" {
+			problems = append(problems, DataProblem{
+				Kind:     ProblemOrphanedCategory,
+				Message:  fmt.Sprintf("Category %q has no name and no notes", cat),
+				Category: cat,
+			})
+		}
+	}
+
+	return problems
+}
+
+// Repair fixes a problem found by Check. The caller is responsible for saving.
+func (ns *NoteSet) Repair(p DataProblem) {
+	switch p.Kind {
+	case ProblemDuplicateUUID:
+		p.Note.UUID = uuid.New().String()
+	case ProblemMissingCategory:
+		// Fall back to the default category
+		p.Note.Category = ""
+	case ProblemOrphanedCategory:
+		delete(ns.Categories, p.Category)
+	case ProblemInvalidDefault:
+		delete(ns.Properties, "default_cat")
+	case ProblemInvalidPosition:
+		// The note is cascaded to a fresh position when shown
+		delete(p.Note.Properties, "position")
+	case ProblemInvalidSize:
+		delete(p.Note.Properties, "size")
+	}
+}
+
+// validCoordinates checks a JSON-decoded [x, y] or [w, h] pair
+func validCoordinates(val interface{}, positive bool) bool {
+	coords, ok := floatList(val)
+	if !ok || len(coords) != 2 {
+		return false
+	}
+	for _, c := range coords {
+		if math.IsNaN(c) || math.IsInf(c, 0) || math.Abs(c) > maxCoordinate {
+			return false
+		}
+		if positive && c <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// noteLabel names a note in reports
+func noteLabel(note *Note) string {
+	title := note.FirstLine()
+	if title == "" {
+		return "(empty note)"
+	}
+	if runes := []rune(title); len(runes) > 40 {
+		return string(runes[:40]) + "…"
+	}
+	return title
+}
@@ -0,0 +1,98 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A single note can be moved between profiles or machines through the clipboard: "Copy
+// as JSON" copies the note as saved in the data file (Extract), and "Paste Note" adds it
+// to the noteset as a new note. Plain text in the clipboard becomes the body of a new note.
+
+// ErrNoNoteInClipboard is returned when the clipboard holds neither a note nor text
+var ErrNoNoteInClipboard = errors.New("the clipboard doesn't hold a note or text")
+
+// CopyJSON copies the note to the clipboard as JSON
+func (n *Note) CopyJSON() error {
+	data, err := json.MarshalIndent(n.Extract(), "", "  ")
+	if err != nil {
+		return err
+	}
+	clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	if err != nil {
+		return err
+	}
+	clipboard.SetText(string(data))
+	return nil
+}
+
+// PasteNote adds the note copied with CopyJSON, or a note with the copied text, from
+// the clipboard. A note whose UUID is already used gets a new one.
+func (ns *NoteSet) PasteNote() (*Note, error) {
+	clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	if err != nil {
+		return nil, err
+	}
+	text, err := clipboard.WaitForText()
+	if err != nil || strings.TrimSpace(text) == "" {
+		return nil, ErrNoNoteInClipboard
+	}
+
+	content := parseNoteJSON(text)
+	if content == nil {
+		content = map[string]interface{}{"body": text}
+	}
+	// A pasted note is never in the trash, and categories are only known in their profile
+	delete(content, "deleted_at")
+	if cat, _ := content["cat"].(string); !ns.HasCategory(cat) {
+		delete(content, "cat")
+	}
+	if uuidStr, _ := content["uuid"].(string); uuidStr != "" && ns.hasUUID(uuidStr) {
+		delete(content, "uuid")
+	}
+
+	defaultCat, _ := ns.Properties["default_cat"].(string)
+	note := NewNote(content, NewStickyNote, ns, defaultCat)
+	ns.Notes = append(ns.Notes, note)
+	ns.RecordUsage(UsageImport)
+	note.Show()
+	ns.Save()
+	return note, nil
+}
+
+// parseNoteJSON returns the note content in text when it is a note copied as JSON,
+// nil otherwise
+func parseNoteJSON(text string) map[string]interface{} {
+	var content map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &content); err != nil {
+		return nil
+	}
+	if _, ok := content["body"].(string); !ok {
+		return nil
+	}
+	return content
+}
+
+// hasUUID reports whether a note or a note in the trash uses the UUID
+func (ns *NoteSet) hasUUID(uuid string) bool {
+	for _, notes := range [][]*Note{ns.Notes, ns.Trash} {
+		for _, note := range notes {
+			if note.UUID == uuid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (sn *StickyNote) onCopyJSON() {
+	if err := sn.Note.CopyJSON(); err != nil {
+		dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error copying note: %v", err)
+		dialog.Run()
+		dialog.Destroy()
+	}
+}
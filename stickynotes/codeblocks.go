@@ -0,0 +1,68 @@
+package stickynotes
+
+import (
+	"strings"
+)
+
+// codeFence opens and closes a code block, as in Markdown
+const codeFence = "```"
+
+// codeBlockShade is how much darker (in HSV value) code blocks are than the note background
+const codeBlockShade = 0.08
+
+// codeBlockLines returns the first and last line of every closed ``` block, fences included
+func codeBlockLines(text string) [][2]int {
+	var blocks [][2]int
+	open := -1
+	for i, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+			continue
+		}
+		if open < 0 {
+			open = i
+		} else {
+			blocks = append(blocks, [2]int{open, i})
+			open = -1
+		}
+	}
+	return blocks
+}
+
+// setupCodeBlocks creates the code block text tag and keeps it applied as the note is edited
+func (sn *StickyNote) setupCodeBlocks() {
+	sn.codeTag = sn.BBody.CreateTag("code", map[string]interface{}{
+		"family": "monospace",
+	})
+	sn.BBody.Connect("changed", sn.highlightCodeBlocks)
+	sn.highlightCodeBlocks()
+}
+
+// highlightCodeBlocks re-applies the code tag to the fenced blocks in the note
+func (sn *StickyNote) highlightCodeBlocks() {
+	if sn.codeTag == nil {
+		return
+	}
+	start, end := sn.BBody.GetBounds()
+	sn.BBody.RemoveTag(sn.codeTag, start, end)
+	text, _ := sn.BBody.GetText(start, end, true)
+
+	for _, block := range codeBlockLines(text) {
+		blockStart := sn.BBody.GetIterAtLine(block[0])
+		blockEnd := sn.BBody.GetIterAtLine(block[1])
+		blockEnd.ForwardToLineEnd()
+		sn.BBody.ApplyTag(sn.codeTag, blockStart, blockEnd)
+	}
+}
+
+// updateCodeBlockColor shades code blocks slightly darker than the note background
+func (sn *StickyNote) updateCodeBlockColor(bgHSV []float64) {
+	if sn.codeTag == nil || len(bgHSV) < 3 {
+		return
+	}
+	v := bgHSV[2] - codeBlockShade
+	if v < 0 {
+		v = 0
+	}
+	rgb := hsvToRGB(bgHSV[0], bgHSV[1], v)
+	sn.codeTag.SetProperty("paragraph-background", rgbToHex(rgb[0], rgb[1], rgb[2]))
+}
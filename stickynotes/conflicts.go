@@ -0,0 +1,295 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// When two devices change the shared data file before Syncthing syncs it, Syncthing keeps
+// one side as a "<name>.sync-conflict-<date>-<time>-<device><ext>" copy next to it. The
+// copy is offered for merging note by note: notes only in the copy can be added, and for
+// notes changed on both sides one version is kept while the other goes to the note's
+// history, like edits from another device (see sync.go). The copy is deleted once merged.
+
+// conflictWatchInterval is how often (ms) the data file's folder is checked for conflict copies
+const conflictWatchInterval = 30000
+
+// Ways to resolve a note of a conflict copy
+const (
+	conflictKeepMine   = "mine"
+	conflictTakeTheirs = "theirs"
+	conflictAdd        = "add"
+	conflictSkip       = "skip"
+)
+
+// ConflictNote is a note of a conflict copy that differs from the notes here
+type ConflictNote struct {
+	Theirs *Note
+	Mine   *Note // nil when the note is only in the copy
+}
+
+// ConflictCopies returns the Syncthing conflict copies of the data file, oldest first
+func (ns *NoteSet) ConflictCopies() []string {
+	path := ns.DataPath()
+	ext := filepath.Ext(path)
+	pattern := strings.TrimSuffix(path, ext) + ".sync-conflict-*" + ext
+	copies, _ := filepath.Glob(pattern)
+	sort.Strings(copies) // The date and time in the name sort chronologically
+	return copies
+}
+
+// ReadConflictCopy returns the notes of a conflict copy that differ from the notes here
+func (ns *NoteSet) ReadConflictCopy(path string) ([]ConflictNote, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = ns.decode(data); err != nil {
+		return nil, err
+	}
+	var conflict struct {
+		Notes []map[string]interface{} `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &conflict); err != nil {
+		return nil, err
+	}
+
+	var diffs []ConflictNote
+	for _, content := range conflict.Notes {
+		theirs := NewNote(content, nil, ns, "")
+		mine := ns.noteByUUID(theirs.UUID)
+		if mine != nil && mine.GUI != nil && mine.GUI.WinMain != nil {
+			mine.GUI.UpdateNote()
+		}
+		if mine != nil && mine.Body == theirs.Body {
+			continue
+		}
+		diffs = append(diffs, ConflictNote{Theirs: theirs, Mine: mine})
+	}
+	return diffs, nil
+}
+
+// defaultResolution picks the newer version of a changed note, and adds notes only in
+// the copy unless they were deleted here
+func (ns *NoteSet) defaultResolution(c ConflictNote) string {
+	if c.Mine == nil {
+		for _, note := range ns.Trash {
+			if note.UUID == c.Theirs.UUID {
+				return conflictSkip
+			}
+		}
+		return conflictAdd
+	}
+	if c.Theirs.Revision > c.Mine.Revision {
+		return conflictTakeTheirs
+	}
+	return conflictKeepMine
+}
+
+// resolve applies the chosen resolution of a note of a conflict copy
+func (ns *NoteSet) resolve(c ConflictNote, resolution string) {
+	switch resolution {
+	case conflictAdd:
+		note := NewNote(c.Theirs.Extract(), NewStickyNote, ns, "")
+		ns.Notes = append(ns.Notes, note)
+		note.Show()
+	case conflictKeepMine:
+		c.Mine.recordVersion(c.Theirs.Body)
+	case conflictTakeTheirs:
+		n := c.Mine
+		n.recordVersion(n.Body)
+		n.Body = c.Theirs.Body
+		n.Formatting = c.Theirs.Formatting
+		n.Tags = c.Theirs.Tags
+		n.LastModified = c.Theirs.LastModified
+		// Saved as newer than both sides, so the other device takes it in
+		n.Revision = max(n.Revision, c.Theirs.Revision) + 1
+		n.EditedOn = deviceName()
+		if n.GUI != nil && n.GUI.WinMain != nil {
+			n.GUI.reloadNote()
+		}
+	}
+}
+
+// WatchSyncConflicts offers to merge conflict copies of the data file as they appear
+func WatchSyncConflicts(ns *NoteSet) {
+	// Copies put aside with "Later" aren't offered again until restart
+	postponed := make(map[string]bool)
+	var open *ConflictWindow
+	glib.TimeoutAdd(conflictWatchInterval, func() bool {
+		if open != nil {
+			return true
+		}
+		for _, path := range ns.ConflictCopies() {
+			if postponed[path] {
+				continue
+			}
+			open = NewConflictWindow(ns, path)
+			if open == nil {
+				// Nothing to merge or unreadable, don't ask again
+				postponed[path] = true
+				continue
+			}
+			open.Window.Connect("destroy", func() {
+				if _, err := os.Stat(path); err == nil {
+					postponed[path] = true
+				}
+				open = nil
+			})
+			break
+		}
+		return true // Repeat
+	})
+}
+
+// ConflictWindow guides merging a conflict copy of the data file
+type ConflictWindow struct {
+	NoteSet *NoteSet
+	Path    string
+	Window  *gtk.Window
+	choices map[*gtk.ComboBoxText]ConflictNote
+}
+
+// NewConflictWindow opens the merge of a conflict copy. A copy without differences is
+// deleted right away and nil is returned, as is nil when the copy can't be read.
+func NewConflictWindow(noteset *NoteSet, path string) *ConflictWindow {
+	diffs, err := noteset.ReadConflictCopy(path)
+	if err != nil {
+		fmt.Printf("[Sync] Can't read conflict copy %s: %v\n", path, err)
+		return nil
+	}
+	if len(diffs) == 0 {
+		if err := os.Remove(path); err != nil {
+			fmt.Printf("[Sync] Can't delete conflict copy %s: %v\n", path, err)
+		}
+		return nil
+	}
+
+	cw := &ConflictWindow{
+		NoteSet: noteset,
+		Path:    path,
+		choices: make(map[*gtk.ComboBoxText]ConflictNote),
+	}
+
+	cw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	cw.Window.SetTitle("Sync Conflict")
+	cw.Window.SetDefaultSize(520, 420)
+	cw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	intro, _ := gtk.LabelNew(fmt.Sprintf("Syncthing kept a conflicting copy of your notes, %s. Choose what to keep of each note that differs; the version not kept goes to the note's history.",
+		filepath.Base(path)))
+	intro.SetLineWrap(true)
+	intro.SetXAlign(0)
+	box.PackStart(intro, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	for _, diff := range diffs {
+		list.Add(cw.buildRow(diff))
+	}
+	scrolled.Add(list)
+	box.PackStart(scrolled, true, true, 0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bDiscard, _ := gtk.ButtonNewWithLabel("Discard Copy")
+	bDiscard.SetTooltipText("Delete the conflict copy without merging it")
+	bDiscard.Connect("clicked", cw.onDiscard)
+	buttons.PackStart(bDiscard, false, false, 0)
+	bMerge, _ := gtk.ButtonNewWithLabel("Merge")
+	bMerge.Connect("clicked", cw.onMerge)
+	buttons.PackEnd(bMerge, false, false, 0)
+	bLater, _ := gtk.ButtonNewWithLabel("Later")
+	bLater.Connect("clicked", func() { cw.Window.Destroy() })
+	buttons.PackEnd(bLater, false, false, 0)
+	box.PackStart(buttons, false, false, 0)
+
+	cw.Window.Add(box)
+	cw.Window.ShowAll()
+	return cw
+}
+
+// buildRow shows a note that differs, with the choice of what to keep
+func (cw *ConflictWindow) buildRow(diff ConflictNote) *gtk.ListBoxRow {
+	row, _ := gtk.ListBoxRowNew()
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetBorderWidth(6)
+
+	label, _ := gtk.LabelNew("")
+	title := diff.Theirs.FirstLine()
+	detail := fmt.Sprintf("Only in the copy, edited on %s %s", diff.Theirs.EditedOn, FormatAgo(diff.Theirs.LastModified))
+	if diff.Mine != nil {
+		title = diff.Mine.FirstLine()
+		detail = fmt.Sprintf("Here: %s · Copy: %s on %s",
+			FormatAgo(diff.Mine.LastModified), FormatAgo(diff.Theirs.LastModified), diff.Theirs.EditedOn)
+	}
+	label.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s</small>",
+		glib.MarkupEscapeText(title), glib.MarkupEscapeText(detail)))
+	label.SetTooltipText(diff.Theirs.Body)
+	label.SetHAlign(gtk.ALIGN_START)
+	label.SetEllipsize(pango.ELLIPSIZE_END)
+	box.PackStart(label, true, true, 0)
+
+	choice, _ := gtk.ComboBoxTextNew()
+	if diff.Mine == nil {
+		choice.Append(conflictAdd, "Add")
+		choice.Append(conflictSkip, "Skip")
+	} else {
+		choice.Append(conflictKeepMine, "Keep mine")
+		choice.Append(conflictTakeTheirs, "Take theirs")
+	}
+	choice.SetActiveID(cw.NoteSet.defaultResolution(diff))
+	choice.SetVAlign(gtk.ALIGN_CENTER)
+	box.PackEnd(choice, false, false, 0)
+	cw.choices[choice] = diff
+
+	row.Add(box)
+	return row
+}
+
+// onMerge applies the choices and deletes the conflict copy
+func (cw *ConflictWindow) onMerge() {
+	cw.NoteSet.backupBefore(BackupSyncConflict)
+	for choice, diff := range cw.choices {
+		cw.NoteSet.resolve(diff, choice.GetActiveID())
+	}
+	cw.NoteSet.Save()
+	cw.removeCopy()
+}
+
+// onDiscard deletes the conflict copy after confirmation
+func (cw *ConflictWindow) onDiscard() {
+	dialog := gtk.MessageDialogNew(cw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Discard the conflict copy?")
+	dialog.FormatSecondaryText("The changes only in the copy are lost.")
+	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Discard", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+	if response == gtk.RESPONSE_ACCEPT {
+		cw.removeCopy()
+	}
+}
+
+// removeCopy deletes the conflict copy and closes the window
+func (cw *ConflictWindow) removeCopy() {
+	if err := os.Remove(cw.Path); err != nil && !os.IsNotExist(err) {
+		dialog := gtk.MessageDialogNew(cw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error deleting the conflict copy.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+	}
+	cw.Window.Destroy()
+}
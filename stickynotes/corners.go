@@ -0,0 +1,204 @@
+package stickynotes
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Notes can be pinned to a corner of a monitor, stored as "corner" (one of the Corner
+// constants) and "corner_monitor" (the monitor's manufacturer and model) in their
+// properties. The shown notes of a corner stack from it towards the middle of the
+// monitor in noteset order, starting a new column when one is full, and are laid out
+// again whenever a note is shown, hidden or pinned. Notes whose monitor isn't connected
+// go to the same corner of the primary monitor.
+
+// Corners a note can be pinned to
+const (
+	CornerNone        = ""
+	CornerTopLeft     = "top-left"
+	CornerTopRight    = "top-right"
+	CornerBottomLeft  = "bottom-left"
+	CornerBottomRight = "bottom-right"
+)
+
+// cornerOptions lists the corners with their labels, in menu order
+var cornerOptions = []struct {
+	Name  string
+	Label string
+}{
+	{CornerTopLeft, "Top left"},
+	{CornerTopRight, "Top right"},
+	{CornerBottomLeft, "Bottom left"},
+	{CornerBottomRight, "Bottom right"},
+}
+
+// cornerLayoutDelay (ms) lets newly shown windows get their window-calls ID before
+// they are moved, and gathers the shows and hides of ShowAll and HideAll into one layout
+const cornerLayoutDelay = 400
+
+// Corner returns the corner the note is pinned to, CornerNone when it isn't
+func (n *Note) Corner() string {
+	corner, _ := n.Properties["corner"].(string)
+	for _, option := range cornerOptions {
+		if option.Name == corner {
+			return corner
+		}
+	}
+	return CornerNone
+}
+
+// SetCorner pins the note to a corner of the monitor it is on, or unpins it with CornerNone
+func (sn *StickyNote) SetCorner(corner string) {
+	if corner == CornerNone {
+		delete(sn.Note.Properties, "corner")
+		delete(sn.Note.Properties, "corner_monitor")
+	} else {
+		sn.Note.Properties["corner"] = corner
+		sn.Note.Properties["corner_monitor"] = sn.monitorName()
+	}
+	sn.NoteSet.scheduleCornerLayout()
+	sn.NoteSet.Save()
+}
+
+// monitorName identifies a monitor across restarts and reconnections
+func monitorName(monitor *gdk.Monitor) string {
+	return strings.TrimSpace(monitor.GetManufacturer() + " " + monitor.GetModel())
+}
+
+// monitorName returns the name of the monitor the middle of the note is on
+func (sn *StickyNote) monitorName() string {
+	display, err := gdk.DisplayGetDefault()
+	if err != nil {
+		return ""
+	}
+	width, height := sn.LastKnownSize[0], sn.LastKnownSize[1]
+	if sn.WinMain != nil {
+		width, height = sn.WinMain.GetSize()
+	}
+	monitor, err := display.GetMonitorAtPoint(sn.LastKnownPos[0]+width/2, sn.LastKnownPos[1]+height/2)
+	if err != nil {
+		return ""
+	}
+	return monitorName(monitor)
+}
+
+// monitorWorkarea returns the usable area of the monitor with the given name, or of the
+// primary monitor when it isn't connected
+func monitorWorkarea(name string) (x, y, width, height int) {
+	if display, err := gdk.DisplayGetDefault(); err == nil && name != "" {
+		for i := 0; i < display.GetNMonitors(); i++ {
+			if monitor, err := display.GetMonitor(i); err == nil && monitorName(monitor) == name {
+				return monitor.GetWorkarea().GetRectangleInt()
+			}
+		}
+	}
+	return workarea()
+}
+
+// scheduleCornerLayout lays out the corners shortly, once for several calls
+func (ns *NoteSet) scheduleCornerLayout() {
+	if ns.cornerLayoutID != 0 || !slices.ContainsFunc(ns.Notes, func(note *Note) bool { return note.Corner() != CornerNone }) {
+		return
+	}
+	ns.cornerLayoutID = glib.TimeoutAdd(cornerLayoutDelay, func() bool {
+		ns.cornerLayoutID = 0
+		ns.LayoutCorners()
+		return false // Don't repeat
+	})
+}
+
+// LayoutCorners stacks the shown notes pinned to each corner of each monitor
+func (ns *NoteSet) LayoutCorners() {
+	type anchor struct{ corner, monitor string }
+	stacks := make(map[anchor][]*StickyNote)
+	var anchors []anchor
+	for _, note := range ns.Notes {
+		corner := note.Corner()
+		if corner == CornerNone || !isNoteShown(note) {
+			continue
+		}
+		monitor, _ := note.Properties["corner_monitor"].(string)
+		a := anchor{corner, monitor}
+		if _, ok := stacks[a]; !ok {
+			anchors = append(anchors, a)
+		}
+		stacks[a] = append(stacks[a], note.GUI)
+	}
+	if len(anchors) == 0 {
+		return
+	}
+
+	for _, a := range anchors {
+		notes := stacks[a]
+		sizes := make([][2]int, len(notes))
+		for i, sn := range notes {
+			width, height := sn.WinMain.GetSize()
+			sizes[i] = [2]int{width, height}
+		}
+		x, y, width, height := monitorWorkarea(a.monitor)
+		for i, pos := range cornerPositions(a.corner, [4]int{x, y, width, height}, sizes) {
+			if notes[i].LastKnownPos != pos {
+				notes[i].moveTo(pos[0], pos[1])
+			}
+		}
+	}
+	ns.Save()
+}
+
+// cornerPositions places notes of the given sizes in a corner of area (x, y, width,
+// height): stacked from the corner, in columns growing towards the other side
+func cornerPositions(corner string, area [4]int, sizes [][2]int) [][2]int {
+	right := corner == CornerTopRight || corner == CornerBottomRight
+	bottom := corner == CornerBottomLeft || corner == CornerBottomRight
+
+	positions := make([][2]int, len(sizes))
+	column, columnWidth, used := 0, 0, 0
+	for i, size := range sizes {
+		// A full column (but never an empty one) moves on to the next
+		if used > 0 && used+size[1] > area[3]-2*arrangeMargin {
+			column += columnWidth + arrangeMargin
+			columnWidth, used = 0, 0
+		}
+		x := area[0] + arrangeMargin + column
+		if right {
+			x = area[0] + area[2] - arrangeMargin - column - size[0]
+		}
+		y := area[1] + arrangeMargin + used
+		if bottom {
+			y = area[1] + area[3] - arrangeMargin - used - size[1]
+		}
+		positions[i] = [2]int{x, y}
+		used += size[1] + arrangeMargin
+		columnWidth = max(columnWidth, size[0])
+	}
+	return positions
+}
+
+// cornerMenu builds the note's "Pin to corner" submenu
+func (sn *StickyNote) cornerMenu() *gtk.Menu {
+	menu, _ := gtk.MenuNew()
+	current := sn.Note.Corner()
+
+	var group *glib.SList
+	add := func(label, corner string) {
+		item, _ := gtk.RadioMenuItemNewWithLabel(group, label)
+		group, _ = item.GetGroup()
+		item.SetActive(corner == current)
+		item.Connect("toggled", func() {
+			if item.GetActive() && corner != sn.Note.Corner() {
+				sn.SetCorner(corner)
+			}
+		})
+		menu.Append(item)
+		item.Show()
+	}
+	add("None", CornerNone)
+	for _, option := range cornerOptions {
+		add(option.Label, option.Name)
+	}
+	return menu
+}
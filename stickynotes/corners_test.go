@@ -0,0 +1,34 @@
+package stickynotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCornerPositions(t *testing.T) {
+	// A 1000x500 work area at (100, 50), room for two 200px tall notes per column
+	area := [4]int{100, 50, 1000, 500}
+	sizes := [][2]int{{200, 200}, {300, 200}, {250, 100}}
+	m := arrangeMargin
+
+	tests := []struct {
+		corner string
+		want   [][2]int
+	}{
+		{CornerTopLeft, [][2]int{{100 + m, 50 + m}, {100 + m, 50 + 2*m + 200}, {100 + 2*m + 300, 50 + m}}},
+		{CornerTopRight, [][2]int{{1100 - m - 200, 50 + m}, {1100 - m - 300, 50 + 2*m + 200}, {1100 - 2*m - 300 - 250, 50 + m}}},
+		{CornerBottomLeft, [][2]int{{100 + m, 550 - m - 200}, {100 + m, 550 - 2*m - 400}, {100 + 2*m + 300, 550 - m - 100}}},
+		{CornerBottomRight, [][2]int{{1100 - m - 200, 550 - m - 200}, {1100 - m - 300, 550 - 2*m - 400}, {1100 - 2*m - 300 - 250, 550 - m - 100}}},
+	}
+	for _, tt := range tests {
+		if got := cornerPositions(tt.corner, area, sizes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cornerPositions(%s) = %v, want %v", tt.corner, got, tt.want)
+		}
+	}
+
+	// A note taller than the work area still gets a column of its own
+	got := cornerPositions(CornerTopLeft, area, [][2]int{{200, 800}, {200, 100}})
+	if want := [][2]int{{100 + m, 50 + m}, {100 + 2*m + 200, 50 + m}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cornerPositions with a tall note = %v, want %v", got, want)
+	}
+}
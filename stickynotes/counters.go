@@ -0,0 +1,198 @@
+package stickynotes
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A "[count:N]" token in a note is a tally counter. In view mode the token is hidden and
+// a −/+ widget is put after it, in a child anchor; clicking it writes the new count back
+// into the text. Child anchors take up a character in the buffer that the note text
+// doesn't have, so offsets into the text go through textOffset and iterAtTextOffset.
+
+var counterToken = regexp.MustCompile(`\[count:(-?\d+)\]`)
+
+// counterSpan is a counter token in a note body, in character offsets
+type counterSpan struct {
+	Start int // Start of the token
+	End   int // End of the token
+	Value int
+}
+
+// counterAnchor is a counter widget in the buffer
+type counterAnchor struct {
+	anchor *gtk.TextChildAnchor
+	mark   *gtk.TextMark // Just before the anchor
+}
+
+// counterSpans finds the counter tokens in text, outside fenced code blocks
+func counterSpans(text string) []counterSpan {
+	inFence := make(map[int]bool)
+	for _, block := range codeBlockLines(text) {
+		for i := block[0]; i <= block[1]; i++ {
+			inFence[i] = true
+		}
+	}
+
+	var spans []counterSpan
+	offset := 0 // Character offset of the current line
+	for i, line := range strings.Split(text, "\n") {
+		if !inFence[i] {
+			for _, m := range counterToken.FindAllStringSubmatchIndex(line, -1) {
+				value, err := strconv.Atoi(line[m[2]:m[3]])
+				if err != nil {
+					continue // Too large
+				}
+				spans = append(spans, counterSpan{
+					Start: offset + utf8.RuneCountInString(line[:m[0]]),
+					End:   offset + utf8.RuneCountInString(line[:m[1]]),
+					Value: value,
+				})
+			}
+		}
+		offset += utf8.RuneCountInString(line) + 1
+	}
+	return spans
+}
+
+// counterText returns the token for a counter with the given value
+func counterText(value int) string {
+	return "[count:" + strconv.Itoa(value) + "]"
+}
+
+// anchorOffsets returns the buffer offsets of the counter widgets, in order
+func (sn *StickyNote) anchorOffsets() []int {
+	offsets := make([]int, 0, len(sn.counters))
+	for _, c := range sn.counters {
+		if !c.anchor.GetDeleted() {
+			offsets = append(offsets, sn.BBody.GetIterAtMark(c.mark).GetOffset())
+		}
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// textOffset returns the offset of iter in the note text, not counting counter widgets
+func (sn *StickyNote) textOffset(iter *gtk.TextIter) int {
+	offset := iter.GetOffset()
+	before := 0
+	for _, a := range sn.anchorOffsets() {
+		if a < offset {
+			before++
+		}
+	}
+	return offset - before
+}
+
+// iterAtTextOffset returns the buffer position of an offset in the note text
+func (sn *StickyNote) iterAtTextOffset(offset int) *gtk.TextIter {
+	for _, a := range sn.anchorOffsets() {
+		if a > offset {
+			break
+		}
+		offset++
+	}
+	return sn.BBody.GetIterAtOffset(offset)
+}
+
+// textCharCount returns the length of the note text, not counting counter widgets
+func (sn *StickyNote) textCharCount() int {
+	return sn.BBody.GetCharCount() - len(sn.anchorOffsets())
+}
+
+// clearCounters removes the counter widgets from the buffer
+func (sn *StickyNote) clearCounters() {
+	if len(sn.counters) == 0 {
+		return
+	}
+	sn.updatingCounters = true
+	for _, c := range sn.counters {
+		if !c.anchor.GetDeleted() {
+			start := sn.BBody.GetIterAtMark(c.mark)
+			end := sn.BBody.GetIterAtOffset(start.GetOffset() + 1)
+			sn.BBody.Delete(start, end)
+		}
+		sn.BBody.DeleteMark(c.mark)
+	}
+	sn.counters = nil
+	sn.updatingCounters = false
+}
+
+// renderCounters hides the counter tokens in text and puts a counter widget after each.
+// The buffer must not hold counter widgets yet.
+func (sn *StickyNote) renderCounters(text string) {
+	spans := counterSpans(text)
+	if len(spans) == 0 {
+		return
+	}
+	sn.updatingCounters = true
+	// From the end, so the offsets still to come don't move
+	for i := len(spans) - 1; i >= 0; i-- {
+		span := spans[i]
+		sn.BBody.ApplyTagByName(viewTagHidden, sn.BBody.GetIterAtOffset(span.Start), sn.BBody.GetIterAtOffset(span.End))
+		iter := sn.BBody.GetIterAtOffset(span.End)
+		mark := sn.BBody.CreateMark("", iter, true)
+		anchor, err := sn.BBody.CreateChildAnchor(iter)
+		if err != nil {
+			sn.BBody.DeleteMark(mark)
+			continue
+		}
+		sn.TxtNote.AddChildAtAnchor(sn.counterWidget(span), anchor)
+		sn.counters = append(sn.counters, counterAnchor{anchor: anchor, mark: mark})
+	}
+	sn.updatingCounters = false
+}
+
+// counterWidget builds the −/+ widget of a counter
+func (sn *StickyNote) counterWidget(span counterSpan) gtk.IWidget {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 2)
+	bMinus, _ := gtk.ButtonNewWithLabel("−")
+	bMinus.SetRelief(gtk.RELIEF_NONE)
+	bMinus.SetTooltipText("Count down")
+	bMinus.Connect("clicked", func() { sn.setCounter(span, span.Value-1) })
+	box.PackStart(bMinus, false, false, 0)
+	label, _ := gtk.LabelNew(strconv.Itoa(span.Value))
+	box.PackStart(label, false, false, 0)
+	bPlus, _ := gtk.ButtonNewWithLabel("+")
+	bPlus.SetRelief(gtk.RELIEF_NONE)
+	bPlus.SetTooltipText("Count up")
+	bPlus.Connect("clicked", func() { sn.setCounter(span, span.Value+1) })
+	box.PackStart(bPlus, false, false, 0)
+	// Locked notes can't be changed
+	box.SetSensitive(!sn.Locked)
+	box.ShowAll()
+	return box
+}
+
+// setCounter writes a counter's new value into the note text
+func (sn *StickyNote) setCounter(span counterSpan, value int) {
+	if sn.Locked {
+		return
+	}
+	sn.clearCounters()
+	sn.updatingCounters = true
+	start := sn.BBody.GetIterAtOffset(span.Start)
+	end := sn.BBody.GetIterAtOffset(span.End)
+	struck := false
+	if table, err := sn.BBody.GetTagTable(); err == nil {
+		if tag, err := table.Lookup(FormatStrikethrough); err == nil {
+			struck = start.HasTag(tag)
+		}
+	}
+	sn.BBody.Delete(start, end)
+	token := counterText(value)
+	sn.BBody.Insert(sn.BBody.GetIterAtOffset(span.Start), token)
+	if struck {
+		sn.BBody.ApplyTagByName(FormatStrikethrough, sn.BBody.GetIterAtOffset(span.Start), sn.BBody.GetIterAtOffset(span.Start+utf8.RuneCountInString(token)))
+	}
+	sn.updatingCounters = false
+
+	sn.renderMarkdown()
+	sn.UpdateNote()
+	sn.NoteSet.Save()
+}
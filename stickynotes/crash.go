@@ -0,0 +1,287 @@
+package stickynotes
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// The application runs as a child of a small supervisor process, which forwards its
+// output and keeps the end of it. When the child crashes (a Go panic, a fatal signal
+// in GTK), the supervisor saves a report with the stack, the recent log and the data
+// file's checksum, and offers to restart. The data file itself is never written by the
+// crashing process: saves are refused until it was loaded (see NoteSet.loaded), and a
+// save interrupted by the crash leaves the previous file in place (see writeDataFile).
+
+const (
+	// supervisedEnv is set in the environment of the supervised application
+	supervisedEnv = "POSTNOTE_SUPERVISED"
+	// crashLogLines is how many lines of the recent log a crash report keeps
+	crashLogLines = 200
+	// crashStackBytes is how much of the end of the error output a crash report keeps,
+	// enough for the stacks of all goroutines
+	crashStackBytes = 256 * 1024
+	// crashLoopWindow is how soon after starting a crash suggests safe mode
+	crashLoopWindow = 15 * time.Second
+)
+
+// CrashReportDir returns the directory holding the crash reports
+func CrashReportDir() string {
+	return filepath.Join(filepath.Dir(BackupDir()), "crashes")
+}
+
+// Supervised reports whether this process is the application started by the supervisor
+func Supervised() bool {
+	return os.Getenv(supervisedEnv) != ""
+}
+
+// crashLog keeps the end of the application's output
+type crashLog struct {
+	mu     sync.Mutex
+	lines  []string // Recent standard output, the application's log
+	stderr []byte   // End of the error output, where the stacks go
+}
+
+func (l *crashLog) addLine(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+	if len(l.lines) > crashLogLines {
+		l.lines = l.lines[len(l.lines)-crashLogLines:]
+	}
+}
+
+func (l *crashLog) addStderr(data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stderr = append(l.stderr, data...)
+	if len(l.stderr) > crashStackBytes {
+		l.stderr = l.stderr[len(l.stderr)-crashStackBytes:]
+	}
+}
+
+// Supervise runs the application (this executable with args) as a child process until
+// it exits, offering to restart it after a crash. Returns the exit code to exit with, -1
+// when the application can't be supervised and should run in this process.
+func Supervise(args []string, dataFile string) int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Crash] Can't supervise the application: %v\n", err)
+		return -1
+	}
+
+	// Signals meant for the application, e.g. when the session ends
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		log := &crashLog{}
+		started := time.Now()
+		code, crashed := runChild(exe, args, log, signals)
+		if !crashed {
+			return code
+		}
+
+		report, err := writeCrashReport(log, dataFile, args, code)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Crash] Failed to save the crash report: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[Crash] The application crashed, report saved to %s\n", report)
+		}
+
+		switch askRestart(report, time.Since(started) < crashLoopWindow) {
+		case gtk.RESPONSE_ACCEPT:
+		case gtk.RESPONSE_APPLY:
+			if !containsFlag(args, "safe-mode") {
+				args = append(args, "-safe-mode")
+			}
+		default:
+			return code
+		}
+	}
+}
+
+// runChild runs the application once. Returns its exit code and whether it crashed.
+func runChild(exe string, args []string, log *crashLog, signals chan os.Signal) (int, bool) {
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), supervisedEnv+"=1")
+	cmd.Stdin = os.Stdin
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 1, false
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return 1, false
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "[Crash] Failed to start the application: %v\n", err)
+		return 1, false
+	}
+
+	var output sync.WaitGroup
+	output.Add(2)
+	go func() {
+		defer output.Done()
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fmt.Println(scanner.Text())
+			log.addLine(scanner.Text())
+		}
+		io.Copy(os.Stdout, stdout) // A line too long for the scanner
+	}()
+	go func() {
+		defer output.Done()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := stderr.Read(buf)
+			if n > 0 {
+				os.Stderr.Write(buf[:n])
+				log.addStderr(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	output.Wait()
+	err = cmd.Wait()
+	close(done)
+
+	if err == nil {
+		return 0, false
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 1, false
+	}
+	status, _ := exitErr.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		switch status.Signal() {
+		case syscall.SIGSEGV, syscall.SIGABRT, syscall.SIGBUS, syscall.SIGILL, syscall.SIGFPE:
+			return 128 + int(status.Signal()), true
+		}
+		return 128 + int(status.Signal()), false
+	}
+	// The Go runtime exits with 2 after printing the panic or fatal error
+	code := status.ExitStatus()
+	return code, code == 2 && log.crashed()
+}
+
+// crashed reports whether the error output ends with a panic or a fatal error
+func (l *crashLog) crashed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stderr := string(l.stderr)
+	for _, marker := range []string{"panic: ", "fatal error: ", "SIGSEGV", "SIGABRT"} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCrashReport saves what is known about the crash. Returns the report's path.
+func writeCrashReport(log *crashLog, dataFile string, args []string, code int) (string, error) {
+	if err := os.MkdirAll(CrashReportDir(), 0700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(CrashReportDir(), "crash-"+now.Format("20060102-150405")+".txt")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "PostNote crash report\n\n")
+	fmt.Fprintf(&b, "Time:       %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Exit code:  %d\n", code)
+	fmt.Fprintf(&b, "Arguments:  %s\n", strings.Join(args, " "))
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&b, "Build:      %s %s\n", info.Main.Version, info.GoVersion)
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				fmt.Fprintf(&b, "Revision:   %s\n", setting.Value)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "Session:    %s\n", os.Getenv("XDG_SESSION_TYPE"))
+	dataPath := ResolvePath(dataFile)
+	fmt.Fprintf(&b, "Data file:  %s\n", dataPath)
+	if data, err := os.ReadFile(dataPath); err == nil {
+		fmt.Fprintf(&b, "Checksum:   sha256:%x (%d bytes)\n", sha256.Sum256(data), len(data))
+	} else {
+		fmt.Fprintf(&b, "Checksum:   unavailable (%v)\n", err)
+	}
+
+	log.mu.Lock()
+	fmt.Fprintf(&b, "\n--- Recent log ---\n%s\n", strings.Join(log.lines, "\n"))
+	fmt.Fprintf(&b, "\n--- Stack ---\n%s\n", log.stderr)
+	log.mu.Unlock()
+
+	return path, os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// askRestart tells about the crash and asks whether to restart, in safe mode when it
+// crashed right after starting. Returns RESPONSE_ACCEPT to restart, RESPONSE_APPLY to
+// restart in safe mode.
+func askRestart(report string, early bool) gtk.ResponseType {
+	gtk.Init(nil)
+	dialog := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_NONE, "PostNote crashed.")
+	details := "Your notes are safe: the data file is kept as it was last saved."
+	if report != "" {
+		details += "\n\nA report with the details was saved to " + report + "."
+	}
+	if early {
+		details += "\n\nIt crashed right after starting. Safe mode starts with the notes hidden, to find the note causing it."
+	}
+	dialog.FormatSecondaryText("%s", details)
+	dialog.AddButton("Quit", gtk.RESPONSE_CLOSE)
+	dialog.AddButton("Restart in Safe Mode", gtk.RESPONSE_APPLY)
+	dialog.AddButton("Restart", gtk.RESPONSE_ACCEPT)
+	if early {
+		dialog.SetDefaultResponse(gtk.RESPONSE_APPLY)
+	} else {
+		dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	}
+	dialog.SetKeepAbove(true)
+	response := dialog.Run()
+	dialog.Destroy()
+	for gtk.EventsPending() {
+		gtk.MainIteration()
+	}
+	return response
+}
+
+// containsFlag reports whether args contain the flag name, with one or two dashes
+func containsFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if strings.TrimLeft(arg, "-") == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package stickynotes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// The data file is written to a temporary file next to it, synced to disk and renamed
+// over the old one, so a crash or a full disk never leaves it half written. The previous
+// version is kept as <file>.bak, and Open falls back to it when the data file is damaged,
+// or to the newest backup that can be read. The data file ends with a "checksum" member,
+// the SHA-256 of the file without it, so damage that still leaves valid JSON is noticed
+// too; files without one (e.g. written by the Python indicator) are read as they are.
+// One process at a time writes it, holding a lock on <file>.lock; another one opens the
+// notes read-only, so the two can't overwrite each other's changes.
+
+// errNotLoaded is returned by Save before the noteset was loaded
+var errNotLoaded = errors.New("the notes aren't loaded yet")
+
+// errReadOnly is returned by Save when the notes were opened read-only
+var errReadOnly = errors.New("the notes are open read-only")
+
+// errChecksum is returned when reading a data file that doesn't match its checksum
+var errChecksum = errors.New("the content doesn't match its checksum, the file was damaged")
+
+// checksumMember starts the checksum, the last member of the JSON object
+const checksumMember = `"checksum":"`
+
+// addChecksum appends the checksum of the notes JSON to it
+func addChecksum(plain []byte) []byte {
+	// Summed as verifyChecksum gets it back, without trailing white space
+	plain = bytes.TrimRight(plain, " \t\n")
+	sum := sha256.Sum256(plain)
+	body := bytes.TrimSuffix(plain, []byte("}"))
+	var out bytes.Buffer
+	out.Write(body)
+	if len(bytes.TrimSpace(body)) > 1 {
+		out.WriteByte(',')
+	}
+	out.WriteString(checksumMember + hex.EncodeToString(sum[:]) + `"}`)
+	return out.Bytes()
+}
+
+// verifyChecksum returns the notes JSON without its checksum, or errChecksum when it
+// doesn't match. JSON without a checksum is returned as it is.
+func verifyChecksum(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimRight(data, " \t\n")
+	start := bytes.LastIndex(trimmed, []byte(checksumMember))
+	end := len(trimmed) - len(`"}`)
+	if start < 0 || end-start-len(checksumMember) != 2*sha256.Size || !bytes.HasSuffix(trimmed, []byte(`"}`)) {
+		return data, nil
+	}
+	want, err := hex.DecodeString(string(trimmed[start+len(checksumMember) : end]))
+	if err != nil {
+		return data, nil
+	}
+	body := bytes.TrimRight(trimmed[:start], " \t\n")
+	body = bytes.TrimSuffix(body, []byte(","))
+	plain := append(append([]byte{}, body...), '}')
+	if sum := sha256.Sum256(plain); !bytes.Equal(sum[:], want) {
+		return nil, errChecksum
+	}
+	return plain, nil
+}
+
+// ErrDataFileLocked is returned by LockDataFile when another process holds the lock
+var ErrDataFileLocked = errors.New("the data file is in use by another instance")
+
+// dataLock is the open lock file, kept until the process exits
+var dataLock *os.File
+
+// LockDataFile takes the lock of the data file for as long as the process runs. Returns
+// ErrDataFileLocked when another process holds it.
+func LockDataFile(dataFile string) error {
+	path := ResolvePath(dataFile) + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return ErrDataFileLocked
+		}
+		return err
+	}
+	// The process id, for whoever wonders who holds it
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	dataLock = f
+	return nil
+}
+
+// SetReadOnly opens the notes read-only: Save doesn't write the data file
+func (ns *NoteSet) SetReadOnly(readOnly bool) {
+	ns.readOnly = readOnly
+}
+
+// ReadOnly reports whether the notes are open read-only
+func (ns *NoteSet) ReadOnly() bool {
+	return ns.readOnly
+}
+
+// Save is called after every change, often several times for one (focus out, window
+// moves, settings), and only writes the data file when the notes differ from what it
+// holds. The notes are compared as serialized, so no change needs to be reported.
+
+// dataFileUnchanged reports whether the data file is as last read or written here
+func (ns *NoteSet) dataFileUnchanged() bool {
+	info, err := os.Stat(ns.DataPath())
+	return err == nil && !ns.dataModTime.IsZero() && info.ModTime().Equal(ns.dataModTime)
+}
+
+// upToDate reports whether the data file already holds the notes serialized as plain
+func (ns *NoteSet) upToDate(plain []byte) bool {
+	return !ns.dataDamaged && ns.savedKey == ns.encryption && ns.savedSum == sha256.Sum256(plain)
+}
+
+// backupPath returns where the previous version of the data file is kept
+func (ns *NoteSet) backupPath() string {
+	return ns.DataPath() + ".bak"
+}
+
+// damagedPath returns where a data file that couldn't be read is kept for inspection
+func (ns *NoteSet) damagedPath() string {
+	return ns.DataPath() + ".damaged"
+}
+
+// writeDataFile replaces the data file with data, keeping the previous version as the
+// backup unless backup is false
+func (ns *NoteSet) writeDataFile(data []byte, backup bool) error {
+	path := ns.DataPath()
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Cleared once the temporary file has been renamed into place
+	tmpPath := tmp.Name()
+	defer func() {
+		if tmpPath != "" {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+
+	if backup {
+		if err := ns.backupDataFile(); err != nil {
+			fmt.Printf("[Save] Failed to keep a backup of the data file: %v\n", err)
+		}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	tmpPath = ""
+
+	// Make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// backupDataFile keeps the current data file as the backup, before it is replaced
+func (ns *NoteSet) backupDataFile() error {
+	path, bak := ns.DataPath(), ns.backupPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	os.Remove(bak)
+	if err := os.Link(path, bak); err == nil {
+		return nil
+	}
+	// No hard links on this file system, copy it instead
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(bak, data, 0644)
+}
+
+// openBackup loads the noteset from the backup after the data file couldn't be read
+// (damaged is the error): <file>.bak, or else the newest snapshot in the backups folder
+// that can be read. The damaged file is kept next to it, even when no backup can be read.
+func (ns *NoteSet) openBackup(data []byte, damaged error) error {
+	if err := os.WriteFile(ns.damagedPath(), data, 0644); err != nil {
+		fmt.Printf("[Open] Failed to keep the damaged data file: %v\n", err)
+	}
+	candidates := []string{ns.backupPath()}
+	for _, backup := range ns.Backups() {
+		candidates = append(candidates, backup.Path)
+	}
+	for _, path := range candidates {
+		backup, err := os.ReadFile(path)
+		if err == nil {
+			backup, err = ns.decode(backup)
+		}
+		if err == nil {
+			err = ns.Loads(string(backup))
+		}
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Printf("[Open] Backup %s can't be read either: %v\n", path, err)
+			}
+			continue
+		}
+
+		ns.Recovered = fmt.Errorf("%s couldn't be read (%v), so the notes were loaded from the backup %s; the damaged file was kept as %s",
+			ns.DataPath(), damaged, path, ns.damagedPath())
+		fmt.Printf("[Open] %v\n", ns.Recovered)
+		return nil
+	}
+	return damaged
+}
@@ -0,0 +1,43 @@
+package stickynotes
+
+// In desktop widget mode notes sit on the desktop like widgets: kept below the other
+// windows, out of the task bar and the window switcher, and not given the focus when shown
+// (by Show All, for instance). Pinned notes ("Always on top") stay above. Stored as
+// "desktop_widgets" in the settings. Keeping windows below goes through EWMH on X11 and
+// window-calls on Wayland (MakeBelow, which KWin also takes out of the task bar and the
+// switcher; GNOME Shell offers no way to do that); layer-shell notes are on the bottom
+// layer anyway.
+
+// DesktopWidgets reports whether notes behave like desktop widgets
+func (ns *NoteSet) DesktopWidgets() bool {
+	on, _ := ns.Properties["desktop_widgets"].(bool)
+	return on
+}
+
+// SetDesktopWidgets turns desktop widget mode on or off, for the shown notes too
+func (ns *NoteSet) SetDesktopWidgets(on bool) {
+	if on {
+		ns.Properties["desktop_widgets"] = true
+	} else {
+		delete(ns.Properties, "desktop_widgets")
+	}
+	for _, note := range ns.Notes {
+		if isNoteShown(note) {
+			note.GUI.applyPinned()
+		}
+	}
+	ns.Save()
+}
+
+// desktopWidget reports whether the note is kept below as a desktop widget
+func (sn *StickyNote) desktopWidget() bool {
+	return sn.NoteSet.DesktopWidgets() && !sn.Pinned()
+}
+
+// applyWidgetHints sets the window hints of desktop widget mode, which GTK applies when
+// the window is mapped on X11 (and the task bar hint right away)
+func (sn *StickyNote) applyWidgetHints() {
+	widget := sn.desktopWidget()
+	sn.WinMain.SetSkipTaskbarHint(widget)
+	sn.WinMain.SetFocusOnMap(!widget)
+}
@@ -0,0 +1,263 @@
+package stickynotes
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A weekly digest lists the notes created or changed in the past week, as Markdown with
+// each note's title, category, time and text. "digest" picks where it goes (off by
+// default): saved to the folder in "digest_dir", or emailed to "digest_email" through
+// sendmail, or as a draft in the mail client (xdg-email) when there is no sendmail. It
+// is made a week after the last one ("digest_last"), checked at startup and hourly.
+// Notes remember when they were created in their "created" property.
+
+// Where the weekly digest goes
+const (
+	DigestOff    = ""
+	DigestFolder = "folder"
+	DigestEmail  = "email"
+)
+
+const (
+	// digestInterval is the time between digests
+	digestInterval = 7 * 24 * time.Hour
+	// digestCheckInterval is how often (ms) a due digest is looked for
+	digestCheckInterval = 60 * 60 * 1000
+	// digestFileLayout dates the digest files
+	digestFileLayout = "2006-01-02"
+)
+
+// DigestMode returns where the weekly digest goes, DigestOff when there is none
+func (ns *NoteSet) DigestMode() string {
+	mode, _ := ns.Properties["digest"].(string)
+	return mode
+}
+
+// SetDigestMode sets where the weekly digest goes, the first one a week from now
+func (ns *NoteSet) SetDigestMode(mode string) {
+	if mode == DigestOff {
+		delete(ns.Properties, "digest")
+		return
+	}
+	if ns.DigestMode() == DigestOff {
+		ns.Properties["digest_last"] = time.Now().Format(time.RFC3339)
+	}
+	ns.Properties["digest"] = mode
+}
+
+// DigestDir returns the folder digests are saved to, the home folder unless set
+func (ns *NoteSet) DigestDir() string {
+	if dir, ok := ns.Properties["digest_dir"].(string); ok && dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// createdAt returns when the note was created, ok=false for notes older than that record
+func (n *Note) createdAt() (time.Time, bool) {
+	value, _ := n.Properties["created"].(string)
+	created, err := time.Parse(time.RFC3339, value)
+	return created, err == nil
+}
+
+// Digest writes the digest of the notes created or changed since then, with how many
+// there are
+func (ns *NoteSet) Digest(since, now time.Time) (string, int) {
+	var created, changed []*Note
+	for _, note := range ns.Notes {
+		if at, ok := note.createdAt(); ok && !at.Before(since) {
+			created = append(created, note)
+		} else if !note.LastModified.Before(since) {
+			changed = append(changed, note)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Notes digest, %s to %s\n\n", FormatDay(since), FormatDay(now))
+	if len(created)+len(changed) == 0 {
+		sb.WriteString("No notes were created or changed.\n")
+		return sb.String(), 0
+	}
+	fmt.Fprintf(&sb, "%d new, %d changed.\n", len(created), len(changed))
+	for _, section := range []struct {
+		title string
+		notes []*Note
+	}{
+		{"New", created},
+		{"Changed", changed},
+	} {
+		if len(section.notes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %s\n", section.title)
+		notes := section.notes
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].LastModified.After(notes[j].LastModified)
+		})
+		for _, note := range notes {
+			fmt.Fprintf(&sb, "\n### %s\n\n", noteLabel(note))
+			fmt.Fprintf(&sb, "*%s · %s*\n", ns.CategoryName(note.Category), FormatDayTime(note.LastModified))
+			// The title is the first line, the rest follows it
+			_, rest, _ := strings.Cut(strings.TrimLeft(note.Body, " \t\n"), "\n")
+			if rest = strings.TrimSpace(rest); rest != "" {
+				sb.WriteString("\n" + rest + "\n")
+			}
+		}
+	}
+	return sb.String(), len(created) + len(changed)
+}
+
+// digestDue reports whether a week passed since the last digest
+func (ns *NoteSet) digestDue(now time.Time) bool {
+	last, _ := ns.Properties["digest_last"].(string)
+	at, err := time.Parse(time.RFC3339, last)
+	return err != nil || now.Sub(at) >= digestInterval
+}
+
+// SendDigest makes the digest of the past week the configured way. A week without
+// notes created or changed gives no digest. Returns how many notes it covers.
+func (ns *NoteSet) SendDigest(now time.Time) (int, error) {
+	mode := ns.DigestMode()
+	if mode == DigestOff {
+		return 0, errors.New("the weekly digest is off, see Settings → General")
+	}
+	digest, count := ns.Digest(now.Add(-digestInterval), now)
+	if count > 0 {
+		var err error
+		switch mode {
+		case DigestFolder:
+			err = ns.SaveDigest(digest, now)
+		case DigestEmail:
+			address, _ := ns.Properties["digest_email"].(string)
+			err = emailDigest(address, fmt.Sprintf("Notes digest: %d new or changed", count), digest)
+		default:
+			err = fmt.Errorf("unknown digest destination %q", mode)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	ns.Properties["digest_last"] = now.Format(time.RFC3339)
+	ns.Save()
+	return count, nil
+}
+
+// SaveDigest writes the digest to the digest folder, named after the date
+func (ns *NoteSet) SaveDigest(digest string, now time.Time) error {
+	dir := ns.DigestDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "postnote-digest-"+now.Format(digestFileLayout)+".md"), []byte(digest), 0644)
+}
+
+// emailDigest sends the digest with sendmail, or opens it as a draft in the mail client
+func emailDigest(address, subject, body string) error {
+	if path, err := exec.LookPath("sendmail"); err == nil && address != "" {
+		message := fmt.Sprintf("To: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+			address, mime.QEncoding.Encode("utf-8", subject), body)
+		cmd := exec.Command(path, "-t", "-i")
+		cmd.Stdin = strings.NewReader(message)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return fmt.Errorf("sendmail: %s", msg)
+			}
+			return err
+		}
+		return nil
+	}
+	path, err := exec.LookPath("xdg-email")
+	if err != nil {
+		return errors.New("neither sendmail nor xdg-email is installed, the digest can't be emailed")
+	}
+	args := []string{"--utf8", "--subject", subject, "--body", body}
+	if address != "" {
+		args = append(args, address)
+	}
+	cmd := exec.Command(path, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// WatchDigest makes the weekly digest when it is due, checking now and then hourly
+func WatchDigest(ns *NoteSet) {
+	check := func() {
+		if ns.DigestMode() == DigestOff || !ns.digestDue(time.Now()) {
+			return
+		}
+		if count, err := ns.SendDigest(time.Now()); err != nil {
+			fmt.Printf("[Digest] Failed to make the weekly digest: %v\n", err)
+		} else {
+			fmt.Printf("[Digest] Weekly digest of %d notes\n", count)
+		}
+	}
+	check()
+	glib.TimeoutAdd(digestCheckInterval, func() bool {
+		check()
+		return true // Repeat
+	})
+}
+
+// digestSettings builds the weekly digest row of the General settings
+func (sd *SettingsDialog) digestSettings() *gtk.Box {
+	ns := sd.NoteSet
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	label, _ := gtk.LabelNew("Weekly digest")
+	label.SetHAlign(gtk.ALIGN_START)
+	label.SetTooltipText("A summary of the notes created or changed in the past week, in Markdown")
+	box.PackStart(label, true, true, 0)
+
+	cbMode, _ := gtk.ComboBoxTextNew()
+	cbMode.Append(DigestOff, "Off")
+	cbMode.Append(DigestFolder, "Save to a folder")
+	cbMode.Append(DigestEmail, "Email")
+	cbMode.SetActiveID(ns.DigestMode())
+	box.PackStart(cbMode, false, false, 0)
+
+	fcDir, _ := gtk.FileChooserButtonNew("Digest Folder", gtk.FILE_CHOOSER_ACTION_SELECT_FOLDER)
+	fcDir.SetFilename(ns.DigestDir())
+	fcDir.SetNoShowAll(true)
+	box.PackStart(fcDir, false, false, 0)
+	eEmail, _ := gtk.EntryNew()
+	eEmail.SetPlaceholderText("Address")
+	eEmail.SetTooltipText("Sent with sendmail when it is set up, otherwise opened as a draft in your mail client")
+	address, _ := ns.Properties["digest_email"].(string)
+	eEmail.SetText(address)
+	eEmail.SetNoShowAll(true)
+	box.PackStart(eEmail, false, false, 0)
+
+	update := func() {
+		fcDir.SetVisible(cbMode.GetActiveID() == DigestFolder)
+		eEmail.SetVisible(cbMode.GetActiveID() == DigestEmail)
+	}
+	cbMode.Connect("changed", func() {
+		ns.SetDigestMode(cbMode.GetActiveID())
+		update()
+	})
+	fcDir.Connect("file-set", func() {
+		ns.Properties["digest_dir"] = fcDir.GetFilename()
+	})
+	eEmail.Connect("changed", func() {
+		text, _ := eEmail.GetText()
+		ns.Properties["digest_email"] = strings.TrimSpace(text)
+	})
+
+	box.ShowAll()
+	update()
+	return box
+}
@@ -0,0 +1,57 @@
+package stickynotes
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigest(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.Local)
+	since := now.AddDate(0, 0, -7)
+
+	// note-0001 (2024-03-01 10:20) is older than the week, note-0002 changed in it
+	findNote(t, ns, "note-0002").Body = "Call Anna\nabout the trip\n"
+	created := NewNote(nil, nil, ns, "cat-a")
+	created.Body = "Plan the week"
+	created.LastModified = now.Add(-time.Hour)
+	created.Properties["created"] = now.Add(-2 * time.Hour).Format(time.RFC3339)
+	ns.Notes = append(ns.Notes, created)
+
+	digest, count := ns.Digest(since, now)
+	if count != 2 {
+		t.Errorf("digest covers %d notes, want 2", count)
+	}
+	for _, want := range []string{"1 new, 1 changed.", "## New\n\n### Plan the week\n\n*Work · ", "## Changed\n\n### Call Anna\n", "\nabout the trip\n"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest lacks %q:\n%s", want, digest)
+		}
+	}
+	if strings.Contains(digest, "Shopping") {
+		t.Errorf("digest lists a note changed before the week:\n%s", digest)
+	}
+
+	if _, count := ns.Digest(now, now.Add(time.Hour)); count != 0 {
+		t.Errorf("digest of an empty week covers %d notes", count)
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	ns := newTestNoteSet(t)
+	now := time.Now()
+	ns.SetDigestMode(DigestFolder)
+	if ns.digestDue(now) {
+		t.Error("digest due right after turning it on, want it a week later")
+	}
+	if !ns.digestDue(now.Add(digestInterval)) {
+		t.Error("digest not due a week later")
+	}
+	ns.SetDigestMode(DigestOff)
+	if ns.DigestMode() != DigestOff {
+		t.Errorf("DigestMode = %q after turning it off", ns.DigestMode())
+	}
+}
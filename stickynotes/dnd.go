@@ -0,0 +1,127 @@
+package stickynotes
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// maxDroppedTextSize is the largest text file whose contents are imported on drop;
+// larger or binary files are attached instead
+const maxDroppedTextSize = 1 << 20
+
+// Drops are only accepted by note windows. The tray icon can't take part: AppIndicator
+// (StatusNotifierItem) doesn't deliver drag-and-drop events to the application.
+
+// setupDragAndDrop lets text and files be dropped onto the note
+func (sn *StickyNote) setupDragAndDrop() {
+	var targets []gtk.TargetEntry
+	for i, target := range []string{"text/uri-list", "UTF8_STRING", "text/plain"} {
+		if entry, err := gtk.TargetEntryNew(target, gtk.TARGET_OTHER_APP, uint(i)); err == nil {
+			targets = append(targets, *entry)
+		}
+	}
+	sn.WinMain.DragDestSet(gtk.DEST_DEFAULT_ALL, targets, gdk.ACTION_COPY)
+	sn.WinMain.Connect("drag-data-received", sn.onDragDataReceived)
+
+	// The text view inserts dropped text at the pointer by itself, only files need handling
+	sn.TxtNote.Connect("drag-data-received", sn.onTextDragDataReceived)
+}
+
+// onDragDataReceived appends dropped text to the note, or imports dropped files
+func (sn *StickyNote) onDragDataReceived(win *gtk.Window, ctx *gdk.DragContext, x, y int, data *gtk.SelectionData) {
+	if sn.InViewMode() || data == nil {
+		return
+	}
+	if files := droppedFiles(data); len(files) > 0 {
+		sn.importDroppedFiles(files)
+		return
+	}
+	if text := data.GetText(); text != "" {
+		sn.AppendText(text)
+		sn.UpdateNote()
+		sn.NoteSet.Save()
+	}
+}
+
+// onTextDragDataReceived imports files dropped onto the text view instead of inserting their paths
+func (sn *StickyNote) onTextDragDataReceived(tv *gtk.TextView, ctx *gdk.DragContext, x, y int, data *gtk.SelectionData) {
+	if sn.InViewMode() || data == nil {
+		return
+	}
+	if files := droppedFiles(data); len(files) > 0 {
+		tv.StopEmission("drag-data-received")
+		sn.importDroppedFiles(files)
+	}
+}
+
+// importDroppedFiles appends the contents of dropped text files to the note and
+// attaches any other file
+func (sn *StickyNote) importDroppedFiles(files []string) {
+	attached := false
+	for _, path := range files {
+		if text, ok := readTextFile(path); ok {
+			sn.AppendText(text)
+			continue
+		}
+		if _, err := sn.Note.Attach(path); err == nil {
+			attached = true
+		}
+	}
+	if attached {
+		sn.RefreshAttachments()
+	}
+	sn.UpdateNote()
+	sn.NoteSet.Save()
+}
+
+// droppedFiles returns the local files in a drop, from a URI list or from text
+// consisting only of file URIs or existing absolute paths (as some file managers send)
+func droppedFiles(data *gtk.SelectionData) []string {
+	lines := data.GetURIs()
+	if len(lines) == 0 {
+		lines = strings.Split(strings.TrimSpace(data.GetText()), "\n")
+	}
+
+	var files []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path := line
+		if strings.HasPrefix(line, "file://") {
+			u, err := url.Parse(line)
+			if err != nil {
+				return nil
+			}
+			path = u.Path
+		}
+		if !filepath.IsAbs(path) {
+			return nil // Plain text, not a file list
+		}
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		files = append(files, path)
+	}
+	return files
+}
+
+// readTextFile returns the contents of a small UTF-8 text file
+func readTextFile(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxDroppedTextSize {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !utf8.Valid(data) || strings.ContainsRune(string(data), 0) {
+		return "", false
+	}
+	return string(data), true
+}
@@ -0,0 +1,235 @@
+package stickynotes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A due date is stored in the note's "due_date" property as a calendar date
+// (2006-01-02). Notes due soon or overdue get a colored border, and the move bars
+// show a countdown as their tooltip.
+
+const (
+	dueDateLayout = "2006-01-02"
+
+	// dueSoonDays is how many days before the due date a note counts as due soon
+	dueSoonDays = 1
+
+	dueSoonColor    = "#e5a50a"
+	dueOverdueColor = "#c01c28"
+
+	// dueRefreshInterval is how often (ms) the due states are brought up to date
+	dueRefreshInterval = 60000
+)
+
+// DueState tells how close a note is to its due date
+type DueState int
+
+// Due states
+const (
+	DueNone DueState = iota // No due date
+	DueLater
+	DueSoon
+	DueOverdue
+)
+
+// DueDate returns the note's due date (midnight, local time), ok=false when none is set
+func (n *Note) DueDate() (time.Time, bool) {
+	value, _ := n.Properties["due_date"].(string)
+	if value == "" {
+		return time.Time{}, false
+	}
+	due, err := time.ParseInLocation(dueDateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+// SetDueDate sets the note's due date, or clears it for a zero time
+func (n *Note) SetDueDate(due time.Time) {
+	if due.IsZero() {
+		delete(n.Properties, "due_date")
+	} else {
+		n.Properties["due_date"] = due.Format(dueDateLayout)
+	}
+	n.NoteSet.Save()
+}
+
+// DaysUntilDue returns the number of calendar days from today to the due date,
+// negative when overdue
+func (n *Note) DaysUntilDue() (int, bool) {
+	due, ok := n.DueDate()
+	if !ok {
+		return 0, false
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	// Round, days aren't always 24 hours long around DST changes
+	return int((due.Sub(today).Hours() + 12) / 24), true
+}
+
+// DueState returns how close the note is to its due date
+func (n *Note) DueState() DueState {
+	days, ok := n.DaysUntilDue()
+	switch {
+	case !ok:
+		return DueNone
+	case days < 0:
+		return DueOverdue
+	case days <= dueSoonDays:
+		return DueSoon
+	}
+	return DueLater
+}
+
+// DueCountdown describes the time left until the due date, "" when none is set
+func (n *Note) DueCountdown() string {
+	days, ok := n.DaysUntilDue()
+	if !ok {
+		return ""
+	}
+	switch {
+	case days < -1:
+		return fmt.Sprintf("Overdue by %d days", -days)
+	case days == -1:
+		return "Overdue since yesterday"
+	case days == 0:
+		return "Due today"
+	case days == 1:
+		return "Due tomorrow"
+	}
+	return fmt.Sprintf("Due in %d days", days)
+}
+
+// OverdueNotes returns the notes past their due date
+func (ns *NoteSet) OverdueNotes() []*Note {
+	var overdue []*Note
+	for _, note := range ns.Notes {
+		if note.DueState() == DueOverdue {
+			overdue = append(overdue, note)
+		}
+	}
+	return overdue
+}
+
+// WatchDueDates periodically updates the due borders and countdowns, so notes turn
+// due soon or overdue without being touched
+func WatchDueDates(ns *NoteSet) {
+	day := time.Now().YearDay()
+	glib.TimeoutAdd(dueRefreshInterval, func() bool {
+		changed := ns.refreshDueDates()
+		// Countdowns change at midnight, even for notes that aren't shown
+		if today := time.Now().YearDay(); today != day {
+			day = today
+			changed = true
+		}
+		if changed && ns.NotesChanged != nil {
+			ns.NotesChanged()
+		}
+		return true // Repeat
+	})
+}
+
+// refreshDueDates restyles the shown notes whose due state changed and updates the
+// countdowns. Reports whether any state changed.
+func (ns *NoteSet) refreshDueDates() bool {
+	changed := false
+	for _, note := range ns.Notes {
+		sn := note.GUI
+		if sn == nil || sn.WinMain == nil {
+			continue
+		}
+		if state := note.DueState(); state != sn.dueState {
+			sn.LoadCSS()
+			changed = true
+		} else {
+			sn.updateDueTooltip()
+		}
+	}
+	return changed
+}
+
+// dueCSS returns the border style for a note due soon or overdue, and remembers the
+// state it was styled for
+func (sn *StickyNote) dueCSS() string {
+	sn.dueState = sn.Note.DueState()
+	sn.updateDueTooltip()
+
+	color := ""
+	switch sn.dueState {
+	case DueSoon:
+		color = dueSoonColor
+	case DueOverdue:
+		color = dueOverdueColor
+	default:
+		return ""
+	}
+	return fmt.Sprintf("\n#main-window\n{\n    border: 3px solid %s;\n}\n", color)
+}
+
+// updateDueTooltip shows the countdown when hovering the move bars
+func (sn *StickyNote) updateDueTooltip() {
+	countdown := sn.Note.DueCountdown()
+	for _, box := range []*gtk.EventBox{sn.MoveBox1, sn.MoveBox2} {
+		if box == nil {
+			continue
+		}
+		if countdown == "" {
+			box.SetProperty("has-tooltip", false)
+		} else {
+			box.SetTooltipText(countdown)
+		}
+	}
+}
+
+// onDueDate lets the user pick the note's due date
+func (sn *StickyNote) onDueDate() {
+	due, set := sn.Note.DueDate()
+	if !set {
+		due = time.Now()
+	}
+
+	buttons := [][]interface{}{{"Cancel", gtk.RESPONSE_CANCEL}}
+	if set {
+		buttons = append(buttons, []interface{}{"Clear", gtk.RESPONSE_REJECT})
+	}
+	buttons = append(buttons, []interface{}{"Set", gtk.RESPONSE_ACCEPT})
+	dialog, err := gtk.DialogNewWithButtons("Due Date", sn.WinMain, gtk.DIALOG_MODAL, buttons...)
+	if err != nil {
+		return
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.SetMarginTop(12)
+
+	calendar, _ := gtk.CalendarNew()
+	calendar.SelectMonth(uint(due.Month()-1), uint(due.Year()))
+	calendar.SelectDay(uint(due.Day()))
+	calendar.Connect("day-selected-double-click", func() {
+		dialog.Response(gtk.RESPONSE_ACCEPT)
+	})
+	content.PackStart(calendar, false, false, 0)
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	year, month, day := calendar.GetDate()
+	dialog.Destroy()
+
+	switch response {
+	case gtk.RESPONSE_ACCEPT:
+		sn.Note.SetDueDate(time.Date(int(year), time.Month(month+1), int(day), 0, 0, 0, 0, time.Local))
+	case gtk.RESPONSE_REJECT:
+		sn.Note.SetDueDate(time.Time{})
+	default:
+		return
+	}
+	sn.LoadCSS()
+	// The menu shows the due date
+	sn.PopulateMenu()
+}
@@ -0,0 +1,64 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// OpenEditor opens the note in a large, resizable editor window. The editor shares
+// the note's text buffer, so edits show up in the sticky window as they are typed,
+// and the note is saved when the editor is closed.
+func (sn *StickyNote) OpenEditor() {
+	if sn.Editor != nil {
+		sn.Editor.Present()
+		return
+	}
+
+	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	if err != nil {
+		return
+	}
+	title := sn.Note.FirstLine()
+	if title == "" {
+		title = sn.Note.UUID[:8]
+	}
+	win.SetTitle(fmt.Sprintf("Edit Note - %s", title))
+	win.SetDefaultSize(640, 480)
+	win.SetName("main-window")
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+
+	tv, _ := gtk.TextViewNewWithBuffer(sn.BBody)
+	tv.SetName("txt-note")
+	tv.SetWrapMode(gtk.WRAP_WORD)
+	tv.SetLeftMargin(12)
+	tv.SetRightMargin(12)
+	tv.SetTopMargin(8)
+	tv.SetBottomMargin(8)
+	tv.SetEditable(!sn.Locked)
+	tv.Connect("key-press-event", sn.onListKeyPress)
+	tv.SetCursorVisible(!sn.Locked)
+	scrolled.Add(tv)
+	win.Add(scrolled)
+
+	// Same category styling as the sticky window
+	if sn.CSSProvider != nil {
+		for _, w := range []gtk.IWidget{win, tv} {
+			if context, err := w.ToWidget().GetStyleContext(); err == nil {
+				context.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+			}
+		}
+	}
+
+	win.Connect("destroy", func() {
+		sn.Editor = nil
+		sn.UpdateNote()
+		sn.NoteSet.Save()
+	})
+
+	sn.Editor = win
+	win.ShowAll()
+	tv.GrabFocus()
+}
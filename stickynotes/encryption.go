@@ -0,0 +1,453 @@
+package stickynotes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// The data file can be encrypted with a passphrase. It then holds a JSON envelope,
+// {"encrypted": {...}}, with the notes sealed by AES-256-GCM under a key derived from the
+// passphrase with PBKDF2-SHA256. The key is derived once when the file is opened and kept
+// for saving, each save using a new nonce. The passphrase is asked for at startup, unless
+// the user chose to remember it in the desktop keyring (through libsecret's secret-tool).
+// The backups are encrypted the same way; version history and attachments are not.
+
+const (
+	encryptionVersion    = 1
+	encryptionKDF        = "pbkdf2-sha256"
+	encryptionIterations = 600000
+	// maxEncryptionIterations bounds the work a damaged or hostile file can ask for
+	maxEncryptionIterations = 10 * encryptionIterations
+	encryptionKeySize       = 32
+	encryptionSaltSize      = 16
+
+	// keyringApplication tags the passphrase in the keyring, with the data file path
+	keyringApplication = "postnote"
+)
+
+var (
+	// ErrPassphraseRequired is returned by Open when the data file is encrypted and no
+	// passphrase was given or remembered
+	ErrPassphraseRequired = errors.New("the data file is encrypted, a passphrase is required")
+	// ErrWrongPassphrase is returned by Open when the passphrase doesn't decrypt the data file
+	ErrWrongPassphrase = errors.New("wrong passphrase, or the data file is damaged")
+)
+
+// encryptedEnvelope is the content of an encrypted data file
+type encryptedEnvelope struct {
+	Encrypted *encryptedData `json:"encrypted"`
+}
+
+type encryptedData struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// encryptionKey is the key the data file is encrypted with
+type encryptionKey struct {
+	key        []byte
+	salt       []byte
+	iterations int
+}
+
+// deriveKey derives the encryption key from a passphrase
+func deriveKey(passphrase string, salt []byte, iterations int) (*encryptionKey, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, encryptionKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptionKey{key: key, salt: salt, iterations: iterations}, nil
+}
+
+// newEncryptionKey derives a key from a passphrase with a new random salt
+func newEncryptionKey(passphrase string) (*encryptionKey, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return deriveKey(passphrase, salt, encryptionIterations)
+}
+
+// seal encrypts plain into an envelope
+func (k *encryptionKey) seal(plain []byte) ([]byte, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedEnvelope{Encrypted: &encryptedData{
+		Version:    encryptionVersion,
+		KDF:        encryptionKDF,
+		Iterations: k.iterations,
+		Salt:       k.salt,
+		Nonce:      nonce,
+		Data:       gcm.Seal(nil, nonce, plain, nil),
+	}})
+}
+
+// open decrypts an envelope's data
+func (k *encryptionKey) open(env *encryptedData) ([]byte, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func (k *encryptionKey) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// parseEnvelope returns the encrypted data of an encrypted file, nil for a plain one
+func parseEnvelope(data []byte) *encryptedData {
+	if !bytes.Contains(data, []byte(`"encrypted"`)) {
+		return nil
+	}
+	var env encryptedEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil
+	}
+	return env.Encrypted
+}
+
+// Encrypted reports whether the data file is encrypted
+func (ns *NoteSet) Encrypted() bool {
+	return ns.encryption != nil
+}
+
+// SetPassphrase gives the passphrase Open decrypts the data file with
+func (ns *NoteSet) SetPassphrase(passphrase string) {
+	ns.passphrase = passphrase
+}
+
+// decode returns the notes JSON of data read from the data file or a backup, decrypting
+// it when it is encrypted and checking its checksum. The key is kept for saving.
+func (ns *NoteSet) decode(data []byte) ([]byte, error) {
+	plain, err := ns.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return verifyChecksum(plain)
+}
+
+// decrypt returns the content of an encrypted data file or backup, data itself when it
+// isn't encrypted
+func (ns *NoteSet) decrypt(data []byte) ([]byte, error) {
+	env := parseEnvelope(data)
+	if env == nil {
+		return data, nil
+	}
+	if env.Version != encryptionVersion || env.KDF != encryptionKDF {
+		return nil, fmt.Errorf("unsupported encryption (version %d, %s)", env.Version, env.KDF)
+	}
+	if env.Iterations < 1 || env.Iterations > maxEncryptionIterations {
+		return nil, fmt.Errorf("invalid encryption (%d iterations)", env.Iterations)
+	}
+
+	// The key already in use opens files saved with the same salt without deriving it again
+	if k := ns.encryption; k != nil && bytes.Equal(k.salt, env.Salt) && k.iterations == env.Iterations {
+		return k.open(env)
+	}
+	passphrase := ns.passphrase
+	if passphrase == "" {
+		passphrase = ns.keyringPassphrase()
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	k, err := deriveKey(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := k.open(env)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the key of the data file, a backup under an older salt doesn't replace it
+	if ns.encryption == nil {
+		ns.encryption = k
+	}
+	ns.passphrase = passphrase
+	return plain, nil
+}
+
+// encode returns what is written to the data file or a backup for the notes JSON, with
+// its checksum, encrypted when encryption is on
+func (ns *NoteSet) encode(plain []byte) ([]byte, error) {
+	return encodeWith(ns.encryption, plain)
+}
+
+// encodeWith encodes the notes JSON like encode, encrypted with key unless it is nil
+func encodeWith(key *encryptionKey, plain []byte) ([]byte, error) {
+	plain = addChecksum(plain)
+	if key == nil {
+		return plain, nil
+	}
+	return key.seal(plain)
+}
+
+// EnableEncryption encrypts the data file with a new passphrase, optionally remembered
+// in the keyring. Also used to change the passphrase.
+func (ns *NoteSet) EnableEncryption(passphrase string, remember bool) error {
+	k, err := newEncryptionKey(passphrase)
+	if err != nil {
+		return err
+	}
+	backups := ns.readBackups()
+	previous, previousPassphrase := ns.encryption, ns.passphrase
+	ns.encryption, ns.passphrase = k, passphrase
+	if err := ns.saveNow(); err != nil {
+		ns.encryption, ns.passphrase = previous, previousPassphrase
+		return err
+	}
+	// The backups would still hold the notes in the clear, or under the old passphrase
+	ns.rewriteBackup()
+	ns.writeBackups(backups)
+	if remember {
+		return ns.storeKeyringPassphrase(passphrase)
+	}
+	ns.clearKeyringPassphrase()
+	return nil
+}
+
+// DisableEncryption saves the data file in the clear again and forgets the passphrase
+func (ns *NoteSet) DisableEncryption() error {
+	backups := ns.readBackups()
+	previous := ns.encryption
+	ns.encryption = nil
+	if err := ns.saveNow(); err != nil {
+		ns.encryption = previous
+		return err
+	}
+	ns.rewriteBackup()
+	ns.writeBackups(backups)
+	ns.passphrase = ""
+	ns.clearKeyringPassphrase()
+	return nil
+}
+
+// rewriteBackup replaces the backup of the data file with the current data file
+func (ns *NoteSet) rewriteBackup() {
+	if err := ns.backupDataFile(); err != nil {
+		fmt.Printf("[Encryption] Failed to replace the backup of the data file: %v\n", err)
+	}
+}
+
+// RemembersPassphrase reports whether the passphrase is in the keyring
+func (ns *NoteSet) RemembersPassphrase() bool {
+	return ns.keyringPassphrase() != ""
+}
+
+// keyringAttributes identify the data file's passphrase in the keyring
+func (ns *NoteSet) keyringAttributes() []string {
+	return []string{"application", keyringApplication, "data-file", ns.DataPath()}
+}
+
+// keyringPassphrase returns the passphrase remembered in the keyring, "" when there is none
+func (ns *NoteSet) keyringPassphrase() string {
+	return keyringLookup(ns.keyringAttributes())
+}
+
+// storeKeyringPassphrase remembers the passphrase in the keyring
+func (ns *NoteSet) storeKeyringPassphrase(passphrase string) error {
+	if err := keyringStore("PostNote data file passphrase", passphrase, ns.keyringAttributes()); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("secret-tool (libsecret) is not installed, the passphrase can't be remembered")
+		}
+		return err
+	}
+	return nil
+}
+
+// clearKeyringPassphrase forgets the passphrase remembered in the keyring
+func (ns *NoteSet) clearKeyringPassphrase() {
+	keyringClear(ns.keyringAttributes())
+}
+
+// keyringLookup returns the secret stored in the keyring under the attributes, "" when
+// there is none or secret-tool is missing
+func keyringLookup(attributes []string) string {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return ""
+	}
+	out, err := exec.Command(path, append([]string{"lookup"}, attributes...)...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// keyringStore stores a secret in the keyring under the attributes
+func keyringStore(label, secret string, attributes []string) error {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return err
+	}
+	args := append([]string{"store", "--label=" + label}, attributes...)
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(secret)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("secret-tool: %s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// keyringClear removes the secret stored in the keyring under the attributes
+func keyringClear(attributes []string) {
+	if path, err := exec.LookPath("secret-tool"); err == nil {
+		exec.Command(path, append([]string{"clear"}, attributes...)...).Run()
+	}
+}
+
+// AskPassphrase asks for a passphrase. With confirm, it is entered twice and the keyring
+// option is offered. ok is false when the user cancelled.
+func AskPassphrase(parent gtk.IWindow, message string, confirm bool) (passphrase string, remember bool, ok bool) {
+	dialog, err := gtk.DialogNewWithButtons("Passphrase", parent, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL}, []interface{}{"OK", gtk.RESPONSE_ACCEPT})
+	if err != nil {
+		return "", false, false
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.SetMarginTop(12)
+
+	label, _ := gtk.LabelNew(message)
+	label.SetLineWrap(true)
+	label.SetMaxWidthChars(40)
+	label.SetHAlign(gtk.ALIGN_START)
+	content.PackStart(label, false, false, 0)
+	entry, _ := gtk.EntryNew()
+	entry.SetVisibility(false)
+	entry.SetActivatesDefault(true)
+	entry.SetPlaceholderText("Passphrase")
+	content.PackStart(entry, false, false, 0)
+
+	var repeat *gtk.Entry
+	var chkRemember *gtk.CheckButton
+	if confirm {
+		repeat, _ = gtk.EntryNew()
+		repeat.SetVisibility(false)
+		repeat.SetActivatesDefault(true)
+		repeat.SetPlaceholderText("Repeat the passphrase")
+		content.PackStart(repeat, false, false, 0)
+		chkRemember, _ = gtk.CheckButtonNewWithLabel("Remember it in the keyring, so it isn't asked at startup")
+		content.PackStart(chkRemember, false, false, 0)
+	}
+	dialog.ShowAll()
+
+	for {
+		if dialog.Run() != gtk.RESPONSE_ACCEPT {
+			dialog.Destroy()
+			return "", false, false
+		}
+		passphrase, _ = entry.GetText()
+		if passphrase == "" {
+			label.SetText("The passphrase can't be empty.")
+			continue
+		}
+		if repeat != nil {
+			if again, _ := repeat.GetText(); again != passphrase {
+				label.SetText("The passphrases don't match.")
+				continue
+			}
+		}
+		if chkRemember != nil {
+			remember = chkRemember.GetActive()
+		}
+		dialog.Destroy()
+		return passphrase, remember, true
+	}
+}
+
+// encryptionSettings builds the encryption section of the General settings
+func (sd *SettingsDialog) encryptionSettings() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	chkEncrypt, _ := gtk.CheckButtonNewWithLabel("Enable encryption of the data file")
+	chkEncrypt.SetTooltipText("Notes are encrypted with a passphrase asked at startup. Version history and attachments are not encrypted.")
+	chkEncrypt.SetActive(sd.NoteSet.Encrypted())
+	box.PackStart(chkEncrypt, true, true, 0)
+	bChange, _ := gtk.ButtonNewWithLabel("Change Passphrase…")
+	bChange.SetSensitive(sd.NoteSet.Encrypted())
+	box.PackStart(bChange, false, false, 0)
+
+	showError := func(message string, err error) {
+		dialog := gtk.MessageDialogNew(sd.WSettings, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "%s", message)
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+	}
+	setPassphrase := func(message string) bool {
+		passphrase, remember, ok := AskPassphrase(sd.WSettings, message, true)
+		if !ok {
+			return false
+		}
+		if err := sd.NoteSet.EnableEncryption(passphrase, remember); err != nil {
+			showError("Error encrypting the data file.", err)
+			// Encrypted, even if the keyring failed
+			return sd.NoteSet.Encrypted()
+		}
+		return true
+	}
+
+	updating := false
+	chkEncrypt.Connect("toggled", func() {
+		if updating {
+			return
+		}
+		if chkEncrypt.GetActive() && !sd.NoteSet.Encrypted() {
+			setPassphrase("Choose a passphrase for your notes. They can't be recovered without it.")
+		} else if !chkEncrypt.GetActive() && sd.NoteSet.Encrypted() {
+			if err := sd.NoteSet.DisableEncryption(); err != nil {
+				showError("Error decrypting the data file.", err)
+			}
+		}
+		updating = true
+		chkEncrypt.SetActive(sd.NoteSet.Encrypted())
+		updating = false
+		bChange.SetSensitive(sd.NoteSet.Encrypted())
+	})
+	bChange.Connect("clicked", func() {
+		setPassphrase("Choose a new passphrase for your notes.")
+	})
+
+	box.ShowAll()
+	return box
+}
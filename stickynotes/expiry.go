@@ -0,0 +1,172 @@
+package stickynotes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A note can expire on a date ("expire_on", 2006-01-02) or after a number of days
+// without edits ("expire_idle_days"). Expired notes are moved to the trash, where they
+// stay restorable until it is purged. Expiry is checked at startup and then daily.
+
+// expiryCheckInterval is how often (ms) expired notes are looked for
+const expiryCheckInterval = 24 * 60 * 60 * 1000
+
+// ExpiresOn returns the date the note expires on, ok=false when none is set
+func (n *Note) ExpiresOn() (time.Time, bool) {
+	value, _ := n.Properties["expire_on"].(string)
+	if value == "" {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation(dueDateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// ExpireIdleDays returns after how many days without edits the note expires, 0 for never
+func (n *Note) ExpireIdleDays() int {
+	days, _ := n.Properties["expire_idle_days"].(float64)
+	return int(days)
+}
+
+// SetExpiry sets when the note expires: on a date, after idleDays without edits, or never
+// for a zero date and 0 days
+func (n *Note) SetExpiry(on time.Time, idleDays int) {
+	delete(n.Properties, "expire_on")
+	delete(n.Properties, "expire_idle_days")
+	if !on.IsZero() {
+		n.Properties["expire_on"] = on.Format(dueDateLayout)
+	}
+	if idleDays > 0 {
+		n.Properties["expire_idle_days"] = float64(idleDays)
+	}
+	n.NoteSet.Save()
+}
+
+// Expired reports whether the note is past its expiry
+func (n *Note) Expired(now time.Time) bool {
+	if on, ok := n.ExpiresOn(); ok && !now.Before(on) {
+		return true
+	}
+	if days := n.ExpireIdleDays(); days > 0 && now.Sub(n.LastModified) >= time.Duration(days)*24*time.Hour {
+		return true
+	}
+	return false
+}
+
+// ExpireNotes moves the expired notes to the trash. Returns how many were moved.
+func (ns *NoteSet) ExpireNotes() int {
+	now := time.Now()
+	var expired []*Note
+	for _, note := range ns.Notes {
+		if !note.deletePending && note.Expired(now) {
+			expired = append(expired, note)
+		}
+	}
+	for _, note := range expired {
+		note.trash()
+	}
+	return len(expired)
+}
+
+// WatchExpiry moves notes to the trash as they expire, checking daily
+func WatchExpiry(ns *NoteSet) {
+	glib.TimeoutAdd(expiryCheckInterval, func() bool {
+		if expired := ns.ExpireNotes(); expired > 0 {
+			fmt.Printf("[Expiry] Moved %d expired notes to the trash\n", expired)
+		}
+		return true // Repeat
+	})
+}
+
+// expirySummary describes when the note expires, "" when it doesn't
+func (n *Note) expirySummary() string {
+	if on, ok := n.ExpiresOn(); ok {
+		return FormatDay(on)
+	}
+	if days := n.ExpireIdleDays(); days > 0 {
+		return fmt.Sprintf("%d idle days", days)
+	}
+	return ""
+}
+
+// onExpiry lets the user choose when the note expires
+func (sn *StickyNote) onExpiry() {
+	on, hasDate := sn.Note.ExpiresOn()
+	if !hasDate {
+		on = time.Now().AddDate(0, 0, 7)
+	}
+	idleDays := sn.Note.ExpireIdleDays()
+
+	dialog, err := gtk.DialogNewWithButtons("Expire Note", sn.WinMain, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL}, []interface{}{"Set", gtk.RESPONSE_ACCEPT})
+	if err != nil {
+		return
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.SetMarginTop(12)
+
+	rbNever, _ := gtk.RadioButtonNewWithLabel(nil, "Never")
+	content.PackStart(rbNever, false, false, 0)
+	rbDate, _ := gtk.RadioButtonNewWithLabelFromWidget(rbNever, "Move to the trash on")
+	content.PackStart(rbDate, false, false, 0)
+	calendar, _ := gtk.CalendarNew()
+	calendar.SelectMonth(uint(on.Month()-1), uint(on.Year()))
+	calendar.SelectDay(uint(on.Day()))
+	calendar.SetMarginStart(24)
+	content.PackStart(calendar, false, false, 0)
+
+	idleBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	rbIdle, _ := gtk.RadioButtonNewWithLabelFromWidget(rbNever, "Move to the trash after")
+	idleBox.PackStart(rbIdle, false, false, 0)
+	spinDays, _ := gtk.SpinButtonNewWithRange(1, 3650, 1)
+	spinDays.SetValue(float64(max(idleDays, 7)))
+	idleBox.PackStart(spinDays, false, false, 0)
+	daysLabel, _ := gtk.LabelNew("days without edits")
+	idleBox.PackStart(daysLabel, false, false, 0)
+	content.PackStart(idleBox, false, false, 0)
+
+	switch {
+	case hasDate:
+		rbDate.SetActive(true)
+	case idleDays > 0:
+		rbIdle.SetActive(true)
+	}
+	updateSensitive := func() {
+		calendar.SetSensitive(rbDate.GetActive())
+		spinDays.SetSensitive(rbIdle.GetActive())
+	}
+	rbDate.Connect("toggled", updateSensitive)
+	rbIdle.Connect("toggled", updateSensitive)
+	updateSensitive()
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	year, month, day := calendar.GetDate()
+	days := spinDays.GetValueAsInt()
+	byDate, byIdle := rbDate.GetActive(), rbIdle.GetActive()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	switch {
+	case byDate:
+		sn.Note.SetExpiry(time.Date(int(year), time.Month(month+1), int(day), 0, 0, 0, 0, time.Local), 0)
+	case byIdle:
+		sn.Note.SetExpiry(time.Time{}, days)
+	default:
+		sn.Note.SetExpiry(time.Time{}, 0)
+	}
+	// The menu shows when the note expires
+	sn.PopulateMenu()
+}
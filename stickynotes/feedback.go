@@ -0,0 +1,81 @@
+package stickynotes
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Feedback sounds are optional ("sound_enabled") and can be muted from the indicator
+// menu ("sound_muted") without losing the setting. Sounds come from the freedesktop
+// sound theme, so they follow the desktop's own theme.
+
+// FeedbackEvent is a sound event, named after the freedesktop sound naming spec
+type FeedbackEvent string
+
+// Feedback events
+const (
+	FeedbackReminder    FeedbackEvent = "alarm-clock-elapsed"
+	FeedbackNoteDeleted FeedbackEvent = "trash-empty"
+)
+
+// soundThemeDirs are searched for the sound files when canberra-gtk-play is not available
+var soundThemeDirs = []string{
+	"/usr/share/sounds/freedesktop/stereo",
+	"/usr/local/share/sounds/freedesktop/stereo",
+}
+
+// FeedbackEnabled reports whether feedback sounds are turned on and not muted
+func (ns *NoteSet) FeedbackEnabled() bool {
+	enabled, _ := ns.Properties["sound_enabled"].(bool)
+	muted, _ := ns.Properties["sound_muted"].(bool)
+	return enabled && !muted
+}
+
+// SetFeedbackMuted mutes or unmutes feedback sounds, keeping the setting itself
+func (ns *NoteSet) SetFeedbackMuted(muted bool) {
+	ns.Properties["sound_muted"] = muted
+	ns.Save()
+}
+
+// Feedback plays the sound for event in the background, if feedback sounds are enabled
+func (ns *NoteSet) Feedback(event FeedbackEvent) {
+	if !ns.FeedbackEnabled() {
+		return
+	}
+	if err := playSound(event); err != nil {
+		fmt.Printf("[Feedback] Failed to play %s: %v\n", event, err)
+	}
+}
+
+// playSound starts a player for the theme sound of event without waiting for it
+func playSound(event FeedbackEvent) error {
+	var cmd *exec.Cmd
+	if path, err := exec.LookPath("canberra-gtk-play"); err == nil {
+		cmd = exec.Command(path, "--id", string(event), "--description", "PostNote")
+	} else {
+		file := ""
+		for _, dir := range soundThemeDirs {
+			candidate := filepath.Join(dir, string(event)+".oga")
+			if _, err := os.Stat(candidate); err == nil {
+				file = candidate
+				break
+			}
+		}
+		if file == "" {
+			return fmt.Errorf("no sound player or theme sound found")
+		}
+		player, err := exec.LookPath("paplay")
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command(player, file)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the player when it is done
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,155 @@
+package stickynotes
+
+import (
+	"unicode"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const findMatchTag = "find-match"
+
+// FindBar is the Ctrl+F search row of a sticky note
+type FindBar struct {
+	Note    *StickyNote
+	Box     *gtk.Box
+	Entry   *gtk.SearchEntry
+	matches [][2]int // Rune offsets [start, end) of every match
+	current int      // Index of the selected match, -1 if none
+}
+
+// newFindBar wires the find row widgets of a note's builder
+func newFindBar(sn *StickyNote) *FindBar {
+	box, err := getObject[*gtk.Box](sn.Builder, "boxFind")
+	if err != nil {
+		return nil
+	}
+	fb := &FindBar{Note: sn, Box: box, current: -1}
+	fb.Entry, _ = getObject[*gtk.SearchEntry](sn.Builder, "eFind")
+
+	sn.BBody.CreateTag(findMatchTag, map[string]interface{}{
+		"background": "#ffff00",
+		"foreground": "#000000",
+	})
+
+	fb.Entry.Connect("search-changed", fb.onSearchChanged)
+	fb.Entry.Connect("activate", fb.Next)
+	fb.Entry.Connect("next-match", fb.Next)
+	fb.Entry.Connect("previous-match", fb.Previous)
+	fb.Entry.Connect("stop-search", fb.Close)
+	fb.Entry.Connect("key-press-event", fb.onKeyPress)
+	if btn, err := getObject[*gtk.Button](sn.Builder, "bFindPrev"); err == nil {
+		btn.Connect("clicked", fb.Previous)
+	}
+	if btn, err := getObject[*gtk.Button](sn.Builder, "bFindNext"); err == nil {
+		btn.Connect("clicked", fb.Next)
+	}
+	return fb
+}
+
+// Open reveals the find row and focuses the entry, prefilled with the selection
+func (fb *FindBar) Open() {
+	fb.Note.NoteSet.RecordUsage(UsageFind)
+	if start, end, ok := fb.Note.BBody.GetSelectionBounds(); ok {
+		if text, err := fb.Note.BBody.GetText(start, end, false); err == nil && text != "" {
+			fb.Entry.SetText(text)
+		}
+	}
+	fb.Box.ShowAll()
+	fb.Entry.GrabFocus()
+	fb.onSearchChanged()
+}
+
+// Close hides the find row, clears highlights and returns focus to the text
+func (fb *FindBar) Close() {
+	fb.clearHighlights()
+	fb.matches = nil
+	fb.current = -1
+	fb.Box.Hide()
+	fb.Note.TxtNote.GrabFocus()
+}
+
+// Next selects the next match, wrapping around at the end
+func (fb *FindBar) Next() {
+	if len(fb.matches) == 0 {
+		return
+	}
+	fb.current = (fb.current + 1) % len(fb.matches)
+	fb.selectCurrent()
+}
+
+// Previous selects the previous match, wrapping around at the start
+func (fb *FindBar) Previous() {
+	if len(fb.matches) == 0 {
+		return
+	}
+	fb.current = (fb.current - 1 + len(fb.matches)) % len(fb.matches)
+	fb.selectCurrent()
+}
+
+func (fb *FindBar) onSearchChanged() {
+	fb.clearHighlights()
+	query, _ := fb.Entry.GetText()
+	start, end := fb.Note.BBody.GetBounds()
+	text, _ := fb.Note.BBody.GetText(start, end, true)
+
+	fb.matches = findMatches(text, query)
+	fb.current = -1
+	for _, m := range fb.matches {
+		fb.Note.BBody.ApplyTagByName(findMatchTag, fb.Note.iterAtTextOffset(m[0]), fb.Note.iterAtTextOffset(m[1]))
+	}
+	fb.Next()
+}
+
+func (fb *FindBar) onKeyPress(entry *gtk.SearchEntry, event *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+	if keyEvent.KeyVal() == gdk.KEY_Return && gdk.ModifierType(keyEvent.State())&gdk.SHIFT_MASK != 0 {
+		fb.Previous()
+		return true
+	}
+	return false
+}
+
+func (fb *FindBar) selectCurrent() {
+	m := fb.matches[fb.current]
+	start := fb.Note.iterAtTextOffset(m[0])
+	end := fb.Note.iterAtTextOffset(m[1])
+	fb.Note.BBody.SelectRange(start, end)
+	fb.Note.TxtNote.ScrollToIter(start, 0.1, false, 0, 0)
+}
+
+func (fb *FindBar) clearHighlights() {
+	start, end := fb.Note.BBody.GetBounds()
+	fb.Note.BBody.RemoveTagByName(findMatchTag, start, end)
+}
+
+// findMatches returns the rune offsets of every case-insensitive occurrence of query in text
+func findMatches(text, query string) [][2]int {
+	if query == "" {
+		return nil
+	}
+	haystack := []rune(text)
+	needle := []rune(query)
+	for i := range haystack {
+		haystack[i] = unicode.ToLower(haystack[i])
+	}
+	for i := range needle {
+		needle[i] = unicode.ToLower(needle[i])
+	}
+
+	var matches [][2]int
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		found := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				found = false
+				break
+			}
+		}
+		if found {
+			matches = append(matches, [2]int{i, i + len(needle)})
+			i += len(needle) - 1
+		}
+	}
+	return matches
+}
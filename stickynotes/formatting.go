@@ -0,0 +1,141 @@
+package stickynotes
+
+import (
+	"time"
+)
+
+// Formatting styles that can be applied to ranges of a note body
+const (
+	FormatStrikethrough = "strikethrough"
+)
+
+// FormatRange is a span of formatted text in a note body, in character offsets
+type FormatRange struct {
+	Style string `json:"style"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// formatRangeList converts a JSON-decoded "formatting" array to format ranges,
+// dropping entries that are malformed
+func formatRangeList(list []interface{}) []FormatRange {
+	ranges := make([]FormatRange, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		style, _ := m["style"].(string)
+		start, okStart := m["start"].(float64)
+		end, okEnd := m["end"].(float64)
+		if style == "" || !okStart || !okEnd || start < 0 || end <= start {
+			continue
+		}
+		ranges = append(ranges, FormatRange{Style: style, Start: int(start), End: int(end)})
+	}
+	return ranges
+}
+
+// setupFormatting creates the formatting text tags and applies the note's saved ranges
+func (sn *StickyNote) setupFormatting() {
+	sn.BBody.CreateTag(FormatStrikethrough, map[string]interface{}{
+		"strikethrough": true,
+	})
+	sn.applyFormatting()
+}
+
+// applyFormatting applies the note's saved formatting ranges to the buffer
+func (sn *StickyNote) applyFormatting() {
+	table, err := sn.BBody.GetTagTable()
+	if err != nil {
+		return
+	}
+	charCount := sn.textCharCount()
+	for _, r := range sn.Note.Formatting {
+		tag, err := table.Lookup(r.Style)
+		if err != nil || r.Start >= charCount {
+			continue
+		}
+		end := r.End
+		if end > charCount {
+			end = charCount
+		}
+		sn.BBody.ApplyTag(tag, sn.iterAtTextOffset(r.Start), sn.iterAtTextOffset(end))
+	}
+}
+
+// formattingFromBuffer reads the formatting ranges back from the text tags, which
+// move along with the text as the note is edited
+func (sn *StickyNote) formattingFromBuffer() []FormatRange {
+	table, err := sn.BBody.GetTagTable()
+	if err != nil {
+		return sn.Note.Formatting
+	}
+	tag, err := table.Lookup(FormatStrikethrough)
+	if err != nil {
+		return sn.Note.Formatting
+	}
+
+	var ranges []FormatRange
+	iter := sn.BBody.GetStartIter()
+	inside := iter.HasTag(tag)
+	from := 0
+	for iter.ForwardToTagToggle(tag) {
+		if inside {
+			ranges = append(ranges, FormatRange{Style: FormatStrikethrough, Start: from, End: sn.textOffset(iter)})
+		} else {
+			from = sn.textOffset(iter)
+		}
+		inside = !inside
+	}
+	if inside {
+		ranges = append(ranges, FormatRange{Style: FormatStrikethrough, Start: from, End: sn.textCharCount()})
+	}
+	return ranges
+}
+
+// ToggleStrikethrough strikes through the current line (or the selected lines), or
+// removes the strikethrough when they are already struck through
+func (sn *StickyNote) ToggleStrikethrough() {
+	if sn.InViewMode() {
+		return
+	}
+	table, err := sn.BBody.GetTagTable()
+	if err != nil {
+		return
+	}
+	tag, err := table.Lookup(FormatStrikethrough)
+	if err != nil {
+		return
+	}
+
+	start, end, ok := sn.BBody.GetSelectionBounds()
+	if !ok {
+		start = sn.BBody.GetIterAtMark(sn.BBody.GetInsert())
+		end = sn.BBody.GetIterAtMark(sn.BBody.GetInsert())
+	}
+	lineStart := sn.BBody.GetIterAtLine(start.GetLine())
+	lineEnd := sn.BBody.GetIterAtLine(end.GetLine())
+	if !lineEnd.EndsLine() {
+		lineEnd.ForwardToLineEnd()
+	}
+	if lineStart.Equal(lineEnd) {
+		return // Nothing to strike through on an empty line
+	}
+
+	// Struck through already if no toggle of the tag happens inside the lines
+	struck := lineStart.HasTag(tag)
+	probe := sn.BBody.GetIterAtOffset(lineStart.GetOffset())
+	if probe.ForwardToTagToggle(tag) && probe.Compare(lineEnd) < 0 {
+		struck = false
+	}
+
+	if struck {
+		sn.BBody.RemoveTag(tag, lineStart, lineEnd)
+	} else {
+		sn.BBody.ApplyTag(tag, lineStart, lineEnd)
+	}
+	sn.Note.Formatting = sn.formattingFromBuffer()
+	sn.Note.LastModified = time.Now()
+	sn.NoteSet.Save()
+}
@@ -0,0 +1,188 @@
+package stickynotes
+
+/*
+#cgo pkg-config: gtk+-3.0
+#define GDK_DISABLE_DEPRECATION_WARNINGS
+#include <gtk/gtk.h>
+#ifdef GDK_WINDOWING_X11
+#include <gdk/gdkx.h>
+#endif
+
+// Whether the active window is fullscreen (_NET_WM_STATE_FULLSCREEN), -1 when not on X11
+static int x11_active_fullscreen(void) {
+#ifdef GDK_WINDOWING_X11
+	GdkScreen *screen = gdk_screen_get_default();
+	if (!screen || !GDK_IS_X11_SCREEN(screen)) {
+		return -1;
+	}
+	GdkWindow *active = gdk_screen_get_active_window(screen);
+	if (!active) {
+		return 0;
+	}
+	int fullscreen = 0;
+	GdkAtom type;
+	gint format, length;
+	guchar *data = NULL;
+	GdkDisplay *display = gdk_window_get_display(active);
+	// The window may be gone by now
+	gdk_x11_display_error_trap_push(display);
+	if (gdk_property_get(active, gdk_atom_intern_static_string("_NET_WM_STATE"),
+			gdk_atom_intern_static_string("ATOM"), 0, G_MAXLONG, FALSE, &type, &format, &length, &data) && data) {
+		GdkAtom *states = (GdkAtom *) data;
+		GdkAtom state = gdk_atom_intern_static_string("_NET_WM_STATE_FULLSCREEN");
+		for (gsize i = 0; i < length / sizeof(GdkAtom); i++) {
+			if (states[i] == state) {
+				fullscreen = 1;
+			}
+		}
+		g_free(data);
+	}
+	gdk_x11_display_error_trap_pop_ignored(display);
+	g_object_unref(active);
+	return fullscreen;
+#endif
+	return -1;
+}
+*/
+import "C"
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+)
+
+// The shown notes can be hidden while another application has the focus fullscreen (a
+// video, a game, a presentation), and shown again once it hasn't. Stored as
+// "hide_fullscreen" in the settings. The focused window is asked for through the window
+// backend: its EWMH state on X11, the window-calls List on GNOME Wayland (the focused
+// window covering its whole monitor), and the tracker script on KDE Plasma (see kwin.go).
+
+// fullscreenCheckInterval is how often (ms) the focused window is checked
+const fullscreenCheckInterval = 1500
+
+// HideForFullscreen reports whether notes are hidden while a fullscreen application has
+// the focus
+func (ns *NoteSet) HideForFullscreen() bool {
+	on, _ := ns.Properties["hide_fullscreen"].(bool)
+	return on
+}
+
+// WatchFullscreen hides the shown notes while a fullscreen application has the focus,
+// when set
+func WatchFullscreen(ns *NoteSet) {
+	glib.TimeoutAdd(fullscreenCheckInterval, func() bool {
+		ns.checkFullscreen()
+		return true // Repeat
+	})
+}
+
+// checkFullscreen hides the shown notes when a fullscreen application got the focus, or
+// shows the notes it hid once it lost it
+func (ns *NoteSet) checkFullscreen() {
+	if !ns.HideForFullscreen() {
+		ns.restoreAfterFullscreen()
+		return
+	}
+	if ns.fullscreenHidden == nil && !ns.anyNoteShown() {
+		return // Nothing to hide, don't ask the window manager
+	}
+	fullscreen, ok := Backend().FullscreenFocused()
+	switch {
+	case !ok:
+	case fullscreen && ns.fullscreenHidden == nil:
+		hidden := make(map[*Note]bool)
+		for _, note := range ns.Notes {
+			if isNoteShown(note) {
+				note.GUI.UpdateNote()
+				hidden[note] = true
+			}
+		}
+		ns.Save()
+		for note := range hidden {
+			note.Hide()
+		}
+		ns.fullscreenHidden = hidden
+	case !fullscreen:
+		ns.restoreAfterFullscreen()
+	}
+}
+
+// restoreAfterFullscreen shows the notes hidden for a fullscreen application again, the
+// ones not deleted meanwhile
+func (ns *NoteSet) restoreAfterFullscreen() {
+	hidden := ns.fullscreenHidden
+	if hidden == nil {
+		return
+	}
+	ns.fullscreenHidden = nil
+	for _, note := range ns.Notes {
+		if hidden[note] {
+			note.Show()
+		}
+	}
+}
+
+// anyNoteShown reports whether a note window is shown
+func (ns *NoteSet) anyNoteShown() bool {
+	for _, note := range ns.Notes {
+		if isNoteShown(note) {
+			return true
+		}
+	}
+	return false
+}
+
+// x11FullscreenFocused reports whether the active window is fullscreen, ok is false when
+// not on X11
+func x11FullscreenFocused() (fullscreen, ok bool) {
+	state := C.x11_active_fullscreen()
+	return state == 1, state >= 0
+}
+
+// windowCallsFullscreenFocused reports whether the focused window of another application
+// covers its whole monitor, from the window-calls List. ok is false when the windows
+// can't be listed.
+func windowCallsFullscreenFocused() (fullscreen, ok bool) {
+	windows, err := ListWindows()
+	if err != nil || windows == nil {
+		return false, false
+	}
+	for _, win := range windows {
+		if !win.Focus {
+			continue
+		}
+		if win.PID == currentPID {
+			return false, true
+		}
+		// In the compositor's coordinates, see scale.go
+		scale := 1.0
+		if lastScale > 0 {
+			scale = lastScale
+		}
+		area := monitorAt(int(float64(win.X+win.Width/2)/scale), int(float64(win.Y+win.Height/2)/scale))
+		at, size := scalePoint([2]int{area[0], area[1]}, scale), scalePoint([2]int{area[2], area[3]}, scale)
+		return coversArea([4]int{win.X, win.Y, win.Width, win.Height}, [4]int{at[0], at[1], size[0], size[1]}), true
+	}
+	return false, true
+}
+
+// monitorAt returns the geometry (x, y, width, height) of the monitor at the point, the
+// whole of it unlike workareaAt
+func monitorAt(x, y int) [4]int {
+	if display, err := gdk.DisplayGetDefault(); err == nil {
+		if monitor, err := display.GetMonitorAtPoint(x, y); err == nil {
+			areaX, areaY, areaW, areaH := monitor.GetGeometry().GetRectangleInt()
+			return [4]int{areaX, areaY, areaW, areaH}
+		}
+	}
+	return [4]int{}
+}
+
+// coversArea reports whether rect covers all of area (x, y, width, height), give or take
+// the pixels lost scaling it
+func coversArea(rect, area [4]int) bool {
+	const slack = 2
+	return area[2] > 0 && area[3] > 0 &&
+		rect[0] <= area[0]+slack && rect[1] <= area[1]+slack &&
+		rect[0]+rect[2] >= area[0]+area[2]-slack && rect[1]+rect[3] >= area[1]+area[3]-slack
+}
@@ -0,0 +1,25 @@
+package stickynotes
+
+import "testing"
+
+func TestCoversArea(t *testing.T) {
+	monitor := [4]int{1920, 0, 2560, 1440}
+	tests := []struct {
+		name string
+		rect [4]int
+		want bool
+	}{
+		{"exactly", [4]int{1920, 0, 2560, 1440}, true},
+		{"overhanging", [4]int{1910, -10, 2580, 1460}, true},
+		{"maximized below a panel", [4]int{1920, 32, 2560, 1408}, false},
+		{"other monitor", [4]int{0, 0, 1920, 1080}, false},
+	}
+	for _, tt := range tests {
+		if got := coversArea(tt.rect, monitor); got != tt.want {
+			t.Errorf("%s: coversArea(%v) = %v, want %v", tt.name, tt.rect, got, tt.want)
+		}
+	}
+	if coversArea([4]int{0, 0, 100, 100}, [4]int{}) {
+		t.Error("coversArea() with an unknown monitor = true, want false")
+	}
+}
@@ -0,0 +1,171 @@
+package stickynotes
+
+import "testing"
+
+// Fuzz targets for everything that reads notes from outside: the data file, imported
+// exports and Markdown files, notes pasted as JSON and encrypted files. Damaged or hostile
+// input must be rejected with an error or loaded into a consistent noteset, never panic.
+// Run one with e.g.: go test ./stickynotes -run '^$' -fuzz FuzzLoads
+
+// fuzzSeeds are inputs shared by the JSON targets
+var fuzzSeeds = []string{
+	testNoteSetJSON,
+	`{}`,
+	`{"notes": []}`,
+	`{"notes": [{"uuid": "note-0001", "body": "a"}, {"uuid": "note-0001", "body": "b"}]}`,
+	`{"notes": [{"uuid": "x", "body": 1, "cat": [], "tags": "a", "properties": [], "formatting": {}}]}`,
+	`{"notes": [{"formatting": [{"start": -1, "end": 1e300, "style": "strikethrough"}], "rev": 1e300}]}`,
+	`{"notes": [{"last_modified": "not a date", "deleted_at": 5}], "trash": [null, 1, "a"]}`,
+	`{"categories": {"a": null, "b": {"name": 5, "bgcolor_hsv": "red"}}, "properties": {"default_cat": 3}}`,
+	`{"notes": [], "attachments": {"../..": {"../x": "AAAA"}, "note-0001": {"a.txt": "not base64!"}}}`,
+	`null`,
+	`[]`,
+	`"notes"`,
+	`{"notes": {}}`,
+}
+
+// checkNoteSet fails the test when the noteset isn't consistent: a note is nil or has a
+// short UUID, or the notes don't survive a save and reload
+func checkNoteSet(t *testing.T, ns *NoteSet) {
+	t.Helper()
+	for _, note := range append(append([]*Note{}, ns.Notes...), ns.Trash...) {
+		if note == nil {
+			t.Fatal("nil note")
+		}
+		if len(note.UUID) < 8 {
+			t.Fatalf("note with uuid %q", note.UUID)
+		}
+	}
+	reloaded := newTestNoteSet(t)
+	if err := reloaded.Loads(ns.Dumps()); err != nil {
+		t.Fatalf("reloading the saved notes: %v", err)
+	}
+	if len(reloaded.Notes) != len(ns.Notes) || len(reloaded.Trash) != len(ns.Trash) {
+		t.Fatalf("reloaded %d notes and %d in trash, saved %d and %d",
+			len(reloaded.Notes), len(reloaded.Trash), len(ns.Notes), len(ns.Trash))
+	}
+}
+
+func FuzzLoads(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		ns := newTestNoteSet(t)
+		if err := ns.Loads(testNoteSetJSON); err != nil {
+			t.Fatal(err)
+		}
+		if err := ns.Loads(data); err != nil {
+			// A rejected file leaves the notes as they were
+			if len(ns.Notes) != 2 || len(ns.Trash) != 2 {
+				t.Fatalf("failed Loads changed the notes: %v", err)
+			}
+			return
+		}
+		checkNoteSet(t, ns)
+	})
+}
+
+func FuzzMerge(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		ns := newTestNoteSet(t)
+		if err := ns.Loads(testNoteSetJSON); err != nil {
+			t.Fatal(err)
+		}
+		before := ns.Dumps()
+		if err := ns.Merge(data); err != nil {
+			if ns.Dumps() != before {
+				t.Fatalf("failed Merge changed the notes: %v", err)
+			}
+			return
+		}
+		checkUniqueUUIDs(t, ns)
+		checkNoteSet(t, ns)
+	})
+}
+
+func FuzzImportMarkdown(f *testing.F) {
+	ns := newTestNoteSet(f)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(findNote(f, ns, "note-0001").ToMarkdown())
+	f.Add("plain text, no front-matter")
+	f.Add("---\n---")
+	f.Add("---\n\n---\n")
+	f.Add("---\nuuid: note-0003\ncategory: Work\n---\nback from the trash")
+	f.Add("---\nuuid: [1, 2]\ntags: [a, b\ncolor: #zzzzzz\nposition: {\"x\": 1}\nproperties: [1]\n---\nbody")
+	f.Add("---\ncategory: \"New\"\ncategory_id: \"\"\ncolor: \"#ff0000\"\ntext_color: \"#000\"\nformatting: [{\"start\": 3, \"end\": 1}]\n---\r\nbody\r\n")
+	f.Fuzz(func(t *testing.T, data string) {
+		ns := newTestNoteSet(t)
+		if err := ns.Loads(testNoteSetJSON); err != nil {
+			t.Fatal(err)
+		}
+		if err := ns.ImportMarkdown(data); err != nil {
+			return
+		}
+		checkUniqueUUIDs(t, ns)
+		checkNoteSet(t, ns)
+	})
+}
+
+func FuzzParseNoteJSON(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Add(`{"uuid": "note-0001", "body": "copied", "cat": "cat-a", "deleted_at": "2024-01-01T00:00:00"}`)
+	f.Add(`{"body": "only a body"}`)
+	f.Fuzz(func(t *testing.T, text string) {
+		content := parseNoteJSON(text)
+		if content == nil {
+			return
+		}
+		ns := newTestNoteSet(t)
+		ns.Notes = append(ns.Notes, NewNote(content, nil, ns, ""))
+		checkNoteSet(t, ns)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	key, err := deriveKey("secret", []byte("0123456789abcdef"), 1000)
+	if err != nil {
+		f.Fatal(err)
+	}
+	sealed, err := key.seal([]byte(testNoteSetJSON))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sealed)
+	f.Add([]byte(`{"encrypted": {"version": 1, "kdf": "pbkdf2-sha256", "iterations": 0}}`))
+	f.Add([]byte(`{"encrypted": {"version": 1, "kdf": "pbkdf2-sha256", "iterations": 2147483647, "salt": "", "nonce": "", "data": ""}}`))
+	f.Add([]byte(`{"encrypted": {"version": 1, "kdf": "pbkdf2-sha256", "iterations": 1000, "nonce": "AAAA"}}`))
+	f.Add([]byte(`{"encrypted": null}`))
+	f.Add([]byte(testNoteSetJSON))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ns := newTestNoteSet(t)
+		ns.SetPassphrase("secret")
+		plain, err := ns.decode(data)
+		if err != nil {
+			return
+		}
+		if ns.Loads(string(plain)) == nil {
+			checkNoteSet(t, ns)
+		}
+	})
+}
+
+// checkUniqueUUIDs fails the test when a UUID is used by two notes, or by a note and a
+// note in the trash
+func checkUniqueUUIDs(t *testing.T, ns *NoteSet) {
+	t.Helper()
+	uuids := make(map[string]bool)
+	for _, note := range append(append([]*Note{}, ns.Notes...), ns.Trash...) {
+		if uuids[note.UUID] {
+			t.Fatalf("uuid %s used twice", note.UUID)
+		}
+		uuids[note.UUID] = true
+	}
+}
@@ -0,0 +1,184 @@
+package stickynotes
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// Glance is a compact overlay summarizing the pinned notes and the upcoming reminders,
+// summoned with one shortcut (the "glance" action, or --glance from a desktop keyboard
+// shortcut) and dismissed with Esc, instead of showing and hiding every note window.
+
+// glanceWindow is the glance currently on screen, there is only ever one
+var glanceWindow *gtk.Window
+
+// glanceEntry is a note listed in the glance
+type glanceEntry struct {
+	note   *Note
+	detail string
+}
+
+// ToggleGlance shows the glance, or dismisses it when it is shown
+func ToggleGlance(ns *NoteSet) {
+	if glanceWindow != nil {
+		HideGlance()
+		return
+	}
+	ShowGlance(ns)
+}
+
+// HideGlance dismisses the glance, if it is shown
+func HideGlance() {
+	if glanceWindow != nil {
+		// Cleared first, destroying the window moves the focus out of it
+		win := glanceWindow
+		glanceWindow = nil
+		win.Destroy()
+	}
+}
+
+// ShowGlance shows the glance of the pinned notes and upcoming reminders
+func ShowGlance(ns *NoteSet) {
+	HideGlance()
+
+	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	if err != nil {
+		return
+	}
+	win.SetTitle("Glance")
+	win.SetDecorated(false)
+	win.SetKeepAbove(true)
+	win.SetSkipTaskbarHint(true)
+	win.SetSkipPagerHint(true)
+	win.SetTypeHint(gdk.WINDOW_TYPE_HINT_DIALOG)
+	win.SetDefaultSize(360, -1)
+	win.SetPosition(gtk.WIN_POS_CENTER)
+	win.Connect("key-press-event", func(win *gtk.Window, event *gdk.Event) bool {
+		if gdk.EventKeyNewFromEvent(event).KeyVal() == gdk.KEY_Escape {
+			HideGlance()
+			return true
+		}
+		return false
+	})
+	// Dismiss like a popup when focus moves elsewhere
+	win.Connect("focus-out-event", func() bool {
+		HideGlance()
+		return false
+	})
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(10)
+
+	pinned, reminders := ns.glanceEntries()
+	if len(pinned) == 0 && len(reminders) == 0 {
+		empty, _ := gtk.LabelNew("No pinned notes or upcoming reminders.")
+		empty.SetMarginTop(6)
+		empty.SetMarginBottom(6)
+		box.PackStart(empty, false, false, 0)
+	}
+	for _, section := range []struct {
+		title   string
+		entries []glanceEntry
+	}{{"Pinned", pinned}, {"Reminders", reminders}} {
+		if len(section.entries) == 0 {
+			continue
+		}
+		header, _ := gtk.LabelNew("")
+		header.SetMarkup("<b>" + section.title + "</b>")
+		header.SetHAlign(gtk.ALIGN_START)
+		box.PackStart(header, false, false, 0)
+
+		list, _ := gtk.ListBoxNew()
+		list.SetActivateOnSingleClick(true)
+		entries := section.entries
+		for _, entry := range entries {
+			list.Add(glanceRow(entry))
+		}
+		list.Connect("row-activated", func(list *gtk.ListBox, row *gtk.ListBoxRow) {
+			index := row.GetIndex()
+			if index < 0 || index >= len(entries) {
+				return
+			}
+			note := entries[index].note
+			HideGlance()
+			note.Show()
+			if note.GUI != nil {
+				note.GUI.Raise()
+			}
+		})
+		box.PackStart(list, false, false, 0)
+	}
+
+	hint, _ := gtk.LabelNew("")
+	hint.SetMarkup("<small>Click a note to open it, Esc to close</small>")
+	hint.SetHAlign(gtk.ALIGN_END)
+	box.PackEnd(hint, false, false, 0)
+
+	win.Add(box)
+	glanceWindow = win
+	win.ShowAll()
+	win.Present()
+}
+
+// glanceEntries returns the pinned notes by title, and the notes with a reminder by
+// when it is due, the soonest first
+func (ns *NoteSet) glanceEntries() (pinned, reminders []glanceEntry) {
+	type reminder struct {
+		note *Note
+		at   time.Time
+	}
+	var due []reminder
+	for _, note := range ns.Notes {
+		if note.GUI != nil && note.GUI.WinMain != nil {
+			note.GUI.UpdateNote()
+		}
+		if isPinned, _ := note.Properties["pinned"].(bool); isPinned {
+			category, _ := ns.Categories[note.Category]["name"].(string)
+			pinned = append(pinned, glanceEntry{note: note, detail: category})
+		}
+		if at, ok := note.ReminderAt(); ok {
+			due = append(due, reminder{note, at})
+		}
+	}
+	sort.SliceStable(pinned, func(i, j int) bool {
+		return pinned[i].note.FirstLine() < pinned[j].note.FirstLine()
+	})
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].at.Before(due[j].at)
+	})
+	for _, r := range due {
+		reminders = append(reminders, glanceEntry{note: r.note, detail: FormatDayTime(r.at)})
+	}
+	return pinned, reminders
+}
+
+// glanceRow shows a note's first line with its detail
+func glanceRow(entry glanceEntry) *gtk.ListBoxRow {
+	row, _ := gtk.ListBoxRowNew()
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetBorderWidth(4)
+
+	title := entry.note.FirstLine()
+	if title == "" {
+		title = "(empty note)"
+	}
+	label, _ := gtk.LabelNew(title)
+	label.SetHAlign(gtk.ALIGN_START)
+	label.SetEllipsize(pango.ELLIPSIZE_END)
+	label.SetTooltipText(entry.note.Body)
+	box.PackStart(label, true, true, 0)
+
+	if entry.detail != "" {
+		detail, _ := gtk.LabelNew("")
+		detail.SetMarkup("<small>" + glib.MarkupEscapeText(entry.detail) + "</small>")
+		box.PackEnd(detail, false, false, 0)
+	}
+
+	row.Add(box)
+	return row
+}
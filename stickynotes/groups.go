@@ -0,0 +1,265 @@
+package stickynotes
+
+import (
+	"github.com/google/uuid"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Notes can be stacked into a group, stored as a shared "group" id in their properties.
+// Dragging one member moves the others by the same offset. A group can be collapsed onto
+// one of its members, the header: the others are hidden and listed at the top of the
+// header, and "group_collapsed" holds the header's UUID on every member.
+
+// Group returns the id of the note's group, "" when it isn't in one
+func (n *Note) Group() string {
+	group, _ := n.Properties["group"].(string)
+	return group
+}
+
+// groupMembers returns the notes in the group, in noteset order
+func (ns *NoteSet) groupMembers(group string) []*Note {
+	if group == "" {
+		return nil
+	}
+	var members []*Note
+	for _, note := range ns.Notes {
+		if note.Group() == group && !note.deletePending {
+			members = append(members, note)
+		}
+	}
+	return members
+}
+
+// collapsedUnder returns the header of the collapsed group the note is hidden in, nil
+// when the note isn't hidden in a group (a header whose note is gone doesn't count)
+func (n *Note) collapsedUnder() *Note {
+	headerUUID, _ := n.Properties["group_collapsed"].(string)
+	if headerUUID == "" || headerUUID == n.UUID {
+		return nil
+	}
+	header := n.NoteSet.noteByUUID(headerUUID)
+	if header == nil || header.deletePending || header.Group() != n.Group() {
+		return nil
+	}
+	return header
+}
+
+// isGroupHeader reports whether the note is the header of its collapsed group
+func (n *Note) isGroupHeader() bool {
+	headerUUID, _ := n.Properties["group_collapsed"].(string)
+	return headerUUID != "" && headerUUID == n.UUID
+}
+
+// joinGroup puts the note into other's group, starting a new group when other isn't in one
+func (n *Note) joinGroup(other *Note) {
+	group := other.Group()
+	if group == "" {
+		group = uuid.New().String()
+		other.Properties["group"] = group
+	}
+	if old := n.Group(); old != "" && old != group {
+		n.leaveGroup()
+	}
+	n.Properties["group"] = group
+	// Join collapsed like the rest of the group
+	if headerUUID, ok := other.Properties["group_collapsed"].(string); ok && headerUUID != "" {
+		n.Properties["group_collapsed"] = headerUUID
+		n.Hide()
+	}
+	n.NoteSet.refreshGroupHeaders(group)
+}
+
+// leaveGroup takes the note out of its group. A group left with a single note is dissolved.
+func (n *Note) leaveGroup() {
+	group := n.Group()
+	if group == "" {
+		return
+	}
+	if n.isGroupHeader() {
+		n.NoteSet.expandGroup(group)
+	}
+	hidden := n.collapsedUnder() != nil
+	delete(n.Properties, "group")
+	delete(n.Properties, "group_collapsed")
+	if hidden {
+		n.Show()
+	}
+	if n.GUI != nil {
+		n.GUI.updateGroupHeader()
+	}
+
+	if rest := n.NoteSet.groupMembers(group); len(rest) == 1 {
+		delete(rest[0].Properties, "group")
+		delete(rest[0].Properties, "group_collapsed")
+		rest[0].Show()
+	}
+	n.NoteSet.refreshGroupHeaders(group)
+}
+
+// collapseGroup hides the other members of the note's group, listing them in the note
+func (n *Note) collapseGroup() {
+	for _, member := range n.NoteSet.groupMembers(n.Group()) {
+		member.Properties["group_collapsed"] = n.UUID
+		if member != n {
+			member.Hide()
+		}
+	}
+	n.Show()
+	if n.GUI != nil {
+		n.GUI.updateGroupHeader()
+		n.GUI.PopulateMenu()
+	}
+}
+
+// expandGroup shows all members of the group again
+func (ns *NoteSet) expandGroup(group string) {
+	for _, member := range ns.groupMembers(group) {
+		delete(member.Properties, "group_collapsed")
+	}
+	for _, member := range ns.groupMembers(group) {
+		member.Show()
+		if member.GUI != nil {
+			member.GUI.updateGroupHeader()
+			member.GUI.PopulateMenu()
+		}
+	}
+}
+
+// refreshGroupHeaders updates the member list of the group's header
+func (ns *NoteSet) refreshGroupHeaders(group string) {
+	for _, member := range ns.groupMembers(group) {
+		if member.GUI != nil {
+			member.GUI.updateGroupHeader()
+		}
+	}
+}
+
+// updateGroupHeader lists the hidden members at the top of a collapsed group's header
+func (sn *StickyNote) updateGroupHeader() {
+	if sn.BoxGroup == nil {
+		return
+	}
+	sn.BoxGroup.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			sn.BoxGroup.Remove(widget)
+		}
+	})
+	if !sn.Note.isGroupHeader() {
+		sn.BoxGroup.Hide()
+		return
+	}
+
+	for _, member := range sn.NoteSet.groupMembers(sn.Note.Group()) {
+		if member == sn.Note {
+			continue
+		}
+		member := member // Capture for closure
+		label, _ := gtk.LabelNew("▸ " + noteLabel(member))
+		label.SetHAlign(gtk.ALIGN_START)
+		button, _ := gtk.ButtonNew()
+		button.Add(label)
+		button.SetRelief(gtk.RELIEF_NONE)
+		button.SetTooltipText("Expand the group")
+		button.Connect("clicked", func() {
+			sn.NoteSet.expandGroup(sn.Note.Group())
+			sn.NoteSet.Save()
+			if member.GUI != nil {
+				member.GUI.Raise()
+			}
+		})
+		sn.BoxGroup.PackStart(button, false, false, 0)
+	}
+	sn.BoxGroup.ShowAll()
+}
+
+// moveGroup moves the other members of the note's group by as much as the note was
+// dragged. Called once the position settles after a drag, moves made by code (the
+// members following, arranging, snapping...) don't move the group.
+func (sn *StickyNote) moveGroup() {
+	pos := sn.LastKnownPos
+	last := sn.groupPos
+	sn.groupPos = pos
+	if sn.Note.Group() == "" || pos == last {
+		return
+	}
+	dx, dy := pos[0]-last[0], pos[1]-last[1]
+	for _, member := range sn.NoteSet.groupMembers(sn.Note.Group()) {
+		if member == sn.Note {
+			continue
+		}
+		if !isNoteShown(member) {
+			// Hidden members are put where the group went when they are shown again
+			if saved, ok := floatList(member.Properties["position"]); ok && len(saved) >= 2 {
+				target := []interface{}{saved[0] + float64(dx), saved[1] + float64(dy)}
+				member.Properties["position"] = target
+				if member.GUI != nil {
+					member.GUI.LastKnownPos = [2]int{int(saved[0]) + dx, int(saved[1]) + dy}
+				}
+			}
+			continue
+		}
+		other := member.GUI
+		target := [2]int{other.LastKnownPos[0] + dx, other.LastKnownPos[1] + dy}
+		other.moveTo(target[0], target[1])
+	}
+	sn.NoteSet.Save()
+}
+
+// groupMenu builds the note's "Group" submenu
+func (sn *StickyNote) groupMenu() *gtk.Menu {
+	menu, _ := gtk.MenuNew()
+	group := sn.Note.Group()
+
+	mwith, _ := gtk.MenuItemNewWithLabel("Group with")
+	withMenu, _ := gtk.MenuNew()
+	candidates := 0
+	for _, other := range sn.NoteSet.Notes {
+		if other == sn.Note || other.deletePending || (group != "" && other.Group() == group) {
+			continue
+		}
+		other := other // Capture for closure
+		item, _ := gtk.MenuItemNewWithLabel(noteLabel(other))
+		item.Connect("activate", func() {
+			sn.Note.joinGroup(other)
+			sn.NoteSet.Save()
+			sn.PopulateMenu()
+		})
+		withMenu.Append(item)
+		item.Show()
+		candidates++
+	}
+	mwith.SetSubmenu(withMenu)
+	mwith.SetSensitive(candidates > 0)
+	menu.Append(mwith)
+	mwith.Show()
+
+	if group != "" {
+		if sn.Note.isGroupHeader() {
+			mexpand, _ := gtk.MenuItemNewWithLabel("Expand group")
+			mexpand.Connect("activate", func() {
+				sn.NoteSet.expandGroup(group)
+				sn.NoteSet.Save()
+			})
+			menu.Append(mexpand)
+			mexpand.Show()
+		} else {
+			mcollapse, _ := gtk.MenuItemNewWithLabel("Collapse group")
+			mcollapse.Connect("activate", func() {
+				sn.Note.collapseGroup()
+				sn.NoteSet.Save()
+			})
+			menu.Append(mcollapse)
+			mcollapse.Show()
+		}
+
+		mleave, _ := gtk.MenuItemNewWithLabel("Leave group")
+		mleave.Connect("activate", func() {
+			sn.Note.leaveGroup()
+			sn.NoteSet.Save()
+			sn.PopulateMenu()
+		})
+		menu.Append(mleave)
+		mleave.Show()
+	}
+	return menu
+}
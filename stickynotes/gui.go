@@ -19,6 +19,14 @@ type ResourceGetter interface {
 	GetEmbeddedIcon(iconPath string) ([]byte, error)
 }
 
+// ResourceCache is implemented by resource getters that extract the embedded resources
+// to disk (AppIndicator needs file paths for its icon)
+type ResourceCache interface {
+	CachePath() string
+	ClearCache() error
+	ExtractResources() error
+}
+
 var globalResourceGetter ResourceGetter
 
 // SetResourceGetter sets the global resource getter (called from main package)
@@ -100,8 +108,17 @@ func removePixbufProperties(xml string) string {
 	return strings.Join(result, "\n")
 }
 
+// AppID is the application id, also used as Wayland app id and X11 WM_CLASS of all
+// windows so desktop files, compositor rules and window-calls can match them
+const AppID = "app.runable.postnote"
+
 // IsWayland checks if the application is running on Wayland
 func IsWayland() bool {
+	// Forced to X11 (--force-x11): GTK runs through XWayland, where positioning works natively
+	if os.Getenv("GDK_BACKEND") == "x11" {
+		return false
+	}
+
 	// Check XDG_SESSION_TYPE environment variable
 	if sessionType := os.Getenv("XDG_SESSION_TYPE"); sessionType == "wayland" {
 		return true
@@ -165,15 +182,25 @@ type StickyNote struct {
 	WinMain           *gtk.Window
 	TxtNote           *gtk.TextView
 	BBody             *gtk.TextBuffer
+	ETags             *gtk.Entry
+	BoxAttachments    *gtk.FlowBox
+	FindBar           *FindBar
+	Editor            *gtk.Window // Large editor window, nil when closed
 	BAdd              *gtk.Button
 	BClose            *gtk.Button
 	BLock             *gtk.Button
 	BMenu             *gtk.Button
+	BEmoji            *gtk.Button
 	ImgAdd            *gtk.Image
 	ImgClose          *gtk.Image
 	ImgLock           *gtk.Image
 	ImgUnlock         *gtk.Image
 	ImgResizeR        *gtk.Image
+	ImgDropdown       *gtk.Image
+	ImgLint           *gtk.Image
+	LRemoteEdit       *gtk.Label
+	LAge              *gtk.Label // Age badge of an untouched note
+	BoxGroup          *gtk.Box   // Hidden members of a collapsed group, on its header
 	EResizeR          *gtk.EventBox
 	MoveBox1          *gtk.EventBox
 	MoveBox2          *gtk.EventBox
@@ -181,9 +208,28 @@ type StickyNote struct {
 	LastKnownPos      [2]int
 	LastKnownSize     [2]int
 	CSSProvider       *gtk.CssProvider
+	fontProvider      *gtk.CssProvider // Category font with the note's size delta
 	menuHideConnected bool
-	WindowID          uint32            // Window ID from window-calls extension (D-Bus uint32)
-	saveTimeoutID     glib.SourceHandle // Timeout ID for debounced save
+	WindowID          uint32                         // Window ID from window-calls extension (D-Bus uint32)
+	saveTimeoutID     glib.SourceHandle              // Timeout ID for debounced save
+	autosaveID        glib.SourceHandle              // Timeout ID for the debounced save of the text
+	dirty             bool                           // The text changed since it was last saved
+	sources           map[glib.SourceHandle]struct{} // Pending timeouts, cancelled on destroy
+	codeTag           *gtk.TextTag                   // Monospace style for ``` code blocks
+	catAccelGroup     *gtk.AccelGroup                // Per-category "new note" shortcuts
+	dropCheckPos      [2]int                         // Position the last drag started from, for a drop onto another note
+	viewLinks         []mdSpan                       // Links rendered in view mode, for clicks
+	counters          []counterAnchor                // Counter widgets rendered in view mode
+	updatingCounters  bool                           // Counter widgets are being added or removed
+	dueState          DueState                       // Due state the CSS was last loaded for
+	ageStep           int                            // Aging step the CSS was last loaded for
+	groupPos          [2]int                         // Position the last drag started from, to move the group along
+	layerDrag         *layerDrag                     // Move or resize in progress of a layer surface
+	pendingRestore    func()                         // Restores the position once the window manager reports the window
+	scale             float64                        // Compositor pixels per logical one, measured from the window (see scale.go)
+	dragged           bool                           // Moved by the user with the handle, until the position settles
+	rolledHidden      []*gtk.Widget                  // Hidden below the top bar while rolled up
+	placing           bool                           // Shown but not placed yet, kept transparent
 }
 
 // NewStickyNote creates a new sticky note GUI
@@ -239,48 +285,93 @@ func (sn *StickyNote) buildNote() {
 
 	// Get widgets
 	sn.TxtNote, _ = getObject[*gtk.TextView](sn.Builder, "txtNote")
+	sn.ETags, _ = getObject[*gtk.Entry](sn.Builder, "eTags")
+	sn.BoxAttachments, _ = getObject[*gtk.FlowBox](sn.Builder, "boxAttachments")
 	sn.BAdd, _ = getObject[*gtk.Button](sn.Builder, "bAdd")
 	sn.BClose, _ = getObject[*gtk.Button](sn.Builder, "bClose")
 	sn.BLock, _ = getObject[*gtk.Button](sn.Builder, "bLock")
 	sn.BMenu, _ = getObject[*gtk.Button](sn.Builder, "bMenu")
+	sn.BEmoji, _ = getObject[*gtk.Button](sn.Builder, "bEmoji")
 	sn.ImgAdd, _ = getObject[*gtk.Image](sn.Builder, "imgAdd")
 	sn.ImgClose, _ = getObject[*gtk.Image](sn.Builder, "imgClose")
 	sn.ImgLock, _ = getObject[*gtk.Image](sn.Builder, "imgLock")
 	sn.ImgUnlock, _ = getObject[*gtk.Image](sn.Builder, "imgUnlock")
 	sn.ImgResizeR, _ = getObject[*gtk.Image](sn.Builder, "imgResizeR")
+	sn.ImgLint, _ = getObject[*gtk.Image](sn.Builder, "imgLint")
+	sn.LRemoteEdit, _ = getObject[*gtk.Label](sn.Builder, "lRemoteEdit")
+	sn.LAge, _ = getObject[*gtk.Label](sn.Builder, "lAge")
+	sn.showRemoteEdit()
+	sn.BoxGroup, _ = getObject[*gtk.Box](sn.Builder, "boxGroup")
+	sn.updateGroupHeader()
 	sn.EResizeR, _ = getObject[*gtk.EventBox](sn.Builder, "eResizeR")
 	sn.MoveBox1, _ = getObject[*gtk.EventBox](sn.Builder, "movebox1")
 	sn.MoveBox2, _ = getObject[*gtk.EventBox](sn.Builder, "movebox2")
 
 	// Get imgDropdown (used by bMenu button)
-	imgDropdown, _ := getObject[*gtk.Image](sn.Builder, "imgDropdown")
+	sn.ImgDropdown, _ = getObject[*gtk.Image](sn.Builder, "imgDropdown")
 
-	// Load icons from embedded resources (since UI file references Icons/ paths)
+	// Load icons from the configured icon set (since UI file references Icons/ paths)
 	// GTK Builder will fail to load these from file system when using BuilderNewFromString
-	// So we manually set them using embedded data
-	sn.loadIconsFromEmbedded(imgDropdown)
+	// So we manually set them, by default using embedded data
+	sn.LoadIcons()
 
 	// Connect signals
 	sn.BAdd.Connect("clicked", sn.onAdd)
 	sn.BClose.Connect("clicked", sn.onDelete)
 	sn.BLock.Connect("clicked", sn.onLockClicked)
 	sn.BMenu.Connect("clicked", sn.onPopupMenu)
+	if sn.BEmoji != nil {
+		sn.BEmoji.Connect("clicked", sn.InsertEmoji)
+	}
 	sn.EResizeR.Connect("button-press-event", sn.onResize)
 	sn.MoveBox1.Connect("button-press-event", sn.onMove)
 	sn.MoveBox2.Connect("button-press-event", sn.onMove)
 	sn.WinMain.Connect("focus-out-event", sn.onFocusOut)
+	sn.WinMain.Connect("focus-in-event", sn.applyOpacity)
 	sn.WinMain.Connect("configure-event", sn.onConfigure)
 	sn.WinMain.Connect("delete-event", sn.onWindowDelete)
+	sn.WinMain.Connect("key-press-event", sn.onKeyPress)
+	sn.WinMain.Connect("destroy", sn.onDestroy)
+	Backend().Prepare(sn)
 
 	// Create text buffer
 	sn.BBody, _ = gtk.TextBufferNew(nil)
 	sn.BBody.SetText(sn.Note.Body)
 	sn.TxtNote.SetBuffer(sn.BBody)
+	sn.BBody.Connect("changed", sn.onBodyChanged)
+	sn.TxtNote.Connect("key-press-event", sn.onListKeyPress)
+	sn.TxtNote.Connect("key-press-event", sn.onCalcKeyPress)
+	sn.TxtNote.AddEvents(int(gdk.SCROLL_MASK | gdk.SMOOTH_SCROLL_MASK))
+	sn.TxtNote.Connect("scroll-event", sn.onScroll)
+	sn.setupCodeBlocks()
+	sn.setupFormatting()
+	sn.setupDragAndDrop()
+	sn.setupViewMode()
+
+	// Ctrl+F find row
+	sn.FindBar = newFindBar(sn)
+
+	// Tag editor row (only visible when the note has tags or the user opens it)
+	if sn.ETags != nil {
+		sn.ETags.SetText(strings.Join(sn.Note.Tags, ", "))
+		sn.ETags.SetVisible(len(sn.Note.Tags) > 0)
+		sn.ETags.Connect("activate", sn.onTagsEdited)
+		sn.ETags.Connect("focus-out-event", sn.onTagsEdited)
+	}
+
+	// Attachment chips (only visible when the note has attachments)
+	sn.RefreshAttachments()
 
 	// Create menu
 	sn.Menu, _ = gtk.MenuNew()
 	sn.PopulateMenu()
 
+	// Bind per-category "new note" shortcuts
+	sn.UpdateAccelerators()
+
+	// Show note hygiene hints in the footer
+	sn.UpdateLint()
+
 	// Note: CSS will be loaded in show() after window is ready
 	// This ensures category properties are available and window is realized
 
@@ -326,6 +417,12 @@ func (sn *StickyNote) buildNote() {
 	// Set locked state
 	sn.SetLockedState(sn.Locked)
 
+	// Keep pinned notes on top and desktop widgets below (on Wayland once the window ID
+	// is known)
+	if sn.Pinned() || sn.NoteSet.DesktopWidgets() {
+		sn.applyPinned()
+	}
+
 	// Set widget names to match CSS selectors
 	sn.WinMain.SetName("main-window")
 	sn.TxtNote.SetName("txt-note")
@@ -333,7 +430,7 @@ func (sn *StickyNote) buildNote() {
 	// Set unique window title for identification via D-Bus
 	// Format: "Sticky Notes - <UUID>" - this allows us to match windows by title
 	// The title is not visible in the UI (window is undecorated) but is available via D-Bus
-	sn.WinMain.SetTitle(fmt.Sprintf("Sticky Notes - %s", sn.Note.UUID[:8]))
+	sn.WinMain.SetTitle(noteWindowTitle(sn.Note.UUID))
 
 	// Initialize Provider: Create the CssProvider and add it to the context NOW
 	// This must be done BEFORE loading data and BEFORE ShowAll()
@@ -344,10 +441,9 @@ func (sn *StickyNote) buildNote() {
 
 	// Get style contexts and add provider BEFORE loading data
 	// This matches the Python version's behavior
-	winContext, _ := sn.WinMain.GetStyleContext()
-	txtContext, _ := sn.TxtNote.GetStyleContext()
-	winContext.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
-	txtContext.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+	for _, context := range sn.styleContexts() {
+		context.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+	}
 
 	// Load Data: Call LoadCSS() logic (generates CSS string and loads into provider)
 	// This happens while the window is still hidden
@@ -356,293 +452,21 @@ func (sn *StickyNote) buildNote() {
 
 	// Strategy: Make window invisible, show it, move it, then make it visible
 	// This prevents the visual "jump" from default position to saved position
-	sn.WinMain.SetOpacity(0.0) // Make window invisible
+	sn.hideUntilPlaced() // Make window invisible
 
 	// FINALLY call ShowAll() - window is shown but invisible
 	sn.WinMain.SetSkipPagerHint(true)
+	sn.applyWidgetHints()
 	sn.WinMain.ShowAll()
+	sn.applyRolledUp()
 
-	// On Wayland, GTK's Move() doesn't work, so we must use D-Bus via window-calls extension
-	// Note: We cannot move the window before showing it because:
-	// - GTK Move() doesn't work on Wayland
-	// - D-Bus Move() requires window ID
-	// - Window ID can only be obtained after window is shown and registered with window manager
-	// So there will be a brief visual "jump" from default position to saved position
-
-	// On Wayland, we need to wait a bit for windows to get their actual size before matching
-	// Use a timeout to allow windows to be fully realized
-	if IsWindowCallsAvailable() {
-		// Wait 300ms for windows to be fully realized and get their sizes
-		glib.TimeoutAdd(300, func() bool {
-
-			// Try to get window ID if not assigned yet (match by title)
-			if sn.WindowID == 0 {
-				expectedTitle := fmt.Sprintf("Sticky Notes - %s", sn.Note.UUID[:8])
-				windows, err := GetCurrentProcessWindows()
-				if err == nil && windows != nil {
-					for _, win := range windows {
-						// Skip if already assigned to another note
-						alreadyAssigned := false
-						for _, otherNote := range sn.NoteSet.Notes {
-							if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-								alreadyAssigned = true
-								break
-							}
-						}
-						if alreadyAssigned {
-							continue
-						}
-
-						// Get details to check title
-						details, err := GetWindowDetails(win.ID)
-						if err == nil && details != nil {
-							// Match by title (exact match)
-							if details.Title == expectedTitle {
-								// Double-check: make sure no other note has this ID
-								conflict := false
-								for _, otherNote := range sn.NoteSet.Notes {
-									if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-										conflict = true
-										break
-									}
-								}
-								if !conflict {
-									// Final atomic check: verify no other note has this ID RIGHT NOW
-									// This prevents race conditions where two notes might assign the same ID simultaneously
-									finalConflict := false
-									for _, otherNote := range sn.NoteSet.Notes {
-										if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-											finalConflict = true
-											break
-										}
-									}
-									if !finalConflict {
-										// ONE MORE CHECK: Make absolutely sure no other note has this ID
-										// This is a last-ditch effort to prevent duplicate assignments
-										for _, otherNote := range sn.NoteSet.Notes {
-											if otherNote != sn.Note && otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID {
-												fmt.Printf("[buildNote] Note %s: ABORT! Window ID %d is already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-												break // Don't assign, break out of window loop
-											}
-										}
-										// Check one more time before assigning (in case another note assigned it in the meantime)
-										stillAvailable := true
-										for _, otherNote := range sn.NoteSet.Notes {
-											if otherNote != sn.Note && otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID {
-												stillAvailable = false
-												break
-											}
-										}
-										if stillAvailable {
-											sn.WindowID = win.ID
-											break
-										}
-									}
-								}
-							}
-						} else {
-							// fmt.Printf("[# buildNote] Note %s: Could not get details for window ID %d: %v\n", sn.Note.UUID[:8], win.ID, err)
-						}
-					}
-				} else {
-					// fmt.Printf("[# buildNote] Note %s: Error getting windows: %v\n", sn.Note.UUID[:8], err)
-				}
-			} else {
-				// fmt.Printf("[# buildNote] Note %s: Window ID already assigned: %d\n", sn.Note.UUID[:8], sn.WindowID)
-			}
-
-			if sn.WindowID != 0 {
-				err := MoveWindow(sn.WindowID, restorePos[0], restorePos[1])
-				if err == nil {
-					sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-				} else {
-					// Fallback to GTK Move() (might not work on Wayland but worth trying)
-					sn.WinMain.Move(restorePos[0], restorePos[1])
-					sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-				}
-			} else {
-				// Fallback to GTK Move() (might not work on Wayland but worth trying)
-				// Also try to move immediately on X11 to prevent appearing at (0,0)
-				if !IsWindowCallsAvailable() {
-					sn.WinMain.Move(restorePos[0], restorePos[1])
-				}
-				sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-				// On Wayland, if we still don't have window ID, try GTK Move as last resort
-				if IsWindowCallsAvailable() {
-					sn.WinMain.Move(restorePos[0], restorePos[1])
-				}
-			}
-
-			return false // Don't repeat
-		})
-	} else {
-		// On X11 or extension not available, use GTK Move() immediately
-		glib.IdleAdd(func() bool {
-			sn.WinMain.Move(restorePos[0], restorePos[1])
-			sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-			return false               // Don't repeat
-		})
-	}
-
-	// Check actual position from D-Bus after a delay to allow window to move and get ID assigned
-	/*
-		if IsWindowCallsAvailable() {
-			// Use TimeoutAdd to check position after a delay
-			// We wait 1500ms to ensure both the move and assignWindowID() have completed
-			fmt.Printf("[buildNote:1500] Note %s: Checking actual position from D-Bus after a delay to allow window to move and get ID assigned\n", sn.Note.UUID[:8])
-			glib.TimeoutAdd(1500, func() bool {
-
-				// If Window ID is still 0, call assignWindowID() directly to get it
-				if sn.WindowID == 0 {
-					fmt.Printf("[buildNote:1500ms] Note %s: Window ID still 0, calling assignWindowID()\n", sn.Note.UUID[:8])
-
-					sn.assignWindowID()
-					if sn.WindowID == 0 {
-						return false // Don't repeat
-					}
-				}
-
-				// Now we have Window ID, verify the position
-				details, err := GetWindowDetails(sn.WindowID)
-				if err == nil && details != nil {
-					// Position verification (no action needed)
-				}
-				return false // Don't repeat
-			})
-			fmt.Printf("[buildNote] Note %s: 1500ms timeout completed\n", sn.Note.UUID[:8])
-		}
-	*/
-}
-
-// assignWindowID gets and stores the window ID for this note from window-calls extension
-// Matches windows by unique title: "Sticky Notes - <UUID>"
-func (sn *StickyNote) assignWindowID() {
-	fmt.Printf("[assignWindowID] Note %s: assignWindowID() called, current WindowID=%d\n", sn.Note.UUID[:8], sn.WindowID)
-	if sn.WindowID != 0 {
-		// Already assigned
-		fmt.Printf("[assignWindowID] Note %s: Window ID already assigned: %d\n", sn.Note.UUID[:8], sn.WindowID)
-		return
-	}
-
-	windows, err := GetCurrentProcessWindows()
-	if err != nil {
-		fmt.Printf("[assignWindowID] Note %s: Error getting windows: %v\n", sn.Note.UUID[:8], err)
-		return
-	}
-
-	if len(windows) == 0 {
-		fmt.Printf("[assignWindowID] Note %s: No windows found\n", sn.Note.UUID[:8])
-		return
-	}
-
-	// Match by unique title
-	expectedTitle := fmt.Sprintf("Sticky Notes - %s", sn.Note.UUID[:8])
-	fmt.Printf("[assignWindowID] Note %s: Looking for window with title: %s\n", sn.Note.UUID[:8], expectedTitle)
-	fmt.Printf("[assignWindowID] Note %s: Found %d windows\n", sn.Note.UUID[:8], len(windows))
-	// Debug: Print all window IDs and their current assignments
-	fmt.Printf("[assignWindowID] Note %s: Current window ID assignments:\n", sn.Note.UUID[:8])
-	for _, otherNote := range sn.NoteSet.Notes {
-		if otherNote.GUI != nil && otherNote.GUI.WindowID != 0 {
-			fmt.Printf("[assignWindowID]   Note %s -> Window ID %d\n", otherNote.UUID[:8], otherNote.GUI.WindowID)
-		}
-	}
-	for _, win := range windows {
-		// Skip if this window ID is already assigned to another note
-		alreadyAssigned := false
-		for _, otherNote := range sn.NoteSet.Notes {
-			if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-				alreadyAssigned = true
-				fmt.Printf("[assignWindowID] Note %s: Window ID %d already assigned to note %s, skipping\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-				break
-			}
-		}
-		if alreadyAssigned {
-			continue
-		}
-
-		// Get details to check title (List() might not have full title info)
-		details, err := GetWindowDetails(win.ID)
-		if err != nil || details == nil {
-			// Fallback: try to match using title from List() if available
-			if win.Title == expectedTitle {
-				// Double-check: make sure no other note has this ID
-				conflict := false
-				for _, otherNote := range sn.NoteSet.Notes {
-					if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-						conflict = true
-						fmt.Printf("[assignWindowID] Note %s: CONFLICT! Window ID %d already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-						break
-					}
-				}
-				if !conflict {
-					// Final atomic check: verify no other note has this ID RIGHT NOW
-					// This prevents race conditions where two notes might assign the same ID simultaneously
-					finalConflict := false
-					for _, otherNote := range sn.NoteSet.Notes {
-						if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-							finalConflict = true
-							fmt.Printf("[assignWindowID] Note %s: FINAL CONFLICT CHECK! Window ID %d already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-							break
-						}
-					}
-					if !finalConflict {
-						// ONE MORE CHECK: Make absolutely sure no other note has this ID
-						// This is a last-ditch effort to prevent duplicate assignments
-						for _, otherNote := range sn.NoteSet.Notes {
-							if otherNote != sn.Note && otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID {
-								fmt.Printf("[assignWindowID] Note %s: ABORT! Window ID %d is already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-								return // Don't assign, just return
-							}
-						}
-						sn.WindowID = win.ID
-						fmt.Printf("[assignWindowID] Note %s: Matched window ID %d with title from List(): %s\n", sn.Note.UUID[:8], win.ID, win.Title)
-						return
-					}
-				}
-			}
-			continue
+	// On Wayland the window can only be placed once shown and known to the compositor,
+	// so there may be a brief "jump" to the saved position
+	Backend().Restore(sn, restorePos, func() {
+		if sn.hasWindowState() {
+			sn.applyPinned()
 		}
-
-		fmt.Printf("[assignWindowID] Note %s: Window ID %d has title: %s\n", sn.Note.UUID[:8], win.ID, details.Title)
-		// Match by title (exact match)
-		if details.Title == expectedTitle {
-			// Double-check: make sure no other note has this ID
-			conflict := false
-			for _, otherNote := range sn.NoteSet.Notes {
-				if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-					conflict = true
-					fmt.Printf("[assignWindowID] Note %s: CONFLICT! Window ID %d already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-					break
-				}
-			}
-			if !conflict {
-				// Final atomic check: verify no other note has this ID RIGHT NOW
-				// This prevents race conditions where two notes might assign the same ID simultaneously
-				finalConflict := false
-				for _, otherNote := range sn.NoteSet.Notes {
-					if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-						finalConflict = true
-						fmt.Printf("[assignWindowID] Note %s: FINAL CONFLICT CHECK! Window ID %d already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-						break
-					}
-				}
-				if !finalConflict {
-					// ONE MORE CHECK: Make absolutely sure no other note has this ID
-					// This is a last-ditch effort to prevent duplicate assignments
-					for _, otherNote := range sn.NoteSet.Notes {
-						if otherNote != sn.Note && otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID {
-							fmt.Printf("[assignWindowID] Note %s: ABORT! Window ID %d is already assigned to note %s, NOT assigning\n", sn.Note.UUID[:8], win.ID, otherNote.UUID[:8])
-							return // Don't assign, just return
-						}
-					}
-					sn.WindowID = win.ID
-					fmt.Printf("[assignWindowID] Note %s: Matched window ID %d with title: %s\n", sn.Note.UUID[:8], win.ID, details.Title)
-					return
-				}
-			}
-		}
-	}
-	fmt.Printf("[assignWindowID] Note %s: No matching window found\n", sn.Note.UUID[:8])
+	})
 }
 
 func (sn *StickyNote) Show() {
@@ -666,7 +490,7 @@ func (sn *StickyNote) Show() {
 		sn.UpdateFont()
 
 		// Ensure unique window title is set (in case it was lost)
-		sn.WinMain.SetTitle(fmt.Sprintf("Sticky Notes - %s", sn.Note.UUID[:8]))
+		sn.WinMain.SetTitle(noteWindowTitle(sn.Note.UUID))
 
 		// Check if window is already visible - if so, preserve its current position
 		// This prevents existing notes from being repositioned when a new note is created
@@ -736,163 +560,118 @@ func (sn *StickyNote) Show() {
 		if isVisible && !shouldMove {
 			// Window is already visible and positioned correctly, just ensure it's shown
 			sn.WinMain.ShowAll()
+			sn.applyRolledUp()
 			return
 		}
 
 		// Strategy: Make window invisible, show it, move it, then make it visible
 		// This prevents the visual "jump" from default position to saved position
 		// Use same logic as buildNote()
-		sn.WinMain.SetOpacity(0.0)        // Make window invisible
+		sn.hideUntilPlaced()              // Make window invisible
 		sn.WinMain.SetSkipPagerHint(true) // Same as buildNote()
+		sn.applyWidgetHints()
 		sn.WinMain.ShowAll()
+		sn.applyRolledUp()
 
 		// Restore position after showing (same logic as buildNote)
-		if IsWindowCallsAvailable() {
-			// Wait 300ms for windows to be fully realized and get their sizes (same as buildNote)
-			glib.TimeoutAdd(300, func() bool {
-				// Only try to assign window ID if it's not already assigned AND note has saved position
-				// For new notes (no saved position), buildNote() already handles window ID assignment,
-				// so we skip it here to avoid duplicate assignments that can cause wrong window matching
-				hasSavedPosition := false
-				if pos, ok := sn.Note.Properties["position"].([]interface{}); ok && len(pos) >= 2 {
-					hasSavedPosition = true
-				}
-				// Only assign window ID for existing notes (have saved position) that lost their window ID
-				// New notes are handled by buildNote()'s timeout
-				if sn.WindowID == 0 && hasSavedPosition {
-					expectedTitle := fmt.Sprintf("Sticky Notes - %s", sn.Note.UUID[:8])
-					windows, err := GetCurrentProcessWindows()
-					if err == nil && windows != nil {
-						// Debug: Print all window IDs and their current assignments
-						// for _, otherNote := range sn.NoteSet.Notes {
-						// 	if otherNote.GUI != nil && otherNote.GUI.WindowID != 0 {
-						// 		fmt.Printf("[Show]   Note %s -> Window ID %d\n", otherNote.UUID[:8], otherNote.GUI.WindowID)
-						// 	}
-						// }
-						for _, win := range windows {
-							// Skip if already assigned to another note
-							alreadyAssigned := false
-							for _, otherNote := range sn.NoteSet.Notes {
-								if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-									alreadyAssigned = true
-									break
-								}
-							}
-							if alreadyAssigned {
-								continue
-							}
-
-							// Get details to check title
-							details, err := GetWindowDetails(win.ID)
-							if err == nil && details != nil {
-								// Match by title (exact match)
-								if details.Title == expectedTitle {
-									// Double-check: make sure no other note has this ID
-									conflict := false
-									for _, otherNote := range sn.NoteSet.Notes {
-										if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-											conflict = true
-											break
-										}
-									}
-									if !conflict {
-										// Final atomic check: verify no other note has this ID RIGHT NOW
-										// This prevents race conditions where two notes might assign the same ID simultaneously
-										finalConflict := false
-										for _, otherNote := range sn.NoteSet.Notes {
-											if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-												finalConflict = true
-												break
-											}
-										}
-										if !finalConflict {
-											sn.WindowID = win.ID
-											break
-										}
-									}
-								}
-							} else {
-								// fmt.Printf("[Show] Note %s: Could not get details for window ID %d: %v\n", sn.Note.UUID[:8], win.ID, err)
-							}
-						}
-					} else {
-						// fmt.Printf("[Show] Note %s: Error getting windows: %v\n", sn.Note.UUID[:8], err)
-					}
-				} else {
-					// fmt.Printf("[Show] Note %s: Window ID already assigned: %d\n", sn.Note.UUID[:8], sn.WindowID)
-				}
+		Backend().Restore(sn, restorePos, func() {
+			// Update note after positioning
+			sn.UpdateNote()
+			if sn.hasWindowState() {
+				sn.applyPinned()
+			}
+		})
+	} else {
+		sn.buildNote()
+	}
+}
 
-				// Move window to saved position (same logic as buildNote)
-				if sn.WindowID != 0 {
-					err := MoveWindow(sn.WindowID, restorePos[0], restorePos[1])
-					if err == nil {
-						sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-					} else {
-						// Fallback to GTK Move() (might not work on Wayland but worth trying)
-						sn.WinMain.Move(restorePos[0], restorePos[1])
-						sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-					}
-				} else {
-					// Fallback to GTK Move() (might not work on Wayland but worth trying)
-					sn.WinMain.Move(restorePos[0], restorePos[1])
-					sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-				}
-				// Update note after positioning (called regardless of which path was taken)
-				sn.UpdateNote()
+// Pinned reports whether the note is kept on top of other windows
+func (sn *StickyNote) Pinned() bool {
+	pinned, _ := sn.Note.Properties["pinned"].(bool)
+	return pinned
+}
 
-				return false // Don't repeat
-			})
-		} else {
-			// On X11 or extension not available, use GTK Move() immediately (same as buildNote)
-			glib.IdleAdd(func() bool {
-				sn.WinMain.Move(restorePos[0], restorePos[1])
-				sn.WinMain.SetOpacity(1.0) // Make window visible after moving
-				// Update note after positioning
-				sn.UpdateNote()
-				return false // Don't repeat
-			})
-		}
+// SetPinned keeps the note on top of other windows and on all workspaces, or not
+func (sn *StickyNote) SetPinned(pinned bool) {
+	if pinned {
+		sn.Note.Properties["pinned"] = true
 	} else {
-		sn.buildNote()
+		delete(sn.Note.Properties, "pinned")
 	}
+	sn.applyPinned()
+	sn.NoteSet.Save()
+}
+
+// applyPinned applies the pinned state, the desktop widget mode and the workspace to the
+// window: pinned notes are on all workspaces, and stay above in desktop widget mode
+func (sn *StickyNote) applyPinned() {
+	if sn.WinMain == nil {
+		return
+	}
+	pinned, workspace := sn.Pinned(), sn.Note.Workspace()
+	Backend().SetPinned(sn, pinned)
+	sn.applyWidgetHints()
+	Backend().SetBelow(sn, sn.desktopWidget())
+	Backend().SetSticky(sn, pinned || workspace == WorkspaceAll)
+	if workspace >= 0 && !pinned && sn.WinMain.GetRealized() {
+		Backend().MoveToWorkspace(sn, workspace)
+	}
+}
+
+// hasWindowState reports whether the window has states to apply once shown, see
+// applyPinned
+func (sn *StickyNote) hasWindowState() bool {
+	return sn.Pinned() || sn.Note.Workspace() != WorkspaceDefault || sn.NoteSet.DesktopWidgets()
+}
+
+// Raise brings the note window to the front
+func (sn *StickyNote) Raise() {
+	if sn.WinMain == nil {
+		return
+	}
+	Backend().Raise(sn)
 }
 
 func (sn *StickyNote) Hide() {
 	// Cancel any pending save timeout
 	if sn.saveTimeoutID != 0 {
-		glib.SourceRemove(sn.saveTimeoutID)
+		sn.removeSource(sn.saveTimeoutID)
 		sn.saveTimeoutID = 0
 	}
 	if sn.WinMain != nil {
 		// Reset WindowID because it will be invalid after hiding
 		// The window will get a new ID when shown again, and we'll match it by title
-		sn.WindowID = 0
+		sn.releaseWindowID()
 		sn.WinMain.Hide()
 	}
+	// The other notes of its corner close the gap
+	if sn.Note.Corner() != CornerNone {
+		sn.NoteSet.scheduleCornerLayout()
+	}
 }
 
 func (sn *StickyNote) UpdateNote() {
 	start, end := sn.BBody.GetBounds()
 	text, _ := sn.BBody.GetText(start, end, true)
+	revision := sn.Note.Revision
 	sn.Note.Update(text)
+	if sn.Note.Revision != revision {
+		if sn.Note.RemoteEdit != nil {
+			sn.hideRemoteEdit()
+		}
+		// An edit makes the note young again
+		sn.refreshAge()
+	}
+	sn.Note.Formatting = sn.formattingFromBuffer()
 
 	// Update position and size
 	if sn.WinMain != nil {
-		// Try window-calls first (works on Wayland)
-		if IsWindowCallsAvailable() && sn.WindowID != 0 {
-			details, err := GetWindowDetails(sn.WindowID)
-			if err == nil && details != nil {
-				sn.LastKnownPos = [2]int{details.X, details.Y}
-				sn.LastKnownSize = [2]int{details.Width, details.Height}
-				return
-			}
+		pos, size, ok := Backend().Geometry(sn)
+		if ok {
+			sn.LastKnownPos = pos
 		}
-
-		// Fallback to GTK (works on X11)
-		x, y := sn.WinMain.GetPosition()
-		w, h := sn.WinMain.GetSize()
-		sn.LastKnownPos = [2]int{x, y}
-		sn.LastKnownSize = [2]int{w, h}
+		sn.LastKnownSize = size
 	}
 }
 
@@ -916,11 +695,15 @@ func (sn *StickyNote) Properties() map[string]interface{} {
 		}
 	}
 
-	result := map[string]interface{}{
-		"position": []int{pos[0], pos[1]},
-		"size":     []int{size[0], size[1]},
-		"locked":   sn.Locked,
+	// Start from the note's own properties so per-note settings (e.g. color override)
+	// survive, then overwrite the ones tracked by the window
+	result := make(map[string]interface{}, len(sn.Note.Properties)+3)
+	for k, v := range sn.Note.Properties {
+		result[k] = v
 	}
+	result["position"] = []int{pos[0], pos[1]}
+	result["size"] = []int{size[0], size[1]}
+	result["locked"] = sn.Locked
 
 	return result
 }
@@ -940,125 +723,85 @@ func (sn *StickyNote) onAdd() {
 func (sn *StickyNote) onDelete() {
 	// Cancel any pending save timeout
 	if sn.saveTimeoutID != 0 {
-		glib.SourceRemove(sn.saveTimeoutID)
+		sn.removeSource(sn.saveTimeoutID)
 		sn.saveTimeoutID = 0
 	}
-	dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Are you sure you want to delete this note?")
-	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
-	dialog.AddButton("Delete", gtk.RESPONSE_ACCEPT)
-	response := dialog.Run()
-	dialog.Destroy()
-
-	if response == gtk.RESPONSE_ACCEPT {
-		sn.Note.Delete()
-		if sn.WinMain != nil {
-			sn.WinMain.Destroy()
-		}
-		// Clear GUI reference to prevent trying to use destroyed window
-		sn.Note.GUI = nil
-	}
+	sn.deleteWithUndo()
 }
 
 func (sn *StickyNote) onWindowDelete(win *gtk.Window, event *gdk.Event) bool {
 	// When window is closed via window manager (like X button in Activities Overview),
 	// we should delete the note
-	sn.Note.Delete()
-	if sn.WinMain != nil {
-		sn.WinMain.Destroy()
-	}
-	// Clear GUI reference to prevent trying to use destroyed window
-	sn.Note.GUI = nil
-	// Return false to allow default handling (window destruction)
-	return false
+	sn.deleteWithUndo()
+	// Keep the window around (hidden) in case the deletion is undone
+	return true
 }
 
-func (sn *StickyNote) onLockClicked() {
-	sn.SetLockedState(!sn.Locked)
+// onTagsEdited stores the tags typed into the tag row and refreshes the indicator's tag filter
+func (sn *StickyNote) onTagsEdited() bool {
+	text, _ := sn.ETags.GetText()
+	tags := ParseTags(text)
+	if strings.Join(tags, ",") == strings.Join(sn.Note.Tags, ",") {
+		return false
+	}
+	sn.Note.SetTags(tags)
+	sn.NoteSet.Save()
+	if indicator, ok := sn.NoteSet.Indicator.(interface{ RefreshTagsMenu() }); ok {
+		indicator.RefreshTagsMenu()
+	}
+	return false
 }
 
-// loadIconsFromEmbedded loads icons from embedded resources and sets them on the image widgets
-// Tries SVG first (better quality), then falls back to PNG
-func (sn *StickyNote) loadIconsFromEmbedded(imgDropdown *gtk.Image) {
-	iconMap := map[*gtk.Image]string{
-		sn.ImgAdd:     "add",
-		sn.ImgClose:   "close",
-		sn.ImgLock:    "lock",
-		sn.ImgUnlock:  "unlock",
-		sn.ImgResizeR: "resizer",
+// onKeyPress runs the action bound to the key, see registerNoteActions
+func (sn *StickyNote) onKeyPress(win *gtk.Window, event *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+	id := sn.NoteSet.actionForKey(keyEvent.KeyVal(), gdk.ModifierType(keyEvent.State()))
+	if id == "" {
+		return false
 	}
-
-	// Add dropdown/menu icon if available
-	if imgDropdown != nil {
-		iconMap[imgDropdown] = "menu"
+	if err := sn.NoteSet.ActivateAction(id, sn); err != nil {
+		fmt.Printf("[Action] %v\n", err)
 	}
+	return true
+}
 
-	for img, iconBase := range iconMap {
-		if img == nil {
-			continue
-		}
-
-		var iconData []byte
-		var err error
-		var iconName string
-
-		// Try SVG first (better quality), then fall back to PNG
-		iconName = iconBase + ".svg"
-		iconData, err = getEmbeddedIcon(iconName)
-		if err != nil {
-			// Fallback to PNG
-			iconName = iconBase + ".png"
-			iconData, err = getEmbeddedIcon(iconName)
-		}
-
-		if err != nil {
-			// Fallback: try to load from file system (try SVG first, then PNG)
-			svgPath := filepath.Join(sn.Path, "Icons", iconBase+".svg")
-			pngPath := filepath.Join(sn.Path, "Icons", iconBase+".png")
-
-			if _, err := os.Stat(svgPath); err == nil {
-				if pixbuf, err := gdk.PixbufNewFromFile(svgPath); err == nil {
-					img.SetFromPixbuf(pixbuf)
-					continue
-				}
-			}
-			if _, err := os.Stat(pngPath); err == nil {
-				if pixbuf, err := gdk.PixbufNewFromFile(pngPath); err == nil {
-					img.SetFromPixbuf(pixbuf)
-				}
-			}
-			continue
-		}
-
-		// Load from embedded bytes using PixbufLoader
-		// Don't scale - let GTK handle scaling naturally based on display DPI
-		loader, err := gdk.PixbufLoaderNew()
-		if err != nil {
-			continue
-		}
-
-		if _, err := loader.Write(iconData); err != nil {
-			loader.Close()
-			continue
-		}
+// InsertEmoji opens the GTK emoji chooser, inserting the chosen emoji at the cursor
+func (sn *StickyNote) InsertEmoji() {
+	if sn.InViewMode() || sn.TxtNote == nil {
+		return
+	}
+	sn.TxtNote.GrabFocus()
+	sn.TxtNote.Emit("insert-emoji", glib.TYPE_NONE)
+}
 
-		// Close loader to finalize pixbuf
-		if err := loader.Close(); err != nil {
-			continue
-		}
+func (sn *StickyNote) onLockClicked() {
+	sn.SetLockedState(!sn.Locked)
+}
 
-		pixbuf, err := loader.GetPixbuf()
-		if err == nil && pixbuf != nil {
-			img.SetFromPixbuf(pixbuf)
+// LoadIcons sets the button images from the icon set chosen in settings
+func (sn *StickyNote) LoadIcons() {
+	iconMap := map[*gtk.Image]string{
+		sn.ImgAdd:      "add",
+		sn.ImgClose:    "close",
+		sn.ImgLock:     "lock",
+		sn.ImgUnlock:   "unlock",
+		sn.ImgResizeR:  "resizer",
+		sn.ImgDropdown: "menu",
+	}
+
+	provider := NewIconProvider(sn.NoteSet)
+	for img, name := range iconMap {
+		if img != nil {
+			provider.SetIcon(img, name)
 		}
 	}
 }
 
 func (sn *StickyNote) SetLockedState(locked bool) {
 	sn.Locked = locked
-	if sn.TxtNote != nil {
-		sn.TxtNote.SetEditable(!locked)
-		sn.TxtNote.SetCursorVisible(!locked)
-	}
+	// Locked notes are shown in view mode: read-only, rendered, without a caret
+	sn.applyViewMode()
+	sn.RefreshAttachments()
 	if sn.BLock != nil {
 		if locked {
 			sn.BLock.SetImage(sn.ImgLock)
@@ -1074,8 +817,15 @@ func (sn *StickyNote) onMove(widget *gtk.EventBox, event *gdk.Event) bool {
 	// Calculate and print the relative pointer position within the window (as a simple move vector).
 	buttonEvent := gdk.EventButtonNewFromEvent(event)
 
+	if buttonEvent.Button() == gdk.BUTTON_PRIMARY && buttonEvent.Type() == gdk.EVENT_DOUBLE_BUTTON_PRESS {
+		sn.SetRolledUp(!sn.Note.RolledUp())
+		return true
+	}
 	if buttonEvent.Button() == gdk.BUTTON_PRIMARY { // Left button
-		sn.WinMain.BeginMoveDrag(buttonEvent.Button(), int(buttonEvent.XRoot()), int(buttonEvent.YRoot()), buttonEvent.Time())
+		sn.dragged = true
+		sn.dropCheckPos = sn.LastKnownPos
+		sn.groupPos = sn.LastKnownPos
+		Backend().BeginMove(sn, buttonEvent)
 	}
 	return false
 }
@@ -1083,14 +833,82 @@ func (sn *StickyNote) onMove(widget *gtk.EventBox, event *gdk.Event) bool {
 func (sn *StickyNote) onResize(widget *gtk.EventBox, event *gdk.Event) bool {
 	buttonEvent := gdk.EventButtonNewFromEvent(event)
 	if buttonEvent.Button() == gdk.BUTTON_PRIMARY {
-		sn.WinMain.BeginResizeDrag(gdk.WINDOW_EDGE_SOUTH_EAST, buttonEvent.Button(), int(buttonEvent.XRoot()), int(buttonEvent.YRoot()), buttonEvent.Time())
+		Backend().BeginResize(sn, buttonEvent)
 	}
 	return true
 }
 
+// autosaveDelay is how long (ms) after the last change the text of a note is saved
+const autosaveDelay = 2000
+
+// onBodyChanged schedules saving the text once typing pauses, so a crash or power loss
+// doesn't lose the edits made since the note last lost focus
+func (sn *StickyNote) onBodyChanged() {
+	// Rendering in view mode rewrites the buffer, not the note
+	if sn.InViewMode() {
+		return
+	}
+	sn.dirty = true
+	if sn.autosaveID != 0 {
+		sn.removeSource(sn.autosaveID)
+	}
+	sn.autosaveID = sn.timeoutAdd(autosaveDelay, func() bool {
+		sn.autosaveID = 0
+		sn.autosave()
+		return false // Don't repeat
+	})
+}
+
+// autosave saves the note when its text changed since it was last saved
+func (sn *StickyNote) autosave() {
+	if !sn.dirty {
+		return
+	}
+	sn.dirty = false
+	revision := sn.Note.Revision
+	sn.UpdateNote()
+	if sn.Note.Revision != revision {
+		sn.NoteSet.Save()
+	}
+}
+
 func (sn *StickyNote) onFocusOut() {
+	// Saved right away, no need for the pending autosave
+	if sn.autosaveID != 0 {
+		sn.removeSource(sn.autosaveID)
+		sn.autosaveID = 0
+	}
+	sn.dirty = false
 	sn.UpdateNote()
 	sn.NoteSet.Save()
+	sn.applyOpacity()
+	// Editing one note can create or resolve duplicates in others
+	for _, note := range sn.NoteSet.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateLint()
+		}
+	}
+}
+
+// UpdateLint shows or hides the footer hint icon with the note's hygiene warnings as tooltip
+func (sn *StickyNote) UpdateLint() {
+	if sn.ImgLint == nil {
+		return
+	}
+	var warnings []LintWarning
+	if sn.NoteSet.LintEnabled() {
+		warnings = sn.NoteSet.LintNote(sn.Note)
+	}
+	if len(warnings) == 0 {
+		sn.ImgLint.Hide()
+		return
+	}
+	messages := make([]string, len(warnings))
+	for i, w := range warnings {
+		messages[i] = w.Message
+	}
+	sn.ImgLint.SetTooltipText(strings.Join(messages, "\n"))
+	sn.ImgLint.Show()
 }
 
 func (sn *StickyNote) onConfigure() {
@@ -1100,89 +918,33 @@ func (sn *StickyNote) onConfigure() {
 
 	// Cancel any pending save timeout
 	if sn.saveTimeoutID != 0 {
-		glib.SourceRemove(sn.saveTimeoutID)
+		sn.removeSource(sn.saveTimeoutID)
 		sn.saveTimeoutID = 0
 	}
 
-	// Try to get position from window-calls extension first (works on Wayland)
-	if IsWindowCallsAvailable() {
-
-		// If we don't have a window ID yet, try to find it by matching title
-		if sn.WindowID == 0 {
-			expectedTitle := fmt.Sprintf("Sticky Notes - %s", sn.Note.UUID[:8])
-			windows, err := GetCurrentProcessWindows()
-			if err == nil && windows != nil {
-				for _, win := range windows {
-					// Skip if already assigned to another note
-					alreadyAssigned := false
-					for _, otherNote := range sn.NoteSet.Notes {
-						if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-							alreadyAssigned = true
-							break
-						}
-					}
-					if alreadyAssigned {
-						continue
-					}
-
-					details, err := GetWindowDetails(win.ID)
-					if err == nil && details != nil {
-						// Match by title (exact match)
-						if details.Title == expectedTitle {
-							// Double-check: make sure no other note has this ID
-							conflict := false
-							for _, otherNote := range sn.NoteSet.Notes {
-								if otherNote.GUI != nil && otherNote.GUI.WindowID == win.ID && otherNote != sn.Note {
-									conflict = true
-									break
-								}
-							}
-							if !conflict {
-								sn.WindowID = win.ID
-								break
-							}
-						}
-					}
-				}
-			}
-		}
-
-		// If we have a window ID, get position from window-calls
-		if sn.WindowID != 0 {
-			details, err := GetWindowDetails(sn.WindowID)
-			if err == nil && details != nil {
-				newPos := [2]int{details.X, details.Y}
-				newSize := [2]int{details.Width, details.Height}
-
-				sn.LastKnownPos = newPos
-				sn.LastKnownSize = newSize
-
-				// Schedule debounced save (500ms delay)
-				sn.saveTimeoutID = glib.TimeoutAdd(500, func() bool {
-					sn.NoteSet.Save()
-					sn.saveTimeoutID = 0
-					return false // Don't repeat
-				})
-				return
-			}
-		}
-	}
-
-	// Fallback to GTK GetPosition (works on X11, returns 0,0 on Wayland)
-	x, y := sn.WinMain.GetPosition()
-	w, h := sn.WinMain.GetSize()
-
-	if x != 0 || y != 0 {
-		sn.LastKnownPos = [2]int{x, y}
+	pos, size, ok := Backend().Geometry(sn)
+	if ok && (pos[0] != 0 || pos[1] != 0) {
+		sn.LastKnownPos = pos
 	}
-	if w > 1 && h > 1 {
-		sn.LastKnownSize = [2]int{w, h}
+	if size[0] > 1 && size[1] > 1 {
+		sn.LastKnownSize = size
 	}
 
 	// Schedule debounced save (500ms delay)
-	sn.saveTimeoutID = glib.TimeoutAdd(500, func() bool {
-		sn.NoteSet.Save()
+	sn.saveTimeoutID = sn.timeoutAdd(500, func() bool {
 		sn.saveTimeoutID = 0
+		dragged := sn.dragged
+		sn.dragged = false
+		if dragged {
+			sn.snapIntoPlace()
+		}
+		sn.NoteSet.Save()
+		// Only a drag moves the group along or drops the note onto another, not a
+		// move made by code (arranging, snapping, the group following, corners)
+		if dragged {
+			sn.moveGroup()
+			sn.checkDroppedOnNote()
+		}
 		return false // Don't repeat
 	})
 }
@@ -1200,26 +962,46 @@ func (sn *StickyNote) PopulateMenu() {
 		})
 	}
 
-	// Always on top (disabled on Wayland as it doesn't work)
-	if !IsWayland() {
-		aot, _ := gtk.CheckMenuItemNewWithLabel("Always on top")
-		aot.Connect("toggled", func() {
-			sn.WinMain.SetKeepAbove(aot.GetActive())
-		})
-		sn.Menu.Append(aot)
-		aot.Show()
+	for _, id := range []string{
+		"always-on-top", "roll-up", "opacity", "settings", "open-editor", "strikethrough", "calculate", "attach-file",
+		"view-mode", "remind-me", "due-date", "expire", "history",
+	} {
+		item := sn.NoteSet.ActionMenuItem(id, sn)
+		sn.Menu.Append(item)
+		item.Show()
 	}
 
-	// Settings
-	mset, _ := gtk.MenuItemNewWithLabel("Settings")
-	mset.Connect("activate", func() {
-		// Call ShowSettings through interface
-		if indicator, ok := sn.NoteSet.Indicator.(interface{ ShowSettings() }); ok {
-			indicator.ShowSettings()
-		}
-	})
-	sn.Menu.Append(mset)
-	mset.Show()
+	// Group with other notes
+	mgroup, _ := gtk.MenuItemNewWithLabel("Group")
+	mgroup.SetSubmenu(sn.groupMenu())
+	sn.Menu.Append(mgroup)
+	mgroup.Show()
+
+	// Pin to a corner of the monitor, stacked with the other notes there
+	mcorner, _ := gtk.MenuItemNewWithLabel("Pin to corner")
+	mcorner.SetSubmenu(sn.cornerMenu())
+	sn.Menu.Append(mcorner)
+	mcorner.Show()
+
+	// Show on all workspaces or keep on one
+	mworkspace, _ := gtk.MenuItemNewWithLabel("Workspace")
+	mworkspace.SetSubmenu(sn.workspaceMenu())
+	sn.Menu.Append(mworkspace)
+	mworkspace.Show()
+
+	for _, id := range []string{
+		"export-note", "qr-code", "copy-json", "merge-into", "tags", "note-color",
+	} {
+		item := sn.NoteSet.ActionMenuItem(id, sn)
+		sn.Menu.Append(item)
+		item.Show()
+	}
+
+	// Background texture (overrides the category texture for this note only)
+	mtexture, _ := gtk.MenuItemNewWithLabel("Background")
+	mtexture.SetSubmenu(sn.textureMenu())
+	sn.Menu.Append(mtexture)
+	mtexture.Show()
 
 	// Separator
 	sep, _ := gtk.SeparatorMenuItemNew()
@@ -1260,6 +1042,34 @@ func (sn *StickyNote) PopulateMenu() {
 	}
 }
 
+// UpdateAccelerators binds each category's "new note" shortcut (category property
+// "accel", e.g. "<Control><Alt>1") on this note's window
+func (sn *StickyNote) UpdateAccelerators() {
+	if sn.WinMain == nil {
+		return
+	}
+	if sn.catAccelGroup != nil {
+		sn.WinMain.RemoveAccelGroup(sn.catAccelGroup)
+	}
+	sn.catAccelGroup, _ = gtk.AccelGroupNew()
+	for cid, cdata := range sn.NoteSet.Categories {
+		accel, ok := cdata["accel"].(string)
+		if !ok || accel == "" {
+			continue
+		}
+		key, mods := gtk.AcceleratorParse(accel)
+		if key == 0 {
+			continue
+		}
+		catID := cid // Capture for closure
+		sn.catAccelGroup.Connect(key, mods, gtk.ACCEL_VISIBLE, func() bool {
+			sn.NoteSet.NewInCategory(catID)
+			return true
+		})
+	}
+	sn.WinMain.AddAccelGroup(sn.catAccelGroup)
+}
+
 func (sn *StickyNote) setCategory(cat string) {
 	if !sn.NoteSet.HasCategory(cat) {
 		return
@@ -1276,6 +1086,48 @@ func (sn *StickyNote) setCategory(cat string) {
 	sn.NoteSet.Save()
 }
 
+// onNoteColor opens a color chooser for this note's background. The chosen color is
+// stored in the note's properties and takes precedence over the category color.
+func (sn *StickyNote) onNoteColor() {
+	dialog, err := gtk.ColorChooserDialogNew("Note color", sn.WinMain)
+	if err != nil {
+		return
+	}
+	dialog.SetUseAlpha(false)
+	dialog.AddButton("Use Category Color", gtk.RESPONSE_REJECT)
+
+	if hsv, ok := floatList(sn.bgColorHSV()); ok && len(hsv) >= 3 {
+		rgb := hsvToRGB(hsv[0], hsv[1], hsv[2])
+		dialog.SetRGBA(gdk.NewRGBA(rgb[0], rgb[1], rgb[2], 1.0))
+	}
+
+	response := dialog.Run()
+	rgba := dialog.GetRGBA()
+	dialog.Destroy()
+
+	switch response {
+	case gtk.RESPONSE_OK:
+		hsv := rgbToHSV(rgba.GetRed(), rgba.GetGreen(), rgba.GetBlue())
+		sn.Note.Properties["bgcolor_hsv"] = []float64{hsv[0], hsv[1], hsv[2]}
+	case gtk.RESPONSE_REJECT:
+		delete(sn.Note.Properties, "bgcolor_hsv")
+	default:
+		return
+	}
+
+	sn.LoadCSS()
+	sn.NoteSet.Save()
+}
+
+// bgColorHSV returns the note's background color, preferring a per-note override
+// over the category color
+func (sn *StickyNote) bgColorHSV() interface{} {
+	if hsv, ok := sn.Note.Properties["bgcolor_hsv"]; ok && hsv != nil {
+		return hsv
+	}
+	return sn.Note.CatProp("bgcolor_hsv")
+}
+
 func (sn *StickyNote) onPopupMenu() {
 	// Connect to menu hide signal to clear button's active state
 	// This prevents the button from staying in pressed/active state
@@ -1284,7 +1136,7 @@ func (sn *StickyNote) onPopupMenu() {
 			// Clear button's active/pressed state
 			if sn.BMenu != nil {
 				// Use glib.IdleAdd to ensure this runs after the menu is fully hidden
-				glib.IdleAdd(func() bool {
+				sn.idleAdd(func() bool {
 					// Remove focus from button to clear visual active state
 					if sn.BMenu.HasFocus() {
 						sn.WinMain.GrabFocus()
@@ -1325,8 +1177,12 @@ func (sn *StickyNote) LoadCSS() {
 
 	// Get colors from category
 	// Always try to get category properties, even if category is empty (will use default)
-	bgHSVInterface := sn.Note.CatProp("bgcolor_hsv")
+	bgHSVInterface := sn.bgColorHSV()
 	textColorInterface := sn.Note.CatProp("textcolor")
+	if safeMode {
+		// Default colors
+		bgHSVInterface, textColorInterface = nil, nil
+	}
 
 	// Convert interface{} to []float64
 	var bgHSV []float64
@@ -1373,14 +1229,22 @@ func (sn *StickyNote) LoadCSS() {
 		textColor = []float64{32.0 / 255, 32.0 / 255, 32.0 / 255} // Default
 	}
 
+	bgHSV = sn.agedHSV(bgHSV)
+
 	// Convert HSV to RGB
 	bgRGB := hsvToRGB(bgHSV[0], bgHSV[1], bgHSV[2])
 	bgHex := rgbToHex(bgRGB[0], bgRGB[1], bgRGB[2])
 	textHex := rgbToHex(textColor[0], textColor[1], textColor[2])
+	sn.updateCodeBlockColor(bgHSV)
 
 	// Substitute in template
 	css := strings.ReplaceAll(cssTemplate, "$bgcolor_hex", bgHex)
 	css = strings.ReplaceAll(css, "$text_color", textHex)
+	if !safeMode {
+		css += textureCSS(sn.Note.Texture(), textColor)
+	}
+	css += sn.dueCSS()
+	sn.updateAgeBadge()
 
 	// Create provider if it doesn't exist (for cases where LoadCSS is called before buildNote completes)
 	if sn.CSSProvider == nil {
@@ -1394,39 +1258,76 @@ func (sn *StickyNote) LoadCSS() {
 
 	// If provider is not yet added to contexts (e.g., called from Show() or setCategory()),
 	// add it now. Otherwise, the data update will automatically refresh the styles.
-	winContext, _ := sn.WinMain.GetStyleContext()
-	txtContext, _ := sn.TxtNote.GetStyleContext()
-
 	// Check if provider is already added by trying to remove it
 	// If it's not added, this is a no-op, then we add it
-	winContext.RemoveProvider(sn.CSSProvider)
-	txtContext.RemoveProvider(sn.CSSProvider)
-	winContext.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
-	txtContext.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+	for _, context := range sn.styleContexts() {
+		context.RemoveProvider(sn.CSSProvider)
+		context.AddProvider(sn.CSSProvider, gtk.STYLE_PROVIDER_PRIORITY_USER)
+	}
 
 	// Force a redraw to apply the CSS
 	sn.WinMain.QueueDraw()
 	sn.TxtNote.QueueDraw()
 }
 
-func (sn *StickyNote) UpdateFont() {
-	fontName := ""
-	if font, ok := sn.Note.CatProp("font").(string); ok {
-		fontName = font
+// styleContexts returns the style contexts of the widgets styled by the per-note CSS provider
+func (sn *StickyNote) styleContexts() []*gtk.StyleContext {
+	widgets := []gtk.IWidget{sn.WinMain, sn.TxtNote}
+	if sn.ETags != nil {
+		widgets = append(widgets, sn.ETags)
 	}
-	if fontName == "" {
-		fontName = "Sans 12"
+	contexts := make([]*gtk.StyleContext, 0, len(widgets))
+	for _, w := range widgets {
+		if context, err := w.ToWidget().GetStyleContext(); err == nil {
+			contexts = append(contexts, context)
+		}
 	}
+	return contexts
+}
 
-	// Apply font through CSS
-	// Note: OverrideFont is deprecated in GTK3, use CSS instead
-	// We'll add font styling to the CSS provider
-	context, _ := sn.TxtNote.GetStyleContext()
-	context.AddClass("custom-font")
-	// Font will be applied via CSS in the style.css template
+func (sn *StickyNote) UpdateFont() {
+	// The font has its own provider, so it can change without reloading the colors
+	if sn.fontProvider == nil {
+		sn.fontProvider, _ = gtk.CssProviderNew()
+		if context, err := sn.TxtNote.GetStyleContext(); err == nil {
+			context.AddProvider(sn.fontProvider, gtk.STYLE_PROVIDER_PRIORITY_USER+1)
+		}
+	}
+	if safeMode {
+		// Default font
+		sn.fontProvider.LoadFromData("")
+		return
+	}
+	sn.fontProvider.LoadFromData(sn.fontCSS())
 }
 
 // Helper functions
+
+// floatList converts a JSON-decoded ([]interface{}) or in-memory ([]float64) number list
+func floatList(val interface{}) ([]float64, bool) {
+	switch list := val.(type) {
+	case []float64:
+		return list, true
+	case []int:
+		result := make([]float64, len(list))
+		for i, item := range list {
+			result[i] = float64(item)
+		}
+		return result, true
+	case []interface{}:
+		result := make([]float64, len(list))
+		for i, item := range list {
+			f, ok := item.(float64)
+			if !ok {
+				return nil, false
+			}
+			result[i] = f
+		}
+		return result, true
+	}
+	return nil, false
+}
+
 func getObject[T any](builder *gtk.Builder, name string) (T, error) {
 	obj, err := builder.GetObject(name)
 	if err != nil {
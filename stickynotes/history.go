@@ -0,0 +1,259 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Previous versions of a note body are kept next to the data file, in
+// "<data file>-history/<note uuid>.json", oldest first. A version is recorded before
+// an edit when the last one is old enough or the edit changes a lot of text.
+const (
+	historySuffix = "-history"
+
+	// historyMaxVersions bounds the number of versions kept per note
+	historyMaxVersions = 30
+	// historyInterval is the minimum time between versions for small edits
+	historyInterval = 10 * time.Minute
+	// historyMinChange is the change in length (characters) that always records a version
+	historyMinChange = 80
+)
+
+// NoteVersion is a previous body of a note
+type NoteVersion struct {
+	Body string    `json:"body"`
+	Time time.Time `json:"time"`
+}
+
+// HistoryDir returns the directory holding the version history of all notes
+func (ns *NoteSet) HistoryDir() string {
+	return ns.DataPath() + historySuffix
+}
+
+// historyFile returns the file holding the note's version history
+func (n *Note) historyFile() string {
+	return filepath.Join(n.NoteSet.HistoryDir(), n.UUID+".json")
+}
+
+// History returns the note's previous versions, oldest first
+func (n *Note) History() []NoteVersion {
+	data, err := os.ReadFile(n.historyFile())
+	if err != nil {
+		return nil
+	}
+	var versions []NoteVersion
+	if err := json.Unmarshal(data, &versions); err != nil {
+		fmt.Printf("[History] Note %s: unreadable history: %v\n", n.UUID[:8], err)
+		return nil
+	}
+	return versions
+}
+
+// RemoveHistory deletes the note's version history
+func (n *Note) RemoveHistory() {
+	if !validNoteUUID(n.UUID) {
+		return
+	}
+	os.Remove(n.historyFile())
+}
+
+// hadVersion reports whether body is one of the note's previous versions
+func (n *Note) hadVersion(body string) bool {
+	for _, version := range n.History() {
+		if version.Body == body {
+			return true
+		}
+	}
+	return false
+}
+
+// recordVersion adds body to the note's history, dropping the oldest versions
+// beyond historyMaxVersions. Bodies equal to the latest version are not recorded twice.
+func (n *Note) recordVersion(body string) {
+	if n.NoteSet == nil || n.NoteSet.DataFile == "" || body == "" {
+		return
+	}
+	versions := n.History()
+	if len(versions) > 0 && versions[len(versions)-1].Body == body {
+		return
+	}
+	versions = append(versions, NoteVersion{Body: body, Time: time.Now()})
+	if len(versions) > historyMaxVersions {
+		versions = versions[len(versions)-historyMaxVersions:]
+	}
+
+	data, err := json.Marshal(versions)
+	if err == nil {
+		err = os.MkdirAll(n.NoteSet.HistoryDir(), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(n.historyFile(), data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("[History] Note %s: failed to record version: %v\n", n.UUID[:8], err)
+		return
+	}
+	n.versionAt = time.Now()
+}
+
+// snapshotBeforeEdit records the current body before it is replaced by body, if the
+// edit is significant
+func (n *Note) snapshotBeforeEdit(body string) {
+	change := utf8.RuneCountInString(body) - utf8.RuneCountInString(n.Body)
+	if change < 0 {
+		change = -change
+	}
+	if change >= historyMinChange || time.Since(n.versionAt) >= historyInterval {
+		n.recordVersion(n.Body)
+	}
+}
+
+// RestoreVersion replaces the body with a previous version, keeping the current body
+// in the history so the restore can be undone
+func (n *Note) RestoreVersion(version NoteVersion) {
+	n.recordVersion(n.Body)
+	n.Body = version.Body
+	// Formatting offsets belong to the replaced text
+	n.Formatting = nil
+	n.LastModified = time.Now()
+}
+
+// HistoryWindow lists a note's previous versions with a preview, to restore one
+type HistoryWindow struct {
+	StickyNote *StickyNote
+	Window     *gtk.Window
+	List       *gtk.ListBox
+	Preview    *gtk.TextView
+	versions   []NoteVersion // Newest first, by ListBox row index
+}
+
+// onHistory opens the note's version history
+func (sn *StickyNote) onHistory() {
+	sn.UpdateNote()
+	NewHistoryWindow(sn)
+}
+
+// NewHistoryWindow opens the version history of a note
+func NewHistoryWindow(sn *StickyNote) *HistoryWindow {
+	hw := &HistoryWindow{StickyNote: sn}
+	versions := sn.Note.History()
+	for i := len(versions) - 1; i >= 0; i-- {
+		hw.versions = append(hw.versions, versions[i])
+	}
+
+	hw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	hw.Window.SetTitle("History")
+	hw.Window.SetTransientFor(sn.WinMain)
+	hw.Window.SetDefaultSize(560, 360)
+	hw.Window.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+	paned, _ := gtk.PanedNew(gtk.ORIENTATION_HORIZONTAL)
+	paned.SetPosition(200)
+
+	listScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	listScroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	listScroll.SetShadowType(gtk.SHADOW_IN)
+	hw.List, _ = gtk.ListBoxNew()
+	hw.List.Connect("row-selected", hw.showPreview)
+	listScroll.Add(hw.List)
+	paned.Pack1(listScroll, false, false)
+
+	previewScroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	previewScroll.SetShadowType(gtk.SHADOW_IN)
+	hw.Preview, _ = gtk.TextViewNew()
+	hw.Preview.SetEditable(false)
+	hw.Preview.SetCursorVisible(false)
+	hw.Preview.SetWrapMode(gtk.WRAP_WORD_CHAR)
+	hw.Preview.SetLeftMargin(6)
+	hw.Preview.SetRightMargin(6)
+	previewScroll.Add(hw.Preview)
+	paned.Pack2(previewScroll, true, false)
+	box.PackStart(paned, true, true, 0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bClose, _ := gtk.ButtonNewWithLabel("Close")
+	bClose.Connect("clicked", hw.Window.Destroy)
+	buttons.PackEnd(bClose, false, false, 0)
+	bRestore, _ := gtk.ButtonNewWithLabel("Restore")
+	bRestore.SetSensitive(len(hw.versions) > 0)
+	bRestore.Connect("clicked", hw.onRestore)
+	buttons.PackEnd(bRestore, false, false, 0)
+	box.PackStart(buttons, false, false, 0)
+
+	if len(hw.versions) == 0 {
+		label, _ := gtk.LabelNew("No earlier versions yet")
+		label.SetMarginTop(12)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		row.SetSelectable(false)
+		row.SetActivatable(false)
+		hw.List.Add(row)
+	}
+	for _, version := range hw.versions {
+		title := noteLabel(&Note{Body: version.Body})
+		label, _ := gtk.LabelNew("")
+		label.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s</small>",
+			glib.MarkupEscapeText(FormatDateTime(version.Time)),
+			glib.MarkupEscapeText(title)))
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		hw.List.Add(row)
+	}
+
+	hw.Window.Add(box)
+	hw.Window.ShowAll()
+	if row := hw.List.GetRowAtIndex(0); row != nil && len(hw.versions) > 0 {
+		hw.List.SelectRow(row)
+	}
+
+	return hw
+}
+
+// selected returns the selected version
+func (hw *HistoryWindow) selected() (NoteVersion, bool) {
+	row := hw.List.GetSelectedRow()
+	if row == nil {
+		return NoteVersion{}, false
+	}
+	index := row.GetIndex()
+	if index < 0 || index >= len(hw.versions) {
+		return NoteVersion{}, false
+	}
+	return hw.versions[index], true
+}
+
+// showPreview shows the body of the selected version
+func (hw *HistoryWindow) showPreview() {
+	buffer, _ := hw.Preview.GetBuffer()
+	if version, ok := hw.selected(); ok {
+		buffer.SetText(version.Body)
+	} else {
+		buffer.SetText("")
+	}
+}
+
+func (hw *HistoryWindow) onRestore() {
+	version, ok := hw.selected()
+	if !ok {
+		return
+	}
+	sn := hw.StickyNote
+	sn.UpdateNote()
+	sn.Note.RestoreVersion(version)
+	if sn.WinMain != nil {
+		sn.reloadNote()
+	}
+	sn.NoteSet.Save()
+	hw.Window.Destroy()
+}
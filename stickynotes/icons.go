@@ -0,0 +1,137 @@
+package stickynotes
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Icon sets selectable in settings ("icon_set" property)
+const (
+	IconSetEmbedded = "embedded"
+	IconSetSymbolic = "symbolic"
+	IconSetCustom   = "custom"
+)
+
+// IconProvider supplies the images of the note buttons.
+// Icon names are "add", "close", "lock", "unlock", "resizer" and "menu".
+type IconProvider interface {
+	// SetIcon sets the named icon on img, returning false if the set has no such icon
+	SetIcon(img *gtk.Image, name string) bool
+}
+
+// NewIconProvider returns the icon set chosen in settings. Icons missing from a
+// symbolic theme or a custom directory fall back to the embedded ones.
+func NewIconProvider(ns *NoteSet) IconProvider {
+	embedded := &embeddedIcons{path: getBasePath()}
+	set, _ := ns.Properties["icon_set"].(string)
+	switch set {
+	case IconSetSymbolic:
+		return &symbolicIcons{fallback: embedded}
+	case IconSetCustom:
+		if dir, ok := ns.Properties["icon_dir"].(string); ok && dir != "" {
+			return &directoryIcons{dir: dir, fallback: embedded}
+		}
+	}
+	return embedded
+}
+
+// embeddedIcons loads the bundled Icons set, from embedded resources or next to the executable.
+// Tries SVG first (better quality), then falls back to PNG
+type embeddedIcons struct {
+	path string
+}
+
+func (p *embeddedIcons) SetIcon(img *gtk.Image, name string) bool {
+	// Try SVG first (better quality), then fall back to PNG
+	iconData, err := getEmbeddedIcon(name + ".svg")
+	if err != nil {
+		// Fallback to PNG
+		iconData, err = getEmbeddedIcon(name + ".png")
+	}
+
+	if err != nil {
+		// Fallback: try to load from file system (try SVG first, then PNG)
+		return setIconFromDir(img, filepath.Join(p.path, "Icons"), name)
+	}
+
+	// Load from embedded bytes using PixbufLoader
+	// Don't scale - let GTK handle scaling naturally based on display DPI
+	loader, err := gdk.PixbufLoaderNew()
+	if err != nil {
+		return false
+	}
+
+	if _, err := loader.Write(iconData); err != nil {
+		loader.Close()
+		return false
+	}
+
+	// Close loader to finalize pixbuf
+	if err := loader.Close(); err != nil {
+		return false
+	}
+
+	pixbuf, err := loader.GetPixbuf()
+	if err != nil || pixbuf == nil {
+		return false
+	}
+	img.SetFromPixbuf(pixbuf)
+	return true
+}
+
+// symbolicIconNames maps button icons to freedesktop symbolic icon names
+var symbolicIconNames = map[string]string{
+	"add":    "list-add-symbolic",
+	"close":  "window-close-symbolic",
+	"lock":   "changes-prevent-symbolic",
+	"unlock": "changes-allow-symbolic",
+	"menu":   "open-menu-symbolic",
+}
+
+// symbolicIcons uses the symbolic icons of the current GTK icon theme, so the buttons
+// follow the theme and the note's text color
+type symbolicIcons struct {
+	fallback IconProvider
+}
+
+func (p *symbolicIcons) SetIcon(img *gtk.Image, name string) bool {
+	iconName, ok := symbolicIconNames[name]
+	if ok {
+		if theme, err := gtk.IconThemeGetDefault(); err == nil && theme.HasIcon(iconName) {
+			img.SetFromIconName(iconName, gtk.ICON_SIZE_BUTTON)
+			return true
+		}
+	}
+	return p.fallback.SetIcon(img, name)
+}
+
+// directoryIcons loads <name>.svg or <name>.png from a user-provided directory
+type directoryIcons struct {
+	dir      string
+	fallback IconProvider
+}
+
+func (p *directoryIcons) SetIcon(img *gtk.Image, name string) bool {
+	if setIconFromDir(img, p.dir, name) {
+		return true
+	}
+	return p.fallback.SetIcon(img, name)
+}
+
+// setIconFromDir loads name.svg or name.png from dir into img
+func setIconFromDir(img *gtk.Image, dir, name string) bool {
+	for _, ext := range []string{".svg", ".png"} {
+		iconPath := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(iconPath); err != nil {
+			continue
+		}
+		if pixbuf, err := gdk.PixbufNewFromFile(iconPath); err == nil {
+			img.SetFromPixbuf(pixbuf)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// Importing a note that is already here merges the two copies. Their common ancestor is
+// the revision they shared when the note was last imported, kept in the note's
+// "merge_rev" property: when only one copy changed since, that one is kept. When both
+// changed, or the note was never imported before, the one modified last wins; the
+// fields the import has replace the note's (fields it lacks are kept) and the text not
+// kept goes to the note's history. Notes changed on both sides are listed afterwards
+// (NoteSet.MergeConflicts) to choose which text to keep. An import older than a text
+// this note already had is never taken.
+
+// mergeRevProperty holds the revision of the note when it was last imported
+const mergeRevProperty = "merge_rev"
+
+// MergeConflict is a note changed both here and in an import, since it was last imported
+type MergeConflict struct {
+	Note     *Note // The note, with the text of the copy modified last
+	Other    *Note // The copy that wasn't kept, its text is in the note's history
+	Imported bool  // Other is the imported copy, rather than the one that was here
+}
+
+// mergeNote takes in the imported copy of the note, returning the conflict when both
+// copies changed since they were last merged
+func (ns *NoteSet) mergeNote(mine *Note, content map[string]interface{}) *MergeConflict {
+	theirs := NewNote(content, nil, ns, "")
+	previous := NewNote(mine.Extract(), nil, ns, "")
+
+	// Which copies changed since their common ancestor, both when it isn't known
+	mineChanged, theirsChanged := true, true
+	if base, ok := mine.Properties[mergeRevProperty].(float64); ok {
+		mineChanged = mine.Revision > int(base)
+		theirsChanged = theirs.Revision > int(base)
+	}
+	sameBody := theirs.Body == mine.Body
+	if !sameBody && mine.hadVersion(theirs.Body) {
+		// An older text of this note
+		theirsChanged = false
+	}
+
+	takeTheirs := theirsChanged
+	if mineChanged && theirsChanged {
+		// Last write wins; an import without modification times is taken as newer
+		_, dated := content["last_modified"]
+		takeTheirs = !dated || theirs.LastModified.After(mine.LastModified)
+	}
+
+	if takeTheirs {
+		if !sameBody {
+			ns.audit(AuditImportReplace, mine, "")
+			mine.recordVersion(mine.Body)
+			mine.Body = theirs.Body
+		}
+		if _, ok := content["properties"].(map[string]interface{}); ok {
+			mine.Properties = theirs.Properties
+		}
+		if cat, ok := content["cat"].(string); ok {
+			mine.Category = cat
+		}
+		if _, ok := content["tags"].([]interface{}); ok {
+			mine.Tags = theirs.Tags
+		}
+		if _, ok := content["formatting"].([]interface{}); ok {
+			mine.Formatting = theirs.Formatting
+		}
+		if _, ok := content["last_modified"]; ok {
+			mine.LastModified = theirs.LastModified
+		}
+		if _, ok := content["rev"]; ok {
+			mine.Revision = theirs.Revision
+		}
+		if _, ok := content["edited_on"]; ok {
+			mine.EditedOn = theirs.EditedOn
+		}
+		mine.Properties[mergeRevProperty] = float64(mine.Revision)
+	} else {
+		if !sameBody && theirsChanged {
+			mine.recordVersion(theirs.Body)
+			// Saved as newer than both copies, so importing it there takes it
+			mine.Revision = max(mine.Revision, theirs.Revision) + 1
+		}
+		mine.Properties[mergeRevProperty] = float64(theirs.Revision)
+	}
+
+	if sameBody || !mineChanged || !theirsChanged {
+		return nil
+	}
+	if takeTheirs {
+		return &MergeConflict{Note: mine, Other: previous}
+	}
+	return &MergeConflict{Note: mine, Other: theirs, Imported: true}
+}
+
+// MergeConflictWindow lists the notes changed both here and in the import, to choose
+// which text of each to keep
+type MergeConflictWindow struct {
+	NoteSet *NoteSet
+	Window  *gtk.Window
+	choices map[*gtk.ComboBoxText]MergeConflict
+}
+
+// NewMergeConflictWindow opens the list of conflicts of an import
+func NewMergeConflictWindow(noteset *NoteSet, conflicts []MergeConflict) *MergeConflictWindow {
+	mw := &MergeConflictWindow{
+		NoteSet: noteset,
+		choices: make(map[*gtk.ComboBoxText]MergeConflict),
+	}
+
+	mw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	mw.Window.SetTitle("Import Conflicts")
+	mw.Window.SetDefaultSize(520, 420)
+	mw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	intro, _ := gtk.LabelNew(fmt.Sprintf("%d notes were changed both here and in the imported file. The text modified last was kept; choose another one where it should not have been. The text not kept stays in the note's history.",
+		len(conflicts)))
+	intro.SetLineWrap(true)
+	intro.SetXAlign(0)
+	box.PackStart(intro, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	for _, conflict := range conflicts {
+		list.Add(mw.buildRow(conflict))
+	}
+	scrolled.Add(list)
+	box.PackStart(scrolled, true, true, 0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bApply, _ := gtk.ButtonNewWithLabel("Apply")
+	bApply.Connect("clicked", mw.onApply)
+	buttons.PackEnd(bApply, false, false, 0)
+	bClose, _ := gtk.ButtonNewWithLabel("Keep All")
+	bClose.SetTooltipText("Keep the texts modified last")
+	bClose.Connect("clicked", func() { mw.Window.Destroy() })
+	buttons.PackEnd(bClose, false, false, 0)
+	box.PackStart(buttons, false, false, 0)
+
+	mw.Window.Add(box)
+	mw.Window.ShowAll()
+	return mw
+}
+
+// buildRow shows a note changed on both sides, with the choice of which text to keep
+func (mw *MergeConflictWindow) buildRow(conflict MergeConflict) *gtk.ListBoxRow {
+	row, _ := gtk.ListBoxRowNew()
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetBorderWidth(6)
+
+	here, imported := conflict.Note, conflict.Other
+	if !conflict.Imported {
+		here, imported = conflict.Other, conflict.Note
+	}
+	label, _ := gtk.LabelNew("")
+	detail := fmt.Sprintf("Here: %s · Import: %s", FormatAgo(here.LastModified), FormatAgo(imported.LastModified))
+	label.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s</small>",
+		glib.MarkupEscapeText(conflict.Note.FirstLine()), glib.MarkupEscapeText(detail)))
+	label.SetTooltipText(conflict.Other.Body)
+	label.SetHAlign(gtk.ALIGN_START)
+	label.SetEllipsize(pango.ELLIPSIZE_END)
+	box.PackStart(label, true, true, 0)
+
+	choice, _ := gtk.ComboBoxTextNew()
+	if conflict.Imported {
+		choice.Append(conflictKeepMine, "Keep mine")
+		choice.Append(conflictTakeTheirs, "Take imported")
+	} else {
+		choice.Append(conflictKeepMine, "Keep imported")
+		choice.Append(conflictTakeTheirs, "Take mine")
+	}
+	choice.SetActiveID(conflictKeepMine)
+	choice.SetVAlign(gtk.ALIGN_CENTER)
+	box.PackEnd(choice, false, false, 0)
+	mw.choices[choice] = conflict
+
+	row.Add(box)
+	return row
+}
+
+// onApply takes the other text of the notes where it was chosen
+func (mw *MergeConflictWindow) onApply() {
+	changed := false
+	for choice, conflict := range mw.choices {
+		if choice.GetActiveID() == conflictTakeTheirs {
+			mw.NoteSet.resolve(ConflictNote{Theirs: conflict.Other, Mine: conflict.Note}, conflictTakeTheirs)
+			changed = true
+		}
+	}
+	if changed {
+		mw.NoteSet.Save()
+	}
+	mw.Window.Destroy()
+}
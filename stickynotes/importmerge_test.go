@@ -0,0 +1,65 @@
+package stickynotes
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMergeLastWriteWins(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	note := findNote(t, ns, "note-0001")
+	note.Properties[mergeRevProperty] = float64(3) // Last imported at its revision 3
+	merge := func(body string, rev int, modified string) {
+		t.Helper()
+		data := fmt.Sprintf(`{"notes": [{"uuid": "note-0001", "body": %q, "rev": %d, "last_modified": %q, "edited_on": "desktop"}]}`,
+			body, rev, modified)
+		if err := ns.Merge(data); err != nil {
+			t.Fatalf("Merge: %v", err)
+		}
+	}
+
+	// Changed only in the import: taken even though it is dated earlier
+	merge("Shopping\nbread", 4, "2024-02-01T00:00:00")
+	if note.Body != "Shopping\nbread" || note.Revision != 4 || note.EditedOn != "desktop" || len(ns.MergeConflicts) != 0 {
+		t.Errorf("import changed only there = %q rev %d from %s, %d conflicts; want it taken without conflict",
+			note.Body, note.Revision, note.EditedOn, len(ns.MergeConflicts))
+	}
+
+	// Changed only here since: the same import again changes nothing
+	note.Body = "Shopping\neggs"
+	note.Revision = 5
+	note.LastModified = time.Date(2024, 3, 5, 9, 0, 0, 0, time.Local)
+	merge("Shopping\nbread", 4, "2024-02-01T00:00:00")
+	if note.Body != "Shopping\neggs" || len(ns.MergeConflicts) != 0 {
+		t.Errorf("import unchanged there = %q, %d conflicts; want the text here kept", note.Body, len(ns.MergeConflicts))
+	}
+
+	// Changed on both sides: the text modified last wins, the other is a conflict kept in
+	// the history
+	merge("Shopping\ntea", 6, "2024-03-04T12:00:00")
+	if note.Body != "Shopping\neggs" || note.Revision != 7 {
+		t.Errorf("older import changed on both sides = %q rev %d, want the text here at rev 7", note.Body, note.Revision)
+	}
+	if len(ns.MergeConflicts) != 1 || !ns.MergeConflicts[0].Imported || ns.MergeConflicts[0].Other.Body != "Shopping\ntea" {
+		t.Fatalf("conflicts = %+v, want the imported text", ns.MergeConflicts)
+	}
+	if !note.hadVersion("Shopping\ntea") {
+		t.Error("the imported text not kept is missing from the history")
+	}
+	merge("Shopping\ncoffee", 8, "2024-03-06T12:00:00")
+	if note.Body != "Shopping\ncoffee" || len(ns.MergeConflicts) != 1 || ns.MergeConflicts[0].Imported ||
+		ns.MergeConflicts[0].Other.Body != "Shopping\neggs" {
+		t.Errorf("newer import changed on both sides = %q, conflicts %+v; want it taken, ours the conflict", note.Body, ns.MergeConflicts)
+	}
+
+	// An import of a text the note already had is older, never taken
+	note.Revision = 9
+	merge("Shopping\nmilk", 9, "2024-03-07T00:00:00")
+	if note.Body != "Shopping\ncoffee" || len(ns.MergeConflicts) != 0 {
+		t.Errorf("import of an earlier text = %q, %d conflicts; want it ignored", note.Body, len(ns.MergeConflicts))
+	}
+}
@@ -0,0 +1,66 @@
+package stickynotes
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultInboxNote is the note scripts append to when no note is named
+const DefaultInboxNote = "Inbox"
+
+// FindNote looks a note up by UUID, or else by title (first line, case-insensitive)
+func (ns *NoteSet) FindNote(uuidOrName string) *Note {
+	for _, note := range ns.Notes {
+		if note.UUID == uuidOrName {
+			return note
+		}
+	}
+	for _, note := range ns.Notes {
+		if strings.EqualFold(note.FirstLine(), strings.TrimSpace(uuidOrName)) {
+			return note
+		}
+	}
+	return nil
+}
+
+// AppendToNote appends text as new line(s) to the note with the given UUID or title.
+// If there's no such note, a note titled uuidOrName is created in the default category
+// (not shown, the caller decides). Returns the note and whether it was created.
+func (ns *NoteSet) AppendToNote(uuidOrName, text string) (*Note, bool) {
+	text = strings.TrimRight(text, "\n")
+
+	note := ns.FindNote(uuidOrName)
+	if note == nil {
+		name := strings.TrimSpace(uuidOrName)
+		if name == "" {
+			name = DefaultInboxNote
+		}
+		defaultCat, _ := ns.Properties["default_cat"].(string)
+		note = NewNote(map[string]interface{}{"body": name + "\n" + text}, NewStickyNote, ns, defaultCat)
+		ns.Notes = append(ns.Notes, note)
+		return note, true
+	}
+
+	if note.GUI != nil {
+		note.GUI.AppendText(text)
+		note.GUI.UpdateNote()
+		return note, false
+	}
+
+	body := note.Body
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	note.Body = body + text
+	note.LastModified = time.Now()
+	return note, false
+}
+
+// AppendText adds text as new line(s) at the end of the note, keeping its formatting
+func (sn *StickyNote) AppendText(text string) {
+	end := sn.BBody.GetEndIter()
+	if sn.BBody.GetCharCount() > 0 && !end.StartsLine() {
+		text = "\n" + text
+	}
+	sn.BBody.Insert(end, text)
+}
@@ -4,8 +4,12 @@ const (
 	PODir             = "po"
 	MODir             = "locale"
 	LocaleDomain      = "indicator-stickynotes"
-	SettingsFile      = "~/.config/indicator-stickynotes"
-	DebugSettingsFile = "~/.stickynotes"
+	SettingsFile      = "$XDG_DATA_HOME/postnote/notes.json"
+	DebugSettingsFile = "$XDG_DATA_HOME/postnote/notes-dev.json"
+
+	// Where older versions kept the data files, see MigrateDataFile
+	LegacySettingsFile      = "~/.config/indicator-stickynotes"
+	LegacyDebugSettingsFile = "~/.stickynotes"
 )
 
 var FallbackProperties = map[string]interface{}{
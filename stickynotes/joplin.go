@@ -0,0 +1,231 @@
+package stickynotes
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Joplin exports its notes as RAW (a folder of "<id>.md" items) or JEX (the same items in
+// a tar archive). Each item is its title, a blank line, the body, and then its properties
+// as "key: value" lines, "type_" telling notes, notebooks and tags apart. Notebooks become
+// categories (matched by name), tags are kept, and a note keeps its Joplin id as UUID, so
+// importing the export again updates the notes instead of duplicating them.
+
+// Joplin item types
+const (
+	joplinNote     = "1"
+	joplinNotebook = "2"
+	joplinTag      = "5"
+	joplinNoteTag  = "6"
+)
+
+// joplinMaxItemSize bounds the size of an item read from an export
+const joplinMaxItemSize = 16 * 1024 * 1024
+
+// joplinItem is a note, notebook, tag or note-tag link of a Joplin export
+type joplinItem struct {
+	Title string
+	Body  string
+	Props map[string]string
+}
+
+// parseJoplinItem reads a Joplin item, ok=false when data isn't one
+func parseJoplinItem(data string) (joplinItem, bool) {
+	item := joplinItem{Props: make(map[string]string)}
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(data, "\r\n", "\n"), "\n"), "\n")
+
+	// The properties are the lines after the last blank line
+	i := len(lines) - 1
+	for ; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return item, false
+		}
+		item.Props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if item.Props["type_"] == "" {
+		return item, false
+	}
+
+	if i > 0 {
+		body := lines[:i]
+		item.Title = body[0]
+		if len(body) > 2 {
+			item.Body = strings.Join(body[2:], "\n")
+		}
+	}
+	return item, true
+}
+
+// IsJoplinItem reports whether data is an item of a Joplin RAW export
+func IsJoplinItem(data string) bool {
+	_, ok := parseJoplinItem(data)
+	return ok
+}
+
+// readJoplinExport reads the items of a RAW export folder or a JEX archive
+func readJoplinExport(path string) ([]joplinItem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []joplinItem
+	add := func(r io.Reader) error {
+		data, err := io.ReadAll(io.LimitReader(r, joplinMaxItemSize))
+		if err != nil {
+			return err
+		}
+		if item, ok := parseJoplinItem(string(data)); ok {
+			items = append(items, item)
+		}
+		return nil
+	}
+
+	if info.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, err
+			}
+			err = add(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	archive := tar.NewReader(f)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a Joplin export (JEX): %w", err)
+		}
+		// Resources (attachments) are in a subfolder, the items at the top
+		if header.Typeflag != tar.TypeReg || strings.Contains(strings.Trim(header.Name, "/"), "/") ||
+			!strings.HasSuffix(header.Name, ".md") {
+			continue
+		}
+		if err := add(archive); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// ImportJoplin adds the notes of a Joplin export, a RAW export folder or a JEX file, like
+// Merge. Notebooks become categories, matched by name, and the notes keep their tags and
+// when they were last updated.
+func (ns *NoteSet) ImportJoplin(path string) error {
+	items, err := readJoplinExport(path)
+	if err != nil {
+		return err
+	}
+
+	notebooks := make(map[string]string) // Joplin notebook id to name
+	tags := make(map[string]string)      // Joplin tag id to name
+	noteTags := make(map[string][]interface{})
+	for _, item := range items {
+		switch item.Props["type_"] {
+		case joplinNotebook:
+			notebooks[item.Props["id"]] = item.Title
+		case joplinTag:
+			tags[item.Props["id"]] = item.Title
+		}
+	}
+	for _, item := range items {
+		if item.Props["type_"] == joplinNoteTag {
+			if tag, ok := tags[item.Props["tag_id"]]; ok {
+				noteTags[item.Props["note_id"]] = append(noteTags[item.Props["note_id"]], tag)
+			}
+		}
+	}
+
+	categories := make(map[string]interface{})
+	var notes []interface{}
+	for _, item := range items {
+		if item.Props["type_"] != joplinNote || joplinSkipped(item) {
+			continue
+		}
+		body := item.Title
+		if item.Body != "" {
+			body += "\n" + item.Body
+		}
+		content := map[string]interface{}{
+			"body":       body,
+			"properties": map[string]interface{}{},
+		}
+		// Joplin ids are UUIDs without the dashes
+		if id, err := uuid.Parse(item.Props["id"]); err == nil {
+			content["uuid"] = id.String()
+		}
+		if updated, err := time.Parse(time.RFC3339Nano, item.Props["updated_time"]); err == nil {
+			content["last_modified"] = updated.Local().Format("2006-01-02T15:04:05")
+		}
+		if tags, ok := noteTags[item.Props["id"]]; ok {
+			content["tags"] = tags
+		}
+		if name := notebooks[item.Props["parent_id"]]; name != "" {
+			content["cat"] = ns.joplinCategory(item.Props["parent_id"], name, categories)
+		}
+		notes = append(notes, content)
+	}
+	if len(notes) == 0 {
+		return errors.New("no notes found in the Joplin export")
+	}
+
+	jdata, err := json.Marshal(map[string]interface{}{
+		"notes":      notes,
+		"categories": categories,
+	})
+	if err != nil {
+		return err
+	}
+	return ns.Merge(string(jdata))
+}
+
+// joplinSkipped reports whether a note of an export isn't imported: deleted (in Joplin's
+// trash) or still encrypted by Joplin's end-to-end encryption
+func joplinSkipped(item joplinItem) bool {
+	if deleted := item.Props["deleted_time"]; deleted != "" && deleted != "0" {
+		return true
+	}
+	return item.Props["encryption_applied"] == "1"
+}
+
+// joplinCategory returns the category of a Joplin notebook: the category with the same
+// name, or a new one added to newCats
+func (ns *NoteSet) joplinCategory(notebookID, name string, newCats map[string]interface{}) string {
+	if cid, ok := ns.categoryByName(name); ok {
+		return cid
+	}
+	catID := "joplin-" + notebookID
+	newCats[catID] = map[string]interface{}{"name": name}
+	return catID
+}
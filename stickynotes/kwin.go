@@ -0,0 +1,355 @@
+package stickynotes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/google/uuid"
+)
+
+// KDE Plasma has no window-calls extension, but KWin runs scripts loaded over D-Bus. The
+// KWin backend answers the window-calls methods used here (List, Details, Move,
+// Activate, MakeAbove, Stick...) with a short script per call, which sends its result
+// back to an object this process exports, in the same JSON as window-calls: windowCall
+// routes them here, so the rest of the code doesn't tell the two apart. KWin identifies
+// windows by UUID, the backend numbers them for the uint32 ids of window-calls. Only the
+// windows of this process are listed. One more script stays loaded to report the note
+// windows being added, moved and removed to the window tracker (see tracker.go), and
+// whether the active window is fullscreen.
+
+// The object KWin scripts send their results to
+const (
+	kwinReplyPath      = dbus.ObjectPath("/io/github/runableapp/PostNote/KWin")
+	kwinReplyInterface = "io.github.runableapp.PostNote.KWin"
+)
+
+var (
+	kwinScripting bool // Window calls go to KWin instead of GNOME Shell
+	kwinExported  bool
+	kwinMu        sync.Mutex
+	kwinPending   = make(map[string]chan kwinReply) // Replies awaited, by call token
+	kwinIDs       = make(map[string]uint32)         // Window ids by KWin window UUID
+	kwinUUIDs     = make(map[uint32]string)         // KWin window UUIDs by id
+)
+
+// kwinReply is the result a script sent back
+type kwinReply struct {
+	out     string
+	failure string
+}
+
+// kwinReceiver receives the results of KWin scripts
+type kwinReceiver struct{}
+
+// Reply is called by a KWin script with its result, or why it failed
+func (kwinReceiver) Reply(token, out, failure string) *dbus.Error {
+	kwinMu.Lock()
+	reply, ok := kwinPending[token]
+	delete(kwinPending, token)
+	kwinMu.Unlock()
+	if ok {
+		reply <- kwinReply{out: out, failure: failure}
+	}
+	return nil
+}
+
+// WindowAdded is called by the tracker script with a window mapped or renamed, as
+// described by window-calls Details
+func (kwinReceiver) WindowAdded(window string) *dbus.Error {
+	if details, ok := kwinTrackedWindow(window); ok {
+		postWindowEvent(func(t *windowTracker) { t.windowAdded(details) })
+	}
+	return nil
+}
+
+// WindowMoved is called by the tracker script with a window moved or resized
+func (kwinReceiver) WindowMoved(window string) *dbus.Error {
+	if details, ok := kwinTrackedWindow(window); ok {
+		postWindowEvent(func(t *windowTracker) { t.windowMoved(details) })
+	}
+	return nil
+}
+
+// WindowRemoved is called by the tracker script with the UUID of a window unmapped or
+// closed
+func (kwinReceiver) WindowRemoved(kwinUUID string) *dbus.Error {
+	id := kwinWindowID(kwinUUID)
+	postWindowEvent(func(t *windowTracker) { t.windowRemoved(id) })
+	return nil
+}
+
+// ActiveWindowChanged is called by the tracker script when another window is activated,
+// or the active one enters or leaves fullscreen
+func (kwinReceiver) ActiveWindowChanged(fullscreen bool) *dbus.Error {
+	postWindowEvent(func(t *windowTracker) {
+		t.activeFullscreen, t.activeKnown = fullscreen, true
+	})
+	return nil
+}
+
+// kwinTrackedWindow parses a window the tracker script reported
+func kwinTrackedWindow(window string) (WindowDetails, bool) {
+	var details struct {
+		WindowDetails
+		UUID string `json:"uuid"`
+	}
+	if err := json.Unmarshal([]byte(window), &details); err != nil {
+		fmt.Printf("[KWin] Bad window from the tracker script: %v\n", err)
+		return WindowDetails{}, false
+	}
+	details.WindowDetails.ID = kwinWindowID(details.UUID)
+	return details.WindowDetails, true
+}
+
+// isKDESession reports whether the session runs on KDE Plasma
+func isKDESession() bool {
+	if os.Getenv("KDE_FULL_SESSION") == "true" {
+		return true
+	}
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if strings.EqualFold(desktop, "KDE") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKWinScripting switches window calls to KWin when it runs scripts
+func checkKWinScripting() bool {
+	kwinScripting = true
+	var out string
+	if err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.List").Store(&out); err != nil {
+		fmt.Printf("[KWin] Scripting check failed: %v\n", err)
+		kwinScripting = false
+		return false
+	}
+	fmt.Printf("[KWin] Placing windows through KWin scripts\n")
+	return true
+}
+
+// KWinScripting reports whether window calls go to KWin instead of GNOME Shell
+func KWinScripting() bool {
+	return kwinScripting
+}
+
+// kwinWindowID returns the window id of a KWin window UUID
+func kwinWindowID(kwinUUID string) uint32 {
+	kwinMu.Lock()
+	defer kwinMu.Unlock()
+	id, ok := kwinIDs[kwinUUID]
+	if !ok {
+		id = uint32(len(kwinIDs) + 1)
+		kwinIDs[kwinUUID] = id
+		kwinUUIDs[id] = kwinUUID
+	}
+	return id
+}
+
+// kwinWindowJS is the common start of the scripts: the windows of this process, as
+// window-calls describes them, and the window to act on
+const kwinWindowJS = `
+const pid = %d;
+const windows = (workspace.windowList ? workspace.windowList() : workspace.clientList())
+	.filter(w => w.pid === pid);
+const describe = w => ({
+	uuid: String(w.internalId), pid: w.pid, title: w.caption, wm_class: String(w.resourceClass),
+	x: w.frameGeometry.x, y: w.frameGeometry.y, width: w.frameGeometry.width, height: w.frameGeometry.height,
+	window_type: w.normalWindow ? 0 : w.utility ? 7 : w.dialog ? 3 : 1,
+	maximized: w.maximizeMode || 0,
+	focus: w === (workspace.activeWindow || workspace.activeClient),
+});
+const target = windows.find(w => String(w.internalId) === %q);
+const call = (method, ...args) => callDBus(%q, %q, %q, method, ...args);
+const reply = (out, failure) => call("Reply", %q, out, failure || "");
+`
+
+// kwinMethodJS are the scripts answering the window-calls methods
+var kwinMethodJS = map[string]string{
+	"List":            `reply(JSON.stringify(windows.map(describe)));`,
+	"Details":         `target ? reply(JSON.stringify(describe(target))) : reply("", "no such window");`,
+	"Move":            `if (target) { const g = target.frameGeometry; target.frameGeometry = {x: %d, y: %d, width: g.width, height: g.height}; } reply("", target ? "" : "no such window");`,
+	"MoveResize":      `if (target) target.frameGeometry = {x: %d, y: %d, width: %d, height: %d}; reply("", target ? "" : "no such window");`,
+	"Resize":          `if (target) { const g = target.frameGeometry; target.frameGeometry = {x: g.x, y: g.y, width: %d, height: %d}; } reply("", target ? "" : "no such window");`,
+	"Activate":        `if (target) { if ("activeWindow" in workspace) workspace.activeWindow = target; else workspace.activeClient = target; } reply("", target ? "" : "no such window");`,
+	"MakeAbove":       `if (target) target.keepAbove = true; reply("", target ? "" : "no such window");`,
+	"UnmakeAbove":     `if (target) target.keepAbove = false; reply("", target ? "" : "no such window");`,
+	"Stick":           `if (target) target.onAllDesktops = true; reply("", target ? "" : "no such window");`,
+	"Unstick":         `if (target) target.onAllDesktops = false; reply("", target ? "" : "no such window");`,
+	"MakeBelow":       `if (target) { target.keepBelow = true; target.skipTaskbar = true; target.skipSwitcher = true; } reply("", target ? "" : "no such window");`,
+	"UnmakeBelow":     `if (target) { target.keepBelow = false; target.skipTaskbar = false; target.skipSwitcher = false; } reply("", target ? "" : "no such window");`,
+	"MoveToWorkspace": `const n = %d; if (target) { if (workspace.desktops) target.desktops = [workspace.desktops[n]]; else target.desktop = n + 1; } reply("", target ? "" : "no such window");`,
+}
+
+// kwinTrackerJS is the script reporting the note windows to the window tracker. It stays
+// loaded, replying once it is connected to KWin's signals.
+const kwinTrackerJS = `
+const track = w => {
+	if (w.pid !== pid) return;
+	const added = () => call("WindowAdded", JSON.stringify(describe(w)));
+	added();
+	w.captionChanged.connect(added);
+	w.frameGeometryChanged.connect(() => call("WindowMoved", JSON.stringify(describe(w))));
+};
+windows.forEach(track);
+(workspace.windowAdded || workspace.clientAdded).connect(track);
+(workspace.windowRemoved || workspace.clientRemoved).connect(w => {
+	if (w.pid === pid) call("WindowRemoved", String(w.internalId));
+});
+let active = null;
+const reportActive = () => call("ActiveWindowChanged", !!(active && active.pid !== pid && active.fullScreen));
+const activated = w => {
+	try { if (active) active.fullScreenChanged.disconnect(reportActive); } catch (e) {}
+	active = w;
+	if (active) active.fullScreenChanged.connect(reportActive);
+	reportActive();
+};
+(workspace.windowActivated || workspace.clientActivated).connect(activated);
+activated(workspace.activeWindow || workspace.activeClient);
+reply("");
+`
+
+// kwinTrackerPlugin names the tracker script in KWin
+const kwinTrackerPlugin = "postnote-tracker"
+
+// startKWinTracker loads the tracker script into KWin, replacing the one a previous run
+// may have left
+func startKWinTracker() error {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*windowCallTimeout)
+	defer cancel()
+	conn.Object("org.kde.KWin", "/Scripting").CallWithContext(ctx, "org.kde.kwin.Scripting.unloadScript", 0, kwinTrackerPlugin)
+	_, err = runKWinScript(ctx, conn, kwinTrackerPlugin, "", kwinTrackerJS)
+	return err
+}
+
+// kwinCall answers a window-calls method through a KWin script, like windowCall
+func kwinCall(ctx context.Context, conn *dbus.Conn, method string, args ...interface{}) *dbus.Call {
+	name := strings.TrimPrefix(method, "org.gnome.Shell.Extensions.Windows.")
+	body, ok := kwinMethodJS[name]
+	if !ok {
+		return &dbus.Call{Err: fmt.Errorf("%s is not available through KWin", method)}
+	}
+	var target string
+	if len(args) > 0 {
+		id, _ := args[0].(uint32)
+		kwinMu.Lock()
+		target = kwinUUIDs[id]
+		kwinMu.Unlock()
+	}
+	if len(args) > 1 {
+		// Geometry of Move, MoveResize and Resize, workspace of MoveToWorkspace
+		body = fmt.Sprintf(body, args[1:]...)
+	}
+
+	out, err := runKWinScript(ctx, conn, "", target, body)
+	if err != nil {
+		return &dbus.Call{Err: fmt.Errorf("%s: %w", method, err)}
+	}
+	switch name {
+	case "List":
+		var windows []struct {
+			WindowInfo
+			UUID string `json:"uuid"`
+		}
+		if err := json.Unmarshal([]byte(out), &windows); err != nil {
+			return &dbus.Call{Err: err}
+		}
+		result := make([]WindowInfo, len(windows))
+		for i, win := range windows {
+			result[i] = win.WindowInfo
+			result[i].ID = kwinWindowID(win.UUID)
+		}
+		data, _ := json.Marshal(result)
+		out = string(data)
+	case "Details":
+		var details struct {
+			WindowDetails
+			UUID string `json:"uuid"`
+		}
+		if err := json.Unmarshal([]byte(out), &details); err != nil {
+			return &dbus.Call{Err: err}
+		}
+		details.WindowDetails.ID = kwinWindowID(details.UUID)
+		data, _ := json.Marshal(details.WindowDetails)
+		out = string(data)
+	}
+	return &dbus.Call{Body: []interface{}{out}}
+}
+
+// runKWinScript loads a script acting on the target window (a KWin UUID) into KWin, runs
+// it and returns what it replied. A script given a plugin name stays loaded under it,
+// others are unloaded once they replied.
+func runKWinScript(ctx context.Context, conn *dbus.Conn, plugin, target, body string) (string, error) {
+	if !kwinExported {
+		if err := conn.Export(kwinReceiver{}, kwinReplyPath, kwinReplyInterface); err != nil {
+			return "", err
+		}
+		kwinExported = true
+	}
+	names := conn.Names()
+	if len(names) == 0 {
+		return "", errors.New("not connected to the session bus")
+	}
+
+	token := uuid.New().String()
+	script := fmt.Sprintf(kwinWindowJS, currentPID, target, names[0], kwinReplyPath, kwinReplyInterface, token) + body
+	f, err := os.CreateTemp("", "postnote-kwin-*.js")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(script)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	reply := make(chan kwinReply, 1)
+	kwinMu.Lock()
+	kwinPending[token] = reply
+	kwinMu.Unlock()
+	defer func() {
+		kwinMu.Lock()
+		delete(kwinPending, token)
+		kwinMu.Unlock()
+	}()
+
+	kwin := conn.Object("org.kde.KWin", "/Scripting")
+	if plugin == "" {
+		plugin = "postnote-" + token
+		defer kwin.Go("org.kde.kwin.Scripting.unloadScript", dbus.FlagNoReplyExpected, nil, plugin)
+	}
+	var id int32
+	if err := kwin.CallWithContext(ctx, "org.kde.kwin.Scripting.loadScript", 0, f.Name(), plugin).Store(&id); err != nil {
+		return "", err
+	}
+	// Plasma 6 and late Plasma 5 put the script under /Scripting, earlier versions at the root
+	err = conn.Object("org.kde.KWin", dbus.ObjectPath(fmt.Sprintf("/Scripting/Script%d", id))).CallWithContext(ctx, "org.kde.kwin.Script.run", 0).Err
+	if err != nil {
+		err = conn.Object("org.kde.KWin", dbus.ObjectPath(fmt.Sprintf("/%d", id))).CallWithContext(ctx, "org.kde.kwin.Script.run", 0).Err
+	}
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case result := <-reply:
+		if result.failure != "" {
+			return "", errors.New(result.failure)
+		}
+		return result.out, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,213 @@
+package stickynotes
+
+/*
+#cgo pkg-config: gtk+-3.0
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <gtk/gtk.h>
+
+// The gtk-layer-shell functions used, looked up at run time so the library is only
+// needed on the compositors it is used on
+static void (*layer_init_for_window)(GtkWindow *);
+static gboolean (*layer_is_supported)(void);
+static void (*layer_set_namespace)(GtkWindow *, const char *);
+static void (*layer_set_layer)(GtkWindow *, int);
+static void (*layer_set_anchor)(GtkWindow *, int, gboolean);
+static void (*layer_set_margin)(GtkWindow *, int, int);
+static void (*layer_set_keyboard_mode)(GtkWindow *, int);
+static void (*layer_set_keyboard_interactivity)(GtkWindow *, gboolean);
+
+static int layer_shell_load(void) {
+	void *lib = dlopen("libgtk-layer-shell.so.0", RTLD_NOW | RTLD_GLOBAL);
+	if (!lib) {
+		return 0;
+	}
+	layer_init_for_window = dlsym(lib, "gtk_layer_init_for_window");
+	layer_is_supported = dlsym(lib, "gtk_layer_is_supported");
+	layer_set_namespace = dlsym(lib, "gtk_layer_set_namespace");
+	layer_set_layer = dlsym(lib, "gtk_layer_set_layer");
+	layer_set_anchor = dlsym(lib, "gtk_layer_set_anchor");
+	layer_set_margin = dlsym(lib, "gtk_layer_set_margin");
+	// Keyboard modes came with 0.6, earlier versions only turn the keyboard on or off
+	layer_set_keyboard_mode = dlsym(lib, "gtk_layer_set_keyboard_mode");
+	layer_set_keyboard_interactivity = dlsym(lib, "gtk_layer_set_keyboard_interactivity");
+	if (!layer_init_for_window || !layer_set_layer || !layer_set_anchor || !layer_set_margin) {
+		return 0;
+	}
+	// Before 0.6 there is no telling whether the compositor has the protocol
+	return !layer_is_supported || layer_is_supported();
+}
+
+static void layer_shell_init(GtkWindow *window, const char *namespace) {
+	layer_init_for_window(window);
+	if (layer_set_namespace) {
+		layer_set_namespace(window, namespace);
+	}
+	// Top left anchored: the margins are the position
+	layer_set_anchor(window, 0, TRUE); // GTK_LAYER_SHELL_EDGE_LEFT
+	layer_set_anchor(window, 2, TRUE); // GTK_LAYER_SHELL_EDGE_TOP
+	if (layer_set_keyboard_mode) {
+		layer_set_keyboard_mode(window, 2); // GTK_LAYER_SHELL_KEYBOARD_MODE_ON_DEMAND
+	} else if (layer_set_keyboard_interactivity) {
+		layer_set_keyboard_interactivity(window, TRUE);
+	}
+}
+
+static void layer_shell_move(GtkWindow *window, int x, int y) {
+	layer_set_margin(window, 0, x); // GTK_LAYER_SHELL_EDGE_LEFT
+	layer_set_margin(window, 2, y); // GTK_LAYER_SHELL_EDGE_TOP
+}
+
+static void layer_shell_set_layer(GtkWindow *window, int layer) {
+	layer_set_layer(window, layer);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// On wlroots compositors (Sway, Hyprland, Wayfire...) there is no window-calls extension
+// to place windows, but there is the layer-shell protocol: note windows become layer
+// surfaces anchored at the top left of the screen, their margins being the position.
+// Unpinned notes are on the bottom layer, over the desktop and under other windows;
+// pinned ones on the top layer, over them. The compositor doesn't move layer surfaces,
+// so notes are moved and resized by the drag handles themselves. The backend is chosen
+// at run time: on Wayland, without window-calls, on a wlroots compositor, when
+// libgtk-layer-shell is installed.
+
+// Layers of gtk-layer-shell (GtkLayerShellLayer)
+const (
+	layerBottom = 1
+	layerTop    = 2
+)
+
+var (
+	layerShellAvailable bool
+	layerShellChecked   bool
+)
+
+// wlrootsEnv are environment variables set by wlroots compositors
+var wlrootsEnv = []string{"SWAYSOCK", "HYPRLAND_INSTANCE_SIGNATURE", "WAYFIRE_SOCKET", "NIRI_SOCKET"}
+
+// wlrootsDesktops are XDG_CURRENT_DESKTOP values of compositors with layer-shell
+var wlrootsDesktops = []string{"sway", "hyprland", "wayfire", "river", "labwc", "niri", "wlroots"}
+
+// isWlrootsCompositor reports whether the session runs on a compositor with layer-shell
+// and without window-calls
+func isWlrootsCompositor() bool {
+	for _, name := range wlrootsEnv {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		for _, known := range wlrootsDesktops {
+			if strings.EqualFold(desktop, known) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsLayerShellAvailable reports whether note windows are placed through gtk-layer-shell.
+// Checked on the first call, after GTK is initialized.
+func IsLayerShellAvailable() bool {
+	if !layerShellChecked {
+		layerShellChecked = true
+		if IsWayland() && !IsWindowCallsAvailable() && !safeMode && isWlrootsCompositor() {
+			layerShellAvailable = C.layer_shell_load() != 0
+			if layerShellAvailable {
+				fmt.Printf("[LayerShell] Placing notes through gtk-layer-shell\n")
+			} else {
+				fmt.Printf("[LayerShell] libgtk-layer-shell isn't installed or not supported by the compositor, notes can't be placed\n")
+			}
+		}
+	}
+	return layerShellAvailable
+}
+
+func nativeWindow(win *gtk.Window) *C.GtkWindow {
+	return (*C.GtkWindow)(unsafe.Pointer(win.Native()))
+}
+
+// initLayerShell makes the note window a layer surface moved and resized by its drag
+// handles. Must be called before the window is shown.
+func (sn *StickyNote) initLayerShell() {
+	namespace := C.CString(AppID)
+	defer C.free(unsafe.Pointer(namespace))
+	C.layer_shell_init(nativeWindow(sn.WinMain), namespace)
+	sn.applyLayer()
+	for _, handle := range []*gtk.EventBox{sn.MoveBox1, sn.MoveBox2, sn.EResizeR} {
+		sn.connectLayerDrag(handle)
+	}
+}
+
+// applyLayer puts the note on the top layer when pinned, on the bottom one otherwise
+func (sn *StickyNote) applyLayer() {
+	layer := layerBottom
+	if sn.Pinned() {
+		layer = layerTop
+	}
+	C.layer_shell_set_layer(nativeWindow(sn.WinMain), C.int(layer))
+}
+
+// layerShellMove places the note's layer surface
+func (sn *StickyNote) layerShellMove(x, y int) {
+	C.layer_shell_move(nativeWindow(sn.WinMain), C.int(max(x, 0)), C.int(max(y, 0)))
+	sn.LastKnownPos = [2]int{max(x, 0), max(y, 0)}
+}
+
+// layerDrag is a move or resize of a layer surface in progress
+type layerDrag struct {
+	resize bool
+	x, y   float64 // Pointer position where the drag started, or was last applied
+}
+
+// beginLayerDrag starts moving or resizing the note with the pointer, which the compositor
+// doesn't do for layer surfaces
+func (sn *StickyNote) beginLayerDrag(event *gdk.EventButton, resize bool) {
+	sn.layerDrag = &layerDrag{resize: resize, x: event.XRoot(), y: event.YRoot()}
+}
+
+// connectLayerDrag follows the pointer over a drag handle while a drag is in progress
+func (sn *StickyNote) connectLayerDrag(handle *gtk.EventBox) {
+	if handle == nil {
+		return
+	}
+	handle.AddEvents(int(gdk.POINTER_MOTION_MASK | gdk.BUTTON_RELEASE_MASK))
+	handle.Connect("motion-notify-event", func(_ *gtk.EventBox, event *gdk.Event) bool {
+		if sn.layerDrag == nil {
+			return false
+		}
+		x, y := gdk.EventMotionNewFromEvent(event).MotionValRoot()
+		dx, dy := int(x-sn.layerDrag.x), int(y-sn.layerDrag.y)
+		if sn.layerDrag.resize {
+			// The surface stays in place, the pointer is measured from the same corner
+			w, h := sn.WinMain.GetSize()
+			sn.WinMain.Resize(max(w+dx, 1), max(h+dy, 1))
+			sn.layerDrag.x, sn.layerDrag.y = x, y
+		} else {
+			// The surface moves with the pointer, which stays where it was on it
+			sn.layerShellMove(sn.LastKnownPos[0]+dx, sn.LastKnownPos[1]+dy)
+		}
+		return true
+	})
+	handle.Connect("button-release-event", func() bool {
+		if sn.layerDrag == nil {
+			return false
+		}
+		sn.layerDrag = nil
+		sn.onConfigure()
+		return true
+	})
+}
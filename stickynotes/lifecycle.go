@@ -0,0 +1,71 @@
+package stickynotes
+
+import (
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Timeouts and idle callbacks scheduled for a note capture the StickyNote and touch
+// its window when they fire. They are registered here so they can all be cancelled
+// when the window is destroyed, instead of running against a dead window.
+
+// timeoutAdd schedules f like glib.TimeoutAdd, bound to the lifetime of the note window
+func (sn *StickyNote) timeoutAdd(milliseconds uint, f func() bool) glib.SourceHandle {
+	var handle glib.SourceHandle
+	handle = glib.TimeoutAdd(milliseconds, func() bool {
+		return sn.runSource(handle, f)
+	})
+	sn.trackSource(handle)
+	return handle
+}
+
+// idleAdd schedules f like glib.IdleAdd, bound to the lifetime of the note window
+func (sn *StickyNote) idleAdd(f func() bool) glib.SourceHandle {
+	var handle glib.SourceHandle
+	handle = glib.IdleAdd(func() bool {
+		return sn.runSource(handle, f)
+	})
+	sn.trackSource(handle)
+	return handle
+}
+
+// removeSource cancels a source scheduled with timeoutAdd or idleAdd
+func (sn *StickyNote) removeSource(handle glib.SourceHandle) {
+	if handle == 0 {
+		return
+	}
+	if _, ok := sn.sources[handle]; ok {
+		delete(sn.sources, handle)
+		glib.SourceRemove(handle)
+	}
+}
+
+func (sn *StickyNote) trackSource(handle glib.SourceHandle) {
+	if sn.sources == nil {
+		sn.sources = make(map[glib.SourceHandle]struct{})
+	}
+	sn.sources[handle] = struct{}{}
+}
+
+func (sn *StickyNote) runSource(handle glib.SourceHandle, f func() bool) bool {
+	if sn.WinMain == nil {
+		delete(sn.sources, handle)
+		return false
+	}
+	again := f()
+	if !again {
+		delete(sn.sources, handle)
+	}
+	return again
+}
+
+// onDestroy cancels everything still pending for the window and drops the references
+// to it, so a later Show() rebuilds the note instead of using the destroyed window
+func (sn *StickyNote) onDestroy() {
+	for handle := range sn.sources {
+		glib.SourceRemove(handle)
+	}
+	sn.sources = nil
+	sn.saveTimeoutID = 0
+	sn.releaseWindowID()
+	sn.WinMain = nil
+}
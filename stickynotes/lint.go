@@ -0,0 +1,88 @@
+package stickynotes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default thresholds for note hygiene hints, overridable via NoteSet properties
+const (
+	DefaultLintStaleMonths = 3
+	DefaultLintMaxLength   = 2000
+)
+
+// LintWarning is a content hygiene hint for a single note
+type LintWarning struct {
+	Kind    string // "stale", "overlong" or "duplicate"
+	Message string
+}
+
+// LintEnabled reports whether note hygiene hints are turned on in settings
+func (ns *NoteSet) LintEnabled() bool {
+	enabled, _ := ns.Properties["lint_enabled"].(bool)
+	return enabled
+}
+
+// lintIntProperty reads a positive integer setting, falling back to def
+func (ns *NoteSet) lintIntProperty(prop string, def int) int {
+	if val, ok := ns.Properties[prop].(float64); ok && val > 0 {
+		return int(val)
+	}
+	if val, ok := ns.Properties[prop].(int); ok && val > 0 {
+		return val
+	}
+	return def
+}
+
+// LintNote returns the hygiene warnings for a note: untouched for too long,
+// too long to be a sticky note, or identical to another note
+func (ns *NoteSet) LintNote(n *Note) []LintWarning {
+	var warnings []LintWarning
+
+	staleMonths := ns.lintIntProperty("lint_stale_months", DefaultLintStaleMonths)
+	if !n.LastModified.IsZero() && n.LastModified.Before(time.Now().AddDate(0, -staleMonths, 0)) {
+		warnings = append(warnings, LintWarning{
+			Kind:    "stale",
+			Message: fmt.Sprintf("Not edited since %s", FormatDate(n.LastModified)),
+		})
+	}
+
+	maxLength := ns.lintIntProperty("lint_max_length", DefaultLintMaxLength)
+	if length := len([]rune(n.Body)); length > maxLength {
+		warnings = append(warnings, LintWarning{
+			Kind:    "overlong",
+			Message: fmt.Sprintf("%d characters long, consider archiving it somewhere else", length),
+		})
+	}
+
+	if body := normalizeBody(n.Body); body != "" {
+		for _, other := range ns.Notes {
+			if other != n && normalizeBody(other.Body) == body {
+				warnings = append(warnings, LintWarning{
+					Kind:    "duplicate",
+					Message: fmt.Sprintf("Same content as note %s", other.UUID[:8]),
+				})
+				break
+			}
+		}
+	}
+
+	return warnings
+}
+
+// LintReport returns the warnings of every note that has at least one
+func (ns *NoteSet) LintReport() map[*Note][]LintWarning {
+	report := make(map[*Note][]LintWarning)
+	for _, note := range ns.Notes {
+		if warnings := ns.LintNote(note); len(warnings) > 0 {
+			report[note] = warnings
+		}
+	}
+	return report
+}
+
+// normalizeBody lowercases the body and collapses whitespace for duplicate detection
+func normalizeBody(body string) string {
+	return strings.ToLower(strings.Join(strings.Fields(body), " "))
+}
@@ -0,0 +1,67 @@
+package stickynotes
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// listItemPattern matches a bullet ("-", "*") or numbered ("1.") list marker at the start of a line
+var listItemPattern = regexp.MustCompile(`^(\s*)([-*]|\d+\.)(\s+)`)
+
+// continueList works out what Enter should do on a list line.
+// It returns the marker to start the next line with, or end=true when the item is
+// empty and the list should be terminated. ok is false for lines that aren't list items.
+func continueList(line string) (next string, end bool, ok bool) {
+	m := listItemPattern.FindStringSubmatchIndex(line)
+	if m == nil {
+		return "", false, false
+	}
+	indent := line[m[2]:m[3]]
+	marker := line[m[4]:m[5]]
+	space := line[m[6]:m[7]]
+
+	if m[1] == len(line) {
+		return "", true, true
+	}
+
+	if n, err := strconv.Atoi(marker[:len(marker)-1]); err == nil {
+		marker = strconv.Itoa(n+1) + "."
+	}
+	return indent + marker + space, false, true
+}
+
+// onListKeyPress continues bullet and numbered lists when Enter is pressed in a note
+func (sn *StickyNote) onListKeyPress(tv *gtk.TextView, event *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+	if keyEvent.KeyVal() != gdk.KEY_Return && keyEvent.KeyVal() != gdk.KEY_KP_Enter {
+		return false
+	}
+	// Shift+Enter inserts a plain line break
+	if gdk.ModifierType(keyEvent.State())&(gdk.SHIFT_MASK|gdk.CONTROL_MASK|gdk.MOD1_MASK) != 0 {
+		return false
+	}
+	if sn.InViewMode() || sn.BBody == nil || sn.BBody.GetHasSelection() {
+		return false
+	}
+
+	cursor := sn.BBody.GetIterAtMark(sn.BBody.GetInsert())
+	lineStart := sn.BBody.GetIterAtLine(cursor.GetLine())
+	next, end, ok := continueList(lineStart.GetText(cursor))
+	if !ok {
+		return false
+	}
+
+	sn.BBody.BeginUserAction()
+	defer sn.BBody.EndUserAction()
+	if end {
+		// Enter on an empty item removes the marker and ends the list
+		sn.BBody.Delete(lineStart, cursor)
+		return true
+	}
+	sn.BBody.InsertAtCursor("\n" + next)
+	tv.ScrollMarkOnscreen(sn.BBody.GetInsert())
+	return true
+}
@@ -0,0 +1,344 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Front-matter values are written as JSON, which is valid YAML flow syntax, so the
+// files stay readable by YAML tools (Obsidian, static site generators).
+const frontmatterDelimiter = "---"
+
+// frontmatterPropertyKeys are note properties promoted to their own front-matter key
+var frontmatterPropertyKeys = []string{"position", "size", "locked", "bgcolor_hsv"}
+
+// ToMarkdown renders the note as Markdown with YAML front-matter carrying its metadata
+// (uuid, category, color, position, timestamps, tags), so it can be imported back losslessly
+func (n *Note) ToMarkdown() string {
+	props := n.Properties
+	if n.GUI != nil {
+		n.GUI.UpdateNote()
+		props = n.GUI.Properties()
+	}
+
+	var sb strings.Builder
+	sb.WriteString(frontmatterDelimiter + "\n")
+	writeFrontmatter(&sb, "uuid", n.UUID)
+	writeFrontmatter(&sb, "title", n.FirstLine())
+	catName := ""
+	if n.NoteSet.HasCategory(n.Category) {
+		catName = n.NoteSet.CategoryName(n.Category)
+	}
+	writeFrontmatter(&sb, "category", catName)
+	writeFrontmatter(&sb, "category_id", n.Category)
+
+	// Effective background color of the note, as hex for humans and HSV for re-import
+	bgHSV, _ := floatList(n.CatProp("bgcolor_hsv"))
+	if override, ok := floatList(props["bgcolor_hsv"]); ok {
+		bgHSV = override
+	}
+	if len(bgHSV) >= 3 {
+		rgb := hsvToRGB(bgHSV[0], bgHSV[1], bgHSV[2])
+		writeFrontmatter(&sb, "color", rgbToHex(rgb[0], rgb[1], rgb[2]))
+	}
+	if textColor, ok := floatList(n.CatProp("textcolor")); ok && len(textColor) >= 3 {
+		writeFrontmatter(&sb, "text_color", rgbToHex(textColor[0], textColor[1], textColor[2]))
+	}
+
+	writeFrontmatter(&sb, "last_modified", n.LastModified.Format("2006-01-02T15:04:05"))
+	tags := n.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	writeFrontmatter(&sb, "tags", tags)
+	if len(n.Formatting) > 0 {
+		writeFrontmatter(&sb, "formatting", n.Formatting)
+	}
+
+	for _, key := range frontmatterPropertyKeys {
+		if val, ok := props[key]; ok {
+			writeFrontmatter(&sb, key, val)
+		}
+	}
+
+	// Any other per-note properties are kept together so nothing is lost
+	rest := make(map[string]interface{})
+	for key, val := range props {
+		if !isFrontmatterPropertyKey(key) {
+			rest[key] = val
+		}
+	}
+	if len(rest) > 0 {
+		writeFrontmatter(&sb, "properties", rest)
+	}
+
+	sb.WriteString(frontmatterDelimiter + "\n")
+	// The trailing newline is stripped again by ParseMarkdownNote
+	sb.WriteString(n.Body + "\n")
+	return sb.String()
+}
+
+func writeFrontmatter(sb *strings.Builder, key string, val interface{}) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(sb, "%s: %s\n", key, data)
+}
+
+func isFrontmatterPropertyKey(key string) bool {
+	for _, k := range frontmatterPropertyKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMarkdownNote splits a Markdown document into its front-matter and body.
+// Only the flat "key: value" subset of YAML written by ToMarkdown (plus plain
+// unquoted strings and simple [a, b] lists) is understood.
+func ParseMarkdownNote(data string) (map[string]interface{}, string) {
+	meta := make(map[string]interface{})
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+
+	if !strings.HasPrefix(data, frontmatterDelimiter+"\n") {
+		return meta, data
+	}
+	rest := data[len(frontmatterDelimiter)+1:]
+	end := strings.Index(rest, "\n"+frontmatterDelimiter+"\n")
+	if end < 0 {
+		if strings.HasSuffix(rest, "\n"+frontmatterDelimiter) {
+			end = len(rest) - len(frontmatterDelimiter) - 1
+		} else {
+			return meta, data
+		}
+	}
+	header := rest[:end]
+	body := ""
+	if bodyStart := end + len(frontmatterDelimiter) + 2; bodyStart <= len(rest) {
+		body = rest[bodyStart:]
+	}
+
+	for _, line := range strings.Split(header, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		meta[strings.TrimSpace(key)] = parseFrontmatterValue(strings.TrimSpace(value))
+	}
+
+	// ToMarkdown always ends the body with a newline
+	body = strings.TrimSuffix(body, "\n")
+	return meta, body
+}
+
+func parseFrontmatterValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		return parsed
+	}
+	// Plain YAML flow list: [a, b]
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		items := make([]interface{}, 0)
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			if item = strings.Trim(strings.TrimSpace(item), `"'`); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return strings.Trim(value, `"'`)
+}
+
+// ImportMarkdown adds (or updates, when the uuid already exists) a note from a Markdown
+// document with front-matter. Categories are matched by id, then by name, and created
+// with the exported color if they don't exist yet.
+func (ns *NoteSet) ImportMarkdown(data string) error {
+	meta, body := ParseMarkdownNote(data)
+	content := map[string]interface{}{
+		"body": body,
+	}
+	if uuidStr, ok := meta["uuid"].(string); ok && uuidStr != "" {
+		content["uuid"] = uuidStr
+	}
+	if lastMod, ok := meta["last_modified"].(string); ok {
+		content["last_modified"] = lastMod
+	}
+	if tags, ok := meta["tags"].([]interface{}); ok {
+		content["tags"] = tags
+	}
+	if formatting, ok := meta["formatting"].([]interface{}); ok {
+		content["formatting"] = formatting
+	}
+
+	props := make(map[string]interface{})
+	if rest, ok := meta["properties"].(map[string]interface{}); ok {
+		for key, val := range rest {
+			props[key] = val
+		}
+	}
+	for _, key := range frontmatterPropertyKeys {
+		if val, ok := meta[key]; ok {
+			props[key] = val
+		}
+	}
+	content["properties"] = props
+
+	categories := make(map[string]interface{})
+	content["cat"] = ns.resolveImportedCategory(meta, categories)
+
+	jdata, err := json.Marshal(map[string]interface{}{
+		"notes":      []interface{}{content},
+		"categories": categories,
+	})
+	if err != nil {
+		return err
+	}
+	return ns.Merge(string(jdata))
+}
+
+// resolveImportedCategory finds the category referenced by imported front-matter,
+// adding a new category definition to newCats when none matches
+func (ns *NoteSet) resolveImportedCategory(meta map[string]interface{}, newCats map[string]interface{}) string {
+	if catID, ok := meta["category_id"].(string); ok && catID != "" && ns.HasCategory(catID) {
+		return catID
+	}
+	name, _ := meta["category"].(string)
+	if name == "" {
+		return ""
+	}
+
+	if cid, ok := ns.categoryByName(name); ok {
+		return cid
+	}
+
+	// Unknown category: recreate it, keeping the exported id when there is one
+	catID, _ := meta["category_id"].(string)
+	if catID == "" {
+		catID = "md-" + strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	}
+	catData := map[string]interface{}{"name": name}
+	if hex, ok := meta["color"].(string); ok {
+		if rgb, ok := hexToRGB(hex); ok {
+			hsv := rgbToHSV(rgb[0], rgb[1], rgb[2])
+			catData["bgcolor_hsv"] = []float64{hsv[0], hsv[1], hsv[2]}
+		}
+	}
+	if hex, ok := meta["text_color"].(string); ok {
+		if rgb, ok := hexToRGB(hex); ok {
+			catData["textcolor"] = []float64{rgb[0], rgb[1], rgb[2]}
+		}
+	}
+	newCats[catID] = catData
+	return catID
+}
+
+// hexToRGB parses a "#rrggbb" color into [0, 1] RGB components
+func hexToRGB(hex string) ([3]float64, bool) {
+	var r, g, b int
+	if len(hex) != 7 || hex[0] != '#' {
+		return [3]float64{}, false
+	}
+	if _, err := fmt.Sscanf(hex[1:], "%02x%02x%02x", &r, &g, &b); err != nil {
+		return [3]float64{}, false
+	}
+	return [3]float64{float64(r) / 255, float64(g) / 255, float64(b) / 255}, true
+}
+
+// markdownFileName suggests a file name for the note, from its first line
+func markdownFileName(n *Note) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '-'
+		}
+		return r
+	}, n.FirstLine())
+	if runes := []rune(name); len(runes) > 60 {
+		name = string(runes[:60])
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = "note"
+	}
+	return name + ".md"
+}
+
+// ExportMarkdownDir writes every note to dir as a Markdown file with front-matter, for
+// note-taking apps and static site tools. Files are named after the note's title, or its
+// UUID when the title is empty or taken by another note. Files already in dir are kept,
+// the note gets "name (2).md" etc. instead. Returns the number written.
+func (ns *NoteSet) ExportMarkdownDir(dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	used := make(map[string]bool)
+	written := 0
+	for _, note := range ns.Notes {
+		name := markdownFileName(note)
+		if strings.TrimSpace(note.FirstLine()) == "" || used[strings.ToLower(name)] {
+			name = note.UUID + ".md"
+		}
+		used[strings.ToLower(name)] = true
+		if err := writeNewFile(dir, name, []byte(note.ToMarkdown())); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// writeNewFile writes data to a new file in dir named name, or "name (2).ext" etc. when
+// the name is taken
+func writeNewFile(dir, name string, data []byte) error {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// onExportNote writes the note as Markdown with front-matter to a file of the user's choice
+func (sn *StickyNote) onExportNote() {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Export Note", sn.WinMain, gtk.FILE_CHOOSER_ACTION_SAVE, "Cancel", gtk.RESPONSE_CANCEL, "Save", gtk.RESPONSE_ACCEPT)
+	dialog.SetDoOverwriteConfirmation(true)
+	dialog.SetCurrentName(markdownFileName(sn.Note))
+	if filter, err := gtk.FileFilterNew(); err == nil {
+		filter.SetName("Markdown")
+		filter.AddPattern("*.md")
+		filter.AddPattern("*.markdown")
+		dialog.AddFilter(filter)
+	}
+	response := dialog.Run()
+	file := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT || file == "" {
+		return
+	}
+	if err := os.WriteFile(file, []byte(sn.Note.ToMarkdown()), 0644); err != nil {
+		dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error exporting note.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+	sn.NoteSet.RecordUsage(UsageExport)
+}
@@ -0,0 +1,173 @@
+package stickynotes
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// MergeInto appends the body of n to target and deletes n. Tags, formatting and
+// attachments move to target along with the text.
+func (n *Note) MergeInto(target *Note) {
+	if n.GUI != nil && n.GUI.WinMain != nil {
+		n.GUI.UpdateNote()
+	}
+	if target.GUI != nil && target.GUI.WinMain != nil {
+		target.GUI.UpdateNote()
+	}
+	n.NoteSet.backupBefore(BackupMerge)
+
+	// Keep a blank line between the two bodies
+	sep := ""
+	if target.Body != "" && n.Body != "" {
+		sep = "\n\n"
+		if strings.HasSuffix(target.Body, "\n") {
+			sep = "\n"
+		}
+	}
+	offset := utf8.RuneCountInString(target.Body + sep)
+	for _, r := range n.Formatting {
+		target.Formatting = append(target.Formatting, FormatRange{Style: r.Style, Start: r.Start + offset, End: r.End + offset})
+	}
+	target.Body += sep + n.Body
+	for _, tag := range n.Tags {
+		if !target.HasTag(tag) {
+			target.Tags = append(target.Tags, tag)
+		}
+	}
+	for _, name := range n.Attachments() {
+		if _, err := target.Attach(filepath.Join(n.AttachmentDir(), name)); err != nil {
+			fmt.Printf("[Merge] Failed to move attachment %s: %v\n", name, err)
+		}
+	}
+	target.LastModified = time.Now()
+
+	if target.GUI != nil && target.GUI.WinMain != nil {
+		target.GUI.reloadNote()
+	}
+
+	gui := n.GUI
+	n.deleteAs(AuditMerge, "into "+target.UUID)
+	if gui != nil {
+		if gui.Editor != nil {
+			gui.Editor.Destroy()
+		}
+		if gui.WinMain != nil {
+			gui.WinMain.Destroy()
+		}
+		n.GUI = nil
+	}
+}
+
+// reloadNote shows the note's body, formatting, tags and attachments again after
+// they were changed outside of the window
+func (sn *StickyNote) reloadNote() {
+	sn.BBody.SetText(sn.Note.Body)
+	sn.applyFormatting()
+	if sn.ETags != nil {
+		sn.ETags.SetText(strings.Join(sn.Note.Tags, ", "))
+		sn.ETags.SetVisible(len(sn.Note.Tags) > 0)
+	}
+	sn.RefreshAttachments()
+}
+
+// confirmMerge asks whether to merge the note into target, and merges if so
+func (sn *StickyNote) confirmMerge(target *Note) {
+	dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Merge this note into \"%s\"?", noteLabel(target))
+	dialog.FormatSecondaryText("The text is appended to the other note and this note is deleted.")
+	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Merge", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+
+	if response == gtk.RESPONSE_ACCEPT {
+		sn.Note.MergeInto(target)
+	}
+}
+
+// checkDroppedOnNote offers to merge the note when it was dragged onto another note,
+// i.e. when its center ends up inside another visible note's window. Called once the
+// position settles after a drag.
+func (sn *StickyNote) checkDroppedOnNote() {
+	pos := sn.LastKnownPos
+	if pos == sn.dropCheckPos || sn.Locked || sn.LastKnownSize[0] <= 1 {
+		return
+	}
+	sn.dropCheckPos = pos
+
+	cx := pos[0] + sn.LastKnownSize[0]/2
+	cy := pos[1] + sn.LastKnownSize[1]/2
+	for _, other := range sn.NoteSet.Notes {
+		if other == sn.Note || other.GUI == nil || other.GUI.WinMain == nil || !other.GUI.WinMain.GetVisible() {
+			continue
+		}
+		x, y, w, h := other.GUI.windowRect()
+		if w > 1 && cx >= x && cx < x+w && cy >= y && cy < y+h {
+			sn.confirmMerge(other)
+			return
+		}
+	}
+}
+
+// windowRect returns the note window's position and size, from the window backend when
+// it knows them
+func (sn *StickyNote) windowRect() (int, int, int, int) {
+	if pos, size, ok := Backend().Geometry(sn); ok {
+		return pos[0], pos[1], size[0], size[1]
+	}
+	return sn.LastKnownPos[0], sn.LastKnownPos[1], sn.LastKnownSize[0], sn.LastKnownSize[1]
+}
+
+// onMergeInto lets the user pick another note to merge this note into
+func (sn *StickyNote) onMergeInto() {
+	var targets []*Note
+	for _, note := range sn.NoteSet.Notes {
+		if note != sn.Note {
+			targets = append(targets, note)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	dialog, err := gtk.DialogNewWithButtons("Merge Into", sn.WinMain, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL}, []interface{}{"Merge", gtk.RESPONSE_ACCEPT})
+	if err != nil {
+		return
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.SetMarginTop(12)
+
+	label, _ := gtk.LabelNew("Append this note to:")
+	label.SetHAlign(gtk.ALIGN_START)
+	content.PackStart(label, false, false, 0)
+	combo, _ := gtk.ComboBoxTextNew()
+	for _, note := range targets {
+		combo.Append(note.UUID, fmt.Sprintf("%s (%s)", noteLabel(note), note.UUID[:8]))
+	}
+	combo.SetActive(0)
+	content.PackStart(combo, false, false, 0)
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	targetUUID := combo.GetActiveID()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	for _, note := range targets {
+		if note.UUID == targetUUID {
+			sn.Note.MergeInto(note)
+			return
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package stickynotes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Notes sync with Nextcloud Notes through its REST API (version 1), signing in with the
+// user name and an app password (Nextcloud → Settings → Security → Devices & sessions).
+// The password is kept in the keyring, the server, user name and whether sync is on in
+// the "nextcloud_server", "nextcloud_user" and "nextcloud_enabled" properties.
+// Categories are matched by name, a note's title is its first line.
+
+const (
+	// nextcloudNotesAPI is the path of the notes on the server
+	nextcloudNotesAPI = "/index.php/apps/notes/api/v1/notes"
+	// nextcloudTimeout limits each request
+	nextcloudTimeout = 30 * time.Second
+	// nextcloudTitleLength limits the title sent with a note, the server names its file after it
+	nextcloudTitleLength = 100
+)
+
+// NextcloudBackend syncs with the Notes app of a Nextcloud server
+type NextcloudBackend struct {
+	Server   string
+	User     string
+	Password string
+	client   *http.Client
+}
+
+// NewNextcloudBackend creates a backend for the server, signing in as user
+func NewNextcloudBackend(server, user, password string) *NextcloudBackend {
+	return &NextcloudBackend{
+		Server:   normalizeServerURL(server),
+		User:     user,
+		Password: password,
+		client:   &http.Client{Timeout: nextcloudTimeout},
+	}
+}
+
+// nextcloudNote is a note as the Notes API sends and takes it
+type nextcloudNote struct {
+	ID       int64  `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+	Modified int64  `json:"modified,omitempty"`
+	ReadOnly bool   `json:"readonly,omitempty"`
+}
+
+func (nn nextcloudNote) remote() RemoteNote {
+	return RemoteNote{ID: nn.ID, Content: nn.Content, Category: nn.Category, Modified: nn.Modified, ReadOnly: nn.ReadOnly}
+}
+
+// nextcloudNoteOf converts a note to what the Notes API takes
+func nextcloudNoteOf(note RemoteNote) nextcloudNote {
+	title := ""
+	for _, line := range strings.Split(note.Content, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "# ")); line != "" {
+			title = line
+			break
+		}
+	}
+	if runes := []rune(title); len(runes) > nextcloudTitleLength {
+		title = string(runes[:nextcloudTitleLength])
+	}
+	return nextcloudNote{Title: title, Content: note.Content, Category: note.Category}
+}
+
+// Name names the server in "edited on" labels
+func (nc *NextcloudBackend) Name() string {
+	return "Nextcloud"
+}
+
+// List returns all the notes on the server
+func (nc *NextcloudBackend) List() ([]RemoteNote, error) {
+	var notes []nextcloudNote
+	if err := nc.do(http.MethodGet, "", nil, &notes); err != nil {
+		return nil, err
+	}
+	remote := make([]RemoteNote, len(notes))
+	for i, nn := range notes {
+		remote[i] = nn.remote()
+	}
+	return remote, nil
+}
+
+// Create adds a note on the server, returning it with its id
+func (nc *NextcloudBackend) Create(note RemoteNote) (RemoteNote, error) {
+	var created nextcloudNote
+	err := nc.do(http.MethodPost, "", nextcloudNoteOf(note), &created)
+	return created.remote(), err
+}
+
+// Update replaces the text and category of a note on the server
+func (nc *NextcloudBackend) Update(note RemoteNote) (RemoteNote, error) {
+	var updated nextcloudNote
+	err := nc.do(http.MethodPut, fmt.Sprintf("/%d", note.ID), nextcloudNoteOf(note), &updated)
+	return updated.remote(), err
+}
+
+// Delete deletes a note on the server
+func (nc *NextcloudBackend) Delete(id int64) error {
+	return nc.do(http.MethodDelete, fmt.Sprintf("/%d", id), nil, nil)
+}
+
+// do sends a request to the Notes API with in as its JSON body (optional), and decodes
+// the reply into out (optional)
+func (nc *NextcloudBackend) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, nc.Server+nextcloudNotesAPI+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(nc.User, nc.Password)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := nc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return errors.New("the server refused the user name or app password")
+	case resp.StatusCode == http.StatusNotFound && method == http.MethodDelete:
+		return nil // Already gone
+	case resp.StatusCode == http.StatusNotFound && path == "":
+		return fmt.Errorf("no Notes app at %s", nc.Server)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%s %s: %s", method, nextcloudNotesAPI+path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unexpected reply from %s: %w", nc.Server, err)
+	}
+	return nil
+}
+
+// normalizeServerURL completes a server address typed without its scheme
+func normalizeServerURL(server string) string {
+	server = strings.TrimRight(strings.TrimSpace(server), "/")
+	if server != "" && !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	return server
+}
+
+// NextcloudAccount returns the server and user name notes are synced with
+func (ns *NoteSet) NextcloudAccount() (server, user string) {
+	server, _ = ns.Properties["nextcloud_server"].(string)
+	user, _ = ns.Properties["nextcloud_user"].(string)
+	return normalizeServerURL(server), strings.TrimSpace(user)
+}
+
+// remoteAccount identifies the account notes are synced with, recorded with each link
+// to a note on the server
+func (ns *NoteSet) remoteAccount() string {
+	server, user := ns.NextcloudAccount()
+	return user + "@" + server
+}
+
+// RemoteSyncEnabled reports whether sync is on and has a server and user name
+func (ns *NoteSet) RemoteSyncEnabled() bool {
+	enabled, _ := ns.Properties["nextcloud_enabled"].(bool)
+	server, user := ns.NextcloudAccount()
+	return enabled && server != "" && user != ""
+}
+
+// nextcloudKeyringAttributes identify the app password of a server and user in the keyring
+func nextcloudKeyringAttributes(server, user string) []string {
+	return []string{"application", keyringApplication, "nextcloud-server", server, "nextcloud-user", user}
+}
+
+// SetNextcloudPassword remembers the app password of the configured account in the keyring
+func (ns *NoteSet) SetNextcloudPassword(password string) error {
+	server, user := ns.NextcloudAccount()
+	if server == "" || user == "" {
+		return errors.New("enter the server and user name first")
+	}
+	if err := keyringStore("PostNote Nextcloud app password", password, nextcloudKeyringAttributes(server, user)); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errors.New("secret-tool (libsecret) is not installed, the app password can't be stored")
+		}
+		return err
+	}
+	return nil
+}
+
+// hasNextcloudPassword reports whether the keyring has the configured account's app password
+func (ns *NoteSet) hasNextcloudPassword() bool {
+	server, user := ns.NextcloudAccount()
+	return server != "" && user != "" && keyringLookup(nextcloudKeyringAttributes(server, user)) != ""
+}
+
+// syncBackend returns the server notes are synced with, errSyncNotConfigured when none is
+func (ns *NoteSet) syncBackend() (SyncBackend, error) {
+	if !ns.RemoteSyncEnabled() {
+		return nil, errSyncNotConfigured
+	}
+	server, user := ns.NextcloudAccount()
+	password := keyringLookup(nextcloudKeyringAttributes(server, user))
+	if password == "" {
+		return nil, fmt.Errorf("no app password for %s in the keyring, enter it in Settings → Sync", user)
+	}
+	return NewNextcloudBackend(server, user, password), nil
+}
+
+// syncSettings builds the Nextcloud section of the Sync settings
+func (sd *SettingsDialog) syncSettings() *gtk.Box {
+	ns := sd.NoteSet
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+
+	chkEnable, _ := gtk.CheckButtonNewWithLabel("Sync notes with Nextcloud Notes")
+	chkEnable.SetTooltipText("Notes, their categories and deletions are synced both ways every few minutes. When a note changed on both sides, the last change wins and the other text goes to the note's History.")
+	enabled, _ := ns.Properties["nextcloud_enabled"].(bool)
+	chkEnable.SetActive(enabled)
+	box.PackStart(chkEnable, false, false, 0)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(6)
+	grid.SetColumnSpacing(12)
+	grid.SetMarginStart(24)
+	server, user := ns.NextcloudAccount()
+	eServer, _ := gtk.EntryNew()
+	eServer.SetPlaceholderText("https://cloud.example.com")
+	eServer.SetText(server)
+	eServer.SetHExpand(true)
+	addGridRow(grid, 0, "Server", eServer)
+	eUser, _ := gtk.EntryNew()
+	eUser.SetText(user)
+	addGridRow(grid, 1, "User name", eUser)
+	ePassword, _ := gtk.EntryNew()
+	ePassword.SetVisibility(false)
+	ePassword.SetInputPurpose(gtk.INPUT_PURPOSE_PASSWORD)
+	showPasswordHint := func() {
+		if ns.hasNextcloudPassword() {
+			ePassword.SetPlaceholderText("Stored in the keyring")
+		} else {
+			ePassword.SetPlaceholderText("Nextcloud → Settings → Security")
+		}
+	}
+	showPasswordHint()
+	addGridRow(grid, 2, "App password", ePassword)
+	box.PackStart(grid, false, false, 0)
+
+	actions, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 12)
+	bSync, _ := gtk.ButtonNewWithLabel("Sync Now")
+	actions.PackStart(bSync, false, false, 0)
+	lStatus, _ := gtk.LabelNew(ns.RemoteSyncStatus())
+	lStatus.SetXAlign(0)
+	lStatus.SetLineWrap(true)
+	actions.PackStart(lStatus, true, true, 0)
+	actions.SetMarginStart(24)
+	box.PackStart(actions, false, false, 0)
+
+	updateSensitive := func() {
+		grid.SetSensitive(chkEnable.GetActive())
+		actions.SetSensitive(chkEnable.GetActive())
+	}
+	updateSensitive()
+
+	// Server and user name are saved when the dialog closes
+	chkEnable.Connect("toggled", func() {
+		ns.Properties["nextcloud_enabled"] = chkEnable.GetActive()
+		ns.SetActionEnabled("sync-now", ns.SyncEnabled())
+		updateSensitive()
+	})
+	eServer.Connect("changed", func() {
+		text, _ := eServer.GetText()
+		ns.Properties["nextcloud_server"] = strings.TrimSpace(text)
+		ns.SetActionEnabled("sync-now", ns.SyncEnabled())
+	})
+	eUser.Connect("changed", func() {
+		text, _ := eUser.GetText()
+		ns.Properties["nextcloud_user"] = strings.TrimSpace(text)
+		ns.SetActionEnabled("sync-now", ns.SyncEnabled())
+	})
+
+	// A typed password goes to the keyring, the entry is cleared
+	storePassword := func() bool {
+		password, _ := ePassword.GetText()
+		if password == "" {
+			return true
+		}
+		if err := ns.SetNextcloudPassword(password); err != nil {
+			dialog := gtk.MessageDialogNew(sd.WSettings, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error storing the app password.")
+			dialog.FormatSecondaryText("%s", err.Error())
+			dialog.Run()
+			dialog.Destroy()
+			return false
+		}
+		ePassword.SetText("")
+		showPasswordHint()
+		return true
+	}
+	ePassword.Connect("activate", func() { storePassword() })
+	ePassword.Connect("focus-out-event", func() bool {
+		storePassword()
+		return false
+	})
+
+	// The sync can finish after the dialog is closed
+	closed := false
+	box.Connect("destroy", func() { closed = true })
+	bSync.Connect("clicked", func() {
+		if !storePassword() {
+			return
+		}
+		bSync.SetSensitive(false)
+		lStatus.SetText("Syncing…")
+		ns.SyncRemote(func(result SyncResult, err error) {
+			if closed {
+				return
+			}
+			bSync.SetSensitive(true)
+			if err != nil {
+				lStatus.SetText(ns.RemoteSyncStatus())
+				return
+			}
+			lStatus.SetText(result.String())
+		})
+	})
+
+	box.ShowAll()
+	return box
+}
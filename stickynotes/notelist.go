@@ -0,0 +1,259 @@
+package stickynotes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Sort orders of the note list
+const (
+	noteListSortModified = "modified"
+	noteListSortTitle    = "title"
+	noteListSortCategory = "category"
+)
+
+// NoteListWindow lists all notes, to show, hide, delete or raise them
+type NoteListWindow struct {
+	NoteSet  *NoteSet
+	Window   *gtk.Window
+	List     *gtk.ListBox
+	CbSort   *gtk.ComboBoxText
+	CbFilter *gtk.ComboBoxText
+	rows     map[int]*Note // ListBox row index to note
+}
+
+// NewNoteListWindow opens the list of all notes
+func NewNoteListWindow(noteset *NoteSet) *NoteListWindow {
+	lw := &NoteListWindow{
+		NoteSet: noteset,
+		rows:    make(map[int]*Note),
+	}
+
+	lw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	lw.Window.SetTitle("All Notes")
+	lw.Window.SetDefaultSize(460, 420)
+	lw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	// Sort and filter
+	toolbar, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	sortLabel, _ := gtk.LabelNew("Sort by")
+	toolbar.PackStart(sortLabel, false, false, 0)
+	lw.CbSort, _ = gtk.ComboBoxTextNew()
+	lw.CbSort.Append(noteListSortModified, "Modified")
+	lw.CbSort.Append(noteListSortTitle, "Title")
+	lw.CbSort.Append(noteListSortCategory, "Category")
+	lw.CbSort.SetActiveID(noteListSortModified)
+	lw.CbSort.Connect("changed", lw.refresh)
+	toolbar.PackStart(lw.CbSort, false, false, 0)
+	lw.CbFilter, _ = gtk.ComboBoxTextNew()
+	lw.CbFilter.Append("", "All categories")
+	cats := make([]string, 0, len(noteset.Categories))
+	for cat := range noteset.Categories {
+		cats = append(cats, cat)
+	}
+	sort.Slice(cats, func(i, j int) bool {
+		return strings.ToLower(noteset.CategoryName(cats[i])) < strings.ToLower(noteset.CategoryName(cats[j]))
+	})
+	for _, cat := range cats {
+		lw.CbFilter.Append(cat, noteset.CategoryName(cat))
+	}
+	lw.CbFilter.SetActiveID("")
+	lw.CbFilter.Connect("changed", lw.refresh)
+	toolbar.PackEnd(lw.CbFilter, false, false, 0)
+	box.PackStart(toolbar, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	lw.List, _ = gtk.ListBoxNew()
+	lw.List.SetSelectionMode(gtk.SELECTION_MULTIPLE)
+	lw.List.Connect("row-activated", func(list *gtk.ListBox, row *gtk.ListBoxRow) {
+		if note, ok := lw.rows[row.GetIndex()]; ok {
+			lw.raise(note)
+		}
+	})
+	scrolled.Add(lw.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bDelete, _ := gtk.ButtonNewWithLabel("Delete")
+	bDelete.Connect("clicked", lw.onDelete)
+	buttons.PackStart(bDelete, false, false, 0)
+	bHide, _ := gtk.ButtonNewWithLabel("Hide")
+	bHide.Connect("clicked", func() {
+		for _, note := range lw.selectedNotes() {
+			note.Hide()
+		}
+		lw.refresh()
+	})
+	buttons.PackEnd(bHide, false, false, 0)
+	bShow, _ := gtk.ButtonNewWithLabel("Show")
+	bShow.Connect("clicked", func() {
+		for _, note := range lw.selectedNotes() {
+			note.Show()
+		}
+		lw.refresh()
+	})
+	buttons.PackEnd(bShow, false, false, 0)
+	box.PackStart(buttons, false, false, 0)
+
+	lw.Window.Add(box)
+	lw.refresh()
+	lw.Window.ShowAll()
+
+	return lw
+}
+
+// noteCategory returns the note's category, resolving "" to the default category
+func (ns *NoteSet) noteCategory(note *Note) string {
+	if note.Category != "" {
+		return note.Category
+	}
+	cat, _ := ns.Properties["default_cat"].(string)
+	return cat
+}
+
+// isNoteShown reports whether the note's window is on screen
+func isNoteShown(note *Note) bool {
+	return note.GUI != nil && note.GUI.WinMain != nil && note.GUI.WinMain.GetVisible()
+}
+
+// notes returns the notes to list, filtered and sorted as selected
+func (lw *NoteListWindow) notes() []*Note {
+	filter := lw.CbFilter.GetActiveID()
+	var notes []*Note
+	for _, note := range lw.NoteSet.Notes {
+		if note.deletePending {
+			continue
+		}
+		if filter != "" && lw.NoteSet.noteCategory(note) != filter {
+			continue
+		}
+		notes = append(notes, note)
+	}
+
+	switch lw.CbSort.GetActiveID() {
+	case noteListSortTitle:
+		sort.SliceStable(notes, func(i, j int) bool {
+			return strings.ToLower(noteLabel(notes[i])) < strings.ToLower(noteLabel(notes[j]))
+		})
+	case noteListSortCategory:
+		sort.SliceStable(notes, func(i, j int) bool {
+			return strings.ToLower(lw.NoteSet.CategoryName(notes[i].Category)) < strings.ToLower(lw.NoteSet.CategoryName(notes[j].Category))
+		})
+	default:
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].LastModified.After(notes[j].LastModified)
+		})
+	}
+	return notes
+}
+
+// refresh rebuilds the list of notes
+func (lw *NoteListWindow) refresh() {
+	lw.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			lw.List.Remove(widget)
+		}
+	})
+	lw.rows = make(map[int]*Note)
+
+	notes := lw.notes()
+	if len(notes) == 0 {
+		label, _ := gtk.LabelNew("No notes")
+		label.SetMarginTop(12)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		row.SetSelectable(false)
+		row.SetActivatable(false)
+		lw.List.Add(row)
+		lw.List.ShowAll()
+		return
+	}
+
+	for _, note := range notes {
+		state := "hidden"
+		if isNoteShown(note) {
+			state = "shown"
+		}
+		label, _ := gtk.LabelNew("")
+		label.SetMarkup(fmt.Sprintf("%s <b>%s</b>\n<small>%s · modified %s · %s</small>",
+			categorySwatch(note),
+			glib.MarkupEscapeText(noteLabel(note)),
+			glib.MarkupEscapeText(lw.NoteSet.CategoryName(note.Category)),
+			FormatAgo(note.LastModified),
+			state))
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		lw.List.Add(row)
+		lw.rows[row.GetIndex()] = note
+	}
+	lw.List.ShowAll()
+}
+
+// categorySwatch returns markup for a dot in the note's category color
+func categorySwatch(note *Note) string {
+	hsv, ok := floatList(note.CatProp("bgcolor_hsv"))
+	if !ok || len(hsv) < 3 {
+		return ""
+	}
+	rgb := hsvToRGB(hsv[0], hsv[1], hsv[2])
+	return fmt.Sprintf("<span foreground=\"%s\">●</span>", rgbToHex(rgb[0], rgb[1], rgb[2]))
+}
+
+// selectedNotes returns the notes of the selected rows
+func (lw *NoteListWindow) selectedNotes() []*Note {
+	var notes []*Note
+	if rows := lw.List.GetSelectedRows(); rows != nil {
+		rows.Foreach(func(item interface{}) {
+			if row, ok := item.(*gtk.ListBoxRow); ok {
+				if note, ok := lw.rows[row.GetIndex()]; ok {
+					notes = append(notes, note)
+				}
+			}
+		})
+	}
+	return notes
+}
+
+// raise shows the note and brings it to the front
+func (lw *NoteListWindow) raise(note *Note) {
+	note.Show()
+	lw.refresh()
+	// Give a new window time to get its window-calls ID before raising it
+	glib.TimeoutAdd(400, func() bool {
+		if note.GUI != nil {
+			note.GUI.Raise()
+		}
+		return false // Don't repeat
+	})
+}
+
+func (lw *NoteListWindow) onDelete() {
+	notes := lw.selectedNotes()
+	if len(notes) == 0 {
+		return
+	}
+	dialog := gtk.MessageDialogNew(lw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Delete %d notes?", len(notes))
+	dialog.FormatSecondaryText("They can be restored from the Trash in the indicator menu.")
+	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Delete", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+
+	if response == gtk.RESPONSE_ACCEPT {
+		for _, note := range notes {
+			note.trash()
+		}
+		lw.refresh()
+	}
+}
@@ -0,0 +1,116 @@
+package stickynotes
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Each note can be made see-through, and dimmed further while another window has the
+// focus, with "Opacity…" in the note menu. Stored as "opacity" (0.2 to 1) and
+// "dim_unfocused" in its properties. Until a shown window is placed it stays fully
+// transparent, see hideUntilPlaced.
+
+const (
+	minOpacity    = 0.2 // Below that a note is hard to find again
+	dimmedOpacity = 0.6 // Share of its opacity a dimmed note keeps
+)
+
+// Opacity returns the note's opacity, 1 when opaque
+func (n *Note) Opacity() float64 {
+	if opacity, ok := n.Properties["opacity"].(float64); ok && opacity >= minOpacity && opacity < 1 {
+		return opacity
+	}
+	return 1
+}
+
+// DimUnfocused reports whether the note is dimmed while it doesn't have the focus
+func (n *Note) DimUnfocused() bool {
+	dim, _ := n.Properties["dim_unfocused"].(bool)
+	return dim
+}
+
+// SetOpacity sets the note's opacity, and whether it is dimmed without the focus
+func (sn *StickyNote) SetOpacity(opacity float64, dimUnfocused bool) {
+	sn.setOpacity(opacity, dimUnfocused)
+	sn.NoteSet.Save()
+}
+
+// setOpacity is SetOpacity without saving, for previews
+func (sn *StickyNote) setOpacity(opacity float64, dimUnfocused bool) {
+	if opacity >= 1 {
+		delete(sn.Note.Properties, "opacity")
+	} else {
+		sn.Note.Properties["opacity"] = max(opacity, minOpacity)
+	}
+	if dimUnfocused {
+		sn.Note.Properties["dim_unfocused"] = true
+	} else {
+		delete(sn.Note.Properties, "dim_unfocused")
+	}
+	sn.applyOpacity()
+}
+
+// hideUntilPlaced makes the window fully transparent while it is shown and moved to its
+// position, so it doesn't visibly jump there
+func (sn *StickyNote) hideUntilPlaced() {
+	sn.placing = true
+	sn.WinMain.SetOpacity(0.0)
+}
+
+// showPlaced makes the window visible once placed
+func (sn *StickyNote) showPlaced() {
+	sn.placing = false
+	sn.applyOpacity()
+}
+
+// applyOpacity gives the window the note's opacity, dimmed when it should be
+func (sn *StickyNote) applyOpacity() {
+	if sn.WinMain == nil || sn.placing {
+		return
+	}
+	opacity := sn.Note.Opacity()
+	if sn.Note.DimUnfocused() && !sn.WinMain.IsActive() {
+		opacity *= dimmedOpacity
+	}
+	sn.WinMain.SetOpacity(opacity)
+}
+
+// onOpacity lets the user set the note's opacity, applied while the slider moves
+func (sn *StickyNote) onOpacity() {
+	opacity, dim := sn.Note.Opacity(), sn.Note.DimUnfocused()
+	dialog, err := gtk.DialogNewWithButtons("Opacity", sn.WinMain, gtk.DIALOG_MODAL,
+		[]interface{}{"Cancel", gtk.RESPONSE_CANCEL},
+		[]interface{}{"OK", gtk.RESPONSE_ACCEPT})
+	if err != nil {
+		return
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.SetMarginTop(12)
+
+	scale, _ := gtk.ScaleNewWithRange(gtk.ORIENTATION_HORIZONTAL, minOpacity*100, 100, 5)
+	scale.SetValue(opacity * 100)
+	scale.SetSizeRequest(240, -1)
+	scale.SetTooltipText("Opacity of the note, in percent")
+	content.PackStart(scale, false, false, 0)
+	chkDim, _ := gtk.CheckButtonNewWithLabel("Dim when another window has the focus")
+	chkDim.SetActive(dim)
+	content.PackStart(chkDim, false, false, 0)
+
+	preview := func() {
+		sn.setOpacity(scale.GetValue()/100, chkDim.GetActive())
+	}
+	scale.Connect("value-changed", preview)
+	chkDim.Connect("toggled", preview)
+
+	dialog.ShowAll()
+	response := dialog.Run()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_ACCEPT {
+		sn.setOpacity(opacity, dim)
+		return
+	}
+	sn.NoteSet.Save()
+}
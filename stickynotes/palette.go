@@ -0,0 +1,144 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// CommandPalette is a keyboard-driven overlay listing every registered action, filtered
+// by fuzzy matching as you type; Enter runs the selected one. Opened from a note, it
+// lists the note's actions too.
+type CommandPalette struct {
+	NoteSet *NoteSet
+	Note    *StickyNote // The note it was opened from, nil from the indicator
+	Window  *gtk.Window
+	Entry   *gtk.SearchEntry
+	List    *gtk.ListBox
+	actions []*Action // ListBox row index to action
+}
+
+// NewCommandPalette opens the command palette, for the note sn (nil from the indicator)
+func NewCommandPalette(noteset *NoteSet, sn *StickyNote) *CommandPalette {
+	cp := &CommandPalette{NoteSet: noteset, Note: sn}
+
+	cp.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	cp.Window.SetTitle("Command Palette")
+	cp.Window.SetDecorated(false)
+	cp.Window.SetKeepAbove(true)
+	cp.Window.SetSkipTaskbarHint(true)
+	cp.Window.SetTypeHint(gdk.WINDOW_TYPE_HINT_DIALOG)
+	cp.Window.SetDefaultSize(380, 320)
+	cp.Window.SetPosition(gtk.WIN_POS_CENTER)
+	cp.Window.Connect("key-press-event", cp.onKeyPress)
+	// Dismiss like a popup when focus moves elsewhere
+	cp.Window.Connect("focus-out-event", func() bool {
+		cp.Window.Destroy()
+		return false
+	})
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	cp.Entry, _ = gtk.SearchEntryNew()
+	cp.Entry.SetPlaceholderText("Type a command")
+	cp.Entry.Connect("search-changed", cp.refresh)
+	cp.Entry.Connect("activate", func() {
+		if row := cp.List.GetSelectedRow(); row != nil {
+			cp.onRowActivated(cp.List, row)
+		}
+	})
+	box.PackStart(cp.Entry, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	cp.List, _ = gtk.ListBoxNew()
+	cp.List.SetActivateOnSingleClick(true)
+	cp.List.Connect("row-activated", cp.onRowActivated)
+	scrolled.Add(cp.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	cp.Window.Add(box)
+	cp.refresh()
+	cp.Window.ShowAll()
+	cp.Window.Present()
+	cp.Entry.GrabFocus()
+
+	return cp
+}
+
+// refresh rebuilds the list for the current query and selects the best match
+func (cp *CommandPalette) refresh() {
+	cp.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			cp.List.Remove(widget)
+		}
+	})
+
+	query, _ := cp.Entry.GetText()
+	cp.actions = cp.NoteSet.MatchActions(query, cp.Note)
+	for _, action := range cp.actions {
+		text := action.Label
+		if action.Checked != nil && action.Checked(cp.Note) {
+			text = "✓ " + text
+		}
+		label, _ := gtk.LabelNew(text)
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		label.SetMarginTop(3)
+		label.SetMarginBottom(3)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		cp.List.Add(row)
+	}
+	cp.List.ShowAll()
+	if row := cp.List.GetRowAtIndex(0); row != nil {
+		cp.List.SelectRow(row)
+	}
+}
+
+// onKeyPress moves the selection with the arrow keys while typing and closes on Escape
+func (cp *CommandPalette) onKeyPress(win *gtk.Window, event *gdk.Event) bool {
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+
+	switch keyEvent.KeyVal() {
+	case gdk.KEY_Escape:
+		cp.Window.Destroy()
+		return true
+	case gdk.KEY_Down, gdk.KEY_Up:
+		row := cp.List.GetSelectedRow()
+		index := 0
+		if row != nil {
+			index = row.GetIndex()
+			if keyEvent.KeyVal() == gdk.KEY_Down {
+				index++
+			} else {
+				index--
+			}
+		}
+		if next := cp.List.GetRowAtIndex(index); next != nil {
+			cp.List.SelectRow(next)
+		}
+		return true
+	}
+	return false
+}
+
+func (cp *CommandPalette) onRowActivated(list *gtk.ListBox, row *gtk.ListBoxRow) {
+	index := row.GetIndex()
+	if index < 0 || index >= len(cp.actions) {
+		return
+	}
+	id := cp.actions[index].ID
+	cp.Window.Destroy()
+	// Run once the palette is gone, so windows the action opens get the focus
+	glib.IdleAdd(func() bool {
+		if err := cp.NoteSet.ActivateAction(id, cp.Note); err != nil {
+			fmt.Printf("[Action] %v\n", err)
+		}
+		return false
+	})
+}
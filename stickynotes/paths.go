@@ -0,0 +1,158 @@
+package stickynotes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The data files live in $XDG_DATA_HOME/postnote, as "notes.json" and "notes-<profile>.json".
+// Older versions kept them as bare files in the home directory (see LegacySettingsFile),
+// which are moved on first start, see MigrateDataFile.
+
+// xdgDefaults are the XDG base directories used when their variable isn't set
+var xdgDefaults = map[string]string{
+	"XDG_DATA_HOME":   "~/.local/share",
+	"XDG_CONFIG_HOME": "~/.config",
+	"XDG_CACHE_HOME":  "~/.cache",
+	"XDG_STATE_HOME":  "~/.local/state",
+}
+
+// PathResolver turns the paths of the settings into absolute paths: a leading ~ is
+// the home directory, $VAR and ${VAR} are environment variables (the XDG base
+// directories falling back to their defaults), and symlinks are followed, so the data
+// file is replaced in place rather than the link replaced by a copy.
+type PathResolver struct {
+	Home   string                  // Home directory, the user's when empty
+	Getenv func(key string) string // Environment lookup, os.Getenv when nil
+}
+
+// DefaultResolver resolves paths in the user's environment
+var DefaultResolver = PathResolver{}
+
+// ResolvePath resolves path with DefaultResolver
+func ResolvePath(path string) string {
+	return DefaultResolver.Resolve(path)
+}
+
+// Resolve returns path expanded and with its symlinks followed. Parts of the path that
+// don't exist yet are kept as they are.
+func (r PathResolver) Resolve(path string) string {
+	path = r.Expand(path)
+	if path == "" {
+		return path
+	}
+	return followSymlinks(path)
+}
+
+// Expand expands ~ and environment variables in path, without touching the filesystem
+func (r PathResolver) Expand(path string) string {
+	if path == "" {
+		return path
+	}
+	path = os.Expand(path, r.lookup)
+	path = r.expandHome(path)
+	return filepath.Clean(path)
+}
+
+func (r PathResolver) lookup(key string) string {
+	getenv := r.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if value := getenv(key); value != "" {
+		return value
+	}
+	if fallback, ok := xdgDefaults[key]; ok {
+		return r.expandHome(fallback)
+	}
+	return ""
+}
+
+// expandHome expands a leading ~ (alone or followed by a slash) to the home directory
+func (r PathResolver) expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home := r.Home
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// followSymlinks resolves the symlinks of path, or of its longest existing parent when
+// path doesn't exist yet
+func followSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if dir == path {
+		return path
+	}
+	return filepath.Join(followSymlinks(dir), name)
+}
+
+// dataFileCompanions are the suffixes of the files and folders kept next to a data file
+var dataFileCompanions = []string{".bak", ".damaged", historySuffix, attachmentsSuffix, auditSuffix}
+
+// MigrateDataFile moves the profile's data file from where older versions kept it (in
+// dir when set, portable mode) to its current place, with its history, attachments and
+// backups, unless the current one exists already. Returns the data file to use: the
+// old one when it couldn't be moved.
+func MigrateDataFile(profile, dir string) (string, error) {
+	file := ProfileDataFile(profile)
+	legacy := DefaultResolver.Expand(LegacyProfileDataFile(profile, dir))
+	current := DefaultResolver.Expand(file)
+
+	if _, err := os.Lstat(current); err == nil {
+		return file, nil
+	}
+	if _, err := os.Lstat(legacy); err != nil {
+		return file, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(current), 0700); err != nil {
+		return legacy, err
+	}
+	// A symlinked data file (e.g. into a synced folder) is moved as the link itself
+	if err := os.Rename(legacy, current); err != nil {
+		return legacy, err
+	}
+	for _, suffix := range dataFileCompanions {
+		if _, err := os.Lstat(legacy + suffix); err == nil {
+			if err := os.Rename(legacy+suffix, current+suffix); err != nil {
+				fmt.Printf("[Paths] Failed to move %s: %v\n", legacy+suffix, err)
+			}
+		}
+	}
+	migrateBackups(backupPrefixOf(legacy), backupPrefixOf(current))
+	fmt.Printf("[Paths] Moved the data file %s to %s\n", legacy, current)
+	return file, nil
+}
+
+// migrateBackups renames the backups taken under the old data file's name
+func migrateBackups(oldPrefix, newPrefix string) {
+	entries, err := os.ReadDir(BackupDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp := strings.TrimPrefix(name, oldPrefix)
+		// Only this data file's: the old profile files shared the default's prefix
+		if entry.IsDir() || stamp == name || len(stamp) < len(backupTimeLayout) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeLayout, stamp[:len(backupTimeLayout)]); err != nil {
+			continue
+		}
+		oldPath := filepath.Join(BackupDir(), name)
+		if err := os.Rename(oldPath, filepath.Join(BackupDir(), newPrefix+stamp)); err != nil {
+			fmt.Printf("[Paths] Failed to move backup %s: %v\n", oldPath, err)
+		}
+	}
+}
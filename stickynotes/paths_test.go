@@ -0,0 +1,109 @@
+package stickynotes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathResolverExpand(t *testing.T) {
+	env := map[string]string{
+		"XDG_DATA_HOME": "/data",
+		"NOTES":         "/srv/notes",
+	}
+	r := PathResolver{Home: "/home/user", Getenv: func(key string) string { return env[key] }}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"~", "/home/user"},
+		{"~/", "/home/user"},
+		{"~/.config/indicator-stickynotes", "/home/user/.config/indicator-stickynotes"},
+		{"~other/notes", "~other/notes"},
+		{"/abs/~/notes", "/abs/~/notes"},
+		{"$XDG_DATA_HOME/postnote/notes.json", "/data/postnote/notes.json"},
+		{"${NOTES}/work.json", "/srv/notes/work.json"},
+		{"$XDG_CONFIG_HOME/postnote", "/home/user/.config/postnote"},
+		{"$XDG_CACHE_HOME", "/home/user/.cache"},
+		{"$XDG_STATE_HOME/postnote", "/home/user/.local/state/postnote"},
+		{"$UNSET/notes.json", "/notes.json"},
+		{"relative//dir/../notes.json", "relative/notes.json"},
+	}
+	for _, tt := range tests {
+		if got := r.Expand(tt.path); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	// Without XDG_DATA_HOME, its default under the home directory applies
+	delete(env, "XDG_DATA_HOME")
+	if got, want := r.Expand(SettingsFile), "/home/user/.local/share/postnote/notes.json"; got != want {
+		t.Errorf("Expand(SettingsFile) = %q, want %q", got, want)
+	}
+}
+
+func TestPathResolverSymlinks(t *testing.T) {
+	// The temporary directory itself can be behind a symlink (e.g. /tmp on macOS)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced := filepath.Join(dir, "Sync")
+	if err := os.Mkdir(synced, 0700); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(synced, "notes.json")
+	if err := os.WriteFile(file, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(file, filepath.Join(dir, "notes.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(synced, filepath.Join(dir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := PathResolver{Home: dir, Getenv: func(string) string { return "" }}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~/notes.json", file},
+		{"~/linked/notes.json", file},
+		{"~/Sync/notes.json", file},
+		// Files that don't exist yet resolve through their existing parents
+		{"~/linked/new.json", filepath.Join(synced, "new.json")},
+		{"~/linked/sub/new.json", filepath.Join(synced, "sub", "new.json")},
+		{"~/missing/new.json", filepath.Join(dir, "missing", "new.json")},
+	}
+	for _, tt := range tests {
+		if got := r.Resolve(tt.path); got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestProfileDataFile(t *testing.T) {
+	tests := []struct {
+		profile string
+		file    string
+		legacy  string
+	}{
+		{DefaultProfile, "$XDG_DATA_HOME/postnote/notes.json", "~/.config/indicator-stickynotes"},
+		{DevProfile, "$XDG_DATA_HOME/postnote/notes-dev.json", "~/.stickynotes"},
+		{"work", "$XDG_DATA_HOME/postnote/notes-work.json", "~/.config/indicator-stickynotes-work"},
+	}
+	for _, tt := range tests {
+		if got := ProfileDataFile(tt.profile); got != tt.file {
+			t.Errorf("ProfileDataFile(%q) = %q, want %q", tt.profile, got, tt.file)
+		}
+		if got := LegacyProfileDataFile(tt.profile, ""); got != tt.legacy {
+			t.Errorf("LegacyProfileDataFile(%q) = %q, want %q", tt.profile, got, tt.legacy)
+		}
+	}
+	if got, want := LegacyProfileDataFile("work", "/media/usb"), "/media/usb/indicator-stickynotes-work"; got != want {
+		t.Errorf("portable LegacyProfileDataFile = %q, want %q", got, want)
+	}
+}
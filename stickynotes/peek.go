@@ -0,0 +1,98 @@
+package stickynotes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// peekMaxChars limits how much of a note body is shown in a peek preview
+const peekMaxChars = 600
+
+// peekWindow is the preview currently on screen, there is only ever one
+var peekWindow *gtk.Window
+
+// ShowPeek shows a transient, read-only preview of the note near the pointer.
+// The note's own window and visibility are left untouched. The preview is
+// dismissed when the pointer leaves it, or by HidePeek.
+func ShowPeek(note *Note) {
+	HidePeek()
+
+	if note.GUI != nil {
+		note.GUI.UpdateNote()
+	}
+
+	win, err := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	if err != nil {
+		return
+	}
+	win.SetDecorated(false)
+	win.SetTypeHint(gdk.WINDOW_TYPE_HINT_TOOLTIP)
+	win.SetSkipTaskbarHint(true)
+	win.SetSkipPagerHint(true)
+	win.SetAcceptFocus(false)
+	win.SetKeepAbove(true)
+	win.SetPosition(gtk.WIN_POS_MOUSE)
+	win.SetDefaultSize(240, -1)
+	win.SetName("peek-window")
+
+	body := note.Body
+	if runes := []rune(body); len(runes) > peekMaxChars {
+		body = string(runes[:peekMaxChars]) + "…"
+	}
+	if strings.TrimSpace(body) == "" {
+		body = "(empty note)"
+	}
+	label, _ := gtk.LabelNew(body)
+	label.SetLineWrap(true)
+	label.SetMaxWidthChars(40)
+	label.SetXAlign(0)
+	label.SetYAlign(0)
+	label.SetMarginStart(10)
+	label.SetMarginEnd(10)
+	label.SetMarginTop(8)
+	label.SetMarginBottom(8)
+	win.Add(label)
+
+	// Same colors as the note itself
+	bgHSV, _ := floatList(note.CatProp("bgcolor_hsv"))
+	if override, ok := floatList(note.Properties["bgcolor_hsv"]); ok {
+		bgHSV = override
+	}
+	textColor, _ := floatList(note.CatProp("textcolor"))
+	if len(bgHSV) >= 3 && len(textColor) >= 3 {
+		bgRGB := hsvToRGB(bgHSV[0], bgHSV[1], bgHSV[2])
+		css := fmt.Sprintf("#peek-window { background-color: %s; color: %s; }",
+			rgbToHex(bgRGB[0], bgRGB[1], bgRGB[2]), rgbToHex(textColor[0], textColor[1], textColor[2]))
+		if provider, err := gtk.CssProviderNew(); err == nil && provider.LoadFromData(css) == nil {
+			for _, widget := range []gtk.IWidget{win, label} {
+				if context, err := widget.ToWidget().GetStyleContext(); err == nil {
+					context.AddProvider(provider, gtk.STYLE_PROVIDER_PRIORITY_APPLICATION)
+				}
+			}
+		}
+	}
+
+	win.AddEvents(int(gdk.LEAVE_NOTIFY_MASK))
+	win.Connect("leave-notify-event", func() bool {
+		HidePeek()
+		return false
+	})
+	win.Connect("button-press-event", func() bool {
+		HidePeek()
+		return true
+	})
+
+	peekWindow = win
+	win.ShowAll()
+}
+
+// HidePeek dismisses the peek preview, if one is shown
+func HidePeek() {
+	if peekWindow != nil {
+		peekWindow.Destroy()
+		peekWindow = nil
+	}
+}
@@ -0,0 +1,95 @@
+package stickynotes
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Profiles keep separate sets of notes, e.g. personal and work notes, each in its own
+// data file next to the default one: "notes-<profile>.json". The "dev" profile keeps
+// the data file of the former -d flag.
+
+const (
+	DefaultProfile = ""
+	DevProfile     = "dev"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidProfileName reports whether name can be used as a profile name: letters, digits,
+// "-" and "_"
+func ValidProfileName(name string) bool {
+	return profileNamePattern.MatchString(name)
+}
+
+// ProfileLabel returns the name of the profile shown to the user
+func ProfileLabel(profile string) string {
+	if profile == DefaultProfile {
+		return "Default"
+	}
+	return profile
+}
+
+// ProfileDataFile returns the data file of the profile
+func ProfileDataFile(profile string) string {
+	switch profile {
+	case DefaultProfile:
+		return SettingsFile
+	case DevProfile:
+		return DebugSettingsFile
+	}
+	return strings.TrimSuffix(SettingsFile, ".json") + "-" + profile + ".json"
+}
+
+// LegacyProfileDataFile returns where older versions kept the data file of the profile,
+// in dir when it is set (portable mode)
+func LegacyProfileDataFile(profile, dir string) string {
+	file := LegacySettingsFile
+	switch profile {
+	case DefaultProfile:
+	case DevProfile:
+		file = LegacyDebugSettingsFile
+	default:
+		file = LegacySettingsFile + "-" + profile
+	}
+	if dir != "" {
+		file = filepath.Join(dir, filepath.Base(file))
+	}
+	return file
+}
+
+// Profiles returns the profiles that have a data file, the default profile first and
+// the others by name. Data files older versions kept (in dir when it is set, portable
+// mode) count too, they are moved when switching to their profile.
+func Profiles(dir string) []string {
+	found := make(map[string]bool)
+	base := DefaultResolver.Expand(strings.TrimSuffix(SettingsFile, ".json"))
+	matches, _ := filepath.Glob(base + "-*.json")
+	for _, match := range matches {
+		found[strings.TrimSuffix(strings.TrimPrefix(match, base+"-"), ".json")] = true
+	}
+	legacyBase := DefaultResolver.Expand(LegacyProfileDataFile(DefaultProfile, dir))
+	matches, _ = filepath.Glob(legacyBase + "-*")
+	for _, match := range matches {
+		// Skips the history and attachment folders
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			found[strings.TrimPrefix(match, legacyBase+"-")] = true
+		}
+	}
+	if _, err := os.Stat(DefaultResolver.Expand(LegacyProfileDataFile(DevProfile, dir))); err == nil {
+		found[DevProfile] = true
+	}
+
+	var named []string
+	for name := range found {
+		// Skips the backup, temporary and damaged copies of the data files
+		if ValidProfileName(name) {
+			named = append(named, name)
+		}
+	}
+	sort.Strings(named)
+	return append([]string{DefaultProfile}, named...)
+}
@@ -0,0 +1,82 @@
+package stickynotes
+
+import "math"
+
+// The original Python indicator-stickynotes keeps its notes in ~/.config/indicator-stickynotes
+// in the same JSON layout: categories keyed by GUID, "default_cat" and "all_visible" in the
+// properties, and each note's window position, size and locked state in its properties.
+// Its data file loads as it is (keys this version doesn't know are written back), and
+// ExportPython writes a file the Python app reads without tripping over what it lacks.
+
+// pythonNoteProperties are the note properties the Python app reads
+var pythonNoteProperties = []string{"position", "size", "locked"}
+
+// pythonCategoryKeys are the category properties the Python app reads
+var pythonCategoryKeys = []string{"name", "bgcolor_hsv", "textcolor", "font"}
+
+// pythonProperties are the noteset properties the Python app reads
+var pythonProperties = []string{"default_cat", "all_visible"}
+
+// ExportPython serializes the notes for the Python indicator-stickynotes: only the keys it
+// reads, window positions and sizes as whole pixels (it passes them to GTK unchanged) and
+// without the trash. Tags, formatting, history and attachments are left out.
+func (ns *NoteSet) ExportPython() string {
+	notes := make([]map[string]interface{}, 0, len(ns.Notes))
+	for _, note := range ns.Notes {
+		content := note.Extract()
+		props := make(map[string]interface{})
+		for _, key := range pythonNoteProperties {
+			val, ok := note.Properties[key]
+			if !ok {
+				continue
+			}
+			if key == "locked" {
+				if locked, ok := val.(bool); ok {
+					props[key] = locked
+				}
+			} else if pair, ok := pythonIntPair(val); ok {
+				props[key] = pair
+			}
+		}
+		notes = append(notes, map[string]interface{}{
+			"uuid":          content["uuid"],
+			"body":          content["body"],
+			"last_modified": content["last_modified"],
+			"properties":    props,
+			"cat":           content["cat"],
+		})
+	}
+
+	categories := make(map[string]interface{}, len(ns.Categories))
+	for id, catData := range ns.Categories {
+		cat := make(map[string]interface{})
+		for _, key := range pythonCategoryKeys {
+			if val, ok := catData[key]; ok && val != nil {
+				cat[key] = val
+			}
+		}
+		categories[id] = cat
+	}
+
+	properties := make(map[string]interface{})
+	for _, key := range pythonProperties {
+		if val, ok := ns.Properties[key]; ok && val != nil {
+			properties[key] = val
+		}
+	}
+
+	return marshalNoteSet(map[string]interface{}{
+		"notes":      notes,
+		"categories": categories,
+		"properties": properties,
+	})
+}
+
+// pythonIntPair converts a position or size to two whole numbers
+func pythonIntPair(val interface{}) ([]int, bool) {
+	list, ok := floatList(val)
+	if !ok || len(list) != 2 {
+		return nil, false
+	}
+	return []int{int(math.Round(list[0])), int(math.Round(list[1]))}, true
+}
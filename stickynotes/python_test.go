@@ -0,0 +1,146 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// pythonDataFile is a data file written by the Python indicator-stickynotes, with a key of
+// a newer Python version on a note and at the top
+const pythonDataFile = `{
+	"notes": [
+		{
+			"uuid": "5b8c2f0e-6d1a-4c1e-9a57-3f1d0c2b7e41",
+			"body": "Call the bank\nété",
+			"last_modified": "2019-06-11T14:02:57",
+			"properties": {"position": [1530, 42], "size": [200, 150], "locked": false},
+			"cat": "0b6d4c1c-8f4a-4c36-8ad3-5d9e4b1e9c27",
+			"title": ""
+		},
+		{
+			"uuid": "9e2a4d7c-1b3f-4e5a-8c6d-7f0a1b2c3d4e",
+			"body": "Deleted category",
+			"last_modified": "2019-06-12T08:00:00",
+			"properties": {},
+			"cat": "ffffffff-0000-0000-0000-000000000000"
+		}
+	],
+	"properties": {"default_cat": "0b6d4c1c-8f4a-4c36-8ad3-5d9e4b1e9c27", "all_visible": true},
+	"categories": {
+		"0b6d4c1c-8f4a-4c36-8ad3-5d9e4b1e9c27": {
+			"name": "Yellow",
+			"bgcolor_hsv": [0.1333, 1.0, 1.0],
+			"textcolor": [0.12549, 0.12549, 0.12549],
+			"font": ""
+		},
+		"7a1e3b5d-2c4f-4a6b-9d8e-0f1a2b3c4d5e": {"bgcolor_hsv": [0.55, 0.4, 1.0]}
+	},
+	"version": 2
+}`
+
+func TestPythonDataFileRoundTrip(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(pythonDataFile); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if got := ns.Notes[1].Category; got != "ffffffff-0000-0000-0000-000000000000" {
+		t.Errorf("note of a deleted category has category %q, want it kept", got)
+	}
+
+	var want, got map[string]interface{}
+	if err := json.Unmarshal([]byte(pythonDataFile), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(ns.Dumps()), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything the Python app wrote comes back unchanged, whatever was added
+	for _, key := range []string{"properties", "categories", "version"} {
+		if !reflect.DeepEqual(got[key], want[key]) {
+			t.Errorf("%s = %v, want %v", key, got[key], want[key])
+		}
+	}
+	wantNotes := want["notes"].([]interface{})
+	gotNotes, _ := got["notes"].([]interface{})
+	if len(gotNotes) != len(wantNotes) {
+		t.Fatalf("got %d notes, want %d", len(gotNotes), len(wantNotes))
+	}
+	for i := range wantNotes {
+		wantNote := wantNotes[i].(map[string]interface{})
+		gotNote := gotNotes[i].(map[string]interface{})
+		for key, val := range wantNote {
+			if !reflect.DeepEqual(gotNote[key], val) {
+				t.Errorf("note %d %s = %v, want %v", i, key, gotNote[key], val)
+			}
+		}
+	}
+}
+
+func TestExportPython(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	note := findNote(t, ns, "note-0002")
+	note.Properties["position"] = []interface{}{10.6, 20.2}
+	note.Properties["size"] = "not a size"
+	note.Properties["bgcolor_hsv"] = []interface{}{0.5, 0.5, 0.5}
+
+	var exported map[string]interface{}
+	if err := json.Unmarshal([]byte(ns.ExportPython()), &exported); err != nil {
+		t.Fatalf("export isn't JSON: %v", err)
+	}
+	if got, want := sortedKeys(exported), []string{"categories", "notes", "properties"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top-level keys = %v, want %v", got, want)
+	}
+
+	notes := exported["notes"].([]interface{})
+	if len(notes) != 2 {
+		t.Fatalf("exported %d notes, want the 2 outside the trash", len(notes))
+	}
+	first := notes[0].(map[string]interface{})
+	if got, want := sortedKeys(first), []string{"body", "cat", "last_modified", "properties", "uuid"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("note keys = %v, want %v", got, want)
+	}
+	if first["last_modified"] != "2024-03-01T10:20:30" || first["cat"] != "cat-b" {
+		t.Errorf("note-0001 = %v", first)
+	}
+	wantProps := map[string]interface{}{"locked": true, "position": []interface{}{10.0, 20.0}}
+	if !reflect.DeepEqual(first["properties"], wantProps) {
+		t.Errorf("note-0001 properties = %v, want %v", first["properties"], wantProps)
+	}
+	// Positions are whole pixels, and what the Python app can't read is left out
+	wantProps = map[string]interface{}{"position": []interface{}{11.0, 20.0}}
+	if second := notes[1].(map[string]interface{}); !reflect.DeepEqual(second["properties"], wantProps) {
+		t.Errorf("note-0002 properties = %v, want %v", second["properties"], wantProps)
+	}
+
+	wantCats := map[string]interface{}{
+		"cat-a": map[string]interface{}{"name": "Work", "bgcolor_hsv": []interface{}{0.5, 1.0, 1.0}},
+		"cat-b": map[string]interface{}{"name": "Home"},
+	}
+	if !reflect.DeepEqual(exported["categories"], wantCats) {
+		t.Errorf("categories = %v, want %v", exported["categories"], wantCats)
+	}
+
+	// The export loads back here too
+	reloaded := newTestNoteSet(t)
+	if err := reloaded.Loads(ns.ExportPython()); err != nil {
+		t.Fatalf("Loads of the export: %v", err)
+	}
+	if len(reloaded.Notes) != 2 || reloaded.Notes[0].Body != "Shopping\nmilk" {
+		t.Errorf("reloaded %d notes, first %q", len(reloaded.Notes), reloaded.Notes[0].Body)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package stickynotes
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// A note can be shown as a QR code so a phone can pick it up without any sync. The
+// code is drawn by the qrencode tool, at medium error correction.
+
+const (
+	// qrMaxBytes is the most a QR code holds at medium error correction (version 40)
+	qrMaxBytes = 2331
+	// qrModuleSize is the size (px) of a QR code module
+	qrModuleSize = 6
+)
+
+// qrCodePNG renders text as a QR code PNG with qrencode
+func qrCodePNG(text string) ([]byte, error) {
+	if len(text) > qrMaxBytes {
+		return nil, fmt.Errorf("the note is too long for a QR code (%d bytes, at most %d)", len(text), qrMaxBytes)
+	}
+	path, err := exec.LookPath("qrencode")
+	if err != nil {
+		return nil, fmt.Errorf("qrencode is not installed")
+	}
+	cmd := exec.Command(path, "-t", "PNG", "-l", "M", "-s", fmt.Sprint(qrModuleSize), "-m", "2", "-o", "-")
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	png, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("qrencode: %s", msg)
+		}
+		return nil, err
+	}
+	return png, nil
+}
+
+// onShowQRCode shows the note body as a QR code
+func (sn *StickyNote) onShowQRCode() {
+	sn.UpdateNote()
+	png, err := qrCodePNG(sn.Note.Body)
+	var pixbuf *gdk.Pixbuf
+	if err == nil {
+		var loader *gdk.PixbufLoader
+		if loader, err = gdk.PixbufLoaderNew(); err == nil {
+			pixbuf, err = loader.WriteAndReturnPixbuf(png)
+		}
+	}
+	if err != nil {
+		dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Can't show the note as a QR code.")
+		dialog.FormatSecondaryText("%s", err.Error())
+		dialog.Run()
+		dialog.Destroy()
+		return
+	}
+
+	win, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	win.SetTitle("QR Code")
+	win.SetTransientFor(sn.WinMain)
+	win.SetPosition(gtk.WIN_POS_CENTER_ON_PARENT)
+	win.SetResizable(false)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(12)
+	image, _ := gtk.ImageNewFromPixbuf(pixbuf)
+	box.PackStart(image, false, false, 0)
+	label, _ := gtk.LabelNew(noteLabel(sn.Note))
+	label.SetEllipsize(pango.ELLIPSIZE_END)
+	box.PackStart(label, false, false, 0)
+	bClose, _ := gtk.ButtonNewWithLabel("Close")
+	bClose.SetHAlign(gtk.ALIGN_END)
+	bClose.Connect("clicked", win.Destroy)
+	box.PackStart(bClose, false, false, 0)
+
+	win.Add(box)
+	win.ShowAll()
+}
@@ -0,0 +1,237 @@
+package stickynotes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A reminder is stored in the note's "remind_at" property (RFC 3339). When it is due,
+// a desktop notification with the note's first line is shown; clicking it raises the
+// note. Reminders that came due while the application wasn't running fire at startup.
+
+const (
+	notificationsName      = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+
+	// reminderInterval is how often the scheduler looks for due reminders
+	reminderInterval = 20 * time.Second
+)
+
+// ReminderAt returns when the note's reminder is due, ok=false when none is set
+func (n *Note) ReminderAt() (time.Time, bool) {
+	value, _ := n.Properties["remind_at"].(string)
+	if value == "" {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// SetReminder sets the note's reminder, or clears it for a zero time
+func (n *Note) SetReminder(at time.Time) {
+	if at.IsZero() {
+		delete(n.Properties, "remind_at")
+	} else {
+		n.Properties["remind_at"] = at.Format(time.RFC3339)
+	}
+	n.NoteSet.Save()
+}
+
+// StartReminders starts the scheduler goroutine that fires due reminders, and listens
+// for clicks on the reminder notifications
+func StartReminders(ns *NoteSet) error {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return err
+	}
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface(notificationsInterface),
+		dbus.WithMatchMember("ActionInvoked"),
+	); err != nil {
+		return err
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig.Name != notificationsInterface+".ActionInvoked" || len(sig.Body) == 0 {
+				continue
+			}
+			id, ok := sig.Body[0].(uint32)
+			if !ok {
+				continue
+			}
+			// D-Bus signals arrive on a separate goroutine, GTK calls must happen on the main thread
+			glib.IdleAdd(func() bool {
+				ns.onReminderClicked(id)
+				return false // Don't repeat
+			})
+		}
+	}()
+
+	go func() {
+		// The notes are only touched on the main thread, the goroutine just keeps time
+		check := func() bool {
+			ns.fireDueReminders()
+			return false // Don't repeat
+		}
+		glib.IdleAdd(check)
+		for range time.Tick(reminderInterval) {
+			glib.IdleAdd(check)
+		}
+	}()
+
+	return nil
+}
+
+// fireDueReminders notifies and clears the reminders that are due
+func (ns *NoteSet) fireDueReminders() {
+	now := time.Now()
+	fired := false
+	for _, note := range ns.Notes {
+		at, ok := note.ReminderAt()
+		if !ok || at.After(now) {
+			continue
+		}
+		delete(note.Properties, "remind_at")
+		fired = true
+		if note.GUI != nil && note.GUI.Menu != nil {
+			note.GUI.PopulateMenu()
+		}
+
+		id, err := sendNotification(reminderSummary(note), "Reminder set for "+FormatDateTime(at))
+		if err != nil {
+			fmt.Printf("[Reminders] Failed to send notification: %v\n", err)
+			continue
+		}
+		if ns.reminderNotifications == nil {
+			ns.reminderNotifications = make(map[uint32]string)
+		}
+		ns.reminderNotifications[id] = note.UUID
+	}
+	if fired {
+		ns.Feedback(FeedbackReminder)
+		ns.Save()
+	}
+}
+
+// onReminderClicked shows and raises the note of a clicked reminder notification
+func (ns *NoteSet) onReminderClicked(id uint32) {
+	uuid, ok := ns.reminderNotifications[id]
+	if !ok {
+		return
+	}
+	delete(ns.reminderNotifications, id)
+	note := ns.FindNote(uuid)
+	if note == nil {
+		return
+	}
+	note.Show()
+	// Give a new window time to get its window-calls ID before raising it
+	glib.TimeoutAdd(400, func() bool {
+		if note.GUI != nil {
+			note.GUI.Raise()
+		}
+		return false // Don't repeat
+	})
+}
+
+// reminderSummary is the notification title for a note: its first line
+func reminderSummary(note *Note) string {
+	if line := note.FirstLine(); line != "" {
+		return line
+	}
+	return "Sticky note reminder"
+}
+
+// sendNotification shows a desktop notification whose default action (a click) is
+// reported with ActionInvoked. Returns the notification id.
+func sendNotification(summary, body string) (uint32, error) {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return 0, err
+	}
+	var id uint32
+	obj := conn.Object(notificationsName, notificationsPath)
+	err = obj.Call(notificationsInterface+".Notify", 0,
+		"PostNote",                       // app_name
+		uint32(0),                        // replaces_id
+		"indicator-stickynotes",          // app_icon
+		summary,                          // summary
+		body,                             // body
+		[]string{"default", "Show note"}, // actions
+		map[string]dbus.Variant{},        // hints
+		int32(-1),                        // expire_timeout (server default)
+	).Store(&id)
+	return id, err
+}
+
+// onRemindMe lets the user pick the date and time of the note's reminder
+func (sn *StickyNote) onRemindMe() {
+	at, set := sn.Note.ReminderAt()
+	if !set {
+		// Default to the next full hour
+		at = time.Now().Truncate(time.Hour).Add(time.Hour)
+	}
+
+	buttons := [][]interface{}{{"Cancel", gtk.RESPONSE_CANCEL}}
+	if set {
+		buttons = append(buttons, []interface{}{"Clear", gtk.RESPONSE_REJECT})
+	}
+	buttons = append(buttons, []interface{}{"Set", gtk.RESPONSE_ACCEPT})
+	dialog, err := gtk.DialogNewWithButtons("Remind Me", sn.WinMain, gtk.DIALOG_MODAL, buttons...)
+	if err != nil {
+		return
+	}
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	content, _ := dialog.GetContentArea()
+	content.SetSpacing(6)
+	content.SetMarginStart(12)
+	content.SetMarginEnd(12)
+	content.SetMarginTop(12)
+
+	calendar, _ := gtk.CalendarNew()
+	calendar.SelectMonth(uint(at.Month()-1), uint(at.Year()))
+	calendar.SelectDay(uint(at.Day()))
+	content.PackStart(calendar, false, false, 0)
+
+	timeBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	timeLabel, _ := gtk.LabelNew("Time:")
+	timeBox.PackStart(timeLabel, false, false, 0)
+	spinHour, _ := gtk.SpinButtonNewWithRange(0, 23, 1)
+	spinHour.SetValue(float64(at.Hour()))
+	timeBox.PackStart(spinHour, false, false, 0)
+	colon, _ := gtk.LabelNew(":")
+	timeBox.PackStart(colon, false, false, 0)
+	spinMinute, _ := gtk.SpinButtonNewWithRange(0, 59, 5)
+	spinMinute.SetValue(float64(at.Minute()))
+	timeBox.PackStart(spinMinute, false, false, 0)
+	content.PackStart(timeBox, false, false, 0)
+	dialog.ShowAll()
+
+	response := dialog.Run()
+	year, month, day := calendar.GetDate()
+	hour, minute := spinHour.GetValueAsInt(), spinMinute.GetValueAsInt()
+	dialog.Destroy()
+
+	switch response {
+	case gtk.RESPONSE_ACCEPT:
+		remindAt := time.Date(int(year), time.Month(month+1), int(day), hour, minute, 0, 0, time.Local)
+		sn.Note.SetReminder(remindAt)
+	case gtk.RESPONSE_REJECT:
+		sn.Note.SetReminder(time.Time{})
+	default:
+		return
+	}
+	// The menu shows when the reminder is due
+	sn.PopulateMenu()
+}
@@ -0,0 +1,483 @@
+package stickynotes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Notes can also be synced with a notes server (Nextcloud Notes, see nextcloud.go). A
+// synced note keeps the server's id for it in its "remote_id" property, and what both
+// sides looked like at the last sync: "remote_modified" (the server's modification
+// time) and "remote_hash" (a hash of the text and category name here), with the account
+// they belong to in "remote_account". A sync compares both sides with that: the side
+// that changed wins, and when both did, the one changed last wins and the other text
+// goes to the note's history. A note deleted on one side is deleted on the other,
+// unless it changed there since. The server is called in the background and the results
+// are applied on the main loop, leaving out notes edited in the meantime (the next sync
+// takes them).
+
+// remoteSyncInterval (ms) is how often notes are synced with the server
+const remoteSyncInterval = 5 * 60 * 1000
+
+// errSyncNotConfigured is returned when syncing without a server set up
+var errSyncNotConfigured = errors.New("sync is not set up, see Settings → Sync")
+
+// RemoteNote is a note as stored on a notes server
+type RemoteNote struct {
+	ID       int64
+	Content  string
+	Category string // Category name, "" for none
+	Modified int64  // Unix time
+	ReadOnly bool
+}
+
+// SyncBackend is a notes server notes are synced with
+type SyncBackend interface {
+	Name() string
+	List() ([]RemoteNote, error)
+	Create(note RemoteNote) (RemoteNote, error)
+	Update(note RemoteNote) (RemoteNote, error)
+	Delete(id int64) error
+}
+
+// SyncResult counts what a sync changed
+type SyncResult struct {
+	Uploaded   int // Notes created or changed on the server
+	Downloaded int // Notes created or changed here
+	Deleted    int // Notes deleted on either side
+	Conflicts  int // Notes changed on both sides
+	Failed     int // Notes the server refused, with the first error returned
+}
+
+// String describes the result for the settings
+func (r SyncResult) String() string {
+	var parts []string
+	for _, count := range []struct {
+		n    int
+		what string
+	}{
+		{r.Uploaded, "sent"},
+		{r.Downloaded, "received"},
+		{r.Deleted, "deleted"},
+		{r.Conflicts, "changed on both sides"},
+		{r.Failed, "failed"},
+	} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count.n, count.what))
+		}
+	}
+	if len(parts) == 0 {
+		return "Synced, nothing changed"
+	}
+	return "Synced: " + strings.Join(parts, ", ")
+}
+
+// syncLocal is a note as it was when a sync started
+type syncLocal struct {
+	UUID           string
+	Content        string
+	Category       string // Category name
+	Modified       int64
+	RemoteID       int64 // 0 when never synced
+	RemoteModified int64
+	Hash           string // Of the text and category at the last sync
+	Trashed        bool
+	OtherAccount   bool // RemoteID is from an account no longer synced with
+}
+
+// changed reports whether the note changed here since the last sync
+func (l syncLocal) changed() bool {
+	return syncHash(l.Content, l.Category) != l.Hash
+}
+
+// syncAction is what a sync does with a note
+type syncAction int
+
+const (
+	syncCreateRemote syncAction = iota // New here, or deleted there but changed here
+	syncUpdateRemote                   // Changed here, or on both sides and here last
+	syncDeleteRemote                   // Deleted here
+	syncTakeRemote                     // Changed there, or on both sides and there last
+	syncCreateLocal                    // New there, or deleted here but changed there
+	syncDeleteLocal                    // Deleted there
+	syncUnlink                         // Deleted here and gone there too: forget the remote id
+)
+
+// syncOp is one change of a sync
+type syncOp struct {
+	Action   syncAction
+	UUID     string     // The local note, "" for syncCreateLocal
+	Remote   RemoteNote // What is written to the server, or taken from it
+	Conflict bool       // Both sides changed, the losing text goes to the history
+	Loser    string     // The server's text, when a conflict keeps the local one
+	Hash     string     // Hash of the local note when the sync started, what was uploaded
+	err      error
+}
+
+// syncHash identifies the text and category of a note
+func syncHash(content, category string) string {
+	sum := sha256.Sum256([]byte(category + "\x00" + content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// planSync works out the changes that bring the local notes and the server's in step
+func planSync(local []syncLocal, remote []RemoteNote) []syncOp {
+	byID := make(map[int64]RemoteNote, len(remote))
+	for _, r := range remote {
+		byID[r.ID] = r
+	}
+	linked := make(map[int64]bool)
+
+	var ops []syncOp
+	for _, l := range local {
+		upload := RemoteNote{ID: l.RemoteID, Content: l.Content, Category: l.Category}
+		hash := syncHash(l.Content, l.Category)
+		if l.RemoteID == 0 || l.OtherAccount {
+			// A note synced with another account is new to this one, its id means
+			// nothing here
+			upload.ID = 0
+			switch {
+			case !l.Trashed:
+				ops = append(ops, syncOp{Action: syncCreateRemote, UUID: l.UUID, Remote: upload, Hash: hash})
+			case l.RemoteID != 0:
+				ops = append(ops, syncOp{Action: syncUnlink, UUID: l.UUID})
+			}
+			continue
+		}
+		r, ok := byID[l.RemoteID]
+		if ok {
+			linked[r.ID] = true
+		}
+		remoteChanged := ok && r.Modified != l.RemoteModified
+
+		switch {
+		case !ok && l.Trashed:
+			ops = append(ops, syncOp{Action: syncUnlink, UUID: l.UUID})
+		case !ok && l.changed():
+			upload.ID = 0
+			ops = append(ops, syncOp{Action: syncCreateRemote, UUID: l.UUID, Remote: upload, Hash: hash})
+		case !ok:
+			ops = append(ops, syncOp{Action: syncDeleteLocal, UUID: l.UUID, Hash: hash})
+		case l.Trashed && (remoteChanged || r.ReadOnly):
+			// Changed there since: it comes back as a new note, the trashed one stays
+			ops = append(ops, syncOp{Action: syncUnlink, UUID: l.UUID})
+			ops = append(ops, syncOp{Action: syncCreateLocal, Remote: r})
+		case l.Trashed:
+			ops = append(ops, syncOp{Action: syncDeleteRemote, UUID: l.UUID, Remote: r})
+		case remoteChanged && l.changed():
+			if r.Content == l.Content && r.Category == l.Category {
+				ops = append(ops, syncOp{Action: syncTakeRemote, UUID: l.UUID, Remote: r, Hash: hash})
+			} else if r.Modified > l.Modified || r.ReadOnly {
+				ops = append(ops, syncOp{Action: syncTakeRemote, UUID: l.UUID, Remote: r, Hash: hash, Conflict: true})
+			} else {
+				ops = append(ops, syncOp{Action: syncUpdateRemote, UUID: l.UUID, Remote: upload, Hash: hash, Conflict: true, Loser: r.Content})
+			}
+		case remoteChanged:
+			ops = append(ops, syncOp{Action: syncTakeRemote, UUID: l.UUID, Remote: r, Hash: hash})
+		case l.changed() && !r.ReadOnly:
+			ops = append(ops, syncOp{Action: syncUpdateRemote, UUID: l.UUID, Remote: upload, Hash: hash})
+		}
+	}
+	for _, r := range remote {
+		if !linked[r.ID] {
+			ops = append(ops, syncOp{Action: syncCreateLocal, Remote: r})
+		}
+	}
+	return ops
+}
+
+// remoteProperty returns a remote sync property of the note as a number
+func (n *Note) remoteProperty(key string) int64 {
+	switch val := n.Properties[key].(type) {
+	case float64:
+		return int64(val)
+	case int64:
+		return val
+	case int:
+		return int64(val)
+	}
+	return 0
+}
+
+// remoteCategory returns the name of the note's category as the server knows it
+func (n *Note) remoteCategory() string {
+	if name, ok := n.NoteSet.Categories[n.Category]["name"].(string); ok {
+		return name
+	}
+	return ""
+}
+
+// syncSnapshot returns the note as a sync sees it
+func (n *Note) syncSnapshot(trashed bool) syncLocal {
+	hash, _ := n.Properties["remote_hash"].(string)
+	// Links made before the account was recorded are taken as the current account's
+	account, _ := n.Properties["remote_account"].(string)
+	return syncLocal{
+		UUID:           n.UUID,
+		Content:        n.Body,
+		Category:       n.remoteCategory(),
+		Modified:       n.LastModified.Unix(),
+		RemoteID:       n.remoteProperty("remote_id"),
+		RemoteModified: n.remoteProperty("remote_modified"),
+		Hash:           hash,
+		Trashed:        trashed,
+		OtherAccount:   account != "" && account != n.NoteSet.remoteAccount(),
+	}
+}
+
+// setSynced records that the note, as hash, and the server's copy are in step
+func (n *Note) setSynced(remote RemoteNote, hash string) {
+	n.Properties["remote_id"] = remote.ID
+	n.Properties["remote_modified"] = remote.Modified
+	n.Properties["remote_hash"] = hash
+	n.Properties["remote_account"] = n.NoteSet.remoteAccount()
+}
+
+// unlinkRemote forgets the server's copy of the note
+func (n *Note) unlinkRemote() {
+	delete(n.Properties, "remote_id")
+	delete(n.Properties, "remote_modified")
+	delete(n.Properties, "remote_hash")
+	delete(n.Properties, "remote_account")
+}
+
+// SyncRemote syncs the notes with the configured server in the background, then calls
+// done (optional) on the main loop. Called during a sync, it waits for that one.
+func (ns *NoteSet) SyncRemote(done func(SyncResult, error)) {
+	if done != nil {
+		ns.remoteSyncDone = append(ns.remoteSyncDone, done)
+	}
+	if ns.remoteSyncing {
+		return
+	}
+	finish := func(result SyncResult, err error) {
+		ns.remoteSyncing = false
+		if err != nil {
+			ns.remoteSyncError = err.Error()
+			fmt.Printf("[Sync] Sync with the server failed: %v\n", err)
+		} else {
+			ns.remoteSyncError = ""
+			ns.Properties["remote_last_sync"] = time.Now().Format(time.RFC3339)
+		}
+		ns.Save()
+		callbacks := ns.remoteSyncDone
+		ns.remoteSyncDone = nil
+		for _, done := range callbacks {
+			done(result, err)
+		}
+	}
+
+	backend, err := ns.syncBackend()
+	if err != nil {
+		finish(SyncResult{}, err)
+		return
+	}
+	ns.remoteSyncing = true
+
+	// Pending edits in the windows count
+	for _, note := range ns.Notes {
+		if note.GUI != nil && note.GUI.WinMain != nil {
+			note.GUI.UpdateNote()
+		}
+	}
+	local := make([]syncLocal, 0, len(ns.Notes)+len(ns.Trash))
+	for _, note := range ns.Notes {
+		local = append(local, note.syncSnapshot(false))
+	}
+	for _, note := range ns.Trash {
+		if note.remoteProperty("remote_id") != 0 {
+			local = append(local, note.syncSnapshot(true))
+		}
+	}
+
+	go func() {
+		remote, err := backend.List()
+		var ops []syncOp
+		if err == nil {
+			ops = planSync(local, remote)
+			runSyncOps(backend, ops)
+		}
+		glib.IdleAdd(func() bool {
+			if err != nil {
+				finish(SyncResult{}, err)
+			} else {
+				finish(ns.applySync(backend.Name(), ops))
+			}
+			return false // Don't repeat
+		})
+	}()
+}
+
+// runSyncOps makes the server's side of the changes, recording failures in the ops
+func runSyncOps(backend SyncBackend, ops []syncOp) {
+	for i := range ops {
+		op := &ops[i]
+		switch op.Action {
+		case syncCreateRemote:
+			op.Remote, op.err = backend.Create(op.Remote)
+		case syncUpdateRemote:
+			op.Remote, op.err = backend.Update(op.Remote)
+		case syncDeleteRemote:
+			op.err = backend.Delete(op.Remote.ID)
+		}
+	}
+}
+
+// applySync makes the local side of the changes. The error is the first the server
+// returned, the other changes are made anyway.
+func (ns *NoteSet) applySync(device string, ops []syncOp) (SyncResult, error) {
+	var result SyncResult
+	var firstErr error
+	findNote := func(uuid string) (*Note, bool) {
+		if note := ns.noteByUUID(uuid); note != nil {
+			return note, false
+		}
+		for _, note := range ns.Trash {
+			if note.UUID == uuid {
+				return note, true
+			}
+		}
+		return nil, false
+	}
+
+	for _, op := range ops {
+		if op.err != nil {
+			result.Failed++
+			if firstErr == nil {
+				firstErr = op.err
+			}
+			continue
+		}
+		note, trashed := findNote(op.UUID)
+		if note == nil && op.Action != syncCreateLocal {
+			continue
+		}
+		// A note edited since the sync started is left for the next one, the server
+		// has its new id or modification time already
+		edited := note != nil && syncHash(note.Body, note.remoteCategory()) != op.Hash
+		if op.Conflict {
+			result.Conflicts++
+		}
+
+		switch op.Action {
+		case syncCreateRemote, syncUpdateRemote:
+			note.setSynced(op.Remote, op.Hash)
+			if op.Conflict {
+				note.recordVersion(op.Loser)
+				note.RemoteEdit = &RemoteEdit{Device: device, Time: time.Unix(op.Remote.Modified, 0), Conflict: true}
+				if note.GUI != nil {
+					note.GUI.showRemoteEdit()
+				}
+			}
+			result.Uploaded++
+		case syncDeleteRemote, syncUnlink:
+			note.unlinkRemote()
+			if op.Action == syncDeleteRemote {
+				result.Deleted++
+			}
+		case syncTakeRemote:
+			if edited || trashed {
+				continue
+			}
+			note.takeRemoteNote(device, op.Remote)
+			result.Downloaded++
+		case syncCreateLocal:
+			note := NewNote(nil, NewStickyNote, ns, "")
+			note.takeRemoteNote(device, op.Remote)
+			ns.Notes = append(ns.Notes, note)
+			if visible, _ := ns.Properties["all_visible"].(bool); visible {
+				note.Show()
+			}
+			result.Downloaded++
+		case syncDeleteLocal:
+			if edited || trashed {
+				continue
+			}
+			note.unlinkRemote()
+			note.Hide()
+			for i, n := range ns.Notes {
+				if n == note {
+					ns.Notes = append(ns.Notes[:i], ns.Notes[i+1:]...)
+					break
+				}
+			}
+			note.DeletedAt = time.Now()
+			ns.Trash = append(ns.Trash, note)
+			ns.audit(AuditDelete, note, "deleted on "+device)
+			result.Deleted++
+		}
+	}
+	return result, firstErr
+}
+
+// takeRemoteNote replaces the note's text and category with the server's, keeping the
+// previous text in the history
+func (n *Note) takeRemoteNote(device string, remote RemoteNote) {
+	cat := ""
+	if remote.Category != "" {
+		var ok bool
+		if cat, ok = n.NoteSet.categoryByName(remote.Category); !ok {
+			cat = uuid.New().String()
+			n.NoteSet.Categories[cat] = map[string]interface{}{"name": remote.Category}
+		}
+	}
+	if n.Body != remote.Content {
+		n.recordVersion(n.Body)
+		n.Body = remote.Content
+		// Formatting ranges don't survive a new text, the server doesn't keep them
+		n.Formatting = nil
+	}
+	n.Category = cat
+	n.LastModified = time.Unix(remote.Modified, 0)
+	n.Revision++
+	n.EditedOn = deviceName()
+	n.RemoteEdit = &RemoteEdit{Device: device, Time: n.LastModified}
+	n.setSynced(remote, syncHash(remote.Content, n.remoteCategory()))
+
+	if sn := n.GUI; sn != nil && sn.WinMain != nil {
+		sn.reloadNote()
+		sn.LoadCSS()
+		sn.UpdateFont()
+		sn.showRemoteEdit()
+	}
+}
+
+// RemoteSyncStatus describes the last sync for the settings, "" before the first one
+func (ns *NoteSet) RemoteSyncStatus() string {
+	if ns.remoteSyncError != "" {
+		return "Last sync failed: " + ns.remoteSyncError
+	}
+	if last, ok := ns.Properties["remote_last_sync"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, last); err == nil {
+			return fmt.Sprintf("Last synced %s at %s", FormatDate(t), FormatClock(t))
+		}
+	}
+	return ""
+}
+
+// SyncEnabled reports whether the notes are synced with a server, note by note or as a
+// whole data file (see webdav.go)
+func (ns *NoteSet) SyncEnabled() bool {
+	return ns.RemoteSyncEnabled() || ns.WebDAVEnabled()
+}
+
+// StartRemoteSync syncs with the configured server now and then every few minutes
+func StartRemoteSync(ns *NoteSet) {
+	sync := func() {
+		if _, err := ns.syncBackend(); err == nil {
+			ns.SyncRemote(nil)
+		}
+	}
+	sync()
+	glib.TimeoutAdd(remoteSyncInterval, func() bool {
+		sync()
+		return true // Repeat
+	})
+}
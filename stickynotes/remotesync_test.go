@@ -0,0 +1,119 @@
+package stickynotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+// synced returns a local note as it was left by a sync with the remote note
+func synced(uuid string, remote RemoteNote) syncLocal {
+	return syncLocal{
+		UUID:           uuid,
+		Content:        remote.Content,
+		Category:       remote.Category,
+		Modified:       remote.Modified,
+		RemoteID:       remote.ID,
+		RemoteModified: remote.Modified,
+		Hash:           syncHash(remote.Content, remote.Category),
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	base := RemoteNote{ID: 7, Content: "Groceries", Category: "Home", Modified: 1000}
+	edited := base
+	edited.Content, edited.Modified = "Groceries\nmilk", 2000
+
+	localEdit := func(modified int64) syncLocal {
+		l := synced("a", base)
+		l.Content, l.Modified = "Groceries\nbread", modified
+		return l
+	}
+	trashed := synced("a", base)
+	trashed.Trashed = true
+
+	tests := []struct {
+		name   string
+		local  []syncLocal
+		remote []RemoteNote
+		want   []syncAction
+	}{
+		{"in step", []syncLocal{synced("a", base)}, []RemoteNote{base}, nil},
+		{"new here", []syncLocal{{UUID: "a", Content: "New"}}, nil, []syncAction{syncCreateRemote}},
+		{"new there", nil, []RemoteNote{base}, []syncAction{syncCreateLocal}},
+		{"changed here", []syncLocal{localEdit(1500)}, []RemoteNote{base}, []syncAction{syncUpdateRemote}},
+		{"changed there", []syncLocal{synced("a", base)}, []RemoteNote{edited}, []syncAction{syncTakeRemote}},
+		{"category changed here", []syncLocal{func() syncLocal {
+			l := synced("a", base)
+			l.Category = "Work"
+			return l
+		}()}, []RemoteNote{base}, []syncAction{syncUpdateRemote}},
+		{"deleted here", []syncLocal{trashed}, []RemoteNote{base}, []syncAction{syncDeleteRemote}},
+		{"deleted here, changed there", []syncLocal{trashed}, []RemoteNote{edited}, []syncAction{syncUnlink, syncCreateLocal}},
+		{"deleted on both sides", []syncLocal{trashed}, nil, []syncAction{syncUnlink}},
+		{"deleted there", []syncLocal{synced("a", base)}, nil, []syncAction{syncDeleteLocal}},
+		{"deleted there, changed here", []syncLocal{localEdit(1500)}, nil, []syncAction{syncCreateRemote}},
+		{"synced with another account", []syncLocal{func() syncLocal {
+			l := synced("a", base)
+			l.OtherAccount = true
+			return l
+		}()}, []RemoteNote{base}, []syncAction{syncCreateRemote, syncCreateLocal}},
+		{"synced with another account, deleted here", []syncLocal{func() syncLocal {
+			l := trashed
+			l.OtherAccount = true
+			return l
+		}()}, nil, []syncAction{syncUnlink}},
+		{"read-only changed here", []syncLocal{localEdit(1500)}, []RemoteNote{{ID: 7, Content: "Groceries", Category: "Home", Modified: 1000, ReadOnly: true}}, nil},
+	}
+	for _, tt := range tests {
+		var got []syncAction
+		for _, op := range planSync(tt.local, tt.remote) {
+			got = append(got, op.Action)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: actions = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanSyncConflicts(t *testing.T) {
+	base := RemoteNote{ID: 7, Content: "Groceries", Modified: 1000}
+	remote := base
+	remote.Content, remote.Modified = "Groceries\nmilk", 2000
+	local := synced("a", base)
+	local.Content = "Groceries\nbread"
+
+	// Changed here last: ours is uploaded, theirs goes to the history
+	local.Modified = 3000
+	ops := planSync([]syncLocal{local}, []RemoteNote{remote})
+	if len(ops) != 1 || ops[0].Action != syncUpdateRemote || !ops[0].Conflict || ops[0].Loser != remote.Content {
+		t.Errorf("changed here last: ops = %+v", ops)
+	} else if ops[0].Remote.ID != 7 || ops[0].Remote.Content != local.Content {
+		t.Errorf("changed here last: uploads %+v", ops[0].Remote)
+	}
+
+	// Changed there last: theirs is taken
+	local.Modified = 1500
+	ops = planSync([]syncLocal{local}, []RemoteNote{remote})
+	if len(ops) != 1 || ops[0].Action != syncTakeRemote || !ops[0].Conflict {
+		t.Errorf("changed there last: ops = %+v", ops)
+	}
+
+	// The same change on both sides is no conflict
+	local.Content = remote.Content
+	ops = planSync([]syncLocal{local}, []RemoteNote{remote})
+	if len(ops) != 1 || ops[0].Action != syncTakeRemote || ops[0].Conflict {
+		t.Errorf("same change: ops = %+v", ops)
+	}
+}
+
+func TestNextcloudNoteTitle(t *testing.T) {
+	for content, want := range map[string]string{
+		"Shopping\nmilk":   "Shopping",
+		"\n\n# Plans  \nx": "Plans",
+		"":                 "",
+	} {
+		if got := nextcloudNoteOf(RemoteNote{Content: content}).Title; got != want {
+			t.Errorf("title of %q = %q, want %q", content, got, want)
+		}
+	}
+}
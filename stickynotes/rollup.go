@@ -0,0 +1,70 @@
+package stickynotes
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A note can be rolled up to its top bar, by double-clicking the top bar or with "Roll
+// up" in the note menu, to keep many notes on screen without the clutter. Stored as
+// "rolled_up" in its properties, with the size to unroll to as "expanded_size".
+
+// RolledUp reports whether the note is rolled up to its top bar
+func (n *Note) RolledUp() bool {
+	rolled, _ := n.Properties["rolled_up"].(bool)
+	return rolled
+}
+
+// SetRolledUp rolls the note up to its top bar, or unrolls it to the size it had
+func (sn *StickyNote) SetRolledUp(rolled bool) {
+	if sn.WinMain == nil || rolled == sn.Note.RolledUp() {
+		return
+	}
+	if rolled {
+		w, h := sn.WinMain.GetSize()
+		sn.Note.Properties["rolled_up"] = true
+		sn.Note.Properties["expanded_size"] = []interface{}{float64(w), float64(h)}
+		sn.applyRolledUp()
+		// As small as the top bar lets it
+		sn.WinMain.Resize(w, 1)
+	} else {
+		size, ok := floatList(sn.Note.Properties["expanded_size"])
+		delete(sn.Note.Properties, "rolled_up")
+		delete(sn.Note.Properties, "expanded_size")
+		sn.applyRolledUp()
+		if ok && len(size) >= 2 {
+			sn.WinMain.Resize(int(size[0]), int(size[1]))
+			sn.LastKnownSize = [2]int{int(size[0]), int(size[1])}
+		}
+	}
+	sn.NoteSet.Save()
+}
+
+// applyRolledUp hides what is below the top bar of a rolled up note, after ShowAll()
+// showed it, or shows it again once unrolled
+func (sn *StickyNote) applyRolledUp() {
+	if !sn.Note.RolledUp() {
+		for _, widget := range sn.rolledHidden {
+			widget.Show()
+		}
+		sn.rolledHidden = nil
+		return
+	}
+	mainBox, err := getObject[*gtk.Box](sn.Builder, "mainBox")
+	if err != nil {
+		return
+	}
+	topBox, _ := getObject[*gtk.Box](sn.Builder, "topBox")
+	mainBox.GetChildren().Foreach(func(item interface{}) {
+		widget, ok := item.(*gtk.Widget)
+		if !ok || !widget.GetVisible() {
+			return
+		}
+		// The collapsed group's members are listed on the header, they stay
+		if topBox != nil && widget.Native() == topBox.Native() ||
+			sn.BoxGroup != nil && widget.Native() == sn.BoxGroup.Native() {
+			return
+		}
+		widget.Hide()
+		sn.rolledHidden = append(sn.rolledHidden, widget)
+	})
+}
@@ -0,0 +1,24 @@
+package stickynotes
+
+import "fmt"
+
+// Safe mode (--safe-mode) starts without what a misbehaving note or extension could
+// trip over: notes start hidden, the window-calls extension isn't used, notes get the
+// default colors and font, and the data file isn't watched for edits from other devices
+// nor synced automatically. Edits are still saved, but what safe mode turns off (hidden
+// notes, default colors) isn't written to the data file, so the next normal start shows
+// the notes as before.
+
+var safeMode bool
+
+// EnableSafeMode turns safe mode on. Call it before any note is built.
+func EnableSafeMode() {
+	safeMode = true
+	windowCallsAvailable = false
+	fmt.Printf("[SafeMode] Starting in safe mode\n")
+}
+
+// SafeMode reports whether safe mode is on
+func SafeMode() bool {
+	return safeMode
+}
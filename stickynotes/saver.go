@@ -0,0 +1,113 @@
+package stickynotes
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Once the application runs, Save doesn't write the data file itself: it serializes the
+// notes (their windows can only be read on the GTK main thread) and hands the snapshot
+// to a writer goroutine, which encodes it (see encode) and writes it to disk. Snapshots
+// handed over while one is being written replace each other, only the newest is written.
+// Flush waits until everything handed over is on disk, before quitting or restarting.
+
+// saveSnapshot is the content of the data file to write
+type saveSnapshot struct {
+	plain  []byte         // The notes, before encryption
+	key    *encryptionKey // Encrypting them, nil when the data file isn't encrypted
+	backup bool           // Keep the previous data file as the backup
+}
+
+// saveWriter writes snapshots to the data file in the background
+type saveWriter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending *saveSnapshot // Newest snapshot not written yet
+	writing bool          // A snapshot is being written
+	err     error         // Of the last write
+}
+
+// StartBackgroundSave makes Save write the data file in a goroutine from now on
+func (ns *NoteSet) StartBackgroundSave() {
+	if ns.saver != nil {
+		return
+	}
+	w := &saveWriter{}
+	w.cond = sync.NewCond(&w.mu)
+	ns.saver = w
+	go w.run(ns)
+}
+
+// Flush waits until the notes handed to the writer are written, returning the error of
+// the last write. Without the writer, Save already wrote them.
+func (ns *NoteSet) Flush() error {
+	if ns.saver == nil {
+		return nil
+	}
+	w := ns.saver
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.pending != nil || w.writing {
+		w.cond.Wait()
+	}
+	return w.err
+}
+
+// saveNow saves the notes and waits until they are written
+func (ns *NoteSet) saveNow() error {
+	if err := ns.Save(); err != nil {
+		return err
+	}
+	return ns.Flush()
+}
+
+// queue hands a snapshot to the writer, replacing the one waiting
+func (w *saveWriter) queue(snapshot *saveSnapshot) {
+	w.mu.Lock()
+	w.pending = snapshot
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+func (w *saveWriter) run(ns *NoteSet) {
+	w.mu.Lock()
+	for {
+		for w.pending == nil {
+			w.cond.Wait()
+		}
+		snapshot := w.pending
+		w.pending, w.writing = nil, true
+		w.mu.Unlock()
+
+		modTime, err := ns.writeSnapshot(snapshot)
+		glib.IdleAdd(func() bool {
+			ns.finishSave(modTime, err)
+			return false // Don't repeat
+		})
+
+		w.mu.Lock()
+		w.writing, w.err = false, err
+		w.cond.Broadcast()
+	}
+}
+
+// writeSnapshot writes a snapshot to the data file, returning the file's new
+// modification time. Safe to call off the main thread.
+func (ns *NoteSet) writeSnapshot(snapshot *saveSnapshot) (time.Time, error) {
+	data, err := encodeWith(snapshot.key, snapshot.plain)
+	if err == nil {
+		err = ns.writeDataFile(data, snapshot.backup)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(ns.DataPath())
+	if err != nil {
+		// Written, but the next save can't tell whether it changed since
+		return time.Time{}, nil
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,77 @@
+package stickynotes
+
+import "math"
+
+// window-calls reports and takes window geometry in the compositor's coordinates. On
+// scaled displays (125%, 150%, 200%...) these can be physical pixels, while GTK, the work
+// areas and the saved positions use logical ones, so notes came back at the wrong spot.
+// The window-calls backend translates between them with the factor measured from each
+// note window: the width the compositor reports against the width GTK allocated, once per
+// window as it is matched (sizes differ for a moment while it is resized). Until a note's
+// own factor is known, the last one measured is used (1 before any).
+
+// Measured factors are rounded to a scaleStep, and only trusted between minScale and
+// maxScale
+const (
+	scaleStep = 0.05
+	minScale  = 0.5
+	maxScale  = 4
+)
+
+// lastScale is the factor last measured from any note window, 0 before any
+var lastScale float64
+
+// measureScale returns the factor between the width the compositor reports and the
+// logical one, false when it can't be told
+func measureScale(reported, logical int) (float64, bool) {
+	if reported <= 0 || logical < noteMinSize {
+		return 0, false
+	}
+	scale := math.Round(float64(reported)/float64(logical)/scaleStep) * scaleStep
+	if scale < minScale || scale > maxScale {
+		return 0, false
+	}
+	return scale, true
+}
+
+// scalePoint multiplies a point or size by scale, rounded to whole pixels
+func scalePoint(v [2]int, scale float64) [2]int {
+	return [2]int{int(math.Round(float64(v[0]) * scale)), int(math.Round(float64(v[1]) * scale))}
+}
+
+// compositorScale returns the factor from the note's logical coordinates to the
+// compositor's
+func (sn *StickyNote) compositorScale() float64 {
+	switch {
+	case sn.scale > 0:
+		return sn.scale
+	case lastScale > 0:
+		return lastScale
+	}
+	return 1
+}
+
+// measureCompositorScale measures the factor from the geometry the compositor reported
+// for the note window, unless known for this window
+func (sn *StickyNote) measureCompositorScale(details WindowDetails) {
+	if sn.WinMain == nil || sn.scale > 0 {
+		return
+	}
+	if scale, ok := measureScale(details.Width, sn.WinMain.GetAllocatedWidth()); ok {
+		sn.scale, lastScale = scale, scale
+	}
+}
+
+// toCompositor translates a logical point or size of the note to the compositor's
+// coordinates
+func (sn *StickyNote) toCompositor(v [2]int) [2]int {
+	return scalePoint(v, sn.compositorScale())
+}
+
+// fromCompositor returns the logical position and size of the geometry the compositor
+// reported for the note window, measuring the factor first
+func (sn *StickyNote) fromCompositor(details WindowDetails) (pos, size [2]int) {
+	sn.measureCompositorScale(details)
+	scale := 1 / sn.compositorScale()
+	return scalePoint([2]int{details.X, details.Y}, scale), scalePoint([2]int{details.Width, details.Height}, scale)
+}
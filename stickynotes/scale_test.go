@@ -0,0 +1,36 @@
+package stickynotes
+
+import "testing"
+
+func TestMeasureScale(t *testing.T) {
+	tests := []struct {
+		reported, logical int
+		want              float64
+		ok                bool
+	}{
+		{200, 200, 1, true},
+		{250, 200, 1.25, true},
+		{301, 200, 1.5, true},
+		{400, 200, 2, true},
+		{200, 0, 0, false},
+		{200, 20, 0, false},
+		{0, 200, 0, false},
+		{1000, 200, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := measureScale(tt.reported, tt.logical)
+		if ok != tt.ok || ok && (got < tt.want-1e-9 || got > tt.want+1e-9) {
+			t.Errorf("measureScale(%d, %d) = %v, %v, want %v, %v", tt.reported, tt.logical, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestScalePoint(t *testing.T) {
+	if got := scalePoint([2]int{100, 201}, 1.5); got != [2]int{150, 302} {
+		t.Errorf("scalePoint() = %v, want [150 302]", got)
+	}
+	// Back and forth lands on the same logical point
+	if got := scalePoint(scalePoint([2]int{333, 777}, 1.25), 1/1.25); got != [2]int{333, 777} {
+		t.Errorf("scalePoint() round trip = %v, want [333 777]", got)
+	}
+}
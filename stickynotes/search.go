@@ -0,0 +1,185 @@
+package stickynotes
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// snippetContext is the number of characters shown around a match in search results
+const snippetContext = 30
+
+// SearchResult is a note matching a full-text search, with a snippet around the first match
+type SearchResult struct {
+	Note    *Note
+	Snippet string
+	Matches int
+}
+
+// Search returns the notes whose body contains query (case-insensitive), ordered by
+// category name and then by number of matches
+func (ns *NoteSet) Search(query string) []SearchResult {
+	var results []SearchResult
+	for _, note := range ns.Notes {
+		matches := findMatches(note.Body, query)
+		if len(matches) == 0 {
+			continue
+		}
+		results = append(results, SearchResult{
+			Note:    note,
+			Snippet: snippet(note.Body, matches[0]),
+			Matches: len(matches),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		ci := ns.CategoryName(results[i].Note.Category)
+		cj := ns.CategoryName(results[j].Note.Category)
+		if ci != cj {
+			return ci < cj
+		}
+		return results[i].Matches > results[j].Matches
+	})
+	return results
+}
+
+// snippet returns a single-line excerpt of body around the match at rune offsets m
+func snippet(body string, m [2]int) string {
+	runes := []rune(body)
+	start := m[0] - snippetContext
+	end := m[1] + snippetContext
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(runes) {
+		end, suffix = len(runes), ""
+	}
+	text := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	return prefix + text + suffix
+}
+
+// SearchWindow searches the body of every note and raises the chosen one
+type SearchWindow struct {
+	NoteSet *NoteSet
+	Window  *gtk.Window
+	Entry   *gtk.SearchEntry
+	List    *gtk.ListBox
+	rows    map[int]*Note // ListBox row index to note
+}
+
+// NewSearchWindow opens the global search window
+func NewSearchWindow(noteset *NoteSet) *SearchWindow {
+	sw := &SearchWindow{
+		NoteSet: noteset,
+		rows:    make(map[int]*Note),
+	}
+	noteset.RecordUsage(UsageSearch)
+
+	sw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	sw.Window.SetTitle("Search Notes")
+	sw.Window.SetDefaultSize(480, 420)
+	sw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	sw.Entry, _ = gtk.SearchEntryNew()
+	sw.Entry.SetPlaceholderText("Search all notes")
+	sw.Entry.Connect("search-changed", sw.refresh)
+	sw.Entry.Connect("stop-search", sw.Window.Destroy)
+	sw.Entry.Connect("activate", func() {
+		if row := sw.List.GetRowAtIndex(0); row != nil {
+			sw.onRowActivated(sw.List, row)
+		}
+	})
+	box.PackStart(sw.Entry, false, false, 0)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	sw.List, _ = gtk.ListBoxNew()
+	sw.List.SetActivateOnSingleClick(true)
+	sw.List.Connect("row-activated", sw.onRowActivated)
+	scrolled.Add(sw.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	sw.Window.Add(box)
+	sw.Window.ShowAll()
+	sw.Entry.GrabFocus()
+
+	return sw
+}
+
+// refresh re-runs the search and rebuilds the result list, grouped by category
+func (sw *SearchWindow) refresh() {
+	sw.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			sw.List.Remove(widget)
+		}
+	})
+	sw.rows = make(map[int]*Note)
+
+	query, _ := sw.Entry.GetText()
+	if strings.TrimSpace(query) == "" {
+		return
+	}
+
+	// Make sure unsaved edits are searched too
+	for _, note := range sw.NoteSet.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateNote()
+		}
+	}
+
+	lastCategory := ""
+	index := 0
+	for _, result := range sw.NoteSet.Search(query) {
+		category := sw.NoteSet.CategoryName(result.Note.Category)
+		if index == 0 || category != lastCategory {
+			header, _ := gtk.LabelNew("")
+			header.SetMarkup("<b>" + glib.MarkupEscapeText(category) + "</b>")
+			header.SetHAlign(gtk.ALIGN_START)
+			header.SetMarginTop(6)
+			row, _ := gtk.ListBoxRowNew()
+			row.Add(header)
+			row.SetSelectable(false)
+			row.SetActivatable(false)
+			sw.List.Add(row)
+			index++
+			lastCategory = category
+		}
+
+		title := result.Note.FirstLine()
+		if title == "" {
+			title = "(empty note)"
+		}
+		label, _ := gtk.LabelNew("")
+		label.SetMarkup("<b>" + glib.MarkupEscapeText(title) + "</b>\n<small>" + glib.MarkupEscapeText(result.Snippet) + "</small>")
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetLineWrap(true)
+		label.SetMarginStart(12)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		sw.List.Add(row)
+		sw.rows[index] = result.Note
+		index++
+	}
+	sw.List.ShowAll()
+}
+
+func (sw *SearchWindow) onRowActivated(list *gtk.ListBox, row *gtk.ListBoxRow) {
+	note, ok := sw.rows[row.GetIndex()]
+	if !ok {
+		return
+	}
+	note.Show()
+	// Give a freshly shown window time to get its window-calls ID before raising it
+	glib.TimeoutAdd(400, func() bool {
+		if note.GUI != nil {
+			note.GUI.Raise()
+		}
+		return false // Don't repeat
+	})
+}
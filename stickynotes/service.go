@@ -0,0 +1,130 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/gotk3/gotk3/glib"
+)
+
+// D-Bus service exported by the running application, so scripts and the CLI can
+// talk to it instead of writing the data file behind its back
+const (
+	ServiceName      = "io.github.runableapp.PostNote"
+	ServicePath      = dbus.ObjectPath("/io/github/runableapp/PostNote")
+	ServiceInterface = "io.github.runableapp.PostNote"
+)
+
+// noteService implements the exported D-Bus methods. Calls arrive on a D-Bus
+// goroutine and are run on the GTK main thread.
+type noteService struct {
+	noteset *NoteSet
+}
+
+// AppendToNote appends text to the note with the given UUID or title, creating and
+// showing it if missing. Returns the note's UUID.
+func (s *noteService) AppendToNote(target, text string) (string, *dbus.Error) {
+	result := make(chan string, 1)
+	glib.IdleAdd(func() bool {
+		note, created := s.noteset.AppendToNote(target, text)
+		if created {
+			note.Show()
+		}
+		s.noteset.Save()
+		result <- note.UUID
+		return false // Don't repeat
+	})
+	return <-result, nil
+}
+
+// ListActions returns the ids of the registered actions, see ActivateAction
+func (s *noteService) ListActions() ([]string, *dbus.Error) {
+	result := make(chan []string, 1)
+	glib.IdleAdd(func() bool {
+		var ids []string
+		for _, action := range s.noteset.Actions() {
+			ids = append(ids, action.ID)
+		}
+		result <- ids
+		return false // Don't repeat
+	})
+	return <-result, nil
+}
+
+// ActivateAction runs the action with the given id. Note actions run on the note with
+// the given UUID or title, which is shown first; target is ignored by the others.
+func (s *noteService) ActivateAction(id, target string) *dbus.Error {
+	result := make(chan error, 1)
+	glib.IdleAdd(func() bool {
+		var sn *StickyNote
+		if target != "" {
+			note := s.noteset.FindNote(target)
+			if note == nil {
+				result <- fmt.Errorf("no note %q", target)
+				return false
+			}
+			note.Show()
+			sn = note.GUI
+		}
+		result <- s.noteset.ActivateAction(id, sn)
+		return false // Don't repeat
+	})
+	if err := <-result; err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// ExportService registers the application's D-Bus service on the session bus.
+// Fails if another instance already owns the name.
+func ExportService(noteset *NoteSet) error {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return err
+	}
+
+	reply, err := conn.RequestName(ServiceName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("%s is already owned by another instance", ServiceName)
+	}
+
+	return conn.Export(&noteService{noteset: noteset}, ServicePath, ServiceInterface)
+}
+
+// ServiceRunning reports whether an application instance owns the D-Bus service
+func ServiceRunning() bool {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return false
+	}
+	var hasOwner bool
+	err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, ServiceName).Store(&hasOwner)
+	return err == nil && hasOwner
+}
+
+// CallAppendToNote asks the running application to append text to a note.
+// Returns the note's UUID.
+func CallAppendToNote(target, text string) (string, error) {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return "", err
+	}
+	var noteUUID string
+	obj := conn.Object(ServiceName, ServicePath)
+	err = obj.Call(ServiceInterface+".AppendToNote", 0, target, text).Store(&noteUUID)
+	return noteUUID, err
+}
+
+// CallActivateAction asks the running application to run an action, on the note with
+// the given UUID or title for note actions
+func CallActivateAction(id, target string) error {
+	conn, err := getDBusConnection()
+	if err != nil {
+		return err
+	}
+	obj := conn.Object(ServiceName, ServicePath)
+	return obj.Call(ServiceInterface+".ActivateAction", 0, id, target).Err
+}
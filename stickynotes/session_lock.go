@@ -0,0 +1,102 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Screen saver services that emit ActiveChanged(bool) when the screen locks/blanks
+// or becomes idle. GNOME uses its own interface, most other desktops the freedesktop one.
+var screenSaverInterfaces = []string{
+	"org.gnome.ScreenSaver",
+	"org.freedesktop.ScreenSaver",
+}
+
+// WatchSessionLock subscribes to screen lock / idle signals on D-Bus and calls
+// onLock or onUnlock on the GTK main thread when the session state changes.
+// Both the session bus screen saver signals and logind Lock/Unlock on the system
+// bus are used, so it works on GNOME, KDE and plain logind setups.
+func WatchSessionLock(onLock, onUnlock func()) error {
+	signals := make(chan *dbus.Signal, 10)
+	subscribed := false
+
+	conn, err := getDBusConnection()
+	if err == nil {
+		for _, iface := range screenSaverInterfaces {
+			if err := conn.AddMatchSignal(
+				dbus.WithMatchInterface(iface),
+				dbus.WithMatchMember("ActiveChanged"),
+			); err == nil {
+				subscribed = true
+			}
+		}
+		conn.Signal(signals)
+	}
+
+	if sysConn, err := dbus.ConnectSystemBus(); err == nil {
+		// Only listen to our own session, logind broadcasts Lock/Unlock for every session
+		var sessionPath dbus.ObjectPath
+		manager := sysConn.Object("org.freedesktop.login1", dbus.ObjectPath("/org/freedesktop/login1"))
+		err := manager.Call("org.freedesktop.login1.Manager.GetSessionByPID", 0, uint32(currentPID)).Store(&sessionPath)
+		if err == nil {
+			if err := sysConn.AddMatchSignal(
+				dbus.WithMatchInterface("org.freedesktop.login1.Session"),
+				dbus.WithMatchObjectPath(sessionPath),
+			); err == nil {
+				subscribed = true
+			}
+			sysConn.Signal(signals)
+		} else {
+			fmt.Printf("[SessionLock] Failed to get logind session: %v\n", err)
+		}
+	} else {
+		fmt.Printf("[SessionLock] Failed to connect to system bus: %v\n", err)
+	}
+
+	if !subscribed {
+		return fmt.Errorf("no session lock signals available")
+	}
+
+	go func() {
+		for sig := range signals {
+			locked, ok := sessionLockState(sig)
+			if !ok {
+				continue
+			}
+			// D-Bus signals arrive on a separate goroutine, GTK calls must happen on the main thread
+			glib.IdleAdd(func() bool {
+				if locked {
+					onLock()
+				} else {
+					onUnlock()
+				}
+				return false // Don't repeat
+			})
+		}
+	}()
+
+	return nil
+}
+
+// sessionLockState maps a D-Bus signal to a locked/unlocked state.
+// Returns ok=false for signals unrelated to session locking.
+func sessionLockState(sig *dbus.Signal) (locked bool, ok bool) {
+	switch sig.Name {
+	case "org.freedesktop.login1.Session.Lock":
+		return true, true
+	case "org.freedesktop.login1.Session.Unlock":
+		return false, true
+	}
+
+	for _, iface := range screenSaverInterfaces {
+		if sig.Name == iface+".ActiveChanged" && len(sig.Body) > 0 {
+			if active, isBool := sig.Body[0].(bool); isBool {
+				return active, true
+			}
+		}
+	}
+
+	return false, false
+}
@@ -20,6 +20,8 @@ type SettingsCategory struct {
 	CbText         *gtk.ColorButton
 	EName          *gtk.Entry
 	FbFont         *gtk.FontButton
+	EAccel         *gtk.Entry
+	CbTexture      *gtk.ComboBoxText
 }
 
 // NewSettingsCategory creates a new settings category widget
@@ -61,6 +63,8 @@ func NewSettingsCategory(settingsDialog *SettingsDialog, cat string) *SettingsCa
 	sc.CbText, _ = getObject[*gtk.ColorButton](sc.Builder, "cbText")
 	sc.EName, _ = getObject[*gtk.Entry](sc.Builder, "eName")
 	sc.FbFont, _ = getObject[*gtk.FontButton](sc.Builder, "fbFont")
+	sc.EAccel, _ = getObject[*gtk.Entry](sc.Builder, "eAccel")
+	sc.CbTexture, _ = getObject[*gtk.ComboBoxText](sc.Builder, "cbTexture")
 
 	// Set initial values
 	name := "New Category"
@@ -168,8 +172,25 @@ func NewSettingsCategory(settingsDialog *SettingsDialog, cat string) *SettingsCa
 	}
 	sc.FbFont.SetFont(fontName)
 
+	// Set new note shortcut
+	if accel, ok := sc.NoteSet.GetCategoryProperty(cat, "accel").(string); ok {
+		sc.EAccel.SetText(accel)
+	}
+
+	// Set background texture
+	if sc.CbTexture != nil {
+		sc.CbTexture.Append(TextureNone, "None")
+		for _, preset := range texturePresets {
+			sc.CbTexture.Append(preset.Name, preset.Label)
+		}
+		sc.CbTexture.Append(textureImageID, "Image…")
+		sc.showTexture()
+		sc.CbTexture.Connect("changed", sc.OnUpdateTexture)
+	}
+
 	// Connect signals
 	sc.EName.Connect("changed", sc.OnENameChanged)
+	sc.EAccel.Connect("changed", sc.OnUpdateAccel)
 	sc.CbBG.Connect("color-set", sc.OnUpdateBG)
 	sc.CbText.Connect("color-set", sc.OnUpdateTextColor)
 	sc.FbFont.Connect("font-set", sc.OnUpdateFont)
@@ -339,6 +360,76 @@ func (sc *SettingsCategory) OnUpdateFont() {
 	}
 }
 
+// showTexture selects the category's texture in the combo box, an image showing its
+// path as tooltip
+func (sc *SettingsCategory) showTexture() {
+	texture, _ := sc.NoteSet.GetCategoryProperty(sc.Cat, "texture").(string)
+	switch {
+	case texture == "":
+		sc.CbTexture.SetActiveID(TextureNone)
+	case isTexturePreset(texture):
+		sc.CbTexture.SetActiveID(texture)
+	default:
+		sc.CbTexture.SetActiveID(textureImageID)
+		sc.CbTexture.SetTooltipText(texture)
+		return
+	}
+	sc.CbTexture.SetTooltipText("")
+}
+
+func (sc *SettingsCategory) OnUpdateTexture() {
+	texture := sc.CbTexture.GetActiveID()
+	if texture == textureImageID {
+		file := chooseTextureImage(sc.SettingsDialog.WSettings)
+		if file == "" {
+			// Cancelled, show the texture still in use
+			sc.showTexture()
+			return
+		}
+		texture = file
+	}
+	if sc.NoteSet.Categories[sc.Cat] == nil {
+		sc.NoteSet.Categories[sc.Cat] = make(map[string]interface{})
+	}
+	if texture == TextureNone {
+		delete(sc.NoteSet.Categories[sc.Cat], "texture")
+	} else {
+		sc.NoteSet.Categories[sc.Cat]["texture"] = texture
+	}
+	sc.NoteSet.Save()
+	sc.showTexture()
+
+	// Update all notes
+	for _, note := range sc.NoteSet.Notes {
+		if note.GUI != nil {
+			note.GUI.LoadCSS()
+		}
+	}
+}
+
+func (sc *SettingsCategory) OnUpdateAccel() {
+	text, _ := sc.EAccel.GetText()
+	if sc.NoteSet.Categories[sc.Cat] == nil {
+		sc.NoteSet.Categories[sc.Cat] = make(map[string]interface{})
+	}
+	if text == "" {
+		delete(sc.NoteSet.Categories[sc.Cat], "accel")
+	} else {
+		// Only store shortcuts GTK can parse, normalized to GTK's accelerator syntax
+		key, mods := gtk.AcceleratorParse(text)
+		if key == 0 {
+			return
+		}
+		sc.NoteSet.Categories[sc.Cat]["accel"] = gtk.AcceleratorName(key, mods)
+	}
+	// Rebind shortcuts on all notes
+	for _, note := range sc.NoteSet.Notes {
+		if note.GUI != nil {
+			note.GUI.UpdateAccelerators()
+		}
+	}
+}
+
 func (sc *SettingsCategory) OnMakeDefault() {
 	sc.NoteSet.Properties["default_cat"] = sc.Cat
 	sc.SettingsDialog.RefreshCategoryTitles()
@@ -416,6 +507,20 @@ func NewSettingsDialog(noteset *NoteSet) *SettingsDialog {
 		sd.AddCategoryWidgets(cat)
 	}
 
+	// General tab options
+	sd.connectGeneralSettings()
+
+	// Sync tab, after General
+	if nb, err := getObject[*gtk.Notebook](sd.Builder, "nbSettings"); err == nil {
+		page, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 18)
+		page.SetBorderWidth(10)
+		page.PackStart(sd.syncSettings(), false, false, 0)
+		page.PackStart(sd.webdavSettings(), false, false, 0)
+		page.ShowAll()
+		label, _ := gtk.LabelNewWithMnemonic("_Sync")
+		nb.AppendPage(page, label)
+	}
+
 	// Show the dialog
 	sd.WSettings.ShowAll()
 
@@ -461,6 +566,14 @@ func (sd *SettingsDialog) OnNewCategory() {
 }
 
 func (sd *SettingsDialog) DeleteCategory(cat string) {
+	sd.NoteSet.backupBefore(BackupDeleteCategory)
+	moved := 0
+	for _, note := range sd.NoteSet.Notes {
+		if note.Category == cat {
+			moved++
+		}
+	}
+	sd.NoteSet.auditCategory(cat, moved)
 	delete(sd.NoteSet.Categories, cat)
 	if sc, ok := sd.Categories[cat]; ok {
 		sc.CatExpander.Destroy()
@@ -486,6 +599,153 @@ func (sd *SettingsDialog) connectSignals() {
 	// Signals are connected in OnNewCategory
 }
 
+// connectGeneralSettings wires the widgets in the General tab to NoteSet properties
+func (sd *SettingsDialog) connectGeneralSettings() {
+	sd.bindCheckProperty("chkHideOnLock", "hide_on_lock")
+	if chk := sd.bindCheckProperty("chkHideFullscreen", "hide_fullscreen"); chk != nil {
+		chk.Connect("toggled", sd.NoteSet.checkFullscreen)
+	}
+	if chk := sd.bindCheckProperty("chkLint", "lint_enabled"); chk != nil {
+		chk.Connect("toggled", func() {
+			for _, note := range sd.NoteSet.Notes {
+				if note.GUI != nil {
+					note.GUI.UpdateLint()
+				}
+			}
+		})
+	}
+	sd.bindCheckProperty("chkCalc", "calc_on_equals")
+	sd.bindCheckProperty("chkSounds", "sound_enabled")
+	if chk := sd.bindCheckProperty("chkForceX11", "force_x11"); chk != nil && !Backend().CanPlace() {
+		// Point out the option where it matters: on Wayland without the extension
+		chk.SetTooltipText("Window positions can't be saved on Wayland without the Window Calls extension. Running through XWayland restores native positioning. Takes effect after a restart.")
+	}
+	if chk := sd.bindCheckProperty("chkUsage", "usage_enabled"); chk != nil {
+		chk.Connect("toggled", func() {
+			if !chk.GetActive() {
+				sd.NoteSet.ClearUsage()
+			}
+		})
+	}
+	if box, err := getObject[*gtk.Box](sd.Builder, "boxGeneral"); err == nil {
+		aging := sd.agingSettings()
+		box.PackStart(aging, false, false, 0)
+		// Below the check buttons, above the icon set
+		box.ReorderChild(aging, 7)
+		snap := sd.snapSettings()
+		box.PackStart(snap, false, false, 0)
+		box.ReorderChild(snap, 8)
+		encryption := sd.encryptionSettings()
+		box.PackStart(encryption, false, false, 0)
+		box.ReorderChild(encryption, 9)
+		digest := sd.digestSettings()
+		box.PackStart(digest, false, false, 0)
+		box.ReorderChild(digest, 10)
+	}
+	sd.connectIconSettings()
+	sd.connectCacheSettings()
+}
+
+// connectIconSettings wires the button icon set chooser and reloads the icons of all notes on change
+func (sd *SettingsDialog) connectIconSettings() {
+	cbIconSet, err := getObject[*gtk.ComboBoxText](sd.Builder, "cbIconSet")
+	if err != nil {
+		return
+	}
+	fcIconDir, err := getObject[*gtk.FileChooserButton](sd.Builder, "fcIconDir")
+	if err != nil {
+		return
+	}
+
+	if set, ok := sd.NoteSet.Properties["icon_set"].(string); ok && set != "" {
+		cbIconSet.SetActiveID(set)
+	}
+	if dir, ok := sd.NoteSet.Properties["icon_dir"].(string); ok && dir != "" {
+		fcIconDir.SetFilename(dir)
+	}
+	fcIconDir.SetSensitive(cbIconSet.GetActiveID() == IconSetCustom)
+
+	reloadIcons := func() {
+		for _, note := range sd.NoteSet.Notes {
+			if note.GUI != nil {
+				note.GUI.LoadIcons()
+			}
+		}
+		sd.NoteSet.Save()
+	}
+	cbIconSet.Connect("changed", func() {
+		set := cbIconSet.GetActiveID()
+		sd.NoteSet.Properties["icon_set"] = set
+		fcIconDir.SetSensitive(set == IconSetCustom)
+		reloadIcons()
+	})
+	fcIconDir.Connect("file-set", func() {
+		sd.NoteSet.Properties["icon_dir"] = fcIconDir.GetFilename()
+		reloadIcons()
+	})
+}
+
+// connectCacheSettings shows the resource cache path with buttons to clear or re-extract it.
+// The row is hidden when resources are not extracted to disk.
+func (sd *SettingsDialog) connectCacheSettings() {
+	box, err := getObject[*gtk.Box](sd.Builder, "boxResourceCache")
+	if err != nil {
+		return
+	}
+	cache, ok := globalResourceGetter.(ResourceCache)
+	if !ok {
+		box.SetNoShowAll(true)
+		box.Hide()
+		return
+	}
+	if lPath, err := getObject[*gtk.Label](sd.Builder, "lCachePath"); err == nil {
+		lPath.SetText(cache.CachePath())
+	}
+
+	run := func(action func() error, failure string) {
+		if err := action(); err != nil {
+			dialog := gtk.MessageDialogNew(sd.WSettings, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "%s", failure)
+			dialog.FormatSecondaryText("%v", err)
+			dialog.Run()
+			dialog.Destroy()
+		}
+	}
+	if bClear, err := getObject[*gtk.Button](sd.Builder, "bClearCache"); err == nil {
+		bClear.Connect("clicked", func() {
+			run(cache.ClearCache, "Error clearing the resource cache.")
+		})
+	}
+	if bExtract, err := getObject[*gtk.Button](sd.Builder, "bExtractResources"); err == nil {
+		bExtract.Connect("clicked", func() {
+			run(cache.ExtractResources, "Error extracting resources.")
+		})
+	}
+}
+
+// bindCheckProperty binds a check button to a boolean NoteSet property, saving on toggle
+func (sd *SettingsDialog) bindCheckProperty(id, prop string) *gtk.CheckButton {
+	chk, err := getObject[*gtk.CheckButton](sd.Builder, id)
+	if err != nil {
+		return nil
+	}
+	if val, ok := sd.NoteSet.Properties[prop].(bool); ok {
+		chk.SetActive(val)
+	}
+	chk.Connect("toggled", func() {
+		sd.NoteSet.Properties[prop] = chk.GetActive()
+		sd.NoteSet.Save()
+	})
+	return chk
+}
+
+// addGridRow adds a widget with its label on the left to a settings grid
+func addGridRow(grid *gtk.Grid, row int, text string, widget gtk.IWidget) {
+	label, _ := gtk.LabelNew(text)
+	label.SetHAlign(gtk.ALIGN_END)
+	grid.Attach(label, 0, row, 1, 1)
+	grid.Attach(widget, 1, row, 1, 1)
+}
+
 // Helper functions
 func rgbToHSV(r, g, b float64) [3]float64 {
 	max := r
@@ -0,0 +1,80 @@
+package stickynotes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// GNOME Shell signals WindowsChanged on its Introspect interface when a window is opened
+// or closed. The signal carries nothing, and GetWindows is only answered to the shell's
+// own tools, so the window tracker then reads the windows from the window-calls List and
+// Details. Moves aren't signalled: the geometry of the windows is still asked for.
+
+// shellIntrospectInterface is GNOME Shell's Introspect interface (GNOME 3.34 and later)
+const (
+	shellIntrospectInterface = "org.gnome.Shell.Introspect"
+	shellIntrospectPath      = dbus.ObjectPath("/org/gnome/Shell/Introspect")
+)
+
+// startShellTracker subscribes to WindowsChanged, when GNOME Shell has it
+func startShellTracker() error {
+	var xml string
+	if err := windowCall(shellIntrospectPath, "org.freedesktop.DBus.Introspectable.Introspect").Store(&xml); err != nil {
+		return err
+	}
+	if !strings.Contains(xml, `"WindowsChanged"`) {
+		return fmt.Errorf("%s has no WindowsChanged signal", shellIntrospectInterface)
+	}
+	conn, err := getDBusConnection()
+	if err != nil {
+		return err
+	}
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface(shellIntrospectInterface),
+		dbus.WithMatchMember("WindowsChanged"),
+	); err != nil {
+		return err
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig.Name != shellIntrospectInterface+".WindowsChanged" {
+				continue
+			}
+			postWindowEvent(func(t *windowTracker) { t.windowsChanged() })
+		}
+	}()
+	return nil
+}
+
+// windowsChanged matches the windows of this process opened since the last signal with
+// their notes, and forgets the ones closed
+func (t *windowTracker) windowsChanged() {
+	windows, err := ListWindows()
+	if err != nil {
+		fmt.Printf("[Tracker] Failed to list the windows: %v\n", err)
+		return
+	}
+	open := make(map[uint32]bool, len(windows))
+	for _, win := range windows {
+		open[win.ID] = true
+		if _, ok := t.windows[win.ID]; ok || win.PID != currentPID {
+			continue
+		}
+		// List doesn't always have the full title, Details does
+		details, err := GetWindowDetails(win.ID)
+		if err != nil || details == nil {
+			continue
+		}
+		t.windowAdded(*details)
+	}
+	for id := range t.windows {
+		if !open[id] {
+			t.windowRemoved(id)
+		}
+	}
+}
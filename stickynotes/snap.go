@@ -0,0 +1,125 @@
+package stickynotes
+
+import (
+	"math"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// When the user is done moving a note, it snaps to the edges of the monitor's work area
+// and of the other shown notes (next to them or aligned with them) within the snapping
+// distance, or else to a grid when one is set. Stored as "snap_distance" (px, 0 turns
+// edge snapping off) and "snap_grid" (px, 0 for no grid). Notes pinned to a corner are
+// left where the corner puts them.
+
+// DefaultSnapDistance is how close (px) a note snaps to an edge unless set otherwise
+const DefaultSnapDistance = 12
+
+// Snapping returns the snapping distance and grid size (px), 0 when off
+func (ns *NoteSet) Snapping() (distance, grid int) {
+	distance = DefaultSnapDistance
+	if px, ok := ns.Properties["snap_distance"].(float64); ok && px >= 0 {
+		distance = int(px)
+	}
+	if px, ok := ns.Properties["snap_grid"].(float64); ok && px >= 0 {
+		grid = int(px)
+	}
+	return distance, grid
+}
+
+// SetSnapping sets the snapping distance and grid size (px), 0 to turn them off
+func (ns *NoteSet) SetSnapping(distance, grid int) {
+	ns.Properties["snap_distance"] = float64(distance)
+	ns.Properties["snap_grid"] = float64(grid)
+	ns.Save()
+}
+
+// snapPosition returns where a note of size at pos snaps to, given the work area and the
+// other notes (x, y, width, height)
+func snapPosition(pos, size [2]int, area [4]int, notes [][4]int, distance, grid int) [2]int {
+	for axis := range 2 {
+		other := 1 - axis
+		// The edges of the area, inside it
+		candidates := []int{area[axis], area[axis] + area[2+axis] - size[axis]}
+		for _, r := range notes {
+			// Only notes beside this one on the other axis
+			if pos[other]+size[other] < r[other]-distance || pos[other] > r[other]+r[2+other]+distance {
+				continue
+			}
+			candidates = append(candidates,
+				r[axis]+r[2+axis],            // After it
+				r[axis]-size[axis],           // Before it
+				r[axis],                      // Aligned with its start
+				r[axis]+r[2+axis]-size[axis]) // Aligned with its end
+		}
+
+		best, bestDistance := pos[axis], distance+1
+		for _, c := range candidates {
+			if d := int(math.Abs(float64(c - pos[axis]))); d < bestDistance {
+				best, bestDistance = c, d
+			}
+		}
+		if bestDistance > distance {
+			best = pos[axis]
+		}
+		if bestDistance > distance && grid > 0 {
+			steps := math.Round(float64(pos[axis]-area[axis]) / float64(grid))
+			best = area[axis] + int(steps)*grid
+		}
+		pos[axis] = best
+	}
+	return pos
+}
+
+// snapIntoPlace moves the note where it snaps to, once the user has moved it
+func (sn *StickyNote) snapIntoPlace() {
+	distance, grid := sn.NoteSet.Snapping()
+	if distance == 0 && grid == 0 || sn.Note.Corner() != CornerNone || sn.LastKnownSize[0] <= 1 {
+		return
+	}
+	pos, size := sn.LastKnownPos, sn.LastKnownSize
+	var notes [][4]int
+	for _, note := range sn.NoteSet.Notes {
+		// Members of its group follow it, they aren't where they will be
+		if note == sn.Note || !isNoteShown(note) || sn.Note.Group() != "" && note.Group() == sn.Note.Group() {
+			continue
+		}
+		other := note.GUI
+		notes = append(notes, [4]int{other.LastKnownPos[0], other.LastKnownPos[1], other.LastKnownSize[0], other.LastKnownSize[1]})
+	}
+	area := workareaAt(pos[0]+size[0]/2, pos[1]+size[1]/2)
+	if snapped := snapPosition(pos, size, area, notes, distance, grid); snapped != pos {
+		sn.moveTo(snapped[0], snapped[1])
+	}
+}
+
+// snapSettings builds the snapping row of the General settings
+func (sd *SettingsDialog) snapSettings() *gtk.Box {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	label, _ := gtk.LabelNew("Snap moved notes to edges within")
+	label.SetHAlign(gtk.ALIGN_START)
+	box.PackStart(label, true, true, 0)
+
+	distance, grid := sd.NoteSet.Snapping()
+	spinDistance, _ := gtk.SpinButtonNewWithRange(0, 100, 1)
+	spinDistance.SetValue(float64(distance))
+	spinDistance.SetTooltipText("How close a note must be to the edge of the screen or of another note, 0 to turn it off")
+	box.PackStart(spinDistance, false, false, 0)
+	gridLabel, _ := gtk.LabelNew("px, to a grid of")
+	box.PackStart(gridLabel, false, false, 0)
+	spinGrid, _ := gtk.SpinButtonNewWithRange(0, 500, 1)
+	spinGrid.SetValue(float64(grid))
+	spinGrid.SetTooltipText("Notes away from any edge snap to a grid of this size, 0 for no grid")
+	box.PackStart(spinGrid, false, false, 0)
+	pxLabel, _ := gtk.LabelNew("px")
+	box.PackStart(pxLabel, false, false, 0)
+
+	update := func() {
+		sd.NoteSet.SetSnapping(spinDistance.GetValueAsInt(), spinGrid.GetValueAsInt())
+	}
+	spinDistance.Connect("value-changed", update)
+	spinGrid.Connect("value-changed", update)
+
+	box.ShowAll()
+	return box
+}
@@ -0,0 +1,30 @@
+package stickynotes
+
+import "testing"
+
+func TestSnapPosition(t *testing.T) {
+	area := [4]int{0, 0, 1920, 1040}
+	size := [2]int{200, 150}
+	notes := [][4]int{{500, 300, 200, 150}}
+	tests := []struct {
+		name           string
+		pos            [2]int
+		distance, grid int
+		want           [2]int
+	}{
+		{"far from everything", [2]int{1000, 600}, 12, 0, [2]int{1000, 600}},
+		{"left edge of the screen", [2]int{8, 600}, 12, 0, [2]int{0, 600}},
+		{"bottom right of the screen", [2]int{1715, 885}, 12, 0, [2]int{1720, 890}},
+		{"right of a note, aligned with its top", [2]int{705, 295}, 12, 0, [2]int{700, 300}},
+		{"below a note, aligned with its left", [2]int{496, 459}, 12, 0, [2]int{500, 450}},
+		{"note out of reach on the other axis", [2]int{705, 800}, 12, 0, [2]int{705, 800}},
+		{"grid away from the edges", [2]int{1013, 588}, 12, 50, [2]int{1000, 600}},
+		{"edge before grid", [2]int{5, 588}, 12, 50, [2]int{0, 600}},
+		{"off", [2]int{8, 600}, 0, 0, [2]int{8, 600}},
+	}
+	for _, tt := range tests {
+		if got := snapPosition(tt.pos, size, area, notes, tt.distance, tt.grid); got != tt.want {
+			t.Errorf("%s: snapPosition(%v) = %v, want %v", tt.name, tt.pos, got, tt.want)
+		}
+	}
+}
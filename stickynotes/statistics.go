@@ -0,0 +1,92 @@
+package stickynotes
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// NewStatisticsDialog shows a report about the noteset: note counts per category
+// and tag, the note hygiene hints and, when enabled, local usage insights
+func NewStatisticsDialog(noteset *NoteSet) {
+	var builder *gtk.Builder
+	uiContent, err := getEmbeddedUI("GlobalDialogs.ui")
+	if err != nil {
+		uiPath := filepath.Join(GetBasePath(), "GlobalDialogs.ui")
+		builder, err = gtk.BuilderNewFromFile(uiPath)
+	} else {
+		builder, err = gtk.BuilderNewFromString(uiContent)
+	}
+	if err != nil {
+		fmt.Printf("Error loading UI file: %v\n", err)
+		return
+	}
+
+	dialog, err := getObject[*gtk.Dialog](builder, "StatisticsWindow")
+	if err != nil {
+		fmt.Printf("Error getting StatisticsWindow: %v\n", err)
+		return
+	}
+
+	if tv, err := getObject[*gtk.TextView](builder, "tvStatistics"); err == nil {
+		buffer, _ := tv.GetBuffer()
+		buffer.SetText(noteset.StatisticsReport())
+	}
+
+	dialog.Run()
+	dialog.Destroy()
+}
+
+// StatisticsReport renders the statistics shown in the statistics window as plain text
+func (ns *NoteSet) StatisticsReport() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Notes: %d\n", len(ns.Notes))
+	fmt.Fprintf(&sb, "Categories: %d\n", len(ns.Categories))
+	fmt.Fprintf(&sb, "Tags: %d\n", len(ns.AllTags()))
+
+	// Notes per category
+	perCat := make(map[string]int)
+	for _, note := range ns.Notes {
+		perCat[ns.CategoryName(note.Category)]++
+	}
+	names := make([]string, 0, len(perCat))
+	for name := range perCat {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sb.WriteString("\nNotes per category:\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %s: %d\n", name, perCat[name])
+	}
+
+	// Note hygiene
+	sb.WriteString("\nNote hints:\n")
+	report := ns.LintReport()
+	if len(report) == 0 {
+		sb.WriteString("  No stale, overlong or duplicate notes\n")
+	}
+	for _, note := range ns.Notes {
+		warnings, ok := report[note]
+		if !ok {
+			continue
+		}
+		title := note.FirstLine()
+		if title == "" {
+			title = "(empty note)"
+		}
+		fmt.Fprintf(&sb, "  %s\n", title)
+		for _, w := range warnings {
+			fmt.Fprintf(&sb, "    - %s\n", w.Message)
+		}
+	}
+
+	// Opt-in local usage insights
+	sb.WriteString("\nUsage insights:\n")
+	sb.WriteString(ns.UsageReport())
+
+	return sb.String()
+}
@@ -0,0 +1,360 @@
+package stickynotes
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// When the data file is shared between machines (e.g. synced with Nextcloud or
+// Syncthing), another device can change it while the application runs. Every note
+// carries a revision, bumped on each edit, and the device it was last edited on.
+// A newer revision on disk from another device is loaded into notes without local
+// changes, and notes created there are added; when both sides changed, the local text
+// wins and the other device's text is kept in the note's history, and an open note asks
+// which one to keep. Either way the note shows who edited it and when. A note gone from
+// the file that was there when it was last read or written was deleted on the other
+// device, and goes to the trash here too unless it changed here since. The data file's
+// folder is watched with inotify, so changes show up as soon as they are written.
+
+// dataWatchInterval is how often (ms) the data file is checked for changes when it
+// can't be watched
+const dataWatchInterval = 3000
+
+// dataSettleDelay is how long (ms) to wait after the data file changed before reading
+// it, letting the writer finish
+const dataSettleDelay = 300
+
+// RemoteEdit describes a change to a note made on another device
+type RemoteEdit struct {
+	Device   string
+	Time     time.Time
+	Conflict bool // The note was changed here too, the other version went to the history
+}
+
+// deviceName identifies this machine in the data file
+func deviceName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown"
+	}
+	return host
+}
+
+// WatchDataFile checks the data file for changes made by other devices or instances as
+// soon as they are written, or periodically when its folder can't be watched
+func WatchDataFile(ns *NoteSet) {
+	if err := ns.watchDataDir(); err != nil {
+		fmt.Printf("[Sync] Can't watch the data file, checking it every %d ms: %v\n", dataWatchInterval, err)
+		glib.TimeoutAdd(dataWatchInterval, func() bool {
+			ns.checkRemoteChanges()
+			return true // Repeat
+		})
+	}
+}
+
+// watchDataDir watches the data file's folder with inotify: the file is replaced by
+// renaming (here and by sync tools), so the folder is watched rather than the file
+func (ns *NoteSet) watchDataDir() error {
+	path := ns.DataPath()
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	name := filepath.Base(path)
+	var pending atomic.Bool // A check is scheduled
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err != nil {
+				if err == syscall.EINTR {
+					continue
+				}
+				fmt.Printf("[Sync] Stopped watching the data file: %v\n", err)
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+				if strings.TrimRight(string(nameBytes), "\x00") != name || !pending.CompareAndSwap(false, true) {
+					continue
+				}
+				glib.IdleAdd(func() bool {
+					glib.TimeoutAdd(dataSettleDelay, func() bool {
+						pending.Store(false)
+						ns.checkRemoteChanges()
+						return false // Don't repeat
+					})
+					return false // Don't repeat
+				})
+			}
+		}
+	}()
+	return nil
+}
+
+// markSaved remembers the data file's modification time, the revisions it holds and
+// its content (plain, before encryption), after the noteset was read from it
+func (ns *NoteSet) markSaved(plain []byte) {
+	if info, err := os.Stat(ns.DataPath()); err == nil {
+		ns.dataModTime = info.ModTime()
+	}
+	ns.rememberSaved(plain)
+}
+
+// rememberSaved remembers the content of the data file (plain, before encryption) and the
+// revisions it holds, as read or as Save writes it
+func (ns *NoteSet) rememberSaved(plain []byte) {
+	ns.savedSum = sha256.Sum256(plain)
+	ns.savedKey = ns.encryption
+	for _, note := range ns.Notes {
+		note.savedRevision = note.Revision
+		note.onDisk = true
+	}
+}
+
+// checkRemoteChanges looks for notes changed on another device since the data file was
+// last read or written, and takes their changes in
+func (ns *NoteSet) checkRemoteChanges() {
+	if ns.dataModTime.IsZero() {
+		return
+	}
+	info, err := os.Stat(ns.DataPath())
+	if err != nil || info.ModTime().Equal(ns.dataModTime) {
+		return
+	}
+	lastSync := ns.dataModTime
+	ns.dataModTime = info.ModTime()
+
+	data, err := os.ReadFile(ns.DataPath())
+	if err == nil {
+		data, err = ns.decode(data)
+	}
+	if err != nil {
+		return
+	}
+	if _, err := ns.takeRemoteChanges(data, lastSync, ns.onDiskUUIDs()); err != nil {
+		// Probably caught halfway through a sync, the next check reads it again
+		fmt.Printf("[Sync] Data file changed but can't be read yet: %v\n", err)
+		ns.dataModTime = lastSync
+	}
+}
+
+// takeRemoteChanges takes in the notes of a copy of the data file (decrypted) that
+// other devices changed or created since lastSync. before holds the UUIDs of the notes
+// in the previous version of that copy: the ones gone from it were deleted on another
+// device. With a nil before, nothing is taken as deleted. Returns the UUIDs in the copy.
+func (ns *NoteSet) takeRemoteChanges(data []byte, lastSync time.Time, before map[string]bool) (map[string]bool, error) {
+	var disk struct {
+		Notes []map[string]interface{} `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &disk); err != nil {
+		return nil, err
+	}
+
+	host := deviceName()
+	inCopy := make(map[string]bool, len(disk.Notes))
+	for _, content := range disk.Notes {
+		uuid, _ := content["uuid"].(string)
+		inCopy[uuid] = true
+	}
+	ns.takeRemoteDeletions(before, inCopy)
+	for _, content := range disk.Notes {
+		device, _ := content["edited_on"].(string)
+		rev, _ := content["rev"].(float64)
+		if device == "" || device == host {
+			continue
+		}
+		uuid, _ := content["uuid"].(string)
+		note := ns.noteByUUID(uuid)
+		if note == nil {
+			ns.takeRemoteNote(content, lastSync)
+			continue
+		}
+		if int(rev) <= note.savedRevision {
+			continue
+		}
+		note.takeRemoteEdit(NewNote(content, nil, ns, ""))
+	}
+	return inCopy, nil
+}
+
+// onDiskUUIDs returns the UUIDs of the notes in the data file when it was last read or
+// written
+func (ns *NoteSet) onDiskUUIDs() map[string]bool {
+	uuids := make(map[string]bool, len(ns.Notes))
+	for _, note := range ns.Notes {
+		if note.onDisk {
+			uuids[note.UUID] = true
+		}
+	}
+	return uuids
+}
+
+// takeRemoteDeletions moves the notes deleted on another device to the trash: the ones
+// in a copy of the notes before (UUIDs) but not in its new version, inCopy. A note
+// changed here since is kept, and saved again.
+func (ns *NoteSet) takeRemoteDeletions(before, inCopy map[string]bool) {
+	var deleted []*Note
+	for _, note := range ns.Notes {
+		if !before[note.UUID] || inCopy[note.UUID] || note.deletePending {
+			continue
+		}
+		// Pending edits in the window count as local changes
+		if note.GUI != nil && note.GUI.WinMain != nil {
+			note.GUI.UpdateNote()
+		}
+		if note.Revision == note.savedRevision {
+			deleted = append(deleted, note)
+		}
+	}
+	for _, note := range deleted {
+		note.Hide()
+		for i, n := range ns.Notes {
+			if n == note {
+				ns.Notes = append(ns.Notes[:i], ns.Notes[i+1:]...)
+				break
+			}
+		}
+		note.onDisk = false
+		note.DeletedAt = time.Now()
+		ns.Trash = append(ns.Trash, note)
+		ns.audit(AuditDelete, note, "deleted on another device")
+	}
+}
+
+// takeRemoteNote adds a note created on another device since the data file was last
+// synced. Older notes missing here were deleted here, and stay deleted.
+func (ns *NoteSet) takeRemoteNote(content map[string]interface{}, lastSync time.Time) {
+	remote := NewNote(content, NewStickyNote, ns, "")
+	if !remote.LastModified.After(lastSync) {
+		return
+	}
+	for _, note := range ns.Trash {
+		if note.UUID == remote.UUID {
+			return
+		}
+	}
+	remote.savedRevision = remote.Revision
+	remote.onDisk = true
+	remote.RemoteEdit = &RemoteEdit{Device: remote.EditedOn, Time: remote.LastModified}
+	ns.Notes = append(ns.Notes, remote)
+	if visible, _ := ns.Properties["all_visible"].(bool); visible {
+		remote.Show()
+	}
+}
+
+// noteByUUID returns the note with the given UUID, nil if there is none
+func (ns *NoteSet) noteByUUID(uuid string) *Note {
+	for _, note := range ns.Notes {
+		if note.UUID == uuid {
+			return note
+		}
+	}
+	return nil
+}
+
+// takeRemoteEdit takes in the newer version of the note saved by another device
+func (n *Note) takeRemoteEdit(remote *Note) {
+	// Pending edits in the window count as local changes
+	if n.GUI != nil && n.GUI.WinMain != nil {
+		n.GUI.UpdateNote()
+	}
+
+	edit := &RemoteEdit{Device: remote.EditedOn, Time: remote.LastModified}
+	if n.Revision == n.savedRevision {
+		// No local changes: take theirs, keeping ours in the history
+		n.recordVersion(n.Body)
+		n.Body = remote.Body
+		n.Formatting = remote.Formatting
+		n.Tags = remote.Tags
+		n.LastModified = remote.LastModified
+		n.EditedOn = remote.EditedOn
+		n.Revision = remote.Revision
+		if n.GUI != nil && n.GUI.WinMain != nil {
+			n.GUI.reloadNote()
+		}
+	} else {
+		// Both changed: keep ours, theirs goes to the history, and ours is saved as newer
+		edit.Conflict = true
+		n.recordVersion(remote.Body)
+		n.Revision = max(n.Revision, remote.Revision) + 1
+		n.EditedOn = deviceName()
+	}
+	n.savedRevision = remote.Revision
+	n.RemoteEdit = edit
+
+	if n.GUI != nil {
+		n.GUI.showRemoteEdit()
+		if edit.Conflict && n.GUI.WinMain != nil && n.GUI.WinMain.GetVisible() {
+			sn := n.GUI
+			glib.IdleAdd(func() bool {
+				sn.askRemoteConflict(remote)
+				return false // Don't repeat
+			})
+		}
+	}
+}
+
+// askRemoteConflict asks which text to keep when the open note was changed here and on
+// another device. Ours was kept and theirs went to the history; taking theirs swaps them.
+func (sn *StickyNote) askRemoteConflict(remote *Note) {
+	if sn.WinMain == nil {
+		return
+	}
+	dialog := gtk.MessageDialogNew(sn.WinMain, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE,
+		"\"%s\" was also changed on %s.", sn.Note.FirstLine(), remote.EditedOn)
+	dialog.FormatSecondaryText("Keep the text edited here, or take the one from %s (%s)? The other one stays in History….",
+		remote.EditedOn, FormatAgo(remote.LastModified))
+	dialog.AddButton("Take Theirs", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Keep Mine", gtk.RESPONSE_ACCEPT)
+	dialog.SetDefaultResponse(gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+	if response != gtk.RESPONSE_REJECT || sn.Note.Body == remote.Body {
+		return
+	}
+	sn.NoteSet.resolve(ConflictNote{Theirs: remote, Mine: sn.Note}, conflictTakeTheirs)
+	sn.hideRemoteEdit()
+	sn.NoteSet.Save()
+}
+
+// showRemoteEdit shows in the footer that the note was edited on another device
+func (sn *StickyNote) showRemoteEdit() {
+	edit := sn.Note.RemoteEdit
+	if sn.LRemoteEdit == nil || edit == nil {
+		return
+	}
+	sn.LRemoteEdit.SetText(fmt.Sprintf("edited on %s at %s", edit.Device, FormatClock(edit.Time)))
+	if edit.Conflict {
+		sn.LRemoteEdit.SetTooltipText("This note was also changed here. Your text was kept; the other version is in History…")
+	} else {
+		sn.LRemoteEdit.SetTooltipText("Changed on another device. The previous text is in History…")
+	}
+	sn.LRemoteEdit.Show()
+}
+
+// hideRemoteEdit hides the hint once the note is edited here
+func (sn *StickyNote) hideRemoteEdit() {
+	sn.Note.RemoteEdit = nil
+	if sn.LRemoteEdit != nil {
+		sn.LRemoteEdit.Hide()
+	}
+}
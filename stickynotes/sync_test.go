@@ -0,0 +1,48 @@
+package stickynotes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTakeRemoteDeletions(t *testing.T) {
+	ns := newTestNoteSet(t)
+	if err := ns.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	ns.rememberSaved([]byte(ns.Dumps()))
+	// Changed here since the file was read, and created here since
+	findNote(t, ns, "note-0002").Revision++
+	ns.Notes = append(ns.Notes, NewNote(map[string]interface{}{"uuid": "note-0005", "body": "new"}, nil, ns, ""))
+
+	// Another device deleted all of them
+	if _, err := ns.takeRemoteChanges([]byte(`{"notes": []}`), time.Now(), ns.onDiskUUIDs()); err != nil {
+		t.Fatalf("takeRemoteChanges: %v", err)
+	}
+	if ns.noteByUUID("note-0001") != nil {
+		t.Error("note deleted on the other device is still there")
+	}
+	trashed := false
+	for _, note := range ns.Trash {
+		if note.UUID == "note-0001" {
+			trashed = !note.DeletedAt.IsZero()
+		}
+	}
+	if !trashed {
+		t.Error("note deleted on the other device isn't in the trash")
+	}
+	for _, uuid := range []string{"note-0002", "note-0005"} {
+		if ns.noteByUUID(uuid) == nil {
+			t.Errorf("%s was deleted, but changed or created here since", uuid)
+		}
+	}
+
+	// Saved again, the notes kept are in the file and not deleted by the same check
+	ns.rememberSaved([]byte(ns.Dumps()))
+	if _, err := ns.takeRemoteChanges([]byte(ns.Dumps()), time.Now(), ns.onDiskUUIDs()); err != nil {
+		t.Fatalf("takeRemoteChanges: %v", err)
+	}
+	if len(ns.Notes) != 2 {
+		t.Errorf("got %d notes, want 2", len(ns.Notes))
+	}
+}
@@ -0,0 +1,151 @@
+package stickynotes
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Background textures are drawn over the note's background color. The "texture"
+// property of a category (or of a note, overriding its category) holds a preset name
+// or the path of a user-supplied image; a note can set "none" to drop its category's
+// texture. Presets are CSS gradients in a faint shade of the text color.
+
+// Texture presets
+const (
+	TextureNone   = "none"
+	TexturePaper  = "paper"
+	TextureGrid   = "grid"
+	TextureDotted = "dotted"
+)
+
+// texturePresets lists the bundled textures with their labels, in menu order
+var texturePresets = []struct {
+	Name  string
+	Label string
+}{
+	{TexturePaper, "Paper"},
+	{TextureGrid, "Grid"},
+	{TextureDotted, "Dotted"},
+}
+
+// textureImageID is the combo/menu entry for picking a user-supplied image
+const textureImageID = "image"
+
+// isTexturePreset reports whether texture names a bundled preset
+func isTexturePreset(texture string) bool {
+	for _, preset := range texturePresets {
+		if preset.Name == texture {
+			return true
+		}
+	}
+	return false
+}
+
+// Texture returns the note's texture (a preset name or an image path), "" for none
+func (n *Note) Texture() string {
+	texture, ok := n.Properties["texture"].(string)
+	if !ok {
+		texture, _ = n.CatProp("texture").(string)
+	}
+	if texture == TextureNone {
+		return ""
+	}
+	return texture
+}
+
+// textureCSS returns the background image rules for texture, with the preset lines
+// drawn in the text color
+func textureCSS(texture string, textColor []float64) string {
+	if texture == "" {
+		return ""
+	}
+	line := fmt.Sprintf("rgba(%d, %d, %d, 0.14)", int(textColor[0]*255), int(textColor[1]*255), int(textColor[2]*255))
+
+	var rules string
+	switch texture {
+	case TexturePaper:
+		rules = fmt.Sprintf("    background-image: repeating-linear-gradient(to bottom, transparent 0px, transparent 23px, %s 23px, %s 24px);\n", line, line)
+	case TextureGrid:
+		rules = fmt.Sprintf("    background-image: linear-gradient(to right, %s 1px, transparent 1px), linear-gradient(to bottom, %s 1px, transparent 1px);\n    background-size: 16px 16px;\n", line, line)
+	case TextureDotted:
+		rules = fmt.Sprintf("    background-image: radial-gradient(circle, %s 1.5px, transparent 2px);\n    background-size: 14px 14px;\n", line)
+	default:
+		if !filepath.IsAbs(texture) {
+			return ""
+		}
+		image := (&url.URL{Scheme: "file", Path: texture}).String()
+		rules = fmt.Sprintf("    background-image: url(\"%s\");\n    background-size: cover;\n", image)
+	}
+	return "\n#txt-note text\n{\n" + rules + "}\n"
+}
+
+// chooseTextureImage asks for a background image. Returns "" when cancelled.
+func chooseTextureImage(parent gtk.IWindow) string {
+	dialog, _ := gtk.FileChooserDialogNewWith2Buttons("Background Image", parent, gtk.FILE_CHOOSER_ACTION_OPEN, "Cancel", gtk.RESPONSE_CANCEL, "Open", gtk.RESPONSE_ACCEPT)
+	if filter, err := gtk.FileFilterNew(); err == nil {
+		filter.SetName("Images")
+		filter.AddPixbufFormats()
+		dialog.AddFilter(filter)
+	}
+	response := dialog.Run()
+	file := dialog.GetFilename()
+	dialog.Destroy()
+
+	if response != gtk.RESPONSE_ACCEPT {
+		return ""
+	}
+	return file
+}
+
+// textureMenu builds the note's "Background" submenu: the category's texture, none,
+// the presets, or an image
+func (sn *StickyNote) textureMenu() *gtk.Menu {
+	menu, _ := gtk.MenuNew()
+	current, overridden := sn.Note.Properties["texture"].(string)
+
+	var group *glib.SList
+	add := func(label string, active bool, apply func()) {
+		item, _ := gtk.RadioMenuItemNewWithLabel(group, label)
+		group, _ = item.GetGroup()
+		item.SetActive(active)
+		item.Connect("toggled", func() {
+			if item.GetActive() {
+				apply()
+			}
+		})
+		menu.Append(item)
+		item.Show()
+	}
+
+	add("Category default", !overridden, func() { sn.setTexture("", false) })
+	add("None", overridden && current == TextureNone, func() { sn.setTexture(TextureNone, true) })
+	for _, preset := range texturePresets {
+		name := preset.Name // Capture for closure
+		add(preset.Label, overridden && current == name, func() { sn.setTexture(name, true) })
+	}
+	isImage := overridden && current != TextureNone && !isTexturePreset(current)
+	add("Image…", isImage, func() {
+		if file := chooseTextureImage(sn.WinMain); file != "" {
+			sn.setTexture(file, true)
+		} else {
+			// Keep showing the texture that is still in use
+			sn.PopulateMenu()
+		}
+	})
+	return menu
+}
+
+// setTexture sets the note's own texture, or goes back to the category's
+func (sn *StickyNote) setTexture(texture string, override bool) {
+	if override {
+		sn.Note.Properties["texture"] = texture
+	} else {
+		delete(sn.Note.Properties, "texture")
+	}
+	sn.LoadCSS()
+	sn.NoteSet.Save()
+}
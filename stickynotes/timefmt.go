@@ -0,0 +1,152 @@
+package stickynotes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Timestamps shown to the user go through these helpers, so they follow the locale's
+// date order (LC_TIME) and the desktop's 12/24-hour clock setting. Go has no locale
+// support, so dates are numeric in the locale's usual order. Timestamps stored in the
+// data file and exports keep their fixed, machine-readable layouts.
+
+const (
+	// gnomeInterfaceSchema holds the desktop's clock-format ("12h" or "24h")
+	gnomeInterfaceSchema = "org.gnome.desktop.interface"
+)
+
+// timeLayouts are the display layouts for the current locale and clock preference
+type timeLayouts struct {
+	date  string // Full date
+	day   string // Date without the year
+	clock string // Hours and minutes
+}
+
+var (
+	layoutsOnce    sync.Once
+	currentLayouts timeLayouts
+)
+
+// localeTimeLayouts returns the display layouts, worked out once
+func localeTimeLayouts() timeLayouts {
+	layoutsOnce.Do(func() {
+		locale := timeLocale()
+		currentLayouts = dateLayoutsFor(locale)
+		if uses12HourClock(locale) {
+			currentLayouts.clock = "3:04 PM"
+		} else {
+			currentLayouts.clock = "15:04"
+		}
+	})
+	return currentLayouts
+}
+
+// timeLocale returns the locale used for times, e.g. "en_US"
+func timeLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			// Drop the encoding and modifier: en_US.UTF-8@euro
+			value, _, _ = strings.Cut(value, ".")
+			value, _, _ = strings.Cut(value, "@")
+			return value
+		}
+	}
+	return "C"
+}
+
+// dateLayoutsFor returns the date layouts in the locale's usual order
+func dateLayoutsFor(locale string) timeLayouts {
+	language, region, _ := strings.Cut(locale, "_")
+	switch {
+	case locale == "C" || locale == "POSIX":
+		return timeLayouts{date: "2006-01-02", day: "01-02"}
+	case region == "US" || region == "PH" || region == "FM" || region == "MH":
+		// Month first
+		return timeLayouts{date: "01/02/2006", day: "01/02"}
+	}
+	switch language {
+	case "zh", "ja", "ko", "hu", "lt", "sv", "mn":
+		// Year first
+		return timeLayouts{date: "2006-01-02", day: "01-02"}
+	case "de", "ru", "pl", "cs", "sk", "fi", "nb", "nn", "no", "da", "tr", "uk", "ro", "et", "lv", "sl", "hr", "sr", "bg", "be", "kk", "is":
+		return timeLayouts{date: "02.01.2006", day: "02.01."}
+	case "nl":
+		return timeLayouts{date: "02-01-2006", day: "02-01"}
+	}
+	// Day first
+	return timeLayouts{date: "02/01/2006", day: "02/01"}
+}
+
+// uses12HourClock reports whether times are shown with AM/PM: the desktop setting when
+// there is one, the locale's habit otherwise
+func uses12HourClock(locale string) bool {
+	if source := glib.SettingsSchemaSourceGetDefault(); source != nil && source.Lookup(gnomeInterfaceSchema, true) != nil {
+		if settings := glib.SettingsNew(gnomeInterfaceSchema); settings != nil {
+			switch settings.GetString("clock-format") {
+			case "12h":
+				return true
+			case "24h":
+				return false
+			}
+		}
+	}
+	_, region, _ := strings.Cut(locale, "_")
+	switch region {
+	case "US", "CA", "AU", "NZ", "IN", "PH", "PK", "EG", "SA", "MY", "BD":
+		return true
+	}
+	return false
+}
+
+// FormatDate shows a date, e.g. 01/02/2006
+func FormatDate(t time.Time) string {
+	return t.Format(localeTimeLayouts().date)
+}
+
+// FormatDay shows a date without the year when it falls in the current year
+func FormatDay(t time.Time) string {
+	if t.Year() != time.Now().Year() {
+		return FormatDate(t)
+	}
+	return t.Format(localeTimeLayouts().day)
+}
+
+// FormatClock shows the time of day, e.g. 15:04 or 3:04 PM
+func FormatClock(t time.Time) string {
+	return t.Format(localeTimeLayouts().clock)
+}
+
+// FormatDateTime shows a date with the time of day
+func FormatDateTime(t time.Time) string {
+	return FormatDate(t) + " " + FormatClock(t)
+}
+
+// FormatDayTime shows a date, without the year when it falls in the current year, with
+// the time of day
+func FormatDayTime(t time.Time) string {
+	return FormatDay(t) + " " + FormatClock(t)
+}
+
+// FormatAgo shows how long ago t was, e.g. "5 min ago", falling back to the date for
+// anything older than a week
+func FormatAgo(t time.Time) string {
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%d min ago", int(elapsed/time.Minute))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%d h ago", int(elapsed/time.Hour))
+	case elapsed < 48*time.Hour:
+		return "yesterday"
+	case elapsed < 7*24*time.Hour:
+		return fmt.Sprintf("%d days ago", int(elapsed/(24*time.Hour)))
+	}
+	return FormatDay(t)
+}
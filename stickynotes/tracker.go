@@ -0,0 +1,113 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Where the window manager reports the note windows being added, moved and removed, the
+// window tracker follows them instead of polling window-calls: a note is matched with
+// its window as soon as it is mapped, and restored to its position right then instead
+// of after a fixed delay. KWin reports them through a script that stays loaded (see
+// kwin.go), which also reports the moves, so the geometry is known without a call, and
+// whether the active window is fullscreen (see fullscreen.go). GNOME Shell only signals
+// windows being opened and closed (see shell_introspect.go). Without either, the notes
+// keep matching their windows by polling.
+
+// windowTracker holds the note windows the window manager reported. Only used from the
+// GTK main loop, events are passed to it with glib.IdleAdd.
+type windowTracker struct {
+	ns      *NoteSet
+	windows map[uint32]WindowDetails // Last reported, by window id
+	moves   bool                     // Moves are reported, the geometry in windows is current
+
+	activeFullscreen bool // The active window is another application's, fullscreen
+	activeKnown      bool // The active window was reported
+}
+
+// tracker is nil while the window manager doesn't report the note windows
+var (
+	tracker       *windowTracker
+	trackingTried bool // Started once, a window manager without the events isn't asked again
+)
+
+// startWindowTracking follows the note windows of ns from the window manager's events,
+// when it reports them
+func startWindowTracking(ns *NoteSet) {
+	if tracker != nil || trackingTried {
+		return
+	}
+	trackingTried = true
+	if KWinScripting() {
+		if err := startKWinTracker(); err != nil {
+			fmt.Printf("[Tracker] KWin doesn't report the note windows, polling instead: %v\n", err)
+			return
+		}
+		tracker = &windowTracker{ns: ns, windows: make(map[uint32]WindowDetails), moves: true}
+		fmt.Printf("[Tracker] Following the note windows from KWin\n")
+		return
+	}
+	if err := startShellTracker(); err != nil {
+		fmt.Printf("[Tracker] GNOME Shell doesn't report the note windows, polling instead: %v\n", err)
+		return
+	}
+	tracker = &windowTracker{ns: ns, windows: make(map[uint32]WindowDetails)}
+	fmt.Printf("[Tracker] Following the note windows from GNOME Shell\n")
+}
+
+// trackedWindow returns the last reported geometry of a window, when the window manager
+// reports the moves
+func trackedWindow(windowID uint32) (WindowDetails, bool) {
+	if tracker == nil || !tracker.moves || windowID == 0 {
+		return WindowDetails{}, false
+	}
+	details, ok := tracker.windows[windowID]
+	return details, ok
+}
+
+// postWindowEvent runs an event of the window manager on the GTK main loop
+func postWindowEvent(event func(t *windowTracker)) {
+	glib.IdleAdd(func() bool {
+		if tracker != nil {
+			event(tracker)
+		}
+		return false
+	})
+}
+
+// windowAdded matches a window just mapped, or renamed, with its note, and restores the
+// note's position if it waits for it
+func (t *windowTracker) windowAdded(details WindowDetails) {
+	t.windows[details.ID] = details
+	for _, note := range t.ns.Notes {
+		sn := note.GUI
+		if sn == nil || sn.WinMain == nil || !matchesNoteWindowTitle(details.Title, note.UUID) {
+			continue
+		}
+		if sn.WindowID != details.ID && !sn.claimWindowID(details.ID) {
+			return
+		}
+		sn.measureCompositorScale(details)
+		if restore := sn.pendingRestore; restore != nil {
+			restore()
+		}
+		return
+	}
+}
+
+// windowMoved records the new geometry of a note window
+func (t *windowTracker) windowMoved(details WindowDetails) {
+	t.windows[details.ID] = details
+	if sn := t.ns.windowIDs.Owner(details.ID); sn != nil && sn.pendingRestore == nil {
+		sn.onConfigure()
+	}
+}
+
+// windowRemoved forgets a note window unmapped or closed
+func (t *windowTracker) windowRemoved(windowID uint32) {
+	delete(t.windows, windowID)
+	if sn := t.ns.windowIDs.Owner(windowID); sn != nil {
+		sn.releaseWindowID()
+	}
+}
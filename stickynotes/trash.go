@@ -0,0 +1,230 @@
+package stickynotes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// DefaultTrashDays is how long deleted notes stay in the trash unless set in the
+// "trash_days" property (0 keeps them until the trash is emptied)
+const DefaultTrashDays = 30
+
+// TrashDays returns after how many days notes in the trash are purged, 0 meaning never
+func (ns *NoteSet) TrashDays() int {
+	if days, ok := ns.Properties["trash_days"].(float64); ok && days >= 0 {
+		return int(days)
+	}
+	return DefaultTrashDays
+}
+
+// SetTrashDays sets after how many days notes in the trash are purged, and purges
+func (ns *NoteSet) SetTrashDays(days int) {
+	ns.Properties["trash_days"] = float64(days)
+	ns.PurgeTrash()
+	ns.Save()
+}
+
+// Restore moves a note from the trash back to the noteset. An expired note loses its
+// expiry, or the next check would move it back to the trash.
+func (n *Note) Restore() {
+	ns := n.NoteSet
+	for i, note := range ns.Trash {
+		if note == n {
+			ns.Trash = append(ns.Trash[:i], ns.Trash[i+1:]...)
+			break
+		}
+	}
+	n.DeletedAt = time.Time{}
+	if n.Expired(time.Now()) {
+		delete(n.Properties, "expire_on")
+		delete(n.Properties, "expire_idle_days")
+	}
+	ns.Notes = append(ns.Notes, n)
+	ns.Save()
+}
+
+// EmptyTrash permanently deletes all notes in the trash, with their attachments
+func (ns *NoteSet) EmptyTrash() {
+	for _, note := range ns.Trash {
+		ns.audit(AuditPurge, note, "trash emptied")
+		note.RemoveAttachments()
+		note.RemoveHistory()
+	}
+	ns.Trash = nil
+	ns.Save()
+}
+
+// PurgeTrash permanently deletes the notes that have been in the trash longer than
+// TrashDays. Returns how many were purged; the caller saves.
+func (ns *NoteSet) PurgeTrash() int {
+	days := ns.TrashDays()
+	if days == 0 {
+		return 0
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	kept := ns.Trash[:0]
+	purged := 0
+	for _, note := range ns.Trash {
+		if note.DeletedAt.Before(cutoff) {
+			ns.audit(AuditPurge, note, fmt.Sprintf("in the trash over %d days", days))
+			note.RemoveAttachments()
+			note.RemoveHistory()
+			purged++
+			continue
+		}
+		kept = append(kept, note)
+	}
+	ns.Trash = kept
+	return purged
+}
+
+// TrashWindow lists the deleted notes, to restore them or empty the trash
+type TrashWindow struct {
+	NoteSet *NoteSet
+	Window  *gtk.Window
+	List    *gtk.ListBox
+	rows    map[int]*Note // ListBox row index to note
+}
+
+// NewTrashWindow opens the trash browser
+func NewTrashWindow(noteset *NoteSet) *TrashWindow {
+	tw := &TrashWindow{
+		NoteSet: noteset,
+		rows:    make(map[int]*Note),
+	}
+
+	tw.Window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	tw.Window.SetTitle("Trash")
+	tw.Window.SetDefaultSize(420, 380)
+	tw.Window.SetPosition(gtk.WIN_POS_CENTER)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	box.SetBorderWidth(8)
+
+	scrolled, _ := gtk.ScrolledWindowNew(nil, nil)
+	scrolled.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scrolled.SetShadowType(gtk.SHADOW_IN)
+	tw.List, _ = gtk.ListBoxNew()
+	tw.List.SetSelectionMode(gtk.SELECTION_MULTIPLE)
+	tw.List.Connect("row-activated", func(list *gtk.ListBox, row *gtk.ListBoxRow) {
+		if note, ok := tw.rows[row.GetIndex()]; ok {
+			tw.restore([]*Note{note})
+		}
+	})
+	scrolled.Add(tw.List)
+	box.PackStart(scrolled, true, true, 0)
+
+	// Purge setting
+	purgeBox, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	purgeLabel, _ := gtk.LabelNew("Delete notes in the trash after")
+	purgeBox.PackStart(purgeLabel, false, false, 0)
+	spinDays, _ := gtk.SpinButtonNewWithRange(0, 3650, 1)
+	spinDays.SetValue(float64(noteset.TrashDays()))
+	spinDays.SetTooltipText("0 keeps notes until the trash is emptied")
+	spinDays.Connect("value-changed", func() {
+		noteset.SetTrashDays(spinDays.GetValueAsInt())
+		tw.refresh()
+	})
+	purgeBox.PackStart(spinDays, false, false, 0)
+	daysLabel, _ := gtk.LabelNew("days")
+	purgeBox.PackStart(daysLabel, false, false, 0)
+	box.PackStart(purgeBox, false, false, 0)
+
+	buttons, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	bEmpty, _ := gtk.ButtonNewWithLabel("Empty Trash")
+	bEmpty.Connect("clicked", tw.onEmpty)
+	buttons.PackStart(bEmpty, false, false, 0)
+	bRestore, _ := gtk.ButtonNewWithLabel("Restore")
+	bRestore.Connect("clicked", func() {
+		tw.restore(tw.selectedNotes())
+	})
+	buttons.PackEnd(bRestore, false, false, 0)
+	box.PackStart(buttons, false, false, 0)
+
+	tw.Window.Add(box)
+	tw.refresh()
+	tw.Window.ShowAll()
+
+	return tw
+}
+
+// refresh rebuilds the list of deleted notes, most recently deleted first
+func (tw *TrashWindow) refresh() {
+	tw.List.GetChildren().Foreach(func(item interface{}) {
+		if widget, ok := item.(gtk.IWidget); ok {
+			tw.List.Remove(widget)
+		}
+	})
+	tw.rows = make(map[int]*Note)
+
+	if len(tw.NoteSet.Trash) == 0 {
+		label, _ := gtk.LabelNew("The trash is empty")
+		label.SetMarginTop(12)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		row.SetSelectable(false)
+		row.SetActivatable(false)
+		tw.List.Add(row)
+		tw.List.ShowAll()
+		return
+	}
+
+	for i := len(tw.NoteSet.Trash) - 1; i >= 0; i-- {
+		note := tw.NoteSet.Trash[i]
+		label, _ := gtk.LabelNew("")
+		label.SetMarkup(fmt.Sprintf("<b>%s</b>\n<small>%s · deleted %s</small>",
+			glib.MarkupEscapeText(noteLabel(note)),
+			glib.MarkupEscapeText(tw.NoteSet.CategoryName(note.Category)),
+			FormatDateTime(note.DeletedAt)))
+		label.SetHAlign(gtk.ALIGN_START)
+		label.SetMarginStart(6)
+		row, _ := gtk.ListBoxRowNew()
+		row.Add(label)
+		tw.List.Add(row)
+		tw.rows[row.GetIndex()] = note
+	}
+	tw.List.ShowAll()
+}
+
+// selectedNotes returns the notes of the selected rows
+func (tw *TrashWindow) selectedNotes() []*Note {
+	var notes []*Note
+	if rows := tw.List.GetSelectedRows(); rows != nil {
+		rows.Foreach(func(item interface{}) {
+			if row, ok := item.(*gtk.ListBoxRow); ok {
+				if note, ok := tw.rows[row.GetIndex()]; ok {
+					notes = append(notes, note)
+				}
+			}
+		})
+	}
+	return notes
+}
+
+// restore moves notes back from the trash and shows them
+func (tw *TrashWindow) restore(notes []*Note) {
+	for _, note := range notes {
+		note.Restore()
+		note.Show()
+	}
+	tw.refresh()
+}
+
+func (tw *TrashWindow) onEmpty() {
+	if len(tw.NoteSet.Trash) == 0 {
+		return
+	}
+	dialog := gtk.MessageDialogNew(tw.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_NONE, "Permanently delete %d notes in the trash?", len(tw.NoteSet.Trash))
+	dialog.AddButton("Cancel", gtk.RESPONSE_REJECT)
+	dialog.AddButton("Empty Trash", gtk.RESPONSE_ACCEPT)
+	response := dialog.Run()
+	dialog.Destroy()
+
+	if response == gtk.RESPONSE_ACCEPT {
+		tw.NoteSet.EmptyTrash()
+		tw.refresh()
+	}
+}
@@ -0,0 +1,115 @@
+package stickynotes
+
+import (
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Deleting a note hides it and shows a small toast with an Undo button. The note only
+// goes to the trash (and the deletion is saved) when the toast expires, when another
+// note is deleted, or when the application quits.
+
+// undoDeleteTimeout is how long (ms) a deletion can be undone
+const undoDeleteTimeout = 10000
+
+// pendingDelete is the deletion that can still be undone, there is only ever one
+var pendingDelete *deleteToast
+
+type deleteToast struct {
+	note      *Note
+	window    *gtk.Window
+	timeoutID glib.SourceHandle
+}
+
+// deleteWithUndo hides the note and offers to undo its deletion for a few seconds
+func (sn *StickyNote) deleteWithUndo() {
+	FinishPendingDelete()
+
+	sn.UpdateNote()
+	note := sn.Note
+	note.deletePending = true
+	if sn.Editor != nil {
+		sn.Editor.Destroy()
+	}
+	sn.Hide()
+
+	toast := &deleteToast{note: note}
+	toast.window, _ = gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
+	toast.window.SetTitle("Note Deleted")
+	toast.window.SetDecorated(false)
+	toast.window.SetTypeHint(gdk.WINDOW_TYPE_HINT_NOTIFICATION)
+	toast.window.SetSkipTaskbarHint(true)
+	toast.window.SetSkipPagerHint(true)
+	toast.window.SetKeepAbove(true)
+	toast.window.SetPosition(gtk.WIN_POS_MOUSE)
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 12)
+	box.SetBorderWidth(8)
+	label, _ := gtk.LabelNew("Deleted “" + noteLabel(note) + "”")
+	box.PackStart(label, true, true, 0)
+	bUndo, _ := gtk.ButtonNewWithLabel("Undo")
+	bUndo.Connect("clicked", undoPendingDelete)
+	box.PackEnd(bUndo, false, false, 0)
+	toast.window.Add(box)
+	toast.window.ShowAll()
+
+	toast.timeoutID = glib.TimeoutAdd(undoDeleteTimeout, func() bool {
+		toast.timeoutID = 0
+		FinishPendingDelete()
+		return false // Don't repeat
+	})
+	pendingDelete = toast
+}
+
+// close removes the toast and its timeout
+func (toast *deleteToast) close() {
+	if toast.timeoutID != 0 {
+		glib.SourceRemove(toast.timeoutID)
+		toast.timeoutID = 0
+	}
+	toast.window.Destroy()
+	toast.note.deletePending = false
+	pendingDelete = nil
+}
+
+// FinishPendingDelete moves the note whose deletion can still be undone to the trash
+func FinishPendingDelete() {
+	if pendingDelete == nil {
+		return
+	}
+	note := pendingDelete.note
+	pendingDelete.close()
+	note.trash()
+}
+
+// trash moves the note to the trash and destroys its windows
+func (n *Note) trash() {
+	n.Delete()
+	n.destroyGUI()
+}
+
+// destroyGUI destroys the note's windows
+func (n *Note) destroyGUI() {
+	sn := n.GUI
+	if sn != nil {
+		if sn.Editor != nil {
+			sn.Editor.Destroy()
+		}
+		if sn.WinMain != nil {
+			sn.WinMain.Destroy()
+		}
+	}
+	// Clear GUI reference to prevent trying to use destroyed window
+	n.GUI = nil
+}
+
+// undoPendingDelete brings the note back as it was before the deletion
+func undoPendingDelete() {
+	if pendingDelete == nil {
+		return
+	}
+	note := pendingDelete.note
+	pendingDelete.close()
+	note.Show()
+}
@@ -0,0 +1,177 @@
+package stickynotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Usage insights are counters kept in a file of their own under $XDG_STATE_HOME, one per
+// data file, so they are neither synced nor exported with the notes. They are opt-in,
+// stay on this machine and are only shown in the statistics window. Older versions kept
+// them in the data file's "usage" property, which is moved on first use.
+
+// Usage events
+const (
+	UsageNoteCreated = "notes_created"
+	UsageSearch      = "searches"
+	UsageFind        = "finds"
+	UsageExport      = "exports"
+	UsageImport      = "imports"
+)
+
+var usageLabels = []struct {
+	event string
+	label string
+}{
+	{UsageNoteCreated, "Notes created"},
+	{UsageSearch, "Searches"},
+	{UsageFind, "Find in note"},
+	{UsageExport, "Exports"},
+	{UsageImport, "Imports"},
+}
+
+// UsageEnabled reports whether the user opted in to local usage insights
+func (ns *NoteSet) UsageEnabled() bool {
+	enabled, _ := ns.Properties["usage_enabled"].(bool)
+	return enabled
+}
+
+// usagePath returns the file the usage insights of the data file are kept in
+func (ns *NoteSet) usagePath() string {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(ns.DataPath()), "."), ".json")
+	return ResolvePath(filepath.Join("$XDG_STATE_HOME", LocaleDomain, name+"-usage.json"))
+}
+
+// loadUsage returns the recorded usage, read from its file on first use
+func (ns *NoteSet) loadUsage() map[string]interface{} {
+	if ns.usage != nil {
+		return ns.usage
+	}
+	var usage map[string]interface{}
+	if data, err := os.ReadFile(ns.usagePath()); err == nil {
+		if err := json.Unmarshal(data, &usage); err != nil {
+			fmt.Printf("[Usage] Ignoring unreadable %s: %v\n", ns.usagePath(), err)
+		}
+	} else if legacy, ok := ns.Properties["usage"].(map[string]interface{}); ok {
+		usage = legacy
+		ns.usage = usage
+		ns.saveUsage()
+	}
+	if _, ok := ns.Properties["usage"]; ok {
+		delete(ns.Properties, "usage")
+		ns.Save()
+	}
+	if usage == nil {
+		usage = make(map[string]interface{})
+	}
+	ns.usage = usage
+	return usage
+}
+
+// saveUsage writes the recorded usage to its file
+func (ns *NoteSet) saveUsage() {
+	path := ns.usagePath()
+	data, err := json.Marshal(ns.usage)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			err = os.WriteFile(path, data, 0600)
+		}
+	}
+	if err != nil {
+		fmt.Printf("[Usage] Failed to save %s: %v\n", path, err)
+	}
+}
+
+// usageLog returns the recorded usage to add to, starting it on first use
+func (ns *NoteSet) usageLog() map[string]interface{} {
+	usage := ns.loadUsage()
+	if _, ok := usage["since"]; !ok {
+		usage["since"] = time.Now().Format("2006-01-02")
+	}
+	return usage
+}
+
+// RecordUsage counts one use of a feature, if usage insights are enabled
+func (ns *NoteSet) RecordUsage(event string) {
+	if !ns.UsageEnabled() {
+		return
+	}
+	usage := ns.usageLog()
+	count, _ := usage[event].(float64)
+	usage[event] = count + 1
+	ns.saveUsage()
+}
+
+// RecordCategoryUsage counts a note created in the given category, if usage insights are enabled
+func (ns *NoteSet) RecordCategoryUsage(cat string) {
+	if !ns.UsageEnabled() {
+		return
+	}
+	usage := ns.usageLog()
+	perCat, ok := usage["categories"].(map[string]interface{})
+	if !ok {
+		perCat = make(map[string]interface{})
+		usage["categories"] = perCat
+	}
+	count, _ := perCat[cat].(float64)
+	perCat[cat] = count + 1
+	ns.saveUsage()
+}
+
+// ClearUsage forgets all recorded usage insights
+func (ns *NoteSet) ClearUsage() {
+	ns.usage = make(map[string]interface{})
+	if err := os.Remove(ns.usagePath()); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("[Usage] Failed to remove %s: %v\n", ns.usagePath(), err)
+	}
+	if _, ok := ns.Properties["usage"]; ok {
+		delete(ns.Properties, "usage")
+		ns.Save()
+	}
+}
+
+// UsageReport renders the usage insights for the statistics window
+func (ns *NoteSet) UsageReport() string {
+	var sb strings.Builder
+	if !ns.UsageEnabled() {
+		sb.WriteString("  Off (enable in Settings > General)\n")
+		return sb.String()
+	}
+
+	usage := ns.loadUsage()
+	if since, ok := usage["since"].(string); ok {
+		if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+			since = FormatDate(t)
+		}
+		fmt.Fprintf(&sb, "  Since %s\n", since)
+	}
+	for _, item := range usageLabels {
+		count, _ := usage[item.event].(float64)
+		fmt.Fprintf(&sb, "  %s: %d\n", item.label, int(count))
+	}
+
+	perCat, _ := usage["categories"].(map[string]interface{})
+	if len(perCat) > 0 {
+		names := make([]string, 0, len(perCat))
+		counts := make(map[string]int)
+		for cat, val := range perCat {
+			count, _ := val.(float64)
+			name := ns.CategoryName(cat)
+			if _, seen := counts[name]; !seen {
+				names = append(names, name)
+			}
+			counts[name] += int(count)
+		}
+		sort.Strings(names)
+		sb.WriteString("  Notes created per category:\n")
+		for _, name := range names {
+			fmt.Fprintf(&sb, "    %s: %d\n", name, counts[name])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,249 @@
+package stickynotes
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// View mode shows the note read-only with its Markdown rendered: the text itself is not
+// changed, styles are applied with text tags and the Markdown markers are hidden with an
+// invisible tag, so saving still writes the source. Locked notes are always in view mode.
+
+// Text tags used to render Markdown
+const (
+	viewTagH1     = "md-h1"
+	viewTagH2     = "md-h2"
+	viewTagH3     = "md-h3"
+	viewTagBold   = "md-bold"
+	viewTagItalic = "md-italic"
+	viewTagCode   = "md-code"
+	viewTagLink   = "md-link"
+	viewTagHidden = "md-hidden"
+)
+
+var viewTags = []string{viewTagH1, viewTagH2, viewTagH3, viewTagBold, viewTagItalic, viewTagCode, viewTagLink, viewTagHidden}
+
+// mdSpan is a styled range of a note body, in character offsets
+type mdSpan struct {
+	Tag   string
+	Start int
+	End   int
+	URL   string // Link target, for viewTagLink
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,3})\s+`)
+	mdCode    = regexp.MustCompile("`([^`]+)`")
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdItalic  = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*|\b_([^_\s](?:[^_]*[^_\s])?)_\b`)
+	mdURL     = regexp.MustCompile(`https?://[^\s<>()\[\]]+[^\s<>()\[\].,;:!?'"]`)
+)
+
+// markdownSpans finds the Markdown styles in text: headings, **bold**, *italic*,
+// `code`, [links](url) and bare URLs. Fenced code blocks are left alone.
+func markdownSpans(text string) []mdSpan {
+	var spans []mdSpan
+	inFence := make(map[int]bool)
+	for _, block := range codeBlockLines(text) {
+		for i := block[0]; i <= block[1]; i++ {
+			inFence[i] = true
+		}
+	}
+
+	offset := 0 // Character offset of the current line
+	for i, line := range strings.Split(text, "\n") {
+		if !inFence[i] {
+			spans = append(spans, lineSpans(line, offset)...)
+		}
+		offset += utf8.RuneCountInString(line) + 1
+	}
+	return spans
+}
+
+// lineSpans finds the Markdown styles in a single line starting at character offset base
+func lineSpans(line string, base int) []mdSpan {
+	var spans []mdSpan
+	// Byte offsets to character offsets
+	chars := func(b int) int { return base + utf8.RuneCountInString(line[:b]) }
+	add := func(tag string, start, end int, url string) {
+		spans = append(spans, mdSpan{Tag: tag, Start: chars(start), End: chars(end), URL: url})
+	}
+
+	if m := mdHeading.FindStringSubmatchIndex(line); m != nil {
+		tag := [...]string{viewTagH1, viewTagH2, viewTagH3}[m[3]-m[2]-1]
+		add(viewTagHidden, m[0], m[1], "")
+		add(tag, m[1], len(line), "")
+	}
+
+	// Matched ranges are masked so later patterns don't match inside them
+	masked := []byte(line)
+	mask := func(start, end int) {
+		for i := start; i < end; i++ {
+			masked[i] = 0
+		}
+	}
+
+	for _, m := range mdCode.FindAllSubmatchIndex(masked, -1) {
+		add(viewTagHidden, m[0], m[2], "")
+		add(viewTagCode, m[2], m[3], "")
+		add(viewTagHidden, m[3], m[1], "")
+		mask(m[0], m[1])
+	}
+	for _, m := range mdLink.FindAllSubmatchIndex(masked, -1) {
+		url := line[m[4]:m[5]]
+		add(viewTagHidden, m[0], m[2], "")
+		add(viewTagLink, m[2], m[3], url)
+		add(viewTagHidden, m[3], m[1], "")
+		mask(m[0], m[1])
+	}
+	for _, m := range mdURL.FindAllIndex(masked, -1) {
+		add(viewTagLink, m[0], m[1], line[m[0]:m[1]])
+		mask(m[0], m[1])
+	}
+	for _, m := range mdBold.FindAllSubmatchIndex(masked, -1) {
+		add(viewTagHidden, m[0], m[2], "")
+		add(viewTagBold, m[2], m[3], "")
+		add(viewTagHidden, m[3], m[1], "")
+		mask(m[0], m[1])
+	}
+	for _, m := range mdItalic.FindAllSubmatchIndex(masked, -1) {
+		inner := 2
+		if m[2] < 0 {
+			inner = 4
+		}
+		add(viewTagHidden, m[0], m[inner], "")
+		add(viewTagItalic, m[inner], m[inner+1], "")
+		add(viewTagHidden, m[inner+1], m[1], "")
+	}
+	return spans
+}
+
+// setupViewMode creates the rendering tags and applies the note's view mode
+func (sn *StickyNote) setupViewMode() {
+	sn.BBody.CreateTag(viewTagH1, map[string]interface{}{"weight": 700, "scale": 1.5})
+	sn.BBody.CreateTag(viewTagH2, map[string]interface{}{"weight": 700, "scale": 1.25})
+	sn.BBody.CreateTag(viewTagH3, map[string]interface{}{"weight": 700, "scale": 1.1})
+	sn.BBody.CreateTag(viewTagBold, map[string]interface{}{"weight": 700})
+	// Enum properties (style, underline) can't be set from Go ints, a partial font description can
+	sn.BBody.CreateTag(viewTagItalic, map[string]interface{}{"font": "italic"})
+	sn.BBody.CreateTag(viewTagCode, map[string]interface{}{"family": "monospace"})
+	sn.BBody.CreateTag(viewTagLink, map[string]interface{}{"foreground": "#1a5fb4", "weight": 600})
+	sn.BBody.CreateTag(viewTagHidden, map[string]interface{}{"invisible": true})
+
+	sn.BBody.Connect("changed", func() {
+		if sn.InViewMode() && !sn.updatingCounters {
+			sn.renderMarkdown()
+		}
+	})
+	sn.TxtNote.Connect("key-press-event", sn.onViewKeyPress)
+	sn.TxtNote.Connect("button-release-event", sn.onViewClick)
+	sn.applyViewMode()
+}
+
+// ViewMode reports whether the user turned on view mode for the note
+func (sn *StickyNote) ViewMode() bool {
+	viewMode, _ := sn.Note.Properties["view_mode"].(bool)
+	return viewMode
+}
+
+// InViewMode reports whether the note is shown read-only and rendered: in view mode or locked
+func (sn *StickyNote) InViewMode() bool {
+	return sn.ViewMode() || sn.Locked
+}
+
+// SetViewMode turns view mode on or off for the note
+func (sn *StickyNote) SetViewMode(viewMode bool) {
+	if viewMode {
+		sn.Note.Properties["view_mode"] = true
+	} else {
+		delete(sn.Note.Properties, "view_mode")
+	}
+	sn.applyViewMode()
+	sn.NoteSet.Save()
+}
+
+// applyViewMode makes the text view editable or not and renders or un-renders the Markdown
+func (sn *StickyNote) applyViewMode() {
+	if sn.TxtNote == nil || sn.BBody == nil {
+		return
+	}
+	active := sn.InViewMode()
+	sn.TxtNote.SetEditable(!active)
+	sn.TxtNote.SetCursorVisible(!active)
+	if sn.BEmoji != nil {
+		sn.BEmoji.SetSensitive(!active)
+	}
+	if active {
+		sn.renderMarkdown()
+	} else {
+		sn.clearMarkdown()
+		sn.viewLinks = nil
+	}
+}
+
+// clearMarkdown removes the rendering tags and counter widgets from the whole buffer
+func (sn *StickyNote) clearMarkdown() {
+	sn.clearCounters()
+	start, end := sn.BBody.GetBounds()
+	for _, tag := range viewTags {
+		sn.BBody.RemoveTagByName(tag, start, end)
+	}
+}
+
+// renderMarkdown applies the rendering tags to the current text
+func (sn *StickyNote) renderMarkdown() {
+	sn.clearMarkdown()
+	start, end := sn.BBody.GetBounds()
+	text, _ := sn.BBody.GetText(start, end, true)
+
+	sn.viewLinks = nil
+	for _, span := range markdownSpans(text) {
+		sn.BBody.ApplyTagByName(span.Tag, sn.BBody.GetIterAtOffset(span.Start), sn.BBody.GetIterAtOffset(span.End))
+		if span.Tag == viewTagLink {
+			sn.viewLinks = append(sn.viewLinks, span)
+		}
+	}
+	sn.renderCounters(text)
+}
+
+// onViewKeyPress swallows key presses in view mode, except shortcuts like Ctrl+C
+func (sn *StickyNote) onViewKeyPress(tv *gtk.TextView, event *gdk.Event) bool {
+	if !sn.InViewMode() {
+		return false
+	}
+	keyEvent := gdk.EventKeyNewFromEvent(event)
+	return gdk.ModifierType(keyEvent.State())&gdk.CONTROL_MASK == 0
+}
+
+// onViewClick opens the link under the pointer in view mode
+func (sn *StickyNote) onViewClick(tv *gtk.TextView, event *gdk.Event) bool {
+	if !sn.InViewMode() || len(sn.viewLinks) == 0 || sn.BBody.GetHasSelection() {
+		return false
+	}
+	buttonEvent := gdk.EventButtonNewFromEvent(event)
+	if buttonEvent.Button() != gdk.BUTTON_PRIMARY {
+		return false
+	}
+	x, y := tv.WindowToBufferCoords(gtk.TEXT_WINDOW_WIDGET, int(buttonEvent.X()), int(buttonEvent.Y()))
+	iter := tv.GetIterAtLocation(x, y)
+	if iter == nil {
+		return false
+	}
+	offset := sn.textOffset(iter)
+	for _, link := range sn.viewLinks {
+		if offset >= link.Start && offset < link.End {
+			if err := exec.Command("xdg-open", link.URL).Start(); err != nil {
+				fmt.Printf("Error opening link %s: %v\n", link.URL, err)
+			}
+			return true
+		}
+	}
+	return false
+}
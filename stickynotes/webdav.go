@@ -0,0 +1,525 @@
+package stickynotes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// The whole data file can also be synced through a WebDAV server (Nextcloud, ownCloud,
+// a NAS…), simpler than syncing the notes one by one: a few seconds after a save and
+// every few minutes, the file is uploaded when the notes changed, or downloaded when
+// another device uploaded a newer one. The server's ETag tells the copies apart: an
+// upload only replaces the copy this device last saw, and when another device got there
+// first, its copy is downloaded and merged as for a shared data file (see sync.go), then
+// the result is uploaded. An encrypted data file is uploaded encrypted. The file's URL,
+// the user name and whether sync is on are in the "webdav_url", "webdav_user" and
+// "webdav_enabled" properties, the password in the keyring.
+
+const (
+	// webdavPushDelay (ms) lets a burst of saves upload once
+	webdavPushDelay = 5000
+	// webdavInterval (ms) is how often the server is checked for uploads from other devices
+	webdavInterval = 2 * 60 * 1000
+	// webdavTimeout limits each request
+	webdavTimeout = 30 * time.Second
+	// webdavMaxRounds limits the downloads of a sync when other devices keep uploading
+	webdavMaxRounds = 3
+	// webdavFileName names the file when the URL is a folder
+	webdavFileName = "postnote.json"
+)
+
+var (
+	errWebDAVChanged = errors.New("the file on the server was changed by another device")
+	errWebDAVMissing = errors.New("the file is not on the server")
+	errWebDAVBusy    = errors.New("other devices keep uploading the file, try again later")
+)
+
+// webdavSync is the state of the WebDAV sync of a noteset
+type webdavSync struct {
+	pushID  glib.SourceHandle // Pending sync after a save, 0 when none
+	running bool
+	synced  string          // webdavHash of the notes last uploaded or downloaded, "" before the first sync
+	remote  map[string]bool // UUIDs of the notes in the copy last uploaded or downloaded
+	lastErr string          // Why the last sync failed, "" when it didn't
+	waiting []func(error)   // Called when the running sync finishes
+}
+
+// webdavClient reads and writes one file on a WebDAV server
+type webdavClient struct {
+	url      string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// do sends a request for the file, with the headers given as name, value pairs
+func (c *webdavClient) do(method string, body []byte, header ...string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, errors.New("the server refused the user name or password")
+	}
+	return resp, nil
+}
+
+// get downloads the file, unless it still has the ETag etag: data is then nil
+func (c *webdavClient) get(etag string) (data []byte, newETag string, err error) {
+	var header []string
+	if etag != "" {
+		header = []string{"If-None-Match", etag}
+	}
+	resp, err := c.do(http.MethodGet, nil, header...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, etag, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, "", errWebDAVMissing
+	case resp.StatusCode >= 300:
+		return nil, "", fmt.Errorf("GET %s: %s", c.url, resp.Status)
+	}
+	data, err = io.ReadAll(resp.Body)
+	return data, resp.Header.Get("ETag"), err
+}
+
+// put uploads the file in place of the copy with the ETag etag, or where there is none
+// when etag is ""
+func (c *webdavClient) put(data []byte, etag string) (string, error) {
+	header := []string{"Content-Type", "application/json", "If-None-Match", "*"}
+	if etag != "" {
+		header = []string{"Content-Type", "application/json", "If-Match", etag}
+	}
+	resp, err := c.do(http.MethodPut, data, header...)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return "", errWebDAVChanged
+	case resp.StatusCode >= 300:
+		return "", fmt.Errorf("PUT %s: %s", c.url, resp.Status)
+	}
+	if newETag := resp.Header.Get("ETag"); newETag != "" {
+		return newETag, nil
+	}
+	// Not every server sends it with the upload
+	return c.head()
+}
+
+// head returns the ETag of the file
+func (c *webdavClient) head() (string, error) {
+	resp, err := c.do(http.MethodHead, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("HEAD %s: %s", c.url, resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// normalizeWebDAVURL completes a file URL typed without its scheme or file name
+func normalizeWebDAVURL(url string) string {
+	url = strings.TrimSpace(url)
+	if strings.HasSuffix(url, "/") {
+		url += webdavFileName
+	}
+	return normalizeServerURL(url)
+}
+
+// WebDAVAccount returns the URL of the file the data file is synced with, and the user name
+func (ns *NoteSet) WebDAVAccount() (url, user string) {
+	url, _ = ns.Properties["webdav_url"].(string)
+	user, _ = ns.Properties["webdav_user"].(string)
+	return normalizeWebDAVURL(url), strings.TrimSpace(user)
+}
+
+// WebDAVEnabled reports whether WebDAV sync is on and has a URL
+func (ns *NoteSet) WebDAVEnabled() bool {
+	enabled, _ := ns.Properties["webdav_enabled"].(bool)
+	url, _ := ns.WebDAVAccount()
+	return enabled && url != ""
+}
+
+// webdavKeyringAttributes identify the password of a WebDAV account in the keyring
+func webdavKeyringAttributes(url, user string) []string {
+	return []string{"application", keyringApplication, "webdav-url", url, "webdav-user", user}
+}
+
+// SetWebDAVPassword remembers the password of the configured WebDAV account in the keyring
+func (ns *NoteSet) SetWebDAVPassword(password string) error {
+	url, user := ns.WebDAVAccount()
+	if url == "" || user == "" {
+		return errors.New("enter the URL and user name first")
+	}
+	if err := keyringStore("PostNote WebDAV password", password, webdavKeyringAttributes(url, user)); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errors.New("secret-tool (libsecret) is not installed, the password can't be stored")
+		}
+		return err
+	}
+	return nil
+}
+
+// webdavClient returns a client for the configured file. Without a user name, the
+// server is used without signing in.
+func (ns *NoteSet) webdavClient() (*webdavClient, error) {
+	if !ns.WebDAVEnabled() {
+		return nil, errors.New("WebDAV sync is not set up, see Settings → Sync")
+	}
+	url, user := ns.WebDAVAccount()
+	password := ""
+	if user != "" {
+		if password = keyringLookup(webdavKeyringAttributes(url, user)); password == "" {
+			return nil, fmt.Errorf("no WebDAV password for %s in the keyring, enter it in Settings → Sync", user)
+		}
+	}
+	return &webdavClient{url: url, user: user, password: password, client: &http.Client{Timeout: webdavTimeout}}, nil
+}
+
+// webdavHash identifies the notes and categories: a sync uploads them when it changes
+func (ns *NoteSet) webdavHash() string {
+	data := ns.dumpData()
+	synced := marshalNoteSet(map[string]interface{}{
+		"notes":      data["notes"],
+		"trash":      data["trash"],
+		"categories": data["categories"],
+	})
+	sum := sha256.Sum256([]byte(synced))
+	return hex.EncodeToString(sum[:])
+}
+
+// SyncWebDAV uploads the data file when the notes changed since the last sync, first
+// downloading and merging the server's copy when another device uploaded one, then
+// calls done (optional) on the main loop. Called during a sync, it waits for that one.
+func (ns *NoteSet) SyncWebDAV(done func(error)) {
+	w := &ns.webdav
+	if done != nil {
+		w.waiting = append(w.waiting, done)
+	}
+	if w.running {
+		return
+	}
+	client, err := ns.webdavClient()
+	if err != nil {
+		ns.finishWebDAV(err)
+		return
+	}
+	w.running = true
+	ns.webdavRound(client, 0)
+}
+
+// webdavRound uploads or downloads the data file once, round counting the downloads
+func (ns *NoteSet) webdavRound(client *webdavClient, round int) {
+	w := &ns.webdav
+	hash := ns.webdavHash()
+	dirty := hash != w.synced
+	etag, _ := ns.Properties["webdav_etag"].(string)
+	var data []byte
+	var uploaded map[string]bool
+	if dirty {
+		var err error
+		if data, err = ns.encode([]byte(ns.Dumps())); err != nil {
+			ns.finishWebDAV(err)
+			return
+		}
+		uploaded = make(map[string]bool, len(ns.Notes))
+		for _, note := range ns.Notes {
+			uploaded[note.UUID] = true
+		}
+	}
+
+	go func() {
+		var pulled []byte
+		var err error
+		newETag := etag
+		if dirty {
+			newETag, err = client.put(data, etag)
+		}
+		if !dirty || errors.Is(err, errWebDAVChanged) {
+			known := etag
+			if dirty {
+				// Not the copy this device last saw
+				known = ""
+			}
+			pulled, newETag, err = client.get(known)
+		}
+
+		glib.IdleAdd(func() bool {
+			switch {
+			case errors.Is(err, errWebDAVMissing) && round < webdavMaxRounds:
+				// Deleted from the server: upload it again
+				delete(ns.Properties, "webdav_etag")
+				w.synced, w.remote = "", nil
+				ns.webdavRound(client, round+1)
+			case err != nil:
+				ns.finishWebDAV(err)
+			case pulled != nil && round >= webdavMaxRounds:
+				ns.finishWebDAV(errWebDAVBusy)
+			case pulled != nil:
+				if err := ns.takeWebDAVFile(pulled); err != nil {
+					ns.finishWebDAV(err)
+					break
+				}
+				ns.Properties["webdav_etag"] = newETag
+				if dirty {
+					// Upload the merged notes
+					ns.webdavRound(client, round+1)
+				} else {
+					w.synced = ns.webdavHash()
+					ns.finishWebDAV(nil)
+				}
+			default:
+				// Uploaded, or the server has the copy this device last saw
+				ns.Properties["webdav_etag"] = newETag
+				if dirty {
+					w.synced, w.remote = hash, uploaded
+				}
+				ns.finishWebDAV(nil)
+			}
+			return false // Don't repeat
+		})
+	}()
+}
+
+// takeWebDAVFile merges a copy of the data file downloaded from the server. Notes are
+// taken as deleted on another device only when they were in the server's copy this
+// device last uploaded or downloaded: before the first sync, the server's copy has
+// nothing to say about the notes here.
+func (ns *NoteSet) takeWebDAVFile(data []byte) error {
+	w := &ns.webdav
+	plain, err := ns.decode(data)
+	var inCopy map[string]bool
+	if err == nil {
+		var lastPull time.Time
+		if last, ok := ns.Properties["webdav_last_pull"].(string); ok {
+			lastPull, _ = time.Parse(time.RFC3339, last)
+		}
+		before := w.remote
+		if w.synced == "" {
+			before = nil
+		}
+		inCopy, err = ns.takeRemoteChanges(plain, lastPull, before)
+	}
+	if err != nil {
+		return fmt.Errorf("can't read the file on the server: %w", err)
+	}
+	w.remote = inCopy
+	ns.Properties["webdav_last_pull"] = time.Now().Format(time.RFC3339)
+	ns.Save()
+	return nil
+}
+
+// finishWebDAV ends a sync, calling the functions waiting for it
+func (ns *NoteSet) finishWebDAV(err error) {
+	w := &ns.webdav
+	w.running = false
+	if err != nil {
+		w.lastErr = err.Error()
+		fmt.Printf("[WebDAV] Sync failed: %v\n", err)
+	} else {
+		w.lastErr = ""
+		ns.Properties["webdav_last_sync"] = time.Now().Format(time.RFC3339)
+	}
+	waiting := w.waiting
+	w.waiting = nil
+	for _, done := range waiting {
+		done(err)
+	}
+}
+
+// scheduleWebDAVSync syncs shortly after a save, once for a burst of saves
+func (ns *NoteSet) scheduleWebDAVSync() {
+	// Safe mode doesn't sync, the saves stay on this machine
+	if !ns.WebDAVEnabled() || safeMode {
+		return
+	}
+	if ns.webdav.pushID != 0 {
+		glib.SourceRemove(ns.webdav.pushID)
+	}
+	ns.webdav.pushID = glib.TimeoutAdd(webdavPushDelay, func() bool {
+		ns.webdav.pushID = 0
+		ns.SyncWebDAV(nil)
+		return false // Don't repeat
+	})
+}
+
+// WebDAVSyncStatus describes the last WebDAV sync for the settings, "" before the first one
+func (ns *NoteSet) WebDAVSyncStatus() string {
+	if ns.webdav.lastErr != "" {
+		return "Last sync failed: " + ns.webdav.lastErr
+	}
+	if last, ok := ns.Properties["webdav_last_sync"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, last); err == nil {
+			return fmt.Sprintf("Last synced %s at %s", FormatDate(t), FormatClock(t))
+		}
+	}
+	return ""
+}
+
+// StartWebDAVSync syncs the data file with the configured server now and then every
+// few minutes; saves sync too
+func StartWebDAVSync(ns *NoteSet) {
+	sync := func() {
+		if ns.WebDAVEnabled() {
+			ns.SyncWebDAV(nil)
+		}
+	}
+	sync()
+	glib.TimeoutAdd(webdavInterval, func() bool {
+		sync()
+		return true // Repeat
+	})
+}
+
+// webdavSettings builds the WebDAV section of the Sync settings
+func (sd *SettingsDialog) webdavSettings() *gtk.Box {
+	ns := sd.NoteSet
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+
+	chkEnable, _ := gtk.CheckButtonNewWithLabel("Sync the data file through WebDAV")
+	chkEnable.SetTooltipText("The whole data file is uploaded after each change and checked for changes from other devices every two minutes; those are merged like for a shared data file.")
+	enabled, _ := ns.Properties["webdav_enabled"].(bool)
+	chkEnable.SetActive(enabled)
+	box.PackStart(chkEnable, false, false, 0)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(6)
+	grid.SetColumnSpacing(12)
+	grid.SetMarginStart(24)
+	url, user := ns.WebDAVAccount()
+	eURL, _ := gtk.EntryNew()
+	eURL.SetPlaceholderText("https://cloud.example.com/remote.php/dav/files/me/notes.json")
+	eURL.SetTooltipText("The URL of the file on the server. Ending with / uses postnote.json in that folder.")
+	eURL.SetText(url)
+	eURL.SetHExpand(true)
+	addGridRow(grid, 0, "File URL", eURL)
+	eUser, _ := gtk.EntryNew()
+	eUser.SetPlaceholderText("None")
+	eUser.SetText(user)
+	addGridRow(grid, 1, "User name", eUser)
+	ePassword, _ := gtk.EntryNew()
+	ePassword.SetVisibility(false)
+	ePassword.SetInputPurpose(gtk.INPUT_PURPOSE_PASSWORD)
+	showPasswordHint := func() {
+		url, user := ns.WebDAVAccount()
+		if user != "" && keyringLookup(webdavKeyringAttributes(url, user)) != "" {
+			ePassword.SetPlaceholderText("Stored in the keyring")
+		} else {
+			ePassword.SetPlaceholderText("")
+		}
+	}
+	showPasswordHint()
+	addGridRow(grid, 2, "Password", ePassword)
+	box.PackStart(grid, false, false, 0)
+
+	actions, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 12)
+	bSync, _ := gtk.ButtonNewWithLabel("Sync Now")
+	actions.PackStart(bSync, false, false, 0)
+	lStatus, _ := gtk.LabelNew(ns.WebDAVSyncStatus())
+	lStatus.SetXAlign(0)
+	lStatus.SetLineWrap(true)
+	actions.PackStart(lStatus, true, true, 0)
+	actions.SetMarginStart(24)
+	box.PackStart(actions, false, false, 0)
+
+	updateSensitive := func() {
+		grid.SetSensitive(chkEnable.GetActive())
+		actions.SetSensitive(chkEnable.GetActive())
+	}
+	updateSensitive()
+
+	// URL and user name are saved when the dialog closes
+	chkEnable.Connect("toggled", func() {
+		ns.Properties["webdav_enabled"] = chkEnable.GetActive()
+		ns.SetActionEnabled("sync-now", ns.SyncEnabled())
+		updateSensitive()
+	})
+	eURL.Connect("changed", func() {
+		text, _ := eURL.GetText()
+		ns.Properties["webdav_url"] = strings.TrimSpace(text)
+		// Another file has another ETag
+		delete(ns.Properties, "webdav_etag")
+		ns.SetActionEnabled("sync-now", ns.SyncEnabled())
+	})
+	eUser.Connect("changed", func() {
+		text, _ := eUser.GetText()
+		ns.Properties["webdav_user"] = strings.TrimSpace(text)
+	})
+
+	// A typed password goes to the keyring, the entry is cleared
+	storePassword := func() bool {
+		password, _ := ePassword.GetText()
+		if password == "" {
+			return true
+		}
+		if err := ns.SetWebDAVPassword(password); err != nil {
+			dialog := gtk.MessageDialogNew(sd.WSettings, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "Error storing the WebDAV password.")
+			dialog.FormatSecondaryText("%s", err.Error())
+			dialog.Run()
+			dialog.Destroy()
+			return false
+		}
+		ePassword.SetText("")
+		showPasswordHint()
+		return true
+	}
+	ePassword.Connect("activate", func() { storePassword() })
+	ePassword.Connect("focus-out-event", func() bool {
+		storePassword()
+		return false
+	})
+
+	// The sync can finish after the dialog is closed
+	closed := false
+	box.Connect("destroy", func() { closed = true })
+	bSync.Connect("clicked", func() {
+		if !storePassword() {
+			return
+		}
+		bSync.SetSensitive(false)
+		lStatus.SetText("Syncing…")
+		ns.SyncWebDAV(func(err error) {
+			if !closed {
+				bSync.SetSensitive(true)
+				lStatus.SetText(ns.WebDAVSyncStatus())
+			}
+		})
+	})
+
+	box.ShowAll()
+	return box
+}
@@ -0,0 +1,200 @@
+package stickynotes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeWebDAV serves one file with conditional GET and PUT, like a WebDAV server
+type fakeWebDAV struct {
+	data    []byte
+	version int
+}
+
+func (f *fakeWebDAV) etag() string {
+	return fmt.Sprintf(`"v%d"`, f.version)
+}
+
+func (f *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, _ := r.BasicAuth(); user != "me" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	exists := f.data != nil
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", f.etag())
+		if r.Header.Get("If-None-Match") == f.etag() {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(f.data)
+	case http.MethodPut:
+		if match := r.Header.Get("If-Match"); match != "" && (!exists || match != f.etag()) ||
+			r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.data, _ = io.ReadAll(r.Body)
+		f.version++
+		// No ETag with the upload, as some servers do
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestWebDAVClient(t *testing.T) {
+	fake := &fakeWebDAV{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := &webdavClient{url: server.URL + "/notes.json", user: "me", password: "secret", client: server.Client()}
+
+	if _, _, err := client.get(""); !errors.Is(err, errWebDAVMissing) {
+		t.Fatalf("get of a missing file: err = %v, want errWebDAVMissing", err)
+	}
+	etag, err := client.put([]byte("first"), "")
+	if err != nil || etag != `"v1"` {
+		t.Fatalf("first put: etag %q, err %v", etag, err)
+	}
+	if _, err := client.put([]byte("again"), ""); !errors.Is(err, errWebDAVChanged) {
+		t.Errorf("put of a new file over an existing one: err = %v, want errWebDAVChanged", err)
+	}
+
+	// Unchanged since: nothing to download
+	if data, got, err := client.get(etag); err != nil || data != nil || got != etag {
+		t.Errorf("get of an unchanged file = %q, %q, %v", data, got, err)
+	}
+
+	// Another device uploads: this one's upload is refused, and the new copy downloaded
+	fake.data, fake.version = []byte("theirs"), 5
+	if _, err := client.put([]byte("mine"), etag); !errors.Is(err, errWebDAVChanged) {
+		t.Errorf("put over another device's upload: err = %v, want errWebDAVChanged", err)
+	}
+	data, etag, err := client.get(etag)
+	if err != nil || string(data) != "theirs" || etag != `"v5"` {
+		t.Errorf("get of a changed file = %q, %q, %v", data, etag, err)
+	}
+	if etag, err = client.put([]byte("merged"), etag); err != nil || etag != `"v6"` || string(fake.data) != "merged" {
+		t.Errorf("put after the merge: etag %q, err %v, server has %q", etag, err, fake.data)
+	}
+
+	client.password = "wrong"
+	if _, _, err := client.get(""); err == nil {
+		t.Error("get with a wrong password succeeded")
+	}
+}
+
+func TestNormalizeWebDAVURL(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://cloud.example.com/dav/notes.json": "https://cloud.example.com/dav/notes.json",
+		" cloud.example.com/dav/ ":                 "https://cloud.example.com/dav/postnote.json",
+		"http://nas.local:8080/notes.json":         "http://nas.local:8080/notes.json",
+		"":                                         "",
+	} {
+		if got := normalizeWebDAVURL(url); got != want {
+			t.Errorf("normalizeWebDAVURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+// TestWebDAVSecondDevice connects a device with notes of its own to a server another
+// device already syncs with: the merge after its refused upload keeps all of them
+func TestWebDAVSecondDevice(t *testing.T) {
+	fake := &fakeWebDAV{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := &webdavClient{url: server.URL + "/notes.json", user: "me", password: "secret", client: server.Client()}
+	upload := func(ns *NoteSet, etag string) (string, error) {
+		data, err := ns.encode([]byte(ns.Dumps()))
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return client.put(data, etag)
+	}
+
+	first := newTestNoteSet(t)
+	if err := first.Loads(testNoteSetJSON); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	if _, err := upload(first, ""); err != nil {
+		t.Fatalf("first device's upload: %v", err)
+	}
+
+	second := newTestNoteSet(t)
+	if err := second.Loads(`{"notes": [{"uuid": "note-0101", "body": "mine", "rev": 1}, {"uuid": "note-0102", "body": "mine too", "rev": 2}]}`); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	second.rememberSaved([]byte(second.Dumps()))
+	if _, err := upload(second, ""); !errors.Is(err, errWebDAVChanged) {
+		t.Fatalf("second device's first upload: err = %v, want errWebDAVChanged", err)
+	}
+	data, etag, err := client.get("")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if err := second.takeWebDAVFile(data); err != nil {
+		t.Fatalf("takeWebDAVFile: %v", err)
+	}
+	if len(second.Trash) != 0 {
+		t.Errorf("%d of the second device's notes went to the trash", len(second.Trash))
+	}
+	for _, uuid := range []string{"note-0101", "note-0102", "note-0001"} {
+		findNote(t, second, uuid)
+	}
+
+	// Uploaded, as webdavRound does after a merge
+	second.rememberSaved([]byte(second.Dumps()))
+	if etag, err = upload(second, etag); err != nil {
+		t.Fatalf("upload after the merge: %v", err)
+	}
+	second.webdav.synced, second.webdav.remote = second.webdavHash(), second.onDiskUUIDs()
+
+	// The first device deletes a note while the second creates one: the next merge takes
+	// the deletion, and keeps the new note
+	plain, err := first.decode(fake.data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := first.Loads(string(plain)); err != nil {
+		t.Fatalf("Loads: %v", err)
+	}
+	first.Notes = removeNote(t, first.Notes, "note-0001")
+	if _, err := upload(first, fmt.Sprintf(`"v%d"`, fake.version)); err != nil {
+		t.Fatalf("first device's deletion: %v", err)
+	}
+	second.Notes = append(second.Notes, NewNote(map[string]interface{}{"uuid": "note-0103", "body": "new"}, nil, second, ""))
+	if _, err := upload(second, etag); !errors.Is(err, errWebDAVChanged) {
+		t.Fatalf("upload over the deletion: err = %v, want errWebDAVChanged", err)
+	}
+	if data, _, err = client.get(etag); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if err := second.takeWebDAVFile(data); err != nil {
+		t.Fatalf("takeWebDAVFile: %v", err)
+	}
+	if second.noteByUUID("note-0001") != nil {
+		t.Error("note deleted on the first device is still there")
+	}
+	for _, uuid := range []string{"note-0101", "note-0102", "note-0103"} {
+		findNote(t, second, uuid)
+	}
+}
+
+// removeNote returns notes without the one with the uuid
+func removeNote(t testing.TB, notes []*Note, uuid string) []*Note {
+	t.Helper()
+	for i, note := range notes {
+		if note.UUID == uuid {
+			return append(notes[:i:i], notes[i+1:]...)
+		}
+	}
+	t.Fatalf("note %s not found", uuid)
+	return nil
+}
@@ -1,10 +1,14 @@
 package stickynotes
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -19,6 +23,75 @@ type WindowInfo struct {
 	Height  int    `json:"height"`
 	WMClass string `json:"wm_class"`
 	Title   string `json:"title,omitempty"`
+	// Meta.WindowType, only reported by recent window-calls versions
+	WindowType *int `json:"window_type,omitempty"`
+	Focus      bool `json:"focus"`
+}
+
+// Window types (Meta.WindowType) note windows can have: their type hint is utility,
+// some compositors report them as normal windows
+const (
+	metaWindowNormal  = 0
+	metaWindowUtility = 7
+)
+
+// noteWindowTitlePrefix starts the title of every note window. The title is not
+// visible (notes are undecorated) but identifies note windows over D-Bus.
+const noteWindowTitlePrefix = "Sticky Notes - "
+
+// noteWindowTitle returns the window title for the note with the given UUID
+func noteWindowTitle(noteUUID string) string {
+	return noteWindowTitlePrefix + noteUUID[:min(8, len(noteUUID))]
+}
+
+// noteWindowUUIDPrefix extracts the short note UUID from a note window title.
+// Only the first line of the title is considered, some shells report multi-line titles.
+func noteWindowUUIDPrefix(title string) (string, bool) {
+	title, _, _ = strings.Cut(title, "\n")
+	title = strings.TrimSpace(title)
+	if !strings.HasPrefix(title, noteWindowTitlePrefix) {
+		return "", false
+	}
+	prefix := strings.TrimSpace(strings.TrimPrefix(title, noteWindowTitlePrefix))
+	return prefix, prefix != ""
+}
+
+// matchesNoteWindowTitle reports whether title is the window title of the note with the given UUID
+func matchesNoteWindowTitle(title, noteUUID string) bool {
+	prefix, ok := noteWindowUUIDPrefix(title)
+	return ok && prefix == noteUUID[:min(8, len(noteUUID))]
+}
+
+// isNoteWindow reports whether a listed window is one of our note windows, as opposed
+// to a dialog (About, Settings, file choosers...), a peek preview or another application
+func isNoteWindow(win WindowInfo, pid int) bool {
+	if win.PID != pid {
+		return false
+	}
+	if win.WindowType != nil && *win.WindowType != metaWindowUtility && *win.WindowType != metaWindowNormal {
+		return false
+	}
+	// The WM class is the Wayland app id, or the X11 class under XWayland
+	if win.WMClass != "" && !strings.EqualFold(win.WMClass, AppID) {
+		return false
+	}
+	// List leaves the title out on some versions, Details tells (see TakeWindowSnapshot)
+	if win.Title == "" {
+		return true
+	}
+	_, ok := noteWindowUUIDPrefix(win.Title)
+	return ok
+}
+
+// filterNoteWindows returns the note windows of process pid
+func filterNoteWindows(windows []WindowInfo, pid int) []WindowInfo {
+	var noteWindows []WindowInfo
+	for _, win := range windows {
+		if isNoteWindow(win, pid) {
+			noteWindows = append(noteWindows, win)
+		}
+	}
+	return noteWindows
 }
 
 // WindowDetails represents detailed window information
@@ -47,6 +120,9 @@ func init() {
 	// Only check for extension if we're on Wayland
 	if IsWayland() {
 		windowCallsAvailable = checkWindowCallsExtension()
+		if !windowCallsAvailable && isKDESession() {
+			windowCallsAvailable = checkKWinScripting()
+		}
 		windowCallsChecked = true
 	} else {
 		windowCallsAvailable = false
@@ -69,20 +145,142 @@ func getDBusConnection() (*dbus.Conn, error) {
 	return conn, nil
 }
 
-// checkWindowCallsExtension checks if the window-calls GNOME extension is available
-func checkWindowCallsExtension() bool {
+// windowCallsPath is the object of the window-calls extension in GNOME Shell
+const windowCallsPath = dbus.ObjectPath("/org/gnome/Shell/Extensions/Windows")
+
+// Every call to GNOME Shell goes through windowCall, from the GTK main loop: a call that
+// gets no reply in windowCallTimeout fails instead of freezing the notes, calls beyond
+// windowCallRate per second (after a burst) fail at once so configure storms don't flood
+// the shell (waiting for a turn would block the main loop), and after windowCallMaxTimeouts timeouts in a row calls fail at once
+// for windowCallBackoff.
+const (
+	windowCallTimeout     = 500 * time.Millisecond
+	windowCallRate        = 50
+	windowCallBurst       = 20
+	windowCallMaxTimeouts = 3
+	windowCallBackoff     = 10 * time.Second
+)
+
+var (
+	errWindowCallsBackoff   = errors.New("window-calls is not responding, calls are paused")
+	errWindowCallsThrottled = errors.New("too many window-calls calls")
+)
+
+// WindowCallStats counts the calls made to window-calls, for the diagnostics
+type WindowCallStats struct {
+	Calls       int       // Calls made
+	Failures    int       // Calls that returned an error, timeouts included
+	Timeouts    int       // Calls that got no reply in time
+	Throttled   int       // Calls dropped by the rate limit
+	Skipped     int       // Calls failed at once while paused
+	LastError   string    // Error of the last failed call
+	LastErrorAt time.Time // When the last call failed
+	PausedUntil time.Time // Calls fail at once until then, after repeated timeouts
+}
+
+// windowCallLimiter is a token bucket of windowCallBurst tokens refilled at windowCallRate
+// per second, and pauses calls after consecutive timeouts
+type windowCallLimiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	timeouts int // consecutive timeouts
+	stats    WindowCallStats
+}
+
+var windowCalls = &windowCallLimiter{tokens: windowCallBurst}
+
+// reserve takes a token for a call at now. It returns an error when the calls are paused
+// or there is no token left.
+func (l *windowCallLimiter) reserve(now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.stats.PausedUntil) {
+		l.stats.Skipped++
+		return errWindowCallsBackoff
+	}
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * windowCallRate
+		if l.tokens > windowCallBurst {
+			l.tokens = windowCallBurst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		l.stats.Throttled++
+		return errWindowCallsThrottled
+	}
+	l.tokens--
+	return nil
+}
+
+// done records the outcome of a call made at now
+func (l *windowCallLimiter) done(now time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Calls++
+	if err == nil {
+		l.timeouts = 0
+		return
+	}
+	l.stats.Failures++
+	l.stats.LastError = err.Error()
+	l.stats.LastErrorAt = now
+	if !isWindowCallTimeout(err) {
+		return
+	}
+	l.stats.Timeouts++
+	l.timeouts++
+	if l.timeouts >= windowCallMaxTimeouts {
+		fmt.Printf("[WindowCalls] %d calls in a row timed out, pausing calls for %v\n", l.timeouts, windowCallBackoff)
+		l.stats.PausedUntil = now.Add(windowCallBackoff)
+		l.timeouts = 0
+	}
+}
+
+// isWindowCallTimeout reports whether a call failed because GNOME Shell didn't answer
+func isWindowCallTimeout(err error) bool {
+	var dbusErr dbus.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.NoReply"
+}
+
+// WindowCallsStats returns the counts of calls made to window-calls so far
+func WindowCallsStats() WindowCallStats {
+	windowCalls.mu.Lock()
+	defer windowCalls.mu.Unlock()
+	return windowCalls.stats
+}
+
+// windowCall calls a GNOME Shell method on the object at path, with a timeout and
+// rate limited. Errors are returned in the call, like dbus.Object.Call.
+func windowCall(path dbus.ObjectPath, method string, args ...interface{}) *dbus.Call {
 	conn, err := getDBusConnection()
 	if err != nil {
-		fmt.Printf("[WindowCalls] Failed to connect to D-Bus: %v\n", err)
-		return false
+		return &dbus.Call{Err: err}
+	}
+
+	if err := windowCalls.reserve(time.Now()); err != nil {
+		return &dbus.Call{Err: fmt.Errorf("%s: %w", method, err)}
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), windowCallTimeout)
+	defer cancel()
 
-	// Create the bus object
-	obj := conn.Object("org.gnome.Shell", dbus.ObjectPath("/org/gnome/Shell/Extensions/Windows"))
+	var call *dbus.Call
+	if kwinScripting {
+		call = kwinCall(ctx, conn, method, args...)
+	} else {
+		call = conn.Object("org.gnome.Shell", path).CallWithContext(ctx, method, 0, args...)
+	}
+	windowCalls.done(time.Now(), call.Err)
+	return call
+}
 
+// checkWindowCallsExtension checks if the window-calls GNOME extension is available
+func checkWindowCallsExtension() bool {
 	// Try to call the List method - if it succeeds, extension is available
 	var out string
-	err = obj.Call("org.gnome.Shell.Extensions.Windows.List", 0).Store(&out)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.List").Store(&out)
 
 	if err != nil {
 		// Log the error only once during init
@@ -113,17 +311,9 @@ func ListWindows() ([]WindowInfo, error) {
 		return nil, nil
 	}
 
-	conn, err := getDBusConnection()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to D-Bus: %w", err)
-	}
-
-	// Create the bus object
-	obj := conn.Object("org.gnome.Shell", dbus.ObjectPath("/org/gnome/Shell/Extensions/Windows"))
-
 	// Call the List method
 	var out string
-	err = obj.Call("org.gnome.Shell.Extensions.Windows.List", 0).Store(&out)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.List").Store(&out)
 	if err != nil {
 		// If extension is not available, don't spam errors
 		if dbusErr, ok := err.(dbus.Error); ok {
@@ -165,17 +355,9 @@ func GetWindowDetails(windowID uint32) (*WindowDetails, error) {
 		return nil, nil
 	}
 
-	conn, err := getDBusConnection()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to D-Bus: %w", err)
-	}
-
-	// Create the bus object
-	obj := conn.Object("org.gnome.Shell", dbus.ObjectPath("/org/gnome/Shell/Extensions/Windows"))
-
 	// Call the Details method with window ID
 	var out string
-	err = obj.Call("org.gnome.Shell.Extensions.Windows.Details", 0, windowID).Store(&out)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.Details", windowID).Store(&out)
 	if err != nil {
 		// If extension is not available, don't spam errors
 		if dbusErr, ok := err.(dbus.Error); ok {
@@ -238,8 +420,97 @@ func GetWindowPosition(windowID uint32) (int, int, error) {
 	return details.X, details.Y, nil
 }
 
-// GetCurrentProcessWindows finds all windows belonging to the current process
-// Filters by both PID and title "Sticky Notes" for more accurate matching
+// WindowSnapshot is the result of one List call and one Details call per listed note
+// window. Matching note windows by title only needs data that doesn't change, so notes
+// share a snapshot instead of each calling List and Details for every window.
+type WindowSnapshot struct {
+	Windows []WindowInfo
+	Details map[uint32]*WindowDetails
+	takenAt time.Time
+}
+
+// A snapshot is retaken when older than windowSnapshotTTL, or when a note doesn't find
+// its window in a snapshot older than windowSnapshotRetry (the window may have been
+// mapped since). The retry delay keeps notes whose timeouts fire together on one snapshot.
+const (
+	windowSnapshotTTL   = time.Second
+	windowSnapshotRetry = 100 * time.Millisecond
+)
+
+// TakeWindowSnapshot lists the note windows of the current process and fetches their details
+func TakeWindowSnapshot() (*WindowSnapshot, error) {
+	windows, err := GetCurrentProcessWindows()
+	if err != nil {
+		return nil, err
+	}
+	snap := &WindowSnapshot{
+		Details: make(map[uint32]*WindowDetails, len(windows)),
+		takenAt: time.Now(),
+	}
+	for _, win := range windows {
+		if details, err := GetWindowDetails(win.ID); err == nil && details != nil {
+			// A window listed without a title is left out once its title shows it
+			// isn't a note
+			if _, ok := noteWindowUUIDPrefix(details.Title); !ok && details.Title != "" {
+				continue
+			}
+			snap.Details[win.ID] = details
+		}
+		snap.Windows = append(snap.Windows, win)
+	}
+	fmt.Printf("[WindowCalls] Snapshot of %d windows took %v\n", len(windows), time.Since(snap.takenAt))
+	return snap, nil
+}
+
+// hasNote reports whether the snapshot contains the window of the note with the given UUID
+func (snap *WindowSnapshot) hasNote(noteUUID string) bool {
+	for _, win := range snap.Windows {
+		title := win.Title
+		if details, ok := snap.Details[win.ID]; ok {
+			title = details.Title
+		}
+		if matchesNoteWindowTitle(title, noteUUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotWindows is GetCurrentProcessWindows answered from the noteset's shared snapshot,
+// for matching the window of the note with the given UUID
+func (ns *NoteSet) snapshotWindows(noteUUID string) ([]WindowInfo, error) {
+	snap := ns.windows
+	if snap != nil {
+		age := time.Since(snap.takenAt)
+		if age > windowSnapshotTTL || age > windowSnapshotRetry && !snap.hasNote(noteUUID) {
+			snap = nil
+		}
+	}
+	if snap == nil {
+		var err error
+		if snap, err = TakeWindowSnapshot(); err != nil {
+			ns.windows = nil
+			return nil, err
+		}
+		ns.windows = snap
+	}
+	return snap.Windows, nil
+}
+
+// snapshotDetails is GetWindowDetails answered from the noteset's shared snapshot when it has the window
+func (ns *NoteSet) snapshotDetails(windowID uint32) (*WindowDetails, error) {
+	if ns.windows != nil {
+		if details, ok := ns.windows.Details[windowID]; ok {
+			return details, nil
+		}
+	}
+	return GetWindowDetails(windowID)
+}
+
+// GetCurrentProcessWindows finds the note windows belonging to the current process
+// Filters by PID, window type and note title, so dialogs are never mistaken for notes
+// (note windows get their title before they are shown). Windows listed without a title
+// are kept, their details tell.
 func GetCurrentProcessWindows() ([]WindowInfo, error) {
 	windows, err := ListWindows()
 	if err != nil {
@@ -250,19 +521,7 @@ func GetCurrentProcessWindows() ([]WindowInfo, error) {
 		return nil, fmt.Errorf("window-calls extension not available")
 	}
 
-	var ourWindows []WindowInfo
-	for _, win := range windows {
-		pidMatch := win.PID == currentPID
-		titleMatch := strings.Contains(win.Title, "Sticky Notes")
-
-		// Match by PID AND title for more accurate filtering
-		if pidMatch && titleMatch {
-			ourWindows = append(ourWindows, win)
-		} else if pidMatch {
-			// Fallback: if PID matches but title doesn't, still include it (might be a new window without title yet)
-			ourWindows = append(ourWindows, win)
-		}
-	}
+	ourWindows := filterNoteWindows(windows, currentPID)
 
 	// if len(ourWindows) > 0 {
 	// 	fmt.Printf("[WindowCalls] ===== FILTERED STICKY NOTES WINDOW IDs =====\n")
@@ -315,8 +574,7 @@ func (ns *NoteSet) UpdateNotePositionsFromWindowCalls() {
 			if err == nil && details != nil {
 				oldPos := note.GUI.LastKnownPos
 				// oldSize := note.GUI.LastKnownSize
-				newPos := [2]int{details.X, details.Y}
-				newSize := [2]int{details.Width, details.Height}
+				newPos, newSize := note.GUI.fromCompositor(*details)
 
 				note.GUI.LastKnownPos = newPos
 				note.GUI.LastKnownSize = newSize
@@ -352,14 +610,19 @@ func (ns *NoteSet) UpdateNotePositionsFromWindowCalls() {
 				continue
 			}
 
+			// A window titled for another note, or not for a note at all, is never this
+			// note's window
+			if details.Title != "" && !matchesNoteWindowTitle(details.Title, note.UUID) {
+				continue
+			}
+
 			// Match by size (within 10 pixels tolerance)
 			if absInt(details.Width-w) < 10 && absInt(details.Height-h) < 10 {
 				fmt.Printf("[WindowCalls: UpdateNotePositionsFromWindowCalls] Note %s: Matched window ID %d with size (%d, %d)\n", note.UUID[:8], win.ID, w, h)
 				note.GUI.WindowID = win.ID
 				// oldPos := note.GUI.LastKnownPos
 				// oldSize := note.GUI.LastKnownSize
-				newPos := [2]int{details.X, details.Y}
-				newSize := [2]int{details.Width, details.Height}
+				newPos, newSize := note.GUI.fromCompositor(*details)
 
 				note.GUI.LastKnownPos = newPos
 				note.GUI.LastKnownSize = newSize
@@ -383,17 +646,65 @@ func MoveWindow(windowID uint32, x, y int) error {
 		return fmt.Errorf("window-calls extension not available")
 	}
 
-	conn, err := getDBusConnection()
+	// Call the Move method with window ID, x, y
+	// The method signature is: Move(winid: u, x: i, y: i)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.Move", windowID, int32(x), int32(y)).Err
 	if err != nil {
+		if dbusErr, ok := err.(dbus.Error); ok {
+			fmt.Printf("[WindowCalls] D-Bus error name: %s\n", dbusErr.Name)
+		}
 		return err
 	}
 
-	// Create the bus object
-	obj := conn.Object("org.gnome.Shell", dbus.ObjectPath("/org/gnome/Shell/Extensions/Windows"))
+	return nil
+}
 
-	// Call the Move method with window ID, x, y
-	// The method signature is: Move(winid: u, x: i, y: i)
-	err = obj.Call("org.gnome.Shell.Extensions.Windows.Move", 0, windowID, int32(x), int32(y)).Err
+// MoveResizeWindow moves and resizes a window in one step using window-calls extension.
+// GTK's Resize() isn't reliably applied to undecorated windows on Wayland.
+func MoveResizeWindow(windowID uint32, x, y, width, height int) error {
+	if !IsWindowCallsAvailable() {
+		return fmt.Errorf("window-calls extension not available")
+	}
+
+	// The method signature is: MoveResize(winid: u, x: i, y: i, width: u, height: u)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.MoveResize", windowID, int32(x), int32(y), uint32(width), uint32(height)).Err
+	if err != nil {
+		if dbusErr, ok := err.(dbus.Error); ok {
+			fmt.Printf("[WindowCalls] D-Bus error name: %s\n", dbusErr.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ResizeWindow resizes a window using window-calls extension
+func ResizeWindow(windowID uint32, width, height int) error {
+	if !IsWindowCallsAvailable() {
+		return fmt.Errorf("window-calls extension not available")
+	}
+
+	// The method signature is: Resize(winid: u, width: u, height: u)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.Resize", windowID, uint32(width), uint32(height)).Err
+	if err != nil {
+		if dbusErr, ok := err.(dbus.Error); ok {
+			fmt.Printf("[WindowCalls] D-Bus error name: %s\n", dbusErr.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ActivateWindow raises and focuses a window using window-calls extension
+// This works on Wayland where GTK's Present() is usually ignored by the compositor
+func ActivateWindow(windowID uint32) error {
+	if !IsWindowCallsAvailable() {
+		return fmt.Errorf("window-calls extension not available")
+	}
+
+	// The method signature is: Activate(winid: u)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.Activate", windowID).Err
 	if err != nil {
 		if dbusErr, ok := err.(dbus.Error); ok {
 			fmt.Printf("[WindowCalls] D-Bus error name: %s\n", dbusErr.Name)
@@ -403,3 +714,87 @@ func MoveWindow(windowID uint32, x, y int) error {
 
 	return nil
 }
+
+// SetWindowAbove keeps a window above others (MakeAbove) or lets it go back (UnmakeAbove)
+// using the window-calls extension
+func SetWindowAbove(windowID uint32, above bool) error {
+	method := "UnmakeAbove"
+	if above {
+		method = "MakeAbove"
+	}
+	return callWindowMethod(method, windowID)
+}
+
+// SetWindowBelow keeps a window below others (MakeBelow) or lets it go back (UnmakeBelow)
+// using the window-calls extension. KWin also leaves it out of the task bar and the
+// window switcher.
+func SetWindowBelow(windowID uint32, below bool) error {
+	method := "UnmakeBelow"
+	if below {
+		method = "MakeBelow"
+	}
+	return callWindowMethod(method, windowID)
+}
+
+// SetWindowSticky shows a window on all workspaces (Stick) or only its own (Unstick)
+// using the window-calls extension
+func SetWindowSticky(windowID uint32, sticky bool) error {
+	method := "Unstick"
+	if sticky {
+		method = "Stick"
+	}
+	return callWindowMethod(method, windowID)
+}
+
+// MoveWindowToWorkspace moves a window to a workspace, numbered from 0, using the
+// window-calls extension
+func MoveWindowToWorkspace(windowID uint32, workspace int) error {
+	if !IsWindowCallsAvailable() {
+		return fmt.Errorf("window-calls extension not available")
+	}
+
+	// The method signature is: MoveToWorkspace(winid: u, workspaceNum: u)
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows.MoveToWorkspace", windowID, uint32(workspace)).Err
+	if err != nil {
+		if dbusErr, ok := err.(dbus.Error); ok {
+			fmt.Printf("[WindowCalls] D-Bus error name: %s\n", dbusErr.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// callWindowMethod calls a window-calls method that takes only a window ID
+func callWindowMethod(method string, windowID uint32) error {
+	if !IsWindowCallsAvailable() {
+		return fmt.Errorf("window-calls extension not available")
+	}
+
+	err := windowCall(windowCallsPath, "org.gnome.Shell.Extensions.Windows."+method, windowID).Err
+	if err != nil {
+		if dbusErr, ok := err.(dbus.Error); ok {
+			fmt.Printf("[WindowCalls] %s: D-Bus error name: %s\n", method, dbusErr.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// windowCallsUUID is the GNOME Shell extension UUID of window-calls
+const windowCallsUUID = "window-calls@domandoman.xyz"
+
+// WindowCallsVersion asks GNOME Shell for the installed window-calls extension version
+func WindowCallsVersion() (string, error) {
+	var info map[string]dbus.Variant
+	err := windowCall("/org/gnome/Shell", "org.gnome.Shell.Extensions.GetExtensionInfo", windowCallsUUID).Store(&info)
+	if err != nil {
+		return "", err
+	}
+	version, ok := info["version"]
+	if !ok {
+		return "", fmt.Errorf("extension %s is not installed", windowCallsUUID)
+	}
+	return fmt.Sprint(version.Value()), nil
+}
@@ -0,0 +1,154 @@
+package stickynotes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestNoteWindowUUIDPrefix(t *testing.T) {
+	tests := []struct {
+		title  string
+		prefix string
+		ok     bool
+	}{
+		{"Sticky Notes - 1234abcd", "1234abcd", true},
+		{"Sticky Notes - 1234abcd\nsecond line", "1234abcd", true},
+		{"  Sticky Notes - 1234abcd  ", "1234abcd", true},
+		{"Sticky Notes - ", "", false},
+		{"Sticky Notes", "", false},
+		{"About Sticky Notes", "", false},
+		{"Settings", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		prefix, ok := noteWindowUUIDPrefix(tt.title)
+		if prefix != tt.prefix || ok != tt.ok {
+			t.Errorf("noteWindowUUIDPrefix(%q) = %q, %v; want %q, %v", tt.title, prefix, ok, tt.prefix, tt.ok)
+		}
+	}
+}
+
+func TestMatchesNoteWindowTitle(t *testing.T) {
+	uuid := "1234abcd-5678-90ef-1234-567890abcdef"
+	if title := noteWindowTitle(uuid); !matchesNoteWindowTitle(title, uuid) {
+		t.Errorf("title %q does not match its own note", title)
+	}
+	if !matchesNoteWindowTitle("Sticky Notes - 1234abcd\nline", uuid) {
+		t.Error("multi-line title does not match")
+	}
+	if matchesNoteWindowTitle("Sticky Notes - ffffffff", uuid) {
+		t.Error("title of another note matches")
+	}
+	if matchesNoteWindowTitle("Sticky Notes", uuid) {
+		t.Error("generic title matches")
+	}
+}
+
+func TestFilterNoteWindows(t *testing.T) {
+	const pid = 4242
+	windows := []WindowInfo{
+		{ID: 1, PID: pid, Title: "Sticky Notes - aaaaaaaa"},
+		{ID: 2, PID: pid, Title: "Sticky Notes - bbbbbbbb", WindowType: intPtr(metaWindowUtility)},
+		{ID: 3, PID: pid, Title: "Sticky Notes - cccccccc", WindowType: intPtr(metaWindowNormal), WMClass: AppID},
+		// Dialogs of our own process
+		{ID: 4, PID: pid, Title: "About Indicator Stickynotes", WindowType: intPtr(3)},
+		{ID: 5, PID: pid, Title: "Settings"},
+		{ID: 7, PID: pid, Title: "Sticky Notes - dddddddd", WindowType: intPtr(4)},
+		// Another process using the same title
+		{ID: 8, PID: pid + 1, Title: "Sticky Notes - eeeeeeee"},
+		// Same process and title, but another app id (e.g. a helper window)
+		{ID: 9, PID: pid, Title: "Sticky Notes - ffffffff", WMClass: "zenity"},
+		// No title in the List, the details tell whether it's a note
+		{ID: 10, PID: pid, Title: ""},
+		{ID: 11, PID: pid, Title: "", WindowType: intPtr(3)},
+		{ID: 12, PID: pid + 1, Title: ""},
+	}
+
+	got := filterNoteWindows(windows, pid)
+	want := []uint32{1, 2, 3, 10}
+	if len(got) != len(want) {
+		t.Fatalf("filterNoteWindows returned %d windows, want %d: %+v", len(got), len(want), got)
+	}
+	for i, win := range got {
+		if win.ID != want[i] {
+			t.Errorf("window %d: got ID %d, want %d", i, win.ID, want[i])
+		}
+	}
+
+	if got := filterNoteWindows(nil, pid); len(got) != 0 {
+		t.Errorf("filterNoteWindows(nil) = %+v, want none", got)
+	}
+}
+
+func TestWindowCallLimiterRate(t *testing.T) {
+	l := &windowCallLimiter{tokens: windowCallBurst}
+	now := time.Now()
+
+	// A burst goes through at once, then calls are dropped until the refill
+	for i := 0; i < windowCallBurst; i++ {
+		if err := l.reserve(now); err != nil {
+			t.Fatalf("call %d of the burst: %v", i, err)
+		}
+	}
+	if err := l.reserve(now); !errors.Is(err, errWindowCallsThrottled) {
+		t.Fatalf("call after the burst: err %v, want %v", err, errWindowCallsThrottled)
+	}
+	if err := l.reserve(now.Add(2 * time.Second / windowCallRate)); err != nil {
+		t.Errorf("call after a refill: %v", err)
+	}
+
+	// Tokens come back over time, up to the burst
+	later := now.Add(time.Minute)
+	for i := 0; i < windowCallBurst; i++ {
+		if err := l.reserve(later); err != nil {
+			t.Fatalf("call %d after refill: %v", i, err)
+		}
+	}
+	if err := l.reserve(later); err == nil {
+		t.Error("refill went past the burst")
+	}
+	if l.stats.Throttled != 2 {
+		t.Errorf("Throttled = %d, want 2", l.stats.Throttled)
+	}
+}
+
+func TestWindowCallLimiterBackoff(t *testing.T) {
+	l := &windowCallLimiter{tokens: windowCallBurst}
+	now := time.Now()
+	timeout := fmt.Errorf("List: %w", context.DeadlineExceeded)
+
+	// Other errors and successes in between don't pause calls
+	l.done(now, timeout)
+	l.done(now, dbus.Error{Name: "org.gnome.gjs.JSError.Error"})
+	l.done(now, timeout)
+	l.done(now, nil)
+	l.done(now, timeout)
+	if err := l.reserve(now); err != nil {
+		t.Fatalf("calls paused after timeouts that weren't in a row: %v", err)
+	}
+
+	l.done(now, timeout)
+	l.done(now, dbus.Error{Name: "org.freedesktop.DBus.Error.NoReply"})
+	if err := l.reserve(now); !errors.Is(err, errWindowCallsBackoff) {
+		t.Fatalf("calls not paused after %d timeouts in a row: %v", windowCallMaxTimeouts, err)
+	}
+	resumed := now.Add(windowCallBackoff)
+	if err := l.reserve(resumed); err != nil {
+		t.Errorf("calls still paused after %v: %v", windowCallBackoff, err)
+	}
+
+	stats := l.stats
+	if stats.Calls != 7 || stats.Failures != 6 || stats.Timeouts != 5 || stats.Skipped != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.LastError == "" || !stats.LastErrorAt.Equal(now) {
+		t.Errorf("last error %q at %v, want one at %v", stats.LastError, stats.LastErrorAt, now)
+	}
+}
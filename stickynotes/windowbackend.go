@@ -0,0 +1,366 @@
+package stickynotes
+
+import (
+	"fmt"
+
+	"github.com/gotk3/gotk3/gdk"
+)
+
+// Note windows are placed through a WindowBackend, picked once when the first note is
+// built: GTK itself on X11 (and XWayland), the window-calls extension on GNOME Wayland,
+// KWin scripts on KDE Plasma Wayland, layer-shell surfaces on wlroots compositors, or
+// none on other Wayland compositors, where the notes go wherever the compositor puts them.
+
+// WindowBackend places, measures and pins note windows
+type WindowBackend interface {
+	// Name describes the backend in the diagnostics
+	Name() string
+	// CanPlace reports whether note positions are restored and notes can be pinned
+	CanPlace() bool
+	// Prepare sets up a note window before it is shown the first time
+	Prepare(sn *StickyNote)
+	// Restore moves a note window just shown, still transparent, to pos (updating
+	// LastKnownPos), then makes it visible and calls done
+	Restore(sn *StickyNote, pos [2]int, done func())
+	// Move moves a shown note window
+	Move(sn *StickyNote, x, y int)
+	// Geometry returns the position and size of a note window. ok is false when the
+	// position isn't known, the size is always returned.
+	Geometry(sn *StickyNote) (pos, size [2]int, ok bool)
+	// SetPinned keeps a note window above the others, or not
+	SetPinned(sn *StickyNote, pinned bool)
+	// SetBelow keeps a note window below the others, out of the task bar and the window
+	// switcher where the window manager allows it, or not
+	SetBelow(sn *StickyNote, below bool)
+	// SetSticky shows a note window on all workspaces, or only on its own
+	SetSticky(sn *StickyNote, sticky bool)
+	// MoveToWorkspace moves a shown note window to a workspace, numbered from 0
+	MoveToWorkspace(sn *StickyNote, workspace int)
+	// Workspaces returns how many workspaces there are (0 when not known), and false
+	// when note windows can't be moved between them
+	Workspaces() (int, bool)
+	// Raise brings a note window to the front
+	Raise(sn *StickyNote)
+	// FullscreenFocused reports whether another application has the focus fullscreen, ok
+	// is false when it can't be told
+	FullscreenFocused() (fullscreen, ok bool)
+	// BeginMove and BeginResize move or resize a note window with the pointer, from the
+	// button press on its drag handle
+	BeginMove(sn *StickyNote, event *gdk.EventButton)
+	BeginResize(sn *StickyNote, event *gdk.EventButton)
+}
+
+var windowBackend WindowBackend
+
+// Backend returns the window backend, picking it on the first call (after GTK is
+// initialized and safe mode is set)
+func Backend() WindowBackend {
+	if windowBackend == nil {
+		windowBackend = detectWindowBackend()
+		fmt.Printf("[Window] Placing notes through %s\n", windowBackend.Name())
+	}
+	return windowBackend
+}
+
+func detectWindowBackend() WindowBackend {
+	switch {
+	case !IsWayland():
+		return gtkBackend{}
+	case IsWindowCallsAvailable() && KWinScripting():
+		return windowCallsBackend{name: "KWin scripting"}
+	case IsWindowCallsAvailable():
+		return windowCallsBackend{name: "GNOME window-calls"}
+	case IsLayerShellAvailable():
+		return layerShellBackend{}
+	}
+	return nullBackend{}
+}
+
+// gtkBackend places windows through GTK, which works on X11
+type gtkBackend struct{}
+
+func (gtkBackend) Name() string           { return "GTK (X11)" }
+func (gtkBackend) CanPlace() bool         { return true }
+func (gtkBackend) Prepare(sn *StickyNote) {}
+
+func (gtkBackend) Restore(sn *StickyNote, pos [2]int, done func()) {
+	sn.idleAdd(func() bool {
+		sn.moveTo(pos[0], pos[1])
+		sn.showPlaced() // Make window visible after moving
+		done()
+		return false // Don't repeat
+	})
+}
+
+func (gtkBackend) Move(sn *StickyNote, x, y int) {
+	sn.WinMain.Move(x, y)
+}
+
+func (gtkBackend) Geometry(sn *StickyNote) ([2]int, [2]int, bool) {
+	x, y := sn.WinMain.GetPosition()
+	w, h := sn.WinMain.GetSize()
+	return [2]int{x, y}, [2]int{w, h}, true
+}
+
+func (gtkBackend) SetPinned(sn *StickyNote, pinned bool) {
+	sn.WinMain.SetKeepAbove(pinned)
+}
+
+func (gtkBackend) SetBelow(sn *StickyNote, below bool) {
+	sn.WinMain.SetKeepBelow(below)
+}
+
+func (gtkBackend) SetSticky(sn *StickyNote, sticky bool) {
+	if sticky {
+		sn.WinMain.Stick()
+	} else {
+		sn.WinMain.Unstick()
+	}
+}
+
+func (gtkBackend) MoveToWorkspace(sn *StickyNote, workspace int) {
+	x11MoveToDesktop(sn.WinMain, workspace)
+}
+
+func (gtkBackend) Workspaces() (int, bool) {
+	return x11Desktops(), true
+}
+
+func (gtkBackend) Raise(sn *StickyNote) {
+	sn.WinMain.Present()
+}
+
+func (gtkBackend) FullscreenFocused() (bool, bool) {
+	return x11FullscreenFocused()
+}
+
+func (gtkBackend) BeginMove(sn *StickyNote, event *gdk.EventButton) {
+	sn.WinMain.BeginMoveDrag(event.Button(), int(event.XRoot()), int(event.YRoot()), event.Time())
+}
+
+func (gtkBackend) BeginResize(sn *StickyNote, event *gdk.EventButton) {
+	sn.WinMain.BeginResizeDrag(gdk.WINDOW_EDGE_SOUTH_EAST, event.Button(), int(event.XRoot()), int(event.YRoot()), event.Time())
+}
+
+// nullBackend is Wayland without a way to place windows: GTK's calls are made, the
+// compositor ignores them, and the positions can't be known
+type nullBackend struct{ gtkBackend }
+
+func (nullBackend) Name() string   { return "none (Wayland)" }
+func (nullBackend) CanPlace() bool { return false }
+
+func (nullBackend) Workspaces() (int, bool)         { return 0, false }
+func (nullBackend) FullscreenFocused() (bool, bool) { return false, false }
+
+func (nullBackend) Geometry(sn *StickyNote) ([2]int, [2]int, bool) {
+	w, h := sn.WinMain.GetSize()
+	return [2]int{}, [2]int{w, h}, false
+}
+
+// windowCallsBackend places windows through the window-calls methods, answered by
+// GNOME Shell or KWin (see kwin.go). Windows are known by the id matched from their
+// title once shown; until then, and when a call fails, GTK's calls are tried.
+type windowCallsBackend struct {
+	gtkBackend
+	name string
+}
+
+func (b windowCallsBackend) Name() string { return b.name }
+
+func (windowCallsBackend) Prepare(sn *StickyNote) {
+	startWindowTracking(sn.NoteSet)
+}
+
+func (windowCallsBackend) Restore(sn *StickyNote, pos [2]int, done func()) {
+	restore := func() {
+		sn.pendingRestore = nil
+		sn.assignWindowID()
+		// GTK's Resize() before showing isn't reliably applied to undecorated windows on
+		// Wayland, so the saved size is applied with the position, kept on the monitor
+		size := sn.LastKnownSize
+		pos, size := clampToArea(pos, size, workareaAt(pos[0]+size[0]/2, pos[1]+size[1]/2))
+		if details, ok := trackedWindow(sn.WindowID); ok {
+			sn.measureCompositorScale(details)
+		} else if sn.WindowID != 0 {
+			if details, err := sn.NoteSet.snapshotDetails(sn.WindowID); err == nil && details != nil {
+				sn.measureCompositorScale(*details)
+			}
+		}
+		at, atSize := sn.toCompositor(pos), sn.toCompositor(size)
+		if sn.WindowID == 0 || MoveResizeWindow(sn.WindowID, at[0], at[1], atSize[0], atSize[1]) != nil &&
+			MoveWindow(sn.WindowID, at[0], at[1]) != nil {
+			sn.WinMain.Move(pos[0], pos[1])
+		}
+		sn.LastKnownPos, sn.LastKnownSize = pos, size
+		sn.showPlaced() // Make window visible after moving
+		done()
+	}
+	delay := uint(300) // The window gets its id once mapped, and its actual size a little later
+	if tracker != nil {
+		// Restored when the window manager reports the window, or after a while without
+		sn.pendingRestore = restore
+		delay = 1000
+	}
+	sn.timeoutAdd(delay, func() bool {
+		if tracker == nil || sn.pendingRestore != nil {
+			restore()
+		}
+		return false // Don't repeat
+	})
+}
+
+func (windowCallsBackend) Move(sn *StickyNote, x, y int) {
+	at := sn.toCompositor([2]int{x, y})
+	if sn.WindowID == 0 || MoveWindow(sn.WindowID, at[0], at[1]) != nil {
+		sn.WinMain.Move(x, y)
+	}
+}
+
+func (windowCallsBackend) Geometry(sn *StickyNote) ([2]int, [2]int, bool) {
+	sn.assignWindowID()
+	if details, ok := trackedWindow(sn.WindowID); ok {
+		pos, size := sn.fromCompositor(details)
+		return pos, size, true
+	}
+	if sn.WindowID != 0 {
+		if details, err := GetWindowDetails(sn.WindowID); err == nil && details != nil {
+			pos, size := sn.fromCompositor(*details)
+			return pos, size, true
+		}
+	}
+	w, h := sn.WinMain.GetSize()
+	return [2]int{}, [2]int{w, h}, false
+}
+
+// The window states are applied once the window is matched, see Restore
+
+func (windowCallsBackend) SetPinned(sn *StickyNote, pinned bool) {
+	if sn.WindowID == 0 {
+		return
+	}
+	if err := SetWindowAbove(sn.WindowID, pinned); err != nil {
+		fmt.Printf("[Pin] Note %s: failed to set above: %v\n", sn.Note.UUID[:8], err)
+	}
+}
+
+func (windowCallsBackend) SetBelow(sn *StickyNote, below bool) {
+	if sn.WindowID == 0 {
+		return
+	}
+	if err := SetWindowBelow(sn.WindowID, below); err != nil {
+		fmt.Printf("[Widget] Note %s: failed to set below: %v\n", sn.Note.UUID[:8], err)
+	}
+}
+
+func (windowCallsBackend) SetSticky(sn *StickyNote, sticky bool) {
+	if sn.WindowID == 0 {
+		return
+	}
+	if err := SetWindowSticky(sn.WindowID, sticky); err != nil {
+		fmt.Printf("[Pin] Note %s: failed to set sticky: %v\n", sn.Note.UUID[:8], err)
+	}
+}
+
+func (windowCallsBackend) MoveToWorkspace(sn *StickyNote, workspace int) {
+	if sn.WindowID == 0 {
+		return
+	}
+	if err := MoveWindowToWorkspace(sn.WindowID, workspace); err != nil {
+		fmt.Printf("[Workspace] Note %s: failed to move to workspace %d: %v\n", sn.Note.UUID[:8], workspace+1, err)
+	}
+}
+
+func (windowCallsBackend) Workspaces() (int, bool) { return 0, true }
+
+func (windowCallsBackend) Raise(sn *StickyNote) {
+	if sn.WindowID != 0 && ActivateWindow(sn.WindowID) == nil {
+		return
+	}
+	sn.WinMain.Present()
+}
+
+func (windowCallsBackend) FullscreenFocused() (bool, bool) {
+	if KWinScripting() {
+		// Only the windows of this process are listed, the tracker script reports the rest
+		if tracker == nil {
+			return false, false
+		}
+		return tracker.activeFullscreen, tracker.activeKnown
+	}
+	return windowCallsFullscreenFocused()
+}
+
+// assignWindowID matches the note with its window by title, once it is shown
+func (sn *StickyNote) assignWindowID() {
+	if sn.WindowID != 0 || !IsWindowCallsAvailable() {
+		return
+	}
+	windows, err := sn.NoteSet.snapshotWindows(sn.Note.UUID)
+	if err != nil {
+		return
+	}
+	for _, win := range windows {
+		if owner := sn.NoteSet.windowIDs.Owner(win.ID); owner != nil && owner != sn {
+			continue
+		}
+		// List doesn't always have the full title, Details does
+		title := win.Title
+		if details, err := sn.NoteSet.snapshotDetails(win.ID); err == nil && details != nil {
+			title = details.Title
+		}
+		if matchesNoteWindowTitle(title, sn.Note.UUID) && sn.claimWindowID(win.ID) {
+			return
+		}
+	}
+}
+
+// layerShellBackend makes note windows layer surfaces, see layershell.go
+type layerShellBackend struct{ gtkBackend }
+
+func (layerShellBackend) Name() string { return "gtk-layer-shell" }
+
+func (layerShellBackend) Prepare(sn *StickyNote) {
+	sn.initLayerShell()
+}
+
+func (layerShellBackend) Restore(sn *StickyNote, pos [2]int, done func()) {
+	sn.idleAdd(func() bool {
+		sn.layerShellMove(pos[0], pos[1])
+		sn.showPlaced() // Make window visible after moving
+		done()
+		return false // Don't repeat
+	})
+}
+
+func (layerShellBackend) Move(sn *StickyNote, x, y int) {
+	sn.layerShellMove(x, y)
+}
+
+func (layerShellBackend) Geometry(sn *StickyNote) ([2]int, [2]int, bool) {
+	// Where its margins put it
+	w, h := sn.WinMain.GetSize()
+	return sn.LastKnownPos, [2]int{w, h}, true
+}
+
+func (layerShellBackend) SetPinned(sn *StickyNote, pinned bool) {
+	sn.applyLayer()
+}
+
+// Unpinned notes are on the bottom layer already, which task bars don't list
+func (layerShellBackend) SetBelow(sn *StickyNote, below bool) {}
+
+// Layer surfaces are on every workspace of their output
+func (layerShellBackend) SetSticky(sn *StickyNote, sticky bool)         {}
+func (layerShellBackend) MoveToWorkspace(sn *StickyNote, workspace int) {}
+func (layerShellBackend) Workspaces() (int, bool)                       { return 0, false }
+
+// Other applications' windows aren't known
+func (layerShellBackend) FullscreenFocused() (bool, bool) { return false, false }
+
+func (layerShellBackend) BeginMove(sn *StickyNote, event *gdk.EventButton) {
+	sn.beginLayerDrag(event, false)
+}
+
+func (layerShellBackend) BeginResize(sn *StickyNote, event *gdk.EventButton) {
+	sn.beginLayerDrag(event, true)
+}
@@ -0,0 +1,65 @@
+package stickynotes
+
+import "sync"
+
+// WindowIDRegistry records which note each window-calls window id is matched with, so
+// that two notes never take the same window. The zero value is empty and ready to use.
+type WindowIDRegistry struct {
+	mu     sync.Mutex
+	owners map[uint32]*StickyNote
+	ids    map[*StickyNote]uint32
+}
+
+// Claim gives the window id to the note, releasing the one it had. Returns false, and
+// changes nothing, when another note has the id.
+func (r *WindowIDRegistry) Claim(id uint32, sn *StickyNote) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if owner, ok := r.owners[id]; ok {
+		return owner == sn
+	}
+	if r.owners == nil {
+		r.owners = make(map[uint32]*StickyNote)
+		r.ids = make(map[*StickyNote]uint32)
+	}
+	if old, ok := r.ids[sn]; ok {
+		delete(r.owners, old)
+	}
+	r.owners[id] = sn
+	r.ids[sn] = id
+	return true
+}
+
+// Release frees the window id the note has, if any
+func (r *WindowIDRegistry) Release(sn *StickyNote) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.ids[sn]; ok {
+		delete(r.owners, id)
+		delete(r.ids, sn)
+	}
+}
+
+// Owner returns the note that has the window id, or nil
+func (r *WindowIDRegistry) Owner(id uint32) *StickyNote {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.owners[id]
+}
+
+// claimWindowID matches the note with the window, unless another note has it
+func (sn *StickyNote) claimWindowID(id uint32) bool {
+	if !sn.NoteSet.windowIDs.Claim(id, sn) {
+		return false
+	}
+	sn.WindowID = id
+	return true
+}
+
+// releaseWindowID forgets the note's window, which gets a new id when shown again
+func (sn *StickyNote) releaseWindowID() {
+	sn.NoteSet.windowIDs.Release(sn)
+	sn.WindowID = 0
+	// The next window may be on a monitor with another scale
+	sn.scale = 0
+}
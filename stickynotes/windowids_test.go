@@ -0,0 +1,79 @@
+package stickynotes
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWindowIDRegistryClaim(t *testing.T) {
+	var r WindowIDRegistry
+	a, b := &StickyNote{}, &StickyNote{}
+	if !r.Claim(1, a) {
+		t.Fatal("Claim of a free id failed")
+	}
+	if !r.Claim(1, a) {
+		t.Error("Claim of the note's own id failed")
+	}
+	if r.Claim(1, b) {
+		t.Error("Claim of another note's id succeeded")
+	}
+	// Claiming another id releases the first
+	if !r.Claim(2, a) || r.Owner(1) != nil || r.Owner(2) != a {
+		t.Errorf("after claiming id 2, owners are %v and %v, want none and a", r.Owner(1), r.Owner(2))
+	}
+	r.Release(a)
+	if r.Owner(2) != nil {
+		t.Error("id 2 still owned after Release")
+	}
+	if !r.Claim(2, b) {
+		t.Error("Claim of a released id failed")
+	}
+	r.Release(a) // Has nothing, changes nothing
+	if r.Owner(2) != b {
+		t.Error("Release of a note without an id freed another note's")
+	}
+}
+
+func TestWindowIDRegistryConcurrentClaims(t *testing.T) {
+	var r WindowIDRegistry
+	notes := make([]*StickyNote, 50)
+	for i := range notes {
+		notes[i] = &StickyNote{}
+	}
+	// Every note takes the first id it can: each id ends up with exactly one note
+	const ids = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	for _, sn := range notes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := uint32(1); id <= ids; id++ {
+				if r.Claim(id, sn) {
+					mu.Lock()
+					winners++
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if winners != ids {
+		t.Errorf("%d claims succeeded, want %d", winners, ids)
+	}
+	owners := make(map[*StickyNote]uint32)
+	for id := uint32(1); id <= ids; id++ {
+		owner := r.Owner(id)
+		if owner == nil {
+			t.Errorf("id %d is free, want it claimed", id)
+			continue
+		}
+		if other, ok := owners[owner]; ok {
+			t.Errorf("a note owns ids %d and %d", other, id)
+		}
+		owners[owner] = id
+	}
+}
@@ -0,0 +1,128 @@
+package stickynotes
+
+/*
+#cgo pkg-config: gtk+-3.0
+#include <gtk/gtk.h>
+#ifdef GDK_WINDOWING_X11
+#include <gdk/gdkx.h>
+#endif
+
+// EWMH desktops, only on X11 windows (GDK checks nothing and would crash on others)
+static int x11_move_to_desktop(GtkWidget *widget, int desktop) {
+#ifdef GDK_WINDOWING_X11
+	GdkWindow *window = gtk_widget_get_window(widget);
+	if (window && GDK_IS_X11_WINDOW(window)) {
+		gdk_x11_window_move_to_desktop(window, desktop);
+		return 1;
+	}
+#endif
+	return 0;
+}
+
+static int x11_number_of_desktops(void) {
+#ifdef GDK_WINDOWING_X11
+	GdkScreen *screen = gdk_screen_get_default();
+	if (screen && GDK_IS_X11_SCREEN(screen)) {
+		return gdk_x11_screen_get_number_of_desktops(screen);
+	}
+#endif
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// A note can be shown on all workspaces or kept on one, stored as "workspace" in its
+// properties: "all", or the workspace number from 0. Pinned notes ("Always on top") are
+// on all workspaces anyway. It is applied whenever the note is shown: through EWMH on
+// X11, through window-calls on Wayland (MoveToWorkspace and Stick).
+
+// Workspaces a note can be on besides a numbered one
+const (
+	WorkspaceDefault = -1 // Wherever the window manager puts it
+	WorkspaceAll     = -2 // On all workspaces
+)
+
+// workspaceMenuCount is how many numbered workspaces the menu offers when the window
+// manager doesn't tell how many there are
+const workspaceMenuCount = 4
+
+// Workspace returns the workspace the note is kept on, WorkspaceAll or WorkspaceDefault
+func (n *Note) Workspace() int {
+	switch ws := n.Properties["workspace"].(type) {
+	case string:
+		if ws == "all" {
+			return WorkspaceAll
+		}
+	case float64:
+		if ws >= 0 {
+			return int(ws)
+		}
+	}
+	return WorkspaceDefault
+}
+
+// SetWorkspace keeps the note on a workspace, shows it on all of them with WorkspaceAll,
+// or leaves it to the window manager with WorkspaceDefault
+func (sn *StickyNote) SetWorkspace(workspace int) {
+	switch {
+	case workspace == WorkspaceAll:
+		sn.Note.Properties["workspace"] = "all"
+	case workspace >= 0:
+		sn.Note.Properties["workspace"] = float64(workspace)
+	default:
+		delete(sn.Note.Properties, "workspace")
+	}
+	sn.applyPinned()
+	sn.NoteSet.Save()
+}
+
+// x11MoveToDesktop moves a window to an EWMH desktop, returns false when it isn't an X11
+// window
+func x11MoveToDesktop(win *gtk.Window, desktop int) bool {
+	return C.x11_move_to_desktop((*C.GtkWidget)(unsafe.Pointer(win.Native())), C.int(desktop)) != 0
+}
+
+// x11Desktops returns the number of EWMH desktops, 0 when not on X11
+func x11Desktops() int {
+	return int(C.x11_number_of_desktops())
+}
+
+// workspaceMenu builds the note's "Workspace" submenu
+func (sn *StickyNote) workspaceMenu() *gtk.Menu {
+	menu, _ := gtk.MenuNew()
+	current := sn.Note.Workspace()
+	count, ok := Backend().Workspaces()
+	if count == 0 {
+		count = workspaceMenuCount
+	}
+	count = max(count, current+1)
+
+	var group *glib.SList
+	add := func(label string, workspace int) {
+		item, _ := gtk.RadioMenuItemNewWithLabel(group, label)
+		group, _ = item.GetGroup()
+		item.SetActive(workspace == current)
+		item.SetSensitive(ok)
+		item.Connect("toggled", func() {
+			if item.GetActive() && workspace != sn.Note.Workspace() {
+				sn.SetWorkspace(workspace)
+			}
+		})
+		menu.Append(item)
+		item.Show()
+	}
+	add("Default", WorkspaceDefault)
+	add("All workspaces", WorkspaceAll)
+	for i := 0; i < count; i++ {
+		add(fmt.Sprintf("Workspace %d", i+1), i)
+	}
+	return menu
+}
@@ -0,0 +1,26 @@
+package stickynotes
+
+import "testing"
+
+func TestNoteWorkspace(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int
+	}{
+		{nil, WorkspaceDefault},
+		{"all", WorkspaceAll},
+		{float64(0), 0},
+		{float64(3), 3},
+		{float64(-1), WorkspaceDefault},
+		{"2", WorkspaceDefault},
+	}
+	for _, tt := range tests {
+		note := &Note{Properties: map[string]interface{}{}}
+		if tt.value != nil {
+			note.Properties["workspace"] = tt.value
+		}
+		if got := note.Workspace(); got != tt.want {
+			t.Errorf("Workspace() with %#v = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
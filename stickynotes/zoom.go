@@ -0,0 +1,101 @@
+package stickynotes
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// The note text uses the category font, adjusted by a per-note size delta in points
+// (the "font_delta" note property, changed with Ctrl+scroll). Notes saved with the
+// older "zoom" factor keep their size, it is turned into a delta on the next change.
+const (
+	minFontSize   = 6.0
+	maxFontSize   = 72.0
+	fontDeltaStep = 1.0
+)
+
+// categoryFont returns the note's category font, "Sans 12" when none is set
+func (sn *StickyNote) categoryFont() *pango.FontDescription {
+	fontName, _ := sn.Note.CatProp("font").(string)
+	if fontName == "" {
+		fontName = "Sans 12"
+	}
+	return pango.FontDescriptionFromString(fontName)
+}
+
+// categoryFontSize returns the size of the category font in points
+func (sn *StickyNote) categoryFontSize() float64 {
+	size := float64(sn.categoryFont().GetSize()) / float64(pango.PANGO_SCALE)
+	if size <= 0 {
+		return 12
+	}
+	return size
+}
+
+// FontDelta returns how many points the note text is larger (or smaller) than the
+// category font
+func (sn *StickyNote) FontDelta() float64 {
+	if delta, ok := sn.Note.Properties["font_delta"].(float64); ok {
+		return delta
+	}
+	if zoom, ok := sn.Note.Properties["zoom"].(float64); ok && zoom > 0 {
+		return math.Round(sn.categoryFontSize() * (zoom - 1))
+	}
+	return 0
+}
+
+// SetFontDelta sizes the note text relative to the category font, 0 being the category size
+func (sn *StickyNote) SetFontDelta(delta float64) {
+	base := sn.categoryFontSize()
+	delta = math.Round(math.Max(minFontSize-base, math.Min(maxFontSize-base, delta)))
+	if delta == sn.FontDelta() {
+		return
+	}
+	delete(sn.Note.Properties, "zoom")
+	if delta == 0 {
+		delete(sn.Note.Properties, "font_delta")
+	} else {
+		sn.Note.Properties["font_delta"] = delta
+	}
+	sn.UpdateFont()
+	sn.NoteSet.Save()
+}
+
+// onScroll zooms the text with Ctrl+mouse wheel
+func (sn *StickyNote) onScroll(tv *gtk.TextView, event *gdk.Event) bool {
+	scrollEvent := gdk.EventScrollNewFromEvent(event)
+	if scrollEvent.State()&gdk.CONTROL_MASK == 0 {
+		return false
+	}
+
+	switch scrollEvent.Direction() {
+	case gdk.SCROLL_UP:
+		sn.SetFontDelta(sn.FontDelta() + fontDeltaStep)
+	case gdk.SCROLL_DOWN:
+		sn.SetFontDelta(sn.FontDelta() - fontDeltaStep)
+	case gdk.SCROLL_SMOOTH:
+		if dy := scrollEvent.DeltaY(); dy < 0 {
+			sn.SetFontDelta(sn.FontDelta() + fontDeltaStep)
+		} else if dy > 0 {
+			sn.SetFontDelta(sn.FontDelta() - fontDeltaStep)
+		}
+	}
+	return true
+}
+
+// fontCSS renders the category font, with the note's size delta, as a CSS rule for the text view
+func (sn *StickyNote) fontCSS() string {
+	desc := sn.categoryFont()
+	size := math.Max(minFontSize, math.Min(maxFontSize, sn.categoryFontSize()+sn.FontDelta()))
+	style := "normal"
+	if desc.GetStyle() == pango.STYLE_ITALIC {
+		style = "italic"
+	}
+
+	return fmt.Sprintf("\n#txt-note\n{\n    font-family: \"%s\";\n    font-size: %.1fpt;\n    font-weight: %d;\n    font-style: %s;\n}\n",
+		desc.GetFamily(), size, int(desc.GetWeight()), style)
+}